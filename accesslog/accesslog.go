@@ -0,0 +1,46 @@
+// Package accesslog writes access logs of HTTP and TCP streams.
+//
+// Entries are rendered from Envoy-style format strings or JSON templates, compiled once
+// with ParseFormat or ParseJSONFormat, and resolved through the properties package and
+// the header maps of the stream. The supported commands are:
+//
+//	%REQ(X?Y):Z%, %RESP(X?Y):Z%, %TRAILER(X?Y):Z%  headers, HTTP only
+//	%START_TIME%, %DURATION%, %PROTOCOL%
+//	%RESPONSE_CODE%, %RESPONSE_FLAGS%, %RESPONSE_CODE_DETAILS%, %GRPC_STATUS_NUMBER%
+//	%BYTES_RECEIVED%, %BYTES_SENT%
+//	%UPSTREAM_HOST%, %UPSTREAM_CLUSTER%, %UPSTREAM_LOCAL_ADDRESS%, %UPSTREAM_TRANSPORT_FAILURE_REASON%
+//	%DOWNSTREAM_REMOTE_ADDRESS%, %DOWNSTREAM_LOCAL_ADDRESS%, %DOWNSTREAM_TLS_VERSION%
+//	%REQUESTED_SERVER_NAME%, %CONNECTION_ID%, %CONNECTION_TERMINATION_DETAILS%, %ROUTE_NAME%
+//
+// Their meaning follows the Envoy access log format, as described in:
+// https://www.envoyproxy.io/docs/envoy/latest/configuration/observability/access_log/usage#command-operators
+//
+// Rendered entries are written to a Sink: the host log with LogSink, a shared queue with
+// QueueSink, or a collector with HTTPSink, which batches entries into HTTP callouts.
+package accesslog
+
+import "github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm"
+
+// Logger renders entries with a Formatter and writes them to a Sink.
+type Logger struct {
+	Formatter *Formatter
+	Sink      Sink
+}
+
+// LogHttp writes the entry of the current HTTP stream.
+// It must be called from types.HttpContext.OnHttpStreamDone.
+func (l *Logger) LogHttp() {
+	l.write(l.Formatter.FormatHttp())
+}
+
+// LogTcp writes the entry of the current TCP stream.
+// It must be called from types.TcpContext.OnStreamDone.
+func (l *Logger) LogTcp() {
+	l.write(l.Formatter.FormatTcp())
+}
+
+func (l *Logger) write(entry []byte) {
+	if err := l.Sink.Write(entry); err != nil {
+		proxywasm.LogErrorf("accesslog: failed to write entry: %v", err)
+	}
+}
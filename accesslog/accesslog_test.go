@@ -0,0 +1,177 @@
+package accesslog
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm"
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/proxytest"
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/types"
+	"github.com/stretchr/testify/require"
+)
+
+type testVMContext struct {
+	types.DefaultVMContext
+	logger *Logger
+	tcp    bool
+}
+
+func (v *testVMContext) NewPluginContext(uint32) types.PluginContext {
+	return &testPluginContext{logger: v.logger, tcp: v.tcp}
+}
+
+// testPluginContext creates either HTTP or TCP contexts, as the SDK prefers HTTP
+// contexts when a plugin creates both.
+type testPluginContext struct {
+	types.DefaultPluginContext
+	logger *Logger
+	tcp    bool
+}
+
+func (p *testPluginContext) OnPluginStart(int) types.OnPluginStartStatus {
+	if _, err := proxywasm.RegisterSharedQueue("access-logs"); err != nil {
+		return types.OnPluginStartStatusFailed
+	}
+	return types.OnPluginStartStatusOK
+}
+
+func (p *testPluginContext) OnTick() {
+	if sink, ok := p.logger.Sink.(*HTTPSink); ok {
+		sink.OnTick()
+	}
+}
+
+func (p *testPluginContext) NewHttpContext(uint32) types.HttpContext {
+	if p.tcp {
+		return nil
+	}
+	return &testHttpContext{logger: p.logger}
+}
+
+func (p *testPluginContext) NewTcpContext(uint32) types.TcpContext {
+	if !p.tcp {
+		return nil
+	}
+	return &testTcpContext{logger: p.logger}
+}
+
+type testHttpContext struct {
+	types.DefaultHttpContext
+	logger *Logger
+}
+
+func (h *testHttpContext) OnHttpStreamDone() { h.logger.LogHttp() }
+
+type testTcpContext struct {
+	types.DefaultTcpContext
+	logger *Logger
+}
+
+func (c *testTcpContext) OnStreamDone() { c.logger.LogTcp() }
+
+func uint64Property(v uint64) []byte {
+	return binary.LittleEndian.AppendUint64(nil, v)
+}
+
+func newTestHost(t *testing.T, vm *testVMContext) proxytest.HostEmulator {
+	opt := proxytest.NewEmulatorOption().
+		WithVMContext(vm).
+		WithProperty([]string{"request", "time"}, uint64Property(uint64(time.Date(2024, 1, 2, 3, 4, 5, 6e6, time.UTC).UnixNano()))).
+		WithProperty([]string{"request", "duration"}, uint64Property(uint64(42*time.Millisecond+500*time.Microsecond))).
+		WithProperty([]string{"request", "protocol"}, []byte("HTTP/1.1")).
+		WithProperty([]string{"response", "code"}, uint64Property(200)).
+		WithProperty([]string{"upstream", "address"}, []byte("10.0.0.1:8080")).
+		WithProperty([]string{"source", "address"}, []byte("192.168.0.1:50000"))
+	host, reset := proxytest.NewHostEmulator(opt)
+	t.Cleanup(reset)
+	require.Equal(t, types.OnPluginStartStatusOK, host.StartPlugin())
+	return host
+}
+
+func runHttpStream(host proxytest.HostEmulator) {
+	id := host.InitializeHttpContext()
+	host.CallOnRequestHeaders(id, [][2]string{{":method", "GET"}, {":path", "/users?page=2"}, {"user-agent", "curl"}}, true)
+	host.CallOnResponseHeaders(id, [][2]string{{":status", "200"}, {"content-type", "application/json"}}, false)
+	host.CallOnResponseTrailers(id, [][2]string{{"grpc-status", "0"}})
+	host.CompleteHttpContext(id)
+}
+
+func TestLogger_text(t *testing.T) {
+	f, err := ParseFormat(`[%START_TIME%] "%REQ(:METHOD)% %REQ(X-ORIGINAL-PATH?:PATH):6% %PROTOCOL%" ` +
+		`%RESPONSE_CODE% %RESPONSE_FLAGS% %DURATION% "%RESP(CONTENT-TYPE)%" %TRAILER(GRPC-STATUS)% "%REQ(REFERER)%" %UPSTREAM_HOST%`)
+	require.NoError(t, err)
+	host := newTestHost(t, &testVMContext{logger: &Logger{Formatter: f, Sink: LogSink{}}})
+
+	runHttpStream(host)
+	require.Equal(t, []string{
+		`[2024-01-02T03:04:05.006Z] "GET /users HTTP/1.1" 200 - 42 "application/json" 0 "-" 10.0.0.1:8080`,
+	}, host.GetInfoLogs())
+}
+
+func TestLogger_json(t *testing.T) {
+	f, err := ParseJSONFormat([]byte(`{
+		"method": "%REQ(:METHOD)%",
+		"path": "%REQ(:PATH)%",
+		"status": "%RESPONSE_CODE%",
+		"referer": "%REQ(REFERER)%",
+		"summary": "%REQ(:METHOD)% %RESPONSE_CODE_DETAILS%",
+		"upstream": {"host": "%UPSTREAM_HOST%", "cluster": "%UPSTREAM_CLUSTER%"}
+	}`))
+	require.NoError(t, err)
+	queue := &QueueSink{Name: "access-logs"}
+	host := newTestHost(t, &testVMContext{logger: &Logger{Formatter: f, Sink: queue}})
+
+	runHttpStream(host)
+	require.Equal(t, 1, host.GetQueueSize(queue.queueID))
+	entry, err := proxywasm.DequeueSharedQueue(queue.queueID)
+	require.NoError(t, err)
+	require.Equal(t, `{"method":"GET","path":"/users?page=2","status":200,"referer":null,"summary":"GET -",`+
+		`"upstream":{"host":"10.0.0.1:8080","cluster":null}}`, string(entry))
+
+	// Entries are not written when the queue does not exist.
+	queue.Name, queue.resolved = "unknown", false
+	runHttpStream(host)
+	require.Len(t, host.GetErrorLogs(), 1)
+}
+
+func TestLogger_tcp(t *testing.T) {
+	f, err := ParseFormat(`%DOWNSTREAM_REMOTE_ADDRESS% -> %UPSTREAM_HOST% %REQ(:PATH)% %BYTES_SENT% %RESPONSE_FLAGS%`)
+	require.NoError(t, err)
+	host := newTestHost(t, &testVMContext{logger: &Logger{Formatter: f, Sink: LogSink{}}, tcp: true})
+	require.NoError(t, host.SetProperty([]string{"response", "flags"}, uint64Property(0x8020)))
+
+	id, _ := host.InitializeConnection()
+	host.CompleteConnection(id)
+	require.Equal(t, []string{"192.168.0.1:50000 -> 10.0.0.1:8080 - - UF,URX"}, host.GetInfoLogs())
+}
+
+func TestHTTPSink(t *testing.T) {
+	f, err := ParseFormat(`%REQ(:PATH)% %RESPONSE_CODE%`)
+	require.NoError(t, err)
+	sink := &HTTPSink{Cluster: "collector", Authority: "logs", Path: "/ingest", MaxBatchSize: 2, MaxQueueSize: 3}
+	host := newTestHost(t, &testVMContext{logger: &Logger{Formatter: f, Sink: sink}})
+
+	for i := 0; i < 4; i++ {
+		runHttpStream(host)
+	}
+	host.Tick()
+	require.Equal(t, []string{"accesslog: 1 entries dropped since the queue is full"}, host.GetWarnLogs())
+	callouts := host.GetCalloutAttributesFromContext(proxytest.PluginContextID)
+	require.Len(t, callouts, 1)
+	require.Equal(t, "collector", callouts[0].Upstream)
+	require.Contains(t, callouts[0].Headers, [2]string{":path", "/ingest"})
+	require.Contains(t, callouts[0].Headers, [2]string{"content-type", "application/x-ndjson"})
+	require.Equal(t, "/users?page=2 200\n/users?page=2 200\n", string(callouts[0].Body))
+
+	// A single export is in flight at a time.
+	host.Tick()
+	require.Len(t, host.GetCalloutAttributesFromContext(proxytest.PluginContextID), 1)
+
+	host.CallOnHttpCallResponse(callouts[0].CalloutID, [][2]string{{":status", "503"}}, nil, nil)
+	require.Len(t, host.GetWarnLogs(), 2)
+	host.Tick()
+	callouts = host.GetCalloutAttributesFromContext(proxytest.PluginContextID)
+	require.Len(t, callouts, 2)
+	require.Equal(t, "/users?page=2 200\n", string(callouts[1].Body))
+}
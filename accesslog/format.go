@@ -0,0 +1,367 @@
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/properties"
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm"
+)
+
+// missing is rendered in text formats for values that are not available.
+const missing = "-"
+
+// startTimeLayout is the layout of START_TIME, the same as the default of Envoy.
+const startTimeLayout = "2006-01-02T15:04:05.000Z"
+
+// Formatter renders access log entries from a text or JSON format.
+// Formatters are safe to reuse across streams and are usually created once per plugin.
+type Formatter struct {
+	text   []segment
+	json   bool
+	fields []jsonField
+}
+
+// segment is either a literal or a command of a format string.
+type segment struct {
+	literal string
+	command *command
+}
+
+// jsonField is a key of a JSON template with either a format string or nested fields.
+type jsonField struct {
+	key    string
+	text   []segment
+	object bool
+	fields []jsonField
+}
+
+// command is a compiled %COMMAND(args):maxlen% operator.
+type command struct {
+	name    string
+	args    []string
+	maxLen  int
+	resolve func(c *command, http bool) (value, bool)
+}
+
+// value is a resolved command. Numeric values are rendered as numbers in JSON formats.
+type value struct {
+	str     string
+	num     uint64
+	numeric bool
+}
+
+func (v value) String() string {
+	if v.numeric {
+		return strconv.FormatUint(v.num, 10)
+	}
+	return v.str
+}
+
+// ParseFormat compiles an Envoy-style text format such as
+//
+//	[%START_TIME%] "%REQ(:METHOD)% %REQ(X-ENVOY-ORIGINAL-PATH?:PATH)% %PROTOCOL%" %RESPONSE_CODE%
+//
+// Commands are written as %COMMAND%, %COMMAND(args)% or %COMMAND(args):maxlen% where
+// maxlen truncates the value. REQ, RESP and TRAILER take a header name, optionally
+// followed by "?" and a fallback header name. "%%" is a literal "%". Values that are
+// not available are rendered as "-". The supported commands are listed in the package
+// documentation.
+func ParseFormat(format string) (*Formatter, error) {
+	text, err := parseText(format)
+	if err != nil {
+		return nil, fmt.Errorf("accesslog: invalid format: %w", err)
+	}
+	return &Formatter{text: text}, nil
+}
+
+// ParseJSONFormat compiles a JSON template whose values are format strings as
+// accepted by ParseFormat, or nested templates. For example:
+//
+//	{"method": "%REQ(:METHOD)%", "status": "%RESPONSE_CODE%", "upstream": {"host": "%UPSTREAM_HOST%"}}
+//
+// Each entry is a single-line JSON object with the keys of the template in order.
+// Values made of a single command keep their type, so numeric commands are rendered
+// as numbers and values that are not available as null.
+func ParseJSONFormat(template []byte) (*Formatter, error) {
+	dec := json.NewDecoder(bytes.NewReader(template))
+	fields, err := parseJSONObject(dec)
+	if err != nil {
+		return nil, fmt.Errorf("accesslog: invalid JSON format: %w", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("accesslog: invalid JSON format: trailing data")
+	}
+	return &Formatter{json: true, fields: fields}, nil
+}
+
+func parseJSONObject(dec *json.Decoder) ([]jsonField, error) {
+	if t, err := dec.Token(); err != nil {
+		return nil, err
+	} else if t != json.Delim('{') {
+		return nil, fmt.Errorf("expected an object")
+	}
+	var fields []jsonField
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		f := jsonField{key: t.(string)}
+		if !dec.More() {
+			return nil, fmt.Errorf("missing value of %q", f.key)
+		}
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, err
+		}
+		var format string
+		if err := json.Unmarshal(raw, &format); err == nil {
+			if f.text, err = parseText(format); err != nil {
+				return nil, err
+			}
+		} else if f.fields, err = parseJSONObject(json.NewDecoder(bytes.NewReader(raw))); err != nil {
+			return nil, fmt.Errorf("value of %q must be a string or an object", f.key)
+		} else {
+			f.object = true
+		}
+		fields = append(fields, f)
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+func parseText(format string) ([]segment, error) {
+	var segments []segment
+	var literal strings.Builder
+	for i := 0; i < len(format); {
+		c := format[i]
+		if c != '%' {
+			literal.WriteByte(c)
+			i++
+			continue
+		}
+		if strings.HasPrefix(format[i:], "%%") {
+			literal.WriteByte('%')
+			i += 2
+			continue
+		}
+		end := strings.IndexByte(format[i+1:], '%')
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated command at offset %d in %q", i, format)
+		}
+		cmd, err := parseCommand(format[i+1 : i+1+end])
+		if err != nil {
+			return nil, err
+		}
+		if literal.Len() > 0 {
+			segments = append(segments, segment{literal: literal.String()})
+			literal.Reset()
+		}
+		segments = append(segments, segment{command: cmd})
+		i += end + 2
+	}
+	if literal.Len() > 0 {
+		segments = append(segments, segment{literal: literal.String()})
+	}
+	return segments, nil
+}
+
+// parseCommand parses COMMAND, COMMAND(args) or COMMAND(args):maxlen.
+func parseCommand(s string) (*command, error) {
+	c := &command{name: s}
+	var args string
+	hasArgs := false
+	if open := strings.IndexByte(s, '('); open >= 0 {
+		closing := strings.LastIndexByte(s, ')')
+		if closing < open {
+			return nil, fmt.Errorf("unbalanced parenthesis in %%%s%%", s)
+		}
+		c.name, args, hasArgs = s[:open], s[open+1:closing], true
+		if rest := s[closing+1:]; rest != "" {
+			if rest[0] != ':' {
+				return nil, fmt.Errorf("unexpected %q after %s", rest, c.name)
+			}
+			n, err := strconv.Atoi(rest[1:])
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid max length %q of %s", rest[1:], c.name)
+			}
+			c.maxLen = n
+		}
+	}
+
+	if header, ok := headerCommands[c.name]; ok {
+		if !hasArgs || args == "" {
+			return nil, fmt.Errorf("%s requires a header name", c.name)
+		}
+		names := strings.Split(strings.ToLower(args), "?")
+		if len(names) > 2 || names[0] == "" || (len(names) == 2 && names[1] == "") {
+			return nil, fmt.Errorf("invalid header names %q of %s", args, c.name)
+		}
+		c.args = names
+		c.resolve = header
+		return c, nil
+	}
+	resolve, ok := commands[c.name]
+	if !ok {
+		return nil, fmt.Errorf("unknown command %s", c.name)
+	}
+	if hasArgs {
+		return nil, fmt.Errorf("%s does not take arguments", c.name)
+	}
+	c.resolve = func(_ *command, http bool) (value, bool) { return resolve(http) }
+	return c, nil
+}
+
+// FormatHttp renders the entry of the current HTTP stream.
+// Only available during types.HttpContext.OnHttpStreamDone.
+func (f *Formatter) FormatHttp() []byte {
+	return f.format(true)
+}
+
+// FormatTcp renders the entry of the current TCP stream. REQ, RESP and TRAILER are not
+// available for TCP streams. Only available during types.TcpContext.OnStreamDone.
+func (f *Formatter) FormatTcp() []byte {
+	return f.format(false)
+}
+
+func (f *Formatter) format(http bool) []byte {
+	if f.json {
+		return appendJSONObject(nil, f.fields, http)
+	}
+	var buf []byte
+	for _, s := range f.text {
+		if s.command == nil {
+			buf = append(buf, s.literal...)
+		} else if v, ok := s.command.evaluate(http); ok {
+			buf = append(buf, v.String()...)
+		} else {
+			buf = append(buf, missing...)
+		}
+	}
+	return buf
+}
+
+func appendJSONObject(buf []byte, fields []jsonField, http bool) []byte {
+	buf = append(buf, '{')
+	for i, f := range fields {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = appendJSONString(buf, f.key)
+		buf = append(buf, ':')
+		switch {
+		case f.object:
+			buf = appendJSONObject(buf, f.fields, http)
+		case len(f.text) == 1 && f.text[0].command != nil:
+			v, ok := f.text[0].command.evaluate(http)
+			if !ok {
+				buf = append(buf, "null"...)
+			} else if v.numeric {
+				buf = strconv.AppendUint(buf, v.num, 10)
+			} else {
+				buf = appendJSONString(buf, v.str)
+			}
+		default:
+			buf = appendJSONString(buf, string((&Formatter{text: f.text}).format(http)))
+		}
+	}
+	return append(buf, '}')
+}
+
+func appendJSONString(buf []byte, s string) []byte {
+	b, _ := json.Marshal(s)
+	return append(buf, b...)
+}
+
+func (c *command) evaluate(http bool) (value, bool) {
+	v, ok := c.resolve(c, http)
+	if !ok || v.numeric || c.maxLen == 0 || len(v.str) <= c.maxLen {
+		return v, ok
+	}
+	s := v.str[:c.maxLen]
+	for len(s) > 0 && !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	v.str = s
+	return v, true
+}
+
+var headerCommands = map[string]func(c *command, http bool) (value, bool){
+	"REQ":     headerResolver(proxywasm.GetHttpRequestHeader),
+	"RESP":    headerResolver(proxywasm.GetHttpResponseHeader),
+	"TRAILER": headerResolver(proxywasm.GetHttpResponseTrailer),
+}
+
+func headerResolver(get func(string) (string, error)) func(c *command, http bool) (value, bool) {
+	return func(c *command, http bool) (value, bool) {
+		if !http {
+			return value{}, false
+		}
+		for _, name := range c.args {
+			if v, err := get(name); err == nil {
+				return value{str: v}, true
+			}
+		}
+		return value{}, false
+	}
+}
+
+// commands are the commands without arguments. Each resolver reports whether the value
+// is available for the stream, where http is false for TCP streams.
+var commands = map[string]func(http bool) (value, bool){
+	"START_TIME": func(bool) (value, bool) {
+		t, err := properties.GetRequestTime()
+		return value{str: t.UTC().Format(startTimeLayout)}, err == nil
+	},
+	"DURATION": func(bool) (value, bool) {
+		d, err := properties.GetRequestDuration()
+		return value{num: uint64(time.Duration(d).Milliseconds()), numeric: true}, err == nil
+	},
+	// RESPONSE_FLAGS is rendered with the short codes of the flags, e.g. "UF,URX".
+	"RESPONSE_FLAGS": func(bool) (value, bool) {
+		flags, err := properties.GetResponseFlags()
+		return value{str: properties.ResponseFlags(flags).String()}, err == nil
+	},
+	"PROTOCOL":              stringProperty(properties.GetRequestProtocol),
+	"RESPONSE_CODE":         uintProperty(properties.GetResponseCode),
+	"RESPONSE_CODE_DETAILS": stringProperty(properties.GetResponseCodeDetails),
+	"GRPC_STATUS_NUMBER":    uintProperty(properties.GetResponseGrpcStatusCode),
+	"BYTES_RECEIVED":        uintProperty(properties.GetRequestSize),
+	"BYTES_SENT":            uintProperty(properties.GetResponseSize),
+
+	"UPSTREAM_HOST":                     stringProperty(properties.GetUpstreamAddress),
+	"UPSTREAM_CLUSTER":                  stringProperty(properties.GetClusterName),
+	"UPSTREAM_LOCAL_ADDRESS":            stringProperty(properties.GetUpstreamLocalAddress),
+	"UPSTREAM_TRANSPORT_FAILURE_REASON": stringProperty(properties.GetUpstreamTransportFailureReason),
+
+	"DOWNSTREAM_REMOTE_ADDRESS":      stringProperty(properties.GetDownstreamRemoteAddress),
+	"DOWNSTREAM_LOCAL_ADDRESS":       stringProperty(properties.GetDownstreamLocalAddress),
+	"DOWNSTREAM_TLS_VERSION":         stringProperty(properties.GetDownstreamTlsVersion),
+	"REQUESTED_SERVER_NAME":          stringProperty(properties.GetDownstreamRequestedServerName),
+	"CONNECTION_ID":                  uintProperty(properties.GetDownstreamConnectionID),
+	"CONNECTION_TERMINATION_DETAILS": stringProperty(properties.GetDownstreamTerminationDetails),
+	"ROUTE_NAME":                     stringProperty(properties.GetRouteName),
+}
+
+func stringProperty(get func() (string, error)) func(bool) (value, bool) {
+	return func(bool) (value, bool) {
+		s, err := get()
+		return value{str: s}, err == nil && s != ""
+	}
+}
+
+func uintProperty(get func() (uint64, error)) func(bool) (value, bool) {
+	return func(bool) (value, bool) {
+		n, err := get()
+		return value{num: n, numeric: true}, err == nil
+	}
+}
@@ -0,0 +1,56 @@
+package accesslog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFormat(t *testing.T) {
+	f, err := ParseFormat(`%REQ(X-Original-Path?:PATH):10% 100%% %RESPONSE_CODE%`)
+	require.NoError(t, err)
+	require.Len(t, f.text, 3)
+	require.Equal(t, []string{"x-original-path", ":path"}, f.text[0].command.args)
+	require.Equal(t, 10, f.text[0].command.maxLen)
+	require.Equal(t, " 100% ", f.text[1].literal)
+	require.Equal(t, "RESPONSE_CODE", f.text[2].command.name)
+
+	for _, format := range []string{
+		"%RESPONSE_CODE",
+		"%UNKNOWN%",
+		"%REQ%",
+		"%REQ()%",
+		"%REQ(a?b?c)%",
+		"%REQ(a?)%",
+		"%REQ(:path):0%",
+		"%REQ(:path)x%",
+		"%REQ:path)%",
+		"%RESPONSE_CODE(x)%",
+	} {
+		_, err := ParseFormat(format)
+		require.Error(t, err, format)
+	}
+}
+
+func TestParseJSONFormat(t *testing.T) {
+	f, err := ParseJSONFormat([]byte(`{"b": "%PROTOCOL%", "a": {"c": "x %PROTOCOL%"}, "e": {}}`))
+	require.NoError(t, err)
+	require.Len(t, f.fields, 3)
+	require.Equal(t, "b", f.fields[0].key)
+	require.Equal(t, "a", f.fields[1].key)
+	require.True(t, f.fields[1].object)
+	require.Equal(t, "c", f.fields[1].fields[0].key)
+	require.True(t, f.fields[2].object)
+
+	for _, template := range []string{
+		``,
+		`[]`,
+		`{"a": 1}`,
+		`{"a": "%UNKNOWN%"}`,
+		`{"a": {"b": true}}`,
+		`{"a": "b"} {}`,
+	} {
+		_, err := ParseJSONFormat([]byte(template))
+		require.Error(t, err, template)
+	}
+}
@@ -0,0 +1,128 @@
+package accesslog
+
+import (
+	"time"
+
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/internal/export"
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm"
+)
+
+const (
+	defaultMaxBatchSize  = 512
+	defaultMaxQueueSize  = 4096
+	defaultExportTimeout = 5 * time.Second
+)
+
+// Sink receives rendered access log entries.
+type Sink interface {
+	Write(entry []byte) error
+}
+
+// LogSink writes entries to the host log with proxywasm.LogInfo.
+type LogSink struct{}
+
+// Write implements Sink.
+func (LogSink) Write(entry []byte) error {
+	proxywasm.LogInfo(string(entry))
+	return nil
+}
+
+// QueueSink enqueues entries on a shared queue, usually registered and drained by a
+// singleton plugin with proxywasm.RegisterSharedQueue and types.PluginContext.OnQueueReady.
+// The queue is resolved on the first write, so it may be registered after the sink is created.
+type QueueSink struct {
+	// VMID is the vm_id of the VM that registered the queue.
+	VMID string
+	// Name is the name of the queue.
+	Name string
+
+	queueID  uint32
+	resolved bool
+}
+
+// Write implements Sink.
+func (s *QueueSink) Write(entry []byte) error {
+	if !s.resolved {
+		id, err := proxywasm.ResolveSharedQueue(s.VMID, s.Name)
+		if err != nil {
+			return err
+		}
+		s.queueID, s.resolved = id, true
+	}
+	return proxywasm.EnqueueSharedQueue(s.queueID, entry)
+}
+
+// HTTPSink buffers entries and posts them in batches, one entry per line, with
+// proxywasm.DispatchHttpCall from HTTPSink.OnTick. It must be shared by the plugin
+// context and its streams, with OnTick called from types.PluginContext.OnTick.
+type HTTPSink struct {
+	// Cluster is the name of the cluster of the collector.
+	Cluster string
+	// Authority is the ":authority" header of export requests.
+	Authority string
+	// Path is the ":path" header of export requests. Defaults to "/".
+	Path string
+	// ContentType is the "content-type" header of export requests.
+	// Defaults to "application/x-ndjson".
+	ContentType string
+	// MaxBatchSize is the maximum number of entries per export request. Defaults to 512.
+	MaxBatchSize int
+	// MaxQueueSize is the maximum number of buffered entries. Entries written when the
+	// queue is full are dropped. Defaults to 4096.
+	MaxQueueSize int
+	// Timeout of export requests. Defaults to 5 seconds.
+	Timeout time.Duration
+
+	queue export.Queue[[]byte]
+}
+
+// Write implements Sink.
+func (s *HTTPSink) Write(entry []byte) error {
+	s.queue.Add(entry, s.maxQueueSize())
+	return nil
+}
+
+// OnTick exports the buffered entries. It must be called from types.PluginContext.OnTick.
+func (s *HTTPSink) OnTick() {
+	s.queue.Flush("accesslog", "entries", s.maxBatchSize(), s.request)
+}
+
+func (s *HTTPSink) request(batch [][]byte) (*export.Request, error) {
+	var body []byte
+	for _, entry := range batch {
+		body = append(body, entry...)
+		body = append(body, '\n')
+	}
+
+	path, contentType, timeout := s.Path, s.ContentType, s.Timeout
+	if path == "" {
+		path = "/"
+	}
+	if contentType == "" {
+		contentType = "application/x-ndjson"
+	}
+	if timeout == 0 {
+		timeout = defaultExportTimeout
+	}
+	headers := [][2]string{
+		{":method", "POST"},
+		{":path", path},
+		{":authority", s.Authority},
+		{"content-type", contentType},
+	}
+	return &export.Request{Cluster: s.Cluster, Headers: headers, Body: body, Timeout: timeout}, nil
+}
+
+func (s *HTTPSink) maxBatchSize() int {
+	if s.MaxBatchSize == 0 {
+		return defaultMaxBatchSize
+	}
+	return s.MaxBatchSize
+}
+
+func (s *HTTPSink) maxQueueSize() int {
+	if s.MaxQueueSize == 0 {
+		return defaultMaxQueueSize
+	}
+	return s.MaxQueueSize
+}
@@ -0,0 +1,185 @@
+package cors
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Config holds the CORS policies of a plugin, usually parsed from the plugin
+// configuration with ParseConfig:
+//
+//	{
+//	  "routes": [
+//	    {
+//	      "route_name": "api",
+//	      "path_prefix": "/api/",
+//	      "policy": {
+//	        "allow_origins": [{"exact": "https://app.example.com"}, {"suffix": ".example.com"}, {"regex": "^https://.*\\.dev$"}],
+//	        "allow_methods": ["GET", "POST"],
+//	        "allow_headers": ["authorization", "content-type"],
+//	        "expose_headers": ["x-request-id"],
+//	        "max_age": 600,
+//	        "allow_credentials": true
+//	      }
+//	    }
+//	  ],
+//	  "default": {"allow_origins": [{"exact": "*"}]}
+//	}
+type Config struct {
+	// Routes are matched in order; the first matching route selects the policy.
+	Routes []Route `json:"routes"`
+	// Default is the policy of requests not matching any route. Nil disables CORS for them.
+	Default *Policy `json:"default"`
+}
+
+// Route selects the policy of the requests it matches.
+type Route struct {
+	// RouteName, if set, must equal the "route_name" property of the request.
+	RouteName string `json:"route_name"`
+	// PathPrefix, if set, must be a prefix of the ":path" of the request.
+	PathPrefix string `json:"path_prefix"`
+	// Policy applies to matching requests. Nil disables CORS for them.
+	Policy *Policy `json:"policy"`
+}
+
+// Policy describes the cross-origin requests allowed for a route.
+type Policy struct {
+	AllowOrigins     []OriginMatcher `json:"allow_origins"`
+	AllowMethods     []string        `json:"allow_methods"`
+	AllowHeaders     []string        `json:"allow_headers"`
+	ExposeHeaders    []string        `json:"expose_headers"`
+	MaxAge           Seconds         `json:"max_age"`
+	AllowCredentials bool            `json:"allow_credentials"`
+}
+
+// OriginMatcher matches the "origin" request header. Exactly one field must be set.
+// The exact value "*" matches any origin, and the regex must match the whole origin.
+type OriginMatcher struct {
+	Exact  string `json:"exact,omitempty"`
+	Suffix string `json:"suffix,omitempty"`
+	Regex  string `json:"regex,omitempty"`
+
+	regex *regexp.Regexp
+}
+
+// Seconds is a duration encoded in JSON as a number of seconds.
+type Seconds time.Duration
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (s *Seconds) UnmarshalJSON(b []byte) error {
+	var v uint32
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*s = Seconds(time.Duration(v) * time.Second)
+	return nil
+}
+
+// ParseConfig decodes and validates a JSON configuration.
+func ParseConfig(data []byte) (*Config, error) {
+	c := &Config{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("cors: invalid configuration: %w", err)
+	}
+	if err := c.Compile(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Compile validates the configuration and compiles the regexes. It must be called
+// on configurations built without ParseConfig.
+func (c *Config) Compile() error {
+	policies := []*Policy{c.Default}
+	for _, r := range c.Routes {
+		policies = append(policies, r.Policy)
+	}
+	for _, p := range policies {
+		if p == nil {
+			continue
+		}
+		for i := range p.AllowOrigins {
+			if err := p.AllowOrigins[i].compile(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (m *OriginMatcher) compile() error {
+	set := 0
+	for _, v := range []string{m.Exact, m.Suffix, m.Regex} {
+		if v != "" {
+			set++
+		}
+	}
+	if set != 1 {
+		return fmt.Errorf("cors: exactly one of exact, suffix or regex must be set in an origin matcher")
+	}
+	if m.Regex != "" {
+		re, err := regexp.Compile(`^(?:` + m.Regex + `)$`)
+		if err != nil {
+			return fmt.Errorf("cors: invalid origin regex %q: %w", m.Regex, err)
+		}
+		m.regex = re
+	}
+	return nil
+}
+
+func (m *OriginMatcher) match(origin string) bool {
+	switch {
+	case m.Exact != "":
+		return m.Exact == "*" || m.Exact == origin
+	case m.Suffix != "":
+		return strings.HasSuffix(origin, m.Suffix)
+	case m.regex != nil:
+		return m.regex.MatchString(origin)
+	}
+	return false
+}
+
+// policyFor returns the policy of the request with the given route name and path.
+func (c *Config) policyFor(routeName, path string) *Policy {
+	for _, r := range c.Routes {
+		if r.RouteName != "" && r.RouteName != routeName {
+			continue
+		}
+		if r.PathPrefix != "" && !strings.HasPrefix(path, r.PathPrefix) {
+			continue
+		}
+		return r.Policy
+	}
+	return c.Default
+}
+
+// allowOrigin returns the value of "access-control-allow-origin" for the given
+// origin, or false if the origin is not allowed.
+func (p *Policy) allowOrigin(origin string) (string, bool) {
+	for i := range p.AllowOrigins {
+		m := &p.AllowOrigins[i]
+		if !m.match(origin) {
+			continue
+		}
+		// The wildcard cannot be used with credentialed requests, the origin is echoed instead.
+		if m.Exact == "*" && !p.AllowCredentials {
+			return "*", true
+		}
+		return origin, true
+	}
+	return "", false
+}
+
+// variesByOrigin reports whether responses depend on the "origin" request header,
+// in which case caches must be told so with "vary: origin".
+func (p *Policy) variesByOrigin() bool {
+	for _, m := range p.AllowOrigins {
+		if m.Exact != "*" || p.AllowCredentials {
+			return true
+		}
+	}
+	return false
+}
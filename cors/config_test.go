@@ -0,0 +1,89 @@
+package cors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConfig(t *testing.T) {
+	c, err := ParseConfig([]byte(`{
+		"routes": [
+			{"route_name": "api", "policy": {"allow_origins": [{"suffix": ".example.com"}], "max_age": 600}},
+			{"path_prefix": "/public/", "policy": {"allow_origins": [{"exact": "*"}]}},
+			{"path_prefix": "/internal/"}
+		],
+		"default": {"allow_origins": [{"regex": "^https://[a-z]+\\.dev$"}]}
+	}`))
+	require.NoError(t, err)
+
+	api := c.policyFor("api", "/anything")
+	require.Equal(t, Seconds(600*time.Second), api.MaxAge)
+	require.Same(t, c.Routes[1].Policy, c.policyFor("other", "/public/index.html"))
+	require.Nil(t, c.policyFor("other", "/internal/status"))
+	require.Same(t, c.Default, c.policyFor("other", "/"))
+
+	for _, tc := range []struct {
+		policy   *Policy
+		origin   string
+		expected string
+		allowed  bool
+	}{
+		{policy: api, origin: "https://app.example.com", expected: "https://app.example.com", allowed: true},
+		{policy: api, origin: "https://example.org"},
+		{policy: c.Routes[1].Policy, origin: "https://anything.org", expected: "*", allowed: true},
+		{policy: c.Default, origin: "https://foo.dev", expected: "https://foo.dev", allowed: true},
+		{policy: c.Default, origin: "https://foo.dev.evil.com"},
+	} {
+		actual, allowed := tc.policy.allowOrigin(tc.origin)
+		require.Equal(t, tc.allowed, allowed, tc.origin)
+		require.Equal(t, tc.expected, actual, tc.origin)
+	}
+}
+
+func TestParseConfig_invalid(t *testing.T) {
+	for _, data := range []string{
+		`{"default": {"allow_origins": [{}]}}`,
+		`{"default": {"allow_origins": [{"exact": "a", "suffix": "b"}]}}`,
+		`{"routes": [{"policy": {"allow_origins": [{"regex": "("}]}}]}`,
+		`{"default": {"max_age": "10m"}}`,
+		`not json`,
+	} {
+		_, err := ParseConfig([]byte(data))
+		require.Error(t, err, data)
+	}
+}
+
+func TestOriginMatcher_regexMatchesWholeOrigin(t *testing.T) {
+	m := &OriginMatcher{Regex: `https://example\.com|https://app\.example\.com`}
+	require.NoError(t, m.compile())
+
+	require.True(t, m.match("https://example.com"))
+	require.True(t, m.match("https://app.example.com"))
+	for _, origin := range []string{
+		"https://example.com.evil.io",
+		"https://evil.io/?https://example.com",
+		"https://app.example.com:8443",
+	} {
+		require.False(t, m.match(origin), origin)
+	}
+}
+
+func TestPolicy_wildcardWithCredentials(t *testing.T) {
+	p := &Policy{AllowOrigins: []OriginMatcher{{Exact: "*"}}, AllowCredentials: true}
+	origin, ok := p.allowOrigin("https://app.example.com")
+	require.True(t, ok)
+	require.Equal(t, "https://app.example.com", origin)
+	require.True(t, p.variesByOrigin())
+
+	p.AllowCredentials = false
+	require.False(t, p.variesByOrigin())
+}
+
+func TestAppendVary(t *testing.T) {
+	require.Equal(t, "origin", appendVary("", "origin"))
+	require.Equal(t, "accept-encoding,origin", appendVary("accept-encoding", "origin"))
+	require.Equal(t, "Accept-Encoding, Origin", appendVary("Accept-Encoding, Origin", "origin"))
+	require.Equal(t, "*", appendVary("*", "origin"))
+}
@@ -0,0 +1,145 @@
+// Package cors implements Cross-Origin Resource Sharing for HTTP plugins as described in:
+// https://fetch.spec.whatwg.org/#http-cors-protocol
+//
+// Preflight requests are answered with proxywasm.SendHttpResponse, and the
+// "access-control-*" headers of other cross-origin requests are added in
+// types.HttpContext.OnHttpResponseHeaders. Responses whose CORS headers depend on the
+// request origin always carry "vary: origin", including responses to same-origin and
+// disallowed requests, so that shared caches do not mix them up.
+//
+// A Config is usually parsed in types.PluginContext.OnPluginStart and each
+// types.HttpContext delegates its callbacks to a Stream obtained from Config.NewStream.
+package cors
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/properties"
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm"
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/types"
+)
+
+// PreflightStatus is the status of responses to preflight requests.
+const PreflightStatus = 204
+
+// NewStream returns the per-request state. It is usually called from
+// types.PluginContext.NewHttpContext.
+func (c *Config) NewStream() *Stream {
+	return &Stream{config: c}
+}
+
+// Stream applies the CORS policy of a single HTTP request.
+type Stream struct {
+	config *Config
+	policy *Policy
+	// allowOrigin is the value of "access-control-allow-origin", empty if the
+	// request is not an allowed cross-origin request.
+	allowOrigin string
+}
+
+// OnHttpRequestHeaders must be called from types.HttpContext.OnHttpRequestHeaders.
+func (s *Stream) OnHttpRequestHeaders(int, bool) types.Action {
+	path, err := proxywasm.GetHttpRequestHeader(":path")
+	if err != nil {
+		proxywasm.LogErrorf("cors: failed to get :path: %v", err)
+		return types.ActionContinue
+	}
+	routeName, _ := properties.GetRouteName()
+	s.policy = s.config.policyFor(routeName, path)
+	if s.policy == nil {
+		return types.ActionContinue
+	}
+
+	origin, err := proxywasm.GetHttpRequestHeader("origin")
+	if err != nil || origin == "" {
+		return types.ActionContinue
+	}
+	allowOrigin, allowed := s.policy.allowOrigin(origin)
+	method, _ := proxywasm.GetHttpRequestHeader(":method")
+	requestMethod, _ := proxywasm.GetHttpRequestHeader("access-control-request-method")
+
+	if method == "OPTIONS" && requestMethod != "" {
+		s.sendPreflightResponse(allowOrigin, allowed)
+		return types.ActionPause
+	}
+	if allowed {
+		s.allowOrigin = allowOrigin
+	}
+	return types.ActionContinue
+}
+
+// OnHttpResponseHeaders must be called from types.HttpContext.OnHttpResponseHeaders.
+func (s *Stream) OnHttpResponseHeaders(int, bool) types.Action {
+	if s.policy == nil {
+		return types.ActionContinue
+	}
+	var headers [][2]string
+	if s.allowOrigin != "" {
+		headers = append(headers, [2]string{"access-control-allow-origin", s.allowOrigin})
+		if s.policy.AllowCredentials {
+			headers = append(headers, [2]string{"access-control-allow-credentials", "true"})
+		}
+		if len(s.policy.ExposeHeaders) > 0 {
+			headers = append(headers, [2]string{"access-control-expose-headers", strings.Join(s.policy.ExposeHeaders, ",")})
+		}
+	}
+	for _, h := range headers {
+		if err := proxywasm.ReplaceHttpResponseHeader(h[0], h[1]); err != nil {
+			proxywasm.LogErrorf("cors: failed to set %s: %v", h[0], err)
+		}
+	}
+
+	if s.policy.variesByOrigin() {
+		vary, _ := proxywasm.GetHttpResponseHeader("vary")
+		if err := proxywasm.ReplaceHttpResponseHeader("vary", appendVary(vary, "origin")); err != nil {
+			proxywasm.LogErrorf("cors: failed to set vary: %v", err)
+		}
+	}
+	return types.ActionContinue
+}
+
+func (s *Stream) sendPreflightResponse(allowOrigin string, allowed bool) {
+	p := s.policy
+	var headers [][2]string
+	if allowed {
+		headers = append(headers, [2]string{"access-control-allow-origin", allowOrigin})
+		if p.AllowCredentials {
+			headers = append(headers, [2]string{"access-control-allow-credentials", "true"})
+		}
+		if len(p.AllowMethods) > 0 {
+			headers = append(headers, [2]string{"access-control-allow-methods", strings.Join(p.AllowMethods, ",")})
+		}
+		if len(p.AllowHeaders) > 0 {
+			headers = append(headers, [2]string{"access-control-allow-headers", strings.Join(p.AllowHeaders, ",")})
+		}
+		if p.MaxAge > 0 {
+			headers = append(headers, [2]string{"access-control-max-age",
+				strconv.FormatInt(int64(time.Duration(p.MaxAge)/time.Second), 10)})
+		}
+	}
+	vary := "access-control-request-method,access-control-request-headers"
+	if p.variesByOrigin() {
+		vary = "origin," + vary
+	}
+	headers = append(headers, [2]string{"vary", vary})
+	if err := proxywasm.SendHttpResponse(PreflightStatus, headers, nil, -1); err != nil {
+		proxywasm.LogErrorf("cors: failed to send preflight response: %v", err)
+		_ = proxywasm.ResumeHttpRequest()
+	}
+}
+
+// appendVary adds the header name to a "vary" value unless it is already listed.
+func appendVary(vary, name string) string {
+	if vary == "" {
+		return name
+	}
+	for _, v := range strings.Split(vary, ",") {
+		v = strings.TrimSpace(v)
+		if v == "*" || strings.EqualFold(v, name) {
+			return vary
+		}
+	}
+	return vary + "," + name
+}
@@ -0,0 +1,205 @@
+package cors
+
+import (
+	"testing"
+
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm"
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/proxytest"
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/types"
+	"github.com/stretchr/testify/require"
+)
+
+type testVMContext struct {
+	types.DefaultVMContext
+}
+
+func (*testVMContext) NewPluginContext(uint32) types.PluginContext {
+	return &testPluginContext{}
+}
+
+type testPluginContext struct {
+	types.DefaultPluginContext
+	config *Config
+}
+
+func (p *testPluginContext) OnPluginStart(size int) types.OnPluginStartStatus {
+	data, err := proxywasm.GetPluginConfiguration()
+	if err != nil {
+		return types.OnPluginStartStatusFailed
+	}
+	if p.config, err = ParseConfig(data); err != nil {
+		return types.OnPluginStartStatusFailed
+	}
+	return types.OnPluginStartStatusOK
+}
+
+func (p *testPluginContext) NewHttpContext(uint32) types.HttpContext {
+	return &testHttpContext{cors: p.config.NewStream()}
+}
+
+type testHttpContext struct {
+	types.DefaultHttpContext
+	cors *Stream
+}
+
+func (h *testHttpContext) OnHttpRequestHeaders(numHeaders int, endOfStream bool) types.Action {
+	return h.cors.OnHttpRequestHeaders(numHeaders, endOfStream)
+}
+
+func (h *testHttpContext) OnHttpResponseHeaders(numHeaders int, endOfStream bool) types.Action {
+	return h.cors.OnHttpResponseHeaders(numHeaders, endOfStream)
+}
+
+const testConfig = `{
+	"routes": [
+		{
+			"route_name": "api",
+			"policy": {
+				"allow_origins": [{"exact": "https://app.example.com"}, {"suffix": ".example.org"}],
+				"allow_methods": ["GET", "PUT"],
+				"allow_headers": ["authorization", "content-type"],
+				"expose_headers": ["x-request-id"],
+				"max_age": 600,
+				"allow_credentials": true
+			}
+		},
+		{"path_prefix": "/public/", "policy": {"allow_origins": [{"exact": "*"}]}}
+	]
+}`
+
+func newHost(t *testing.T, routeName string) (proxytest.HostEmulator, func()) {
+	opt := proxytest.NewEmulatorOption().
+		WithVMContext(&testVMContext{}).
+		WithPluginConfiguration([]byte(testConfig))
+	if routeName != "" {
+		opt.WithProperty([]string{"route_name"}, []byte(routeName))
+	}
+	host, reset := proxytest.NewHostEmulator(opt)
+	require.Equal(t, types.OnPluginStartStatusOK, host.StartPlugin())
+	return host, reset
+}
+
+func TestStream_preflight(t *testing.T) {
+	host, reset := newHost(t, "api")
+	defer reset()
+
+	t.Run("allowed", func(t *testing.T) {
+		id := host.InitializeHttpContext()
+		action := host.CallOnRequestHeaders(id, [][2]string{
+			{":method", "OPTIONS"},
+			{":path", "/items"},
+			{"origin", "https://shop.example.org"},
+			{"access-control-request-method", "PUT"},
+		}, true)
+		require.Equal(t, types.ActionPause, action)
+
+		res := host.GetSentLocalResponse(id)
+		require.NotNil(t, res)
+		require.Equal(t, uint32(PreflightStatus), res.StatusCode)
+		require.Equal(t, [][2]string{
+			{"access-control-allow-origin", "https://shop.example.org"},
+			{"access-control-allow-credentials", "true"},
+			{"access-control-allow-methods", "GET,PUT"},
+			{"access-control-allow-headers", "authorization,content-type"},
+			{"access-control-max-age", "600"},
+			{"vary", "origin,access-control-request-method,access-control-request-headers"},
+		}, res.Headers)
+	})
+
+	t.Run("disallowed origin", func(t *testing.T) {
+		id := host.InitializeHttpContext()
+		action := host.CallOnRequestHeaders(id, [][2]string{
+			{":method", "OPTIONS"},
+			{":path", "/items"},
+			{"origin", "https://evil.com"},
+			{"access-control-request-method", "PUT"},
+		}, true)
+		require.Equal(t, types.ActionPause, action)
+
+		res := host.GetSentLocalResponse(id)
+		require.NotNil(t, res)
+		require.Equal(t, [][2]string{
+			{"vary", "origin,access-control-request-method,access-control-request-headers"},
+		}, res.Headers)
+	})
+
+	t.Run("plain options request", func(t *testing.T) {
+		id := host.InitializeHttpContext()
+		action := host.CallOnRequestHeaders(id, [][2]string{
+			{":method", "OPTIONS"},
+			{":path", "/items"},
+			{"origin", "https://app.example.com"},
+		}, true)
+		require.Equal(t, types.ActionContinue, action)
+		require.Nil(t, host.GetSentLocalResponse(id))
+	})
+}
+
+func TestStream_actualRequest(t *testing.T) {
+	for _, tc := range []struct {
+		name            string
+		routeName       string
+		path            string
+		origin          string
+		responseHeaders [][2]string
+		expected        [][2]string
+	}{
+		{
+			name:            "credentialed",
+			routeName:       "api",
+			path:            "/items",
+			origin:          "https://app.example.com",
+			responseHeaders: [][2]string{{":status", "200"}, {"vary", "accept-encoding"}},
+			expected: [][2]string{
+				{":status", "200"},
+				{"vary", "accept-encoding,origin"},
+				{"access-control-allow-origin", "https://app.example.com"},
+				{"access-control-allow-credentials", "true"},
+				{"access-control-expose-headers", "x-request-id"},
+			},
+		},
+		{
+			name:            "disallowed origin still varies",
+			routeName:       "api",
+			path:            "/items",
+			origin:          "https://evil.com",
+			responseHeaders: [][2]string{{":status", "200"}},
+			expected:        [][2]string{{":status", "200"}, {"vary", "origin"}},
+		},
+		{
+			name:            "same origin still varies",
+			routeName:       "api",
+			path:            "/items",
+			responseHeaders: [][2]string{{":status", "200"}},
+			expected:        [][2]string{{":status", "200"}, {"vary", "origin"}},
+		},
+		{
+			name:            "wildcard",
+			path:            "/public/logo.png",
+			origin:          "https://anything.com",
+			responseHeaders: [][2]string{{":status", "200"}},
+			expected:        [][2]string{{":status", "200"}, {"access-control-allow-origin", "*"}},
+		},
+		{
+			name:            "no policy",
+			path:            "/private",
+			origin:          "https://app.example.com",
+			responseHeaders: [][2]string{{":status", "200"}},
+			expected:        [][2]string{{":status", "200"}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			host, reset := newHost(t, tc.routeName)
+			defer reset()
+
+			headers := [][2]string{{":method", "GET"}, {":path", tc.path}}
+			if tc.origin != "" {
+				headers = append(headers, [2]string{"origin", tc.origin})
+			}
+			id := host.InitializeHttpContext()
+			require.Equal(t, types.ActionContinue, host.CallOnRequestHeaders(id, headers, true))
+			require.Equal(t, types.ActionContinue, host.CallOnResponseHeaders(id, tc.responseHeaders, false))
+			require.Equal(t, tc.expected, host.GetCurrentResponseHeaders(id))
+		})
+	}
+}
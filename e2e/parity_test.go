@@ -0,0 +1,104 @@
+//go:build proxywasm_parity
+
+// Copyright 2020-2024 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package e2e
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/proxytest"
+)
+
+// subtestResult matches the results of the "go" and "wasm" subtests run by the vmTest helper
+// of the examples, e.g. "--- PASS: TestNetwork_counter/wasm".
+var subtestResult = regexp.MustCompile(`(?m)^\s*--- (PASS|FAIL|SKIP): (\S+)/(go|wasm) `)
+
+// Test_parity runs the tests of each example natively and against its compiled main.wasm,
+// which must both pass. It builds every example, so it only runs with the proxywasm_parity
+// build tag, as in the test.parity Makefile target.
+func Test_parity(t *testing.T) {
+	examples := []string{
+		"dispatch_call_on_tick",
+		"foreign_call_on_tick",
+		"helloworld",
+		"http_auth_random",
+		"http_body",
+		"http_body_chunk",
+		"http_headers",
+		"http_routing",
+		"json_validation",
+		"metrics",
+		"multiple_dispatches",
+		"network",
+		"postpone_requests",
+		"properties",
+		"shared_data",
+		"shared_queue/receiver",
+		"shared_queue/sender",
+		"vm_plugin_configuration",
+	}
+
+	// All the examples are built first, as the ones of shared_queue are tested against each other.
+	// The binaries are built next to the tests of the examples which load them, and removed
+	// afterwards unless they were already built, e.g. by the build.examples Makefile target.
+	for _, example := range examples {
+		dir := filepath.Join("examples", example)
+		if _, err := os.Stat(filepath.Join(dir, "main.wasm")); os.IsNotExist(err) {
+			t.Cleanup(func() { os.Remove(filepath.Join(dir, "main.wasm")) })
+		}
+		build := exec.Command("go", "build", "-buildmode=c-shared", "-o", "main.wasm", "./main.go")
+		build.Dir = dir
+		build.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm")
+		out, err := build.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+
+	for _, example := range examples {
+		t.Run(example, func(t *testing.T) {
+			dir := filepath.Join("examples", example)
+
+			// Every host function imported by the guest must be provided.
+			wasm, err := os.ReadFile(filepath.Join(dir, "main.wasm"))
+			require.NoError(t, err)
+			vm, err := proxytest.NewWasmVMContext(wasm)
+			require.NoError(t, err)
+			require.NoError(t, vm.Close())
+
+			test := exec.Command("go", "test", "-v", "-count=1", "./...")
+			test.Dir = dir
+			out, err := test.CombinedOutput()
+			require.NoError(t, err, string(out))
+
+			results := map[string]map[string]string{}
+			for _, m := range subtestResult.FindAllStringSubmatch(string(out), -1) {
+				if results[m[2]] == nil {
+					results[m[2]] = map[string]string{}
+				}
+				results[m[2]][m[3]] = m[1]
+			}
+			require.NotEmpty(t, results, string(out))
+			for name, result := range results {
+				require.Equal(t, map[string]string{"go": "PASS", "wasm": "PASS"}, result, name)
+			}
+		})
+	}
+}
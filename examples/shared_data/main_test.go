@@ -43,6 +43,28 @@ func TestData(t *testing.T) {
 	})
 }
 
+func TestData_casMismatch(t *testing.T) {
+	vmTest(t, func(t *testing.T, vm types.VMContext) {
+		opt := proxytest.NewEmulatorOption().WithVMContext(vm)
+		host, reset := proxytest.NewHostEmulator(opt)
+		defer reset()
+		require.Equal(t, types.OnVMStartStatusOK, host.StartVM())
+
+		// Another VM updates the value between the read and the write.
+		host.InjectNthFault(1, "proxy_set_shared_data", types.ErrorStatusCasMismatch,
+			proxytest.CallsInCallback("proxy_on_request_headers"), proxytest.CallsWithArg(sharedDataKey))
+		contextID := host.InitializeHttpContext()
+		action := host.CallOnRequestHeaders(contextID, nil, false)
+		require.Equal(t, types.ActionContinue, action)
+
+		// The increment is retried.
+		require.Equal(t, []string{"error setting shared data on OnHttpRequestHeaders: error status returned by host: cas mismatch"},
+			host.GetWarnLogs())
+		require.Equal(t, []string{"shared value: 1"}, host.GetInfoLogs())
+		require.Len(t, host.Calls(proxytest.CallsNamed("proxy_set_shared_data"), proxytest.CallsInContext(contextID)), 2)
+	})
+}
+
 // vmTest executes f twice, once with a types.VMContext that executes plugin code directly
 // in the host, and again by executing the plugin code within the compiled main.wasm binary.
 // Execution with main.wasm will be skipped if the file cannot be found.
@@ -4,4 +4,14 @@ go 1.24
 
 replace github.com/proxy-wasm/proxy-wasm-go-sdk => ../..
 
-require github.com/proxy-wasm/proxy-wasm-go-sdk v0.0.0-00010101000000-000000000000
+require (
+	github.com/proxy-wasm/proxy-wasm-go-sdk v0.0.0-00010101000000-000000000000
+	github.com/stretchr/testify v1.9.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/tetratelabs/wazero v1.7.2 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
@@ -0,0 +1,89 @@
+// The framework emulates the expected behavior of Envoyproxy, and you can test your extensions without running Envoy and with
+// the standard Go CLI. To run tests, simply run
+// go test ./...
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/proxytest"
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReceiver_OnPluginStart(t *testing.T) {
+	vmTest(t, func(t *testing.T, vm types.VMContext) {
+		opt := proxytest.NewEmulatorOption().
+			WithVMContext(vm).
+			WithPluginConfiguration([]byte("http_request_headers"))
+		host, reset := proxytest.NewHostEmulator(opt)
+		defer reset()
+
+		require.Equal(t, types.OnPluginStartStatusOK, host.StartPlugin())
+		require.Equal(t, []string{
+			fmt.Sprintf(`queue "http_request_headers" registered as queueID=0 by contextID=%d`, proxytest.PluginContextID),
+		}, host.GetInfoLogs())
+	})
+}
+
+func TestReceiver_OnQueueReady(t *testing.T) {
+	vmTest(t, func(t *testing.T, vm types.VMContext) {
+		opt := proxytest.NewEmulatorOption().
+			WithVMContext(vm).
+			WithVMID("receiver").
+			WithPlugin("http_request_headers", []byte("http_request_headers"))
+		receiver, reset := proxytest.NewHostEmulator(opt)
+		defer reset()
+		require.Equal(t, types.OnPluginStartStatusOK, receiver.StartPlugin())
+
+		// The sender runs in its own VM, compiled from ../sender.
+		wasm, err := os.ReadFile("../sender/main.wasm")
+		if err != nil {
+			t.Skip("sender wasm not found")
+		}
+		senderVM, err := proxytest.NewWasmVMContext(wasm)
+		require.NoError(t, err)
+		defer senderVM.Close()
+
+		sender := receiver.NewVM(proxytest.NewEmulatorOption().
+			WithVMContext(senderVM).
+			WithVMID("sender").
+			WithPluginConfiguration([]byte("http")))
+		require.Equal(t, types.OnPluginStartStatusOK, sender.StartPlugin())
+
+		// Enqueue the request headers in the sender.
+		id := sender.InitializeHttpContext()
+		action := sender.CallOnRequestHeaders(id, [][2]string{{":path", "/"}, {":method", "GET"}}, false)
+		require.Equal(t, types.ActionContinue, action)
+
+		// Check that the headers are dequeued in the receiver.
+		logs := receiver.GetInfoLogs()
+		require.Contains(t, logs, fmt.Sprintf(`(contextID=%d) dequeued data from http_request_headers(queueID=0): {"key": ":path","value": "/"}`, proxytest.PluginContextID))
+		require.Contains(t, logs, fmt.Sprintf(`(contextID=%d) dequeued data from http_request_headers(queueID=0): {"key": ":method","value": "GET"}`, proxytest.PluginContextID))
+	})
+}
+
+// vmTest executes f twice, once with a types.VMContext that executes plugin code directly
+// in the host, and again by executing the plugin code within the compiled main.wasm binary.
+// Execution with main.wasm will be skipped if the file cannot be found.
+func vmTest(t *testing.T, f func(*testing.T, types.VMContext)) {
+	t.Helper()
+
+	t.Run("go", func(t *testing.T) {
+		f(t, &vmContext{})
+	})
+
+	t.Run("wasm", func(t *testing.T) {
+		wasm, err := os.ReadFile("main.wasm")
+		if err != nil {
+			t.Skip("wasm not found")
+		}
+		v, err := proxytest.NewWasmVMContext(wasm)
+		require.NoError(t, err)
+		defer v.Close()
+		f(t, v)
+	})
+}
@@ -0,0 +1,84 @@
+// The framework emulates the expected behavior of Envoyproxy, and you can test your extensions without running Envoy and with
+// the standard Go CLI. To run tests, simply run
+// go test ./...
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/proxytest"
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSender_OnHttpHeaders(t *testing.T) {
+	vmTest(t, func(t *testing.T, vm types.VMContext) {
+		// The receiver runs in its own VM, compiled from ../receiver.
+		wasm, err := os.ReadFile("../receiver/main.wasm")
+		if err != nil {
+			t.Skip("receiver wasm not found")
+		}
+		receiverVM, err := proxytest.NewWasmVMContext(wasm)
+		require.NoError(t, err)
+		defer receiverVM.Close()
+
+		opt := proxytest.NewEmulatorOption().
+			WithVMContext(receiverVM).
+			WithVMID(receiverVMID).
+			WithPlugin("http_request_headers", []byte("http_request_headers")).
+			WithPlugin("http_response_headers", []byte("http_response_headers"))
+		receiver, reset := proxytest.NewHostEmulator(opt)
+		defer reset()
+		var receiverIDs []uint32
+		for _, rootID := range []string{"http_request_headers", "http_response_headers"} {
+			id, err := receiver.GetPluginContextID(rootID)
+			require.NoError(t, err)
+			require.Equal(t, types.OnPluginStartStatusOK, receiver.StartPluginContext(id))
+			receiverIDs = append(receiverIDs, id)
+		}
+
+		sender := receiver.NewVM(proxytest.NewEmulatorOption().
+			WithVMContext(vm).
+			WithVMID("sender").
+			WithPluginConfiguration([]byte("http")))
+		require.Equal(t, types.OnPluginStartStatusOK, sender.StartPlugin())
+
+		// Enqueue the headers in the sender.
+		id := sender.InitializeHttpContext()
+		action := sender.CallOnRequestHeaders(id, [][2]string{{":path", "/"}}, false)
+		require.Equal(t, types.ActionContinue, action)
+		action = sender.CallOnResponseHeaders(id, [][2]string{{":status", "200"}}, false)
+		require.Equal(t, types.ActionContinue, action)
+		require.Contains(t, sender.GetInfoLogs(), `enqueued data: {"key": ":path","value": "/"}`)
+
+		// Check that the headers are dequeued in the receiver.
+		logs := receiver.GetInfoLogs()
+		require.Contains(t, logs, fmt.Sprintf(`(contextID=%d) dequeued data from http_request_headers(queueID=0): {"key": ":path","value": "/"}`, receiverIDs[0]))
+		require.Contains(t, logs, fmt.Sprintf(`(contextID=%d) dequeued data from http_response_headers(queueID=1): {"key": ":status","value": "200"}`, receiverIDs[1]))
+	})
+}
+
+// vmTest executes f twice, once with a types.VMContext that executes plugin code directly
+// in the host, and again by executing the plugin code within the compiled main.wasm binary.
+// Execution with main.wasm will be skipped if the file cannot be found.
+func vmTest(t *testing.T, f func(*testing.T, types.VMContext)) {
+	t.Helper()
+
+	t.Run("go", func(t *testing.T) {
+		f(t, &vmContext{})
+	})
+
+	t.Run("wasm", func(t *testing.T) {
+		wasm, err := os.ReadFile("main.wasm")
+		if err != nil {
+			t.Skip("wasm not found")
+		}
+		v, err := proxytest.NewWasmVMContext(wasm)
+		require.NoError(t, err)
+		defer v.Close()
+		f(t, v)
+	})
+}
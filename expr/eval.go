@@ -0,0 +1,501 @@
+package expr
+
+import (
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm"
+)
+
+// node is a node of the syntax tree of a compiled expression.
+type node interface {
+	eval() (any, error)
+}
+
+type literal struct {
+	value any
+}
+
+type identifier struct {
+	name string
+}
+
+type selection struct {
+	operand node
+	field   string
+}
+
+type indexing struct {
+	operand node
+	index   node
+}
+
+type call struct {
+	name string
+	args []node
+	// re is the compiled pattern of matches when it is a literal.
+	re *regexp.Regexp
+}
+
+type list struct {
+	items []node
+}
+
+type not struct {
+	operand node
+}
+
+type logical struct {
+	and         bool
+	left, right node
+}
+
+type relation struct {
+	op          string
+	left, right node
+}
+
+type conditional struct {
+	cond, then, otherwise node
+}
+
+type attributeSource int
+
+const (
+	sourceProperty attributeSource = iota
+	sourceRequestHeader
+	sourceResponseHeader
+	sourceResponseTrailer
+	sourceRequestHeaders
+	sourceResponseHeaders
+	sourceResponseTrailers
+)
+
+type valueType int
+
+const (
+	typeString valueType = iota
+	typeInt
+	typeBool
+	typeMap
+)
+
+// attribute reads an attribute with a single hostcall when evaluated.
+type attribute struct {
+	path   []string
+	name   string
+	source attributeSource
+	typ    valueType
+}
+
+// attributeTypes are the types of the attributes which are not strings. Durations and
+// timestamps are integers of nanoseconds. Maps are read with proxywasm.GetPropertyMap.
+var attributeTypes = map[string]valueType{
+	"request.time":                    typeInt,
+	"request.duration":                typeInt,
+	"request.size":                    typeInt,
+	"request.total_size":              typeInt,
+	"response.code":                   typeInt,
+	"response.flags":                  typeInt,
+	"response.grpc_status":            typeInt,
+	"response.size":                   typeInt,
+	"response.total_size":             typeInt,
+	"response.backend_latency":        typeInt,
+	"source.port":                     typeInt,
+	"destination.port":                typeInt,
+	"connection.id":                   typeInt,
+	"connection.mtls":                 typeBool,
+	"upstream.port":                   typeInt,
+	"upstream.request_attempt_count":  typeInt,
+	"upstream.cx_pool_ready_duration": typeInt,
+	"listener_direction":              typeInt,
+	"filter_state":                    typeMap,
+	"upstream_filter_state":           typeMap,
+}
+
+func newAttribute(path []string) *attribute {
+	a := &attribute{path: path, name: strings.Join(path, "."), typ: attributeTypes[strings.Join(path, ".")]}
+	if len(path) < 2 || len(path) > 3 {
+		return a
+	}
+	sources := map[string][2]attributeSource{
+		"request.headers":   {sourceRequestHeaders, sourceRequestHeader},
+		"response.headers":  {sourceResponseHeaders, sourceResponseHeader},
+		"response.trailers": {sourceResponseTrailers, sourceResponseTrailer},
+	}
+	if s, ok := sources[path[0]+"."+path[1]]; ok {
+		if len(path) == 2 {
+			a.source = s[0]
+		} else {
+			a.source = s[1]
+			// Header names are case-insensitive.
+			a.path[2] = strings.ToLower(path[2])
+		}
+	}
+	return a
+}
+
+func (a *attribute) eval() (any, error) {
+	switch a.source {
+	case sourceRequestHeader:
+		return headerValue(a, proxywasm.GetHttpRequestHeader)
+	case sourceResponseHeader:
+		return headerValue(a, proxywasm.GetHttpResponseHeader)
+	case sourceResponseTrailer:
+		return headerValue(a, proxywasm.GetHttpResponseTrailer)
+	case sourceRequestHeaders:
+		return headerMap(a, proxywasm.GetHttpRequestHeaders)
+	case sourceResponseHeaders:
+		return headerMap(a, proxywasm.GetHttpResponseHeaders)
+	case sourceResponseTrailers:
+		return headerMap(a, proxywasm.GetHttpResponseTrailers)
+	}
+
+	if a.typ == typeMap {
+		return headerMap(a, func() ([][2]string, error) { return proxywasm.GetPropertyMap(a.path) })
+	}
+	bs, err := proxywasm.GetProperty(a.path)
+	if err != nil {
+		return nil, fmt.Errorf("no such attribute %s: %w", a.name, err)
+	}
+	switch a.typ {
+	case typeInt:
+		if len(bs) != 8 {
+			return nil, fmt.Errorf("invalid integer attribute %s", a.name)
+		}
+		return int64(binary.LittleEndian.Uint64(bs)), nil
+	case typeBool:
+		return len(bs) > 0 && bs[0] != 0, nil
+	}
+	return string(bs), nil
+}
+
+func headerValue(a *attribute, get func(string) (string, error)) (any, error) {
+	v, err := get(a.path[2])
+	if err != nil {
+		return nil, fmt.Errorf("no such attribute %s: %w", a.name, err)
+	}
+	return v, nil
+}
+
+// headerMap reads a map such as request.headers, which is also used for map-typed properties.
+func headerMap(a *attribute, get func() ([][2]string, error)) (any, error) {
+	hs, err := get()
+	if err != nil {
+		return nil, fmt.Errorf("no such attribute %s: %w", a.name, err)
+	}
+	m := make(map[string]string, len(hs))
+	for _, h := range hs {
+		// Repeated headers are joined as they are by Envoy.
+		if v, ok := m[h[0]]; ok {
+			m[h[0]] = v + "," + h[1]
+		} else {
+			m[h[0]] = h[1]
+		}
+	}
+	return m, nil
+}
+
+func (n *literal) eval() (any, error) {
+	return n.value, nil
+}
+
+// eval of identifiers is only reached for identifiers which are not attributes, which
+// does not happen once the tree is folded.
+func (n *identifier) eval() (any, error) {
+	return newAttribute([]string{n.name}).eval()
+}
+
+func (n *selection) eval() (any, error) {
+	operand, err := n.operand.eval()
+	if err != nil {
+		return nil, err
+	}
+	return index(operand, n.field)
+}
+
+func (n *indexing) eval() (any, error) {
+	operand, err := n.operand.eval()
+	if err != nil {
+		return nil, err
+	}
+	key, err := n.index.eval()
+	if err != nil {
+		return nil, err
+	}
+	return index(operand, key)
+}
+
+func index(operand, key any) (any, error) {
+	switch operand := operand.(type) {
+	case map[string]string:
+		k, ok := key.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid map key %v", key)
+		}
+		v, ok := operand[k]
+		if !ok {
+			return nil, fmt.Errorf("no such key %s", k)
+		}
+		return v, nil
+	case []any:
+		i, ok := key.(int64)
+		if !ok || i < 0 || i >= int64(len(operand)) {
+			return nil, fmt.Errorf("invalid list index %v", key)
+		}
+		return operand[i], nil
+	}
+	return nil, fmt.Errorf("cannot index %T", operand)
+}
+
+func (n *list) eval() (any, error) {
+	items := make([]any, len(n.items))
+	for i, item := range n.items {
+		v, err := item.eval()
+		if err != nil {
+			return nil, err
+		}
+		items[i] = v
+	}
+	return items, nil
+}
+
+func (n *not) eval() (any, error) {
+	v, err := n.operand.eval()
+	if err != nil {
+		return nil, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return nil, fmt.Errorf("no such overload: !%T", v)
+	}
+	return !b, nil
+}
+
+// eval of logical operators is commutative as in CEL: an error on one side is absorbed when
+// the other side decides the result, e.g. false && error is false.
+func (n *logical) eval() (any, error) {
+	left, leftErr := evalBool(n.left)
+	if leftErr == nil && left != n.and {
+		return left, nil
+	}
+	right, rightErr := evalBool(n.right)
+	if rightErr == nil && right != n.and {
+		return right, nil
+	}
+	if leftErr != nil {
+		return nil, leftErr
+	}
+	if rightErr != nil {
+		return nil, rightErr
+	}
+	return n.and, nil
+}
+
+func evalBool(n node) (bool, error) {
+	v, err := n.eval()
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("expected a bool, got %T", v)
+	}
+	return b, nil
+}
+
+func (n *relation) eval() (any, error) {
+	left, err := n.left.eval()
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval()
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "==":
+		return equal(left, right), nil
+	case "!=":
+		return !equal(left, right), nil
+	case "in":
+		switch right := right.(type) {
+		case []any:
+			for _, item := range right {
+				if equal(left, item) {
+					return true, nil
+				}
+			}
+			return false, nil
+		case map[string]string:
+			k, ok := left.(string)
+			if !ok {
+				return false, nil
+			}
+			_, ok = right[k]
+			return ok, nil
+		}
+		return nil, fmt.Errorf("no such overload: %T in %T", left, right)
+	}
+
+	c, err := compare(left, right)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	default:
+		return c >= 0, nil
+	}
+}
+
+func equal(a, b any) bool {
+	if c, err := compare(a, b); err == nil {
+		return c == 0
+	}
+	switch a := a.(type) {
+	case bool:
+		b, ok := b.(bool)
+		return ok && a == b
+	case nil:
+		return b == nil
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !equal(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// compare orders numbers, including integers and doubles, and strings.
+func compare(a, b any) (int, error) {
+	switch a := a.(type) {
+	case string:
+		if b, ok := b.(string); ok {
+			return strings.Compare(a, b), nil
+		}
+	case int64:
+		switch b := b.(type) {
+		case int64:
+			return compareOrdered(a, b), nil
+		case float64:
+			return compareOrdered(float64(a), b), nil
+		}
+	case float64:
+		switch b := b.(type) {
+		case int64:
+			return compareOrdered(a, float64(b)), nil
+		case float64:
+			return compareOrdered(a, b), nil
+		}
+	}
+	return 0, fmt.Errorf("no such overload: %T < %T", a, b)
+}
+
+func compareOrdered[T int64 | float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func (n *conditional) eval() (any, error) {
+	cond, err := evalBool(n.cond)
+	if err != nil {
+		return nil, err
+	}
+	if cond {
+		return n.then.eval()
+	}
+	return n.otherwise.eval()
+}
+
+func (n *call) eval() (any, error) {
+	args := make([]any, len(n.args))
+	for i, arg := range n.args {
+		v, err := arg.eval()
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+
+	switch n.name {
+	case "size":
+		switch v := args[0].(type) {
+		case string:
+			return int64(utf8.RuneCountInString(v)), nil
+		case []any:
+			return int64(len(v)), nil
+		case map[string]string:
+			return int64(len(v)), nil
+		}
+	case "string":
+		switch v := args[0].(type) {
+		case string:
+			return v, nil
+		case int64:
+			return strconv.FormatInt(v, 10), nil
+		case float64:
+			return strconv.FormatFloat(v, 'g', -1, 64), nil
+		case bool:
+			return strconv.FormatBool(v), nil
+		}
+	case "int":
+		switch v := args[0].(type) {
+		case int64:
+			return v, nil
+		case float64:
+			return int64(v), nil
+		case string:
+			i, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("cannot convert %q to int", v)
+			}
+			return i, nil
+		}
+	default:
+		s, ok1 := args[0].(string)
+		arg, ok2 := args[1].(string)
+		if !ok1 || !ok2 {
+			break
+		}
+		switch n.name {
+		case "startsWith":
+			return strings.HasPrefix(s, arg), nil
+		case "endsWith":
+			return strings.HasSuffix(s, arg), nil
+		case "contains":
+			return strings.Contains(s, arg), nil
+		case "matches":
+			re := n.re
+			if re == nil {
+				var err error
+				if re, err = regexp.Compile(arg); err != nil {
+					return nil, fmt.Errorf("invalid pattern %q: %w", arg, err)
+				}
+			}
+			return re.MatchString(s), nil
+		}
+	}
+	return nil, fmt.Errorf("no such overload: %s(%T)", n.name, args[0])
+}
@@ -0,0 +1,76 @@
+// Package expr evaluates a subset of CEL (https://github.com/google/cel-spec) over the
+// attributes of the current stream, so that plugin configurations can carry conditions such as:
+//
+//	request.headers['x-tenant'] == 'a' && connection.mtls
+//
+// Expressions are compiled once, typically in OnPluginStart, and evaluated per request.
+// Identifiers are resolved lazily when evaluated: request.headers, response.headers and
+// response.trailers are read with the header hostcalls, and every other attribute with
+// proxywasm.GetProperty. Integer attributes such as response.code or source.port are
+// ints; durations and timestamps are ints of nanoseconds.
+//
+// The supported subset is:
+//
+//   - literals: strings, ints, doubles, true, false, null and lists;
+//   - comparisons: ==, !=, <, <=, > and >=;
+//   - boolean logic: &&, ||, ! and the conditional operator ?:;
+//   - membership with in, on lists and on the keys of maps such as request.headers;
+//   - the functions startsWith, endsWith, contains, matches (RE2 syntax), size, string and int.
+//
+// As in CEL, && and || absorb errors when the other side decides the result, so that
+// `'x-tenant' in request.headers && request.headers['x-tenant'] == 'a'` is false instead
+// of an error when the header is missing.
+//
+// The package only depends on the standard library and is meant to keep TinyGo binaries small.
+package expr
+
+import (
+	"fmt"
+)
+
+// Program is a compiled expression. A Program can be evaluated any number of times.
+type Program struct {
+	src  string
+	root node
+}
+
+// Compile parses the expression. Patterns of matches that are literals are compiled here too.
+func Compile(src string) (*Program, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, fmt.Errorf("expr: %w", err)
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.parseExpr()
+	if err != nil {
+		return nil, fmt.Errorf("expr: %w", err)
+	}
+	if p.peek().kind != tokenEOF {
+		return nil, fmt.Errorf("expr: %w", p.unexpected())
+	}
+	return &Program{src: src, root: fold(root)}, nil
+}
+
+// String returns the source of the expression.
+func (p *Program) String() string {
+	return p.src
+}
+
+// Eval evaluates the expression in the current context. The result is a string, int64,
+// float64, bool, nil, []any or map[string]string.
+func (p *Program) Eval() (any, error) {
+	v, err := p.root.eval()
+	if err != nil {
+		return nil, fmt.Errorf("expr: %w", err)
+	}
+	return v, nil
+}
+
+// EvalBool evaluates the expression and returns an error if the result is not a bool.
+func (p *Program) EvalBool() (bool, error) {
+	b, err := evalBool(p.root)
+	if err != nil {
+		return false, fmt.Errorf("expr: %w", err)
+	}
+	return b, nil
+}
@@ -0,0 +1,164 @@
+package expr
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/proxytest"
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/types"
+	"github.com/stretchr/testify/require"
+)
+
+type testVMContext struct {
+	types.DefaultVMContext
+	program *Program
+	result  any
+	err     error
+}
+
+func (v *testVMContext) NewPluginContext(uint32) types.PluginContext {
+	return &testPluginContext{vm: v}
+}
+
+type testPluginContext struct {
+	types.DefaultPluginContext
+	vm *testVMContext
+}
+
+func (p *testPluginContext) NewHttpContext(uint32) types.HttpContext {
+	return &testHttpContext{vm: p.vm}
+}
+
+type testHttpContext struct {
+	types.DefaultHttpContext
+	vm *testVMContext
+}
+
+func (h *testHttpContext) OnHttpRequestHeaders(int, bool) types.Action {
+	h.vm.result, h.vm.err = h.vm.program.Eval()
+	return types.ActionContinue
+}
+
+func evalInRequest(t *testing.T, src string) (any, error) {
+	t.Helper()
+	program, err := Compile(src)
+	require.NoError(t, err)
+
+	vm := &testVMContext{program: program}
+	opt := proxytest.NewEmulatorOption().
+		WithVMContext(vm).
+		WithProperty([]string{"request", "path"}, []byte("/api/v1/users")).
+		WithProperty([]string{"response", "code"}, binary.LittleEndian.AppendUint64(nil, 503)).
+		WithProperty([]string{"connection", "mtls"}, []byte{1}).
+		WithProperty([]string{"node", "metadata", "MESH_ID"}, []byte("cluster.local")).
+		// filter_state is a map with the single entry "k": "v".
+		WithProperty([]string{"filter_state"}, []byte{1, 0, 0, 0, 1, 0, 0, 0, 1, 0, 0, 0, 'k', 0, 'v', 0})
+	host, reset := proxytest.NewHostEmulator(opt)
+	defer reset()
+
+	require.Equal(t, types.OnPluginStartStatusOK, host.StartPlugin())
+	id := host.InitializeHttpContext()
+	host.CallOnRequestHeaders(id, [][2]string{
+		{"X-Tenant", "a"},
+		{"user-agent", "curl/8.0"},
+		{"accept", "text/html"},
+		{"accept", "application/json"},
+	}, false)
+	return vm.result, vm.err
+}
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		src  string
+		want any
+	}{
+		{src: `request.headers['x-tenant'] == 'a' && connection.mtls`, want: true},
+		{src: `request.headers['X-Tenant'] == "a"`, want: true},
+		{src: `request.headers["user-agent"]`, want: "curl/8.0"},
+		{src: `'x-tenant' in request.headers`, want: true},
+		{src: `'x-missing' in request.headers`, want: false},
+		{src: `size(request.headers)`, want: int64(3)},
+		{src: `request.path.startsWith('/api/') && !request.path.endsWith('/')`, want: true},
+		{src: `request.path.matches('^/api/v[0-9]+/')`, want: true},
+		{src: `request.path.matches(request.headers['user-agent'])`, want: false},
+		{src: `request.path.contains('users')`, want: true},
+		{src: `response.code >= 500 && response.code < 600`, want: true},
+		{src: `response.code in [502, 503, 504]`, want: true},
+		{src: `response.code == 503.0`, want: true},
+		{src: `response.code != 200`, want: true},
+		{src: `string(response.code) == '503'`, want: true},
+		{src: `int('42') > -1`, want: true},
+		{src: `node.metadata.MESH_ID`, want: "cluster.local"},
+		{src: `connection.mtls ? 'mtls' : 'plain'`, want: "mtls"},
+		{src: `'b' < 'a' || [1, 2] == [1, 2]`, want: true},
+		{src: `request.headers['x-missing'] == 'a' && false`, want: false},
+		{src: `true || request.headers['x-missing'] == 'a'`, want: true},
+		{src: `size('héllo')`, want: int64(5)},
+		{src: `null == null`, want: true},
+		{src: `'k' in filter_state && size(filter_state) == 1`, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			got, err := evalInRequest(t, tt.src)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	for _, src := range []string{
+		`request.headers['x-missing'] == 'a'`,
+		`request.referer == ''`,
+		`response.code > 'a'`,
+		`!request.path`,
+		`request.path.startsWith(1)`,
+		`request.path.matches(connection.mtls ? '(' : '')`,
+		`int('a')`,
+		`[1][2]`,
+		`response.code ? 1 : 2`,
+	} {
+		t.Run(src, func(t *testing.T) {
+			_, err := evalInRequest(t, src)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, tt := range []struct {
+		src, err string
+	}{
+		{src: ``, err: "expr: unexpected end of expression"},
+		{src: `a ==`, err: "expr: unexpected end of expression"},
+		{src: `a b`, err: `expr: unexpected "b" at 2`},
+		{src: `a == 'b`, err: "expr: unterminated string at 5"},
+		{src: `a # b`, err: `expr: unexpected character '#' at 2`},
+		{src: `(a`, err: "expr: unexpected end of expression"},
+		{src: `a.startsWith()`, err: "expr: wrong number of arguments to startsWith"},
+		{src: `a.lower()`, err: "expr: unknown function lower"},
+		{src: `a.matches('(')`, err: "expr: invalid pattern \"(\": error parsing regexp: missing closing ): `(`"},
+		{src: `a ? b`, err: "expr: unexpected end of expression"},
+		{src: `a + b`, err: `expr: unexpected character '+' at 2`},
+		{src: `-a`, err: `expr: unexpected "a" at 1`},
+	} {
+		t.Run(tt.src, func(t *testing.T) {
+			_, err := Compile(tt.src)
+			require.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestEvalBool(t *testing.T) {
+	program, err := Compile(`'a'`)
+	require.NoError(t, err)
+	require.Equal(t, `'a'`, program.String())
+	_, err = program.EvalBool()
+	require.EqualError(t, err, "expr: expected a bool, got string")
+
+	program, err = Compile(`'a' in ['a', 'b']`)
+	require.NoError(t, err)
+	ok, err := program.EvalBool()
+	require.NoError(t, err)
+	require.True(t, ok)
+}
@@ -0,0 +1,442 @@
+package expr
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenInt
+	tokenFloat
+	tokenString
+	tokenOperator
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value any
+	pos   int
+}
+
+// operators are sorted so that longer operators are matched first.
+var operators = []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!", "(", ")", "[", "]", ",", ".", "?", ":", "-"}
+
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '_' || isLetter(c):
+			start := i
+			for i < len(src) && (src[i] == '_' || isLetter(src[i]) || isDigit(src[i])) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: src[start:i], pos: start})
+		case isDigit(c):
+			start := i
+			isFloat := false
+			for i < len(src) && (isDigit(src[i]) || src[i] == '.' || src[i] == 'e' || src[i] == 'E') {
+				if src[i] == '.' || src[i] == 'e' || src[i] == 'E' {
+					isFloat = true
+				}
+				i++
+			}
+			text := src[start:i]
+			if isFloat {
+				f, err := strconv.ParseFloat(text, 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid number %q at %d", text, start)
+				}
+				tokens = append(tokens, token{kind: tokenFloat, text: text, value: f, pos: start})
+			} else {
+				n, err := strconv.ParseInt(text, 0, 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid number %q at %d", text, start)
+				}
+				tokens = append(tokens, token{kind: tokenInt, text: text, value: n, pos: start})
+			}
+		case c == '\'' || c == '"':
+			s, n, err := unquote(src[i:])
+			if err != nil {
+				return nil, fmt.Errorf("%v at %d", err, i)
+			}
+			tokens = append(tokens, token{kind: tokenString, text: src[i : i+n], value: s, pos: i})
+			i += n
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(src[i:], op) {
+					tokens = append(tokens, token{kind: tokenOperator, text: op, pos: i})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected character %q at %d", c, i)
+			}
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(src)}), nil
+}
+
+// unquote reads the quoted string at the start of s and returns it with the number of bytes read.
+func unquote(s string) (string, int, error) {
+	quote := s[0]
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == quote:
+			return b.String(), i + 1, nil
+		case c == '\\' && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case '\\', '\'', '"':
+				b.WriteByte(s[i])
+			default:
+				return "", 0, fmt.Errorf("invalid escape \\%c", s[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+func isLetter(c byte) bool { return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') }
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token { return p.tokens[p.pos] }
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) accept(op string) bool {
+	if t := p.peek(); (t.kind == tokenOperator || t.kind == tokenIdent) && t.text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(op string) error {
+	if !p.accept(op) {
+		return p.unexpected()
+	}
+	return nil
+}
+
+func (p *parser) unexpected() error {
+	t := p.peek()
+	if t.kind == tokenEOF {
+		return fmt.Errorf("unexpected end of expression")
+	}
+	return fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+}
+
+// parseExpr parses the conditional operator, the lowest precedence.
+func (p *parser) parseExpr() (node, error) {
+	cond, err := p.parseOr()
+	if err != nil || !p.accept("?") {
+		return cond, err
+	}
+	then, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	otherwise, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	return &conditional{cond: cond, then: then, otherwise: otherwise}, nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	for err == nil && p.accept("||") {
+		var right node
+		if right, err = p.parseAnd(); err == nil {
+			left = &logical{and: false, left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseRelation()
+	for err == nil && p.accept("&&") {
+		var right node
+		if right, err = p.parseRelation(); err == nil {
+			left = &logical{and: true, left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+func (p *parser) parseRelation() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">", "in"} {
+		if p.accept(op) {
+			right, err := p.parseUnary()
+			if err != nil {
+				return nil, err
+			}
+			return &relation{op: op, left: left, right: right}, nil
+		}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	switch {
+	case p.accept("!"):
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &not{operand: operand}, nil
+	case p.accept("-"):
+		switch t := p.peek(); t.kind {
+		case tokenInt:
+			p.next()
+			return &literal{value: -t.value.(int64)}, nil
+		case tokenFloat:
+			p.next()
+			return &literal{value: -t.value.(float64)}, nil
+		default:
+			return nil, p.unexpected()
+		}
+	}
+	return p.parseMember()
+}
+
+func (p *parser) parseMember() (node, error) {
+	n, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.accept("."):
+			t := p.peek()
+			if t.kind != tokenIdent {
+				return nil, p.unexpected()
+			}
+			p.next()
+			if p.accept("(") {
+				args, err := p.parseList(")")
+				if err != nil {
+					return nil, err
+				}
+				if n, err = newCall(t.text, n, args); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			n = &selection{operand: n, field: t.text}
+		case p.accept("["):
+			index, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			n = &indexing{operand: n, index: index}
+		default:
+			return n, nil
+		}
+	}
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.peek()
+	if t.kind == tokenEOF {
+		return nil, p.unexpected()
+	}
+	p.next()
+	switch t.kind {
+	case tokenInt, tokenFloat, tokenString:
+		return &literal{value: t.value}, nil
+	case tokenIdent:
+		switch t.text {
+		case "true":
+			return &literal{value: true}, nil
+		case "false":
+			return &literal{value: false}, nil
+		case "null":
+			return &literal{value: nil}, nil
+		}
+		if p.accept("(") {
+			args, err := p.parseList(")")
+			if err != nil {
+				return nil, err
+			}
+			return newCall(t.text, nil, args)
+		}
+		return &identifier{name: t.text}, nil
+	case tokenOperator:
+		switch t.text {
+		case "(":
+			n, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			return n, p.expect(")")
+		case "[":
+			items, err := p.parseList("]")
+			if err != nil {
+				return nil, err
+			}
+			return &list{items: items}, nil
+		}
+	}
+	p.pos--
+	return nil, p.unexpected()
+}
+
+// parseList parses comma-separated expressions until the closing operator.
+func (p *parser) parseList(closing string) ([]node, error) {
+	var items []node
+	if p.accept(closing) {
+		return items, nil
+	}
+	for {
+		n, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, n)
+		if p.accept(closing) {
+			return items, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// newCall creates a call of the function, with the receiver as first argument for methods.
+// The patterns of matches are compiled here when they are literals.
+func newCall(name string, receiver node, args []node) (node, error) {
+	arity, ok := functions[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %s", name)
+	}
+	if receiver != nil {
+		args = append([]node{receiver}, args...)
+	}
+	if len(args) != arity {
+		return nil, fmt.Errorf("wrong number of arguments to %s", name)
+	}
+	c := &call{name: name, args: args}
+	if name == "matches" {
+		if l, ok := args[1].(*literal); ok {
+			pattern, ok := l.value.(string)
+			if !ok {
+				return nil, fmt.Errorf("matches takes a string pattern")
+			}
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			}
+			c.re = re
+		}
+	}
+	return c, nil
+}
+
+// functions are the supported functions with their number of arguments including the receiver.
+var functions = map[string]int{
+	"startsWith": 2,
+	"endsWith":   2,
+	"contains":   2,
+	"matches":    2,
+	"size":       1,
+	"string":     1,
+	"int":        1,
+}
+
+// fold replaces chains of identifiers, selections and literal string indexes with attributes
+// so that each attribute is read with a single hostcall.
+func fold(n node) node {
+	if path, ok := attributePath(n); ok {
+		return newAttribute(path)
+	}
+	switch n := n.(type) {
+	case *selection:
+		n.operand = fold(n.operand)
+	case *indexing:
+		n.operand, n.index = fold(n.operand), fold(n.index)
+	case *call:
+		for i := range n.args {
+			n.args[i] = fold(n.args[i])
+		}
+	case *list:
+		for i := range n.items {
+			n.items[i] = fold(n.items[i])
+		}
+	case *not:
+		n.operand = fold(n.operand)
+	case *logical:
+		n.left, n.right = fold(n.left), fold(n.right)
+	case *relation:
+		n.left, n.right = fold(n.left), fold(n.right)
+	case *conditional:
+		n.cond, n.then, n.otherwise = fold(n.cond), fold(n.then), fold(n.otherwise)
+	}
+	return n
+}
+
+func attributePath(n node) ([]string, bool) {
+	switch n := n.(type) {
+	case *identifier:
+		return []string{n.name}, true
+	case *selection:
+		if path, ok := attributePath(n.operand); ok {
+			return append(path, n.field), true
+		}
+	case *indexing:
+		if l, ok := n.index.(*literal); ok {
+			if key, ok := l.value.(string); ok {
+				if path, ok := attributePath(n.operand); ok {
+					return append(path, key), true
+				}
+			}
+		}
+	}
+	return nil, false
+}
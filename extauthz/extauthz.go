@@ -0,0 +1,363 @@
+// Package extauthz provides an external authorization helper for HTTP plugins that
+// mirrors the semantics of Envoy's ext_authz filter: the request is paused, a check
+// request is sent to an authorization service, and the request is then either resumed
+// or answered with a local response.
+//
+// A single Authorizer is usually created per plugin context from its configuration, and
+// each types.HttpContext delegates its callbacks to a Stream obtained from
+// Authorizer.NewStream:
+//
+//	func (ctx *httpContext) OnHttpRequestHeaders(numHeaders int, endOfStream bool) types.Action {
+//		return ctx.authz.OnHttpRequestHeaders(numHeaders, endOfStream)
+//	}
+//
+//	func (ctx *httpContext) OnHttpRequestBody(bodySize int, endOfStream bool) types.Action {
+//		return ctx.authz.OnHttpRequestBody(bodySize, endOfStream)
+//	}
+//
+//	func (ctx *httpContext) OnHttpResponseHeaders(numHeaders int, endOfStream bool) types.Action {
+//		return ctx.authz.OnHttpResponseHeaders(numHeaders, endOfStream)
+//	}
+package extauthz
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm"
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/types"
+)
+
+const (
+	// DefaultStatusOnError is the status of the local response sent when the
+	// authorization service cannot be reached and FailureModeAllow is false.
+	DefaultStatusOnError = 403
+
+	// DefaultStatusOnDenied is the status of the local response sent when the authorization
+	// service denies the request without a 4xx or 5xx status, as Envoy does.
+	DefaultStatusOnDenied = 403
+
+	// FailureModeAllowedHeader is added to the request when it is allowed because of
+	// FailureModeAllow and FailureModeAllowHeaderAdd is set.
+	FailureModeAllowedHeader = "x-envoy-auth-failure-mode-allowed"
+)
+
+// ErrFailure is passed to the Transport callback when the authorization service
+// could not be reached, for example because of a timeout or a connection failure.
+var ErrFailure = errors.New("extauthz: authorization service unavailable")
+
+// CheckRequest is the description of the downstream request sent to the authorization service.
+type CheckRequest struct {
+	Method    string
+	Path      string
+	Authority string
+	// Headers holds the request headers selected by Authorizer.AllowedRequestHeaders,
+	// followed by Authorizer.HeadersToAdd.
+	Headers [][2]string
+	// Body holds the buffered request body when Authorizer.WithRequestBody is set.
+	Body []byte
+}
+
+// CheckResponse is the decision of the authorization service.
+type CheckResponse struct {
+	// Allowed reports whether the request is authorized.
+	Allowed bool
+	// Status is the HTTP status returned by the authorization service, or 0 if it is
+	// missing. Denials without a 4xx or 5xx status are sent as DefaultStatusOnDenied.
+	Status uint32
+	// Headers are the headers returned by the authorization service.
+	Headers [][2]string
+	// Body is the body returned by the authorization service. It is sent to the
+	// client when the request is denied.
+	Body []byte
+}
+
+// Transport sends check requests to the authorization service. The callback must be
+// invoked exactly once, from the context of the HTTP stream, with either a response
+// or an error.
+//
+// HTTPTransport talks to an HTTP authorization service. A transport for the gRPC
+// envoy.service.auth.v3.Authorization service can implement this interface once gRPC
+// calls are exposed by the SDK.
+type Transport interface {
+	Check(req *CheckRequest, callback func(*CheckResponse, error)) error
+}
+
+// BufferSettings controls whether the request body is sent to the authorization service.
+type BufferSettings struct {
+	// MaxRequestBytes is the maximum number of body bytes buffered and sent.
+	MaxRequestBytes int
+	// AllowPartialMessage sends the first MaxRequestBytes bytes of a larger body.
+	// Otherwise, requests with larger bodies are rejected with 413.
+	AllowPartialMessage bool
+}
+
+// Authorizer holds the configuration shared by all the streams of a plugin.
+type Authorizer struct {
+	// Transport sends the check requests. Required.
+	Transport Transport
+
+	// AllowedRequestHeaders lists the request headers sent to the authorization
+	// service, matched case-insensitively. Entries ending in "*" are prefix matches.
+	// Pseudo headers are always sent through the fields of CheckRequest.
+	AllowedRequestHeaders []string
+	// HeadersToAdd are added to every check request.
+	HeadersToAdd [][2]string
+	// WithRequestBody, if set, buffers the request body and sends it along.
+	WithRequestBody *BufferSettings
+
+	// AllowedUpstreamHeaders lists the headers of an allowing response that are set
+	// on the request forwarded upstream, replacing existing values.
+	AllowedUpstreamHeaders []string
+	// AllowedClientHeaders lists the headers of a denying response that are sent to
+	// the client. Nil means all the headers except pseudo headers and content-length.
+	AllowedClientHeaders []string
+	// AllowedClientHeadersOnSuccess lists the headers of an allowing response that
+	// are added to the response sent to the client.
+	AllowedClientHeadersOnSuccess []string
+
+	// FailureModeAllow lets requests through when the authorization service is unavailable.
+	FailureModeAllow bool
+	// FailureModeAllowHeaderAdd adds FailureModeAllowedHeader to requests allowed
+	// because of FailureModeAllow.
+	FailureModeAllowHeaderAdd bool
+	// StatusOnError is the status sent to the client when the authorization service
+	// is unavailable and FailureModeAllow is false. Defaults to DefaultStatusOnError.
+	StatusOnError uint32
+	// StatusOverrides maps statuses of denying responses, after DefaultStatusOnDenied is
+	// applied, to the status sent to the client. Statuses without an entry are sent as is.
+	StatusOverrides map[uint32]uint32
+}
+
+// NewStream returns the per-request state. It is usually called from
+// types.PluginContext.NewHttpContext.
+func (a *Authorizer) NewStream() *Stream {
+	return &Stream{authorizer: a}
+}
+
+type streamState int
+
+const (
+	stateIdle streamState = iota
+	stateBuffering
+	stateChecking
+	stateDone
+)
+
+// Stream runs the authorization of a single HTTP request.
+type Stream struct {
+	authorizer *Authorizer
+	state      streamState
+	request    *CheckRequest
+	// responseHeaders are added to the client response on success.
+	responseHeaders [][2]string
+}
+
+// OnHttpRequestHeaders must be called from types.HttpContext.OnHttpRequestHeaders.
+func (s *Stream) OnHttpRequestHeaders(_ int, endOfStream bool) types.Action {
+	if s.state != stateIdle {
+		return types.ActionContinue
+	}
+	headers, err := proxywasm.GetHttpRequestHeaders()
+	if err != nil {
+		proxywasm.LogErrorf("extauthz: failed to get request headers: %v", err)
+		return s.fail()
+	}
+	s.request = s.authorizer.newCheckRequest(headers)
+
+	if s.authorizer.WithRequestBody != nil && !endOfStream {
+		s.state = stateBuffering
+		return types.ActionPause
+	}
+	return s.check()
+}
+
+// OnHttpRequestBody must be called from types.HttpContext.OnHttpRequestBody when
+// Authorizer.WithRequestBody is set.
+func (s *Stream) OnHttpRequestBody(bodySize int, endOfStream bool) types.Action {
+	if s.state != stateBuffering {
+		if s.state == stateChecking {
+			return types.ActionPause
+		}
+		return types.ActionContinue
+	}
+
+	settings := s.authorizer.WithRequestBody
+	if bodySize < settings.MaxRequestBytes && !endOfStream {
+		return types.ActionPause
+	}
+	if bodySize > settings.MaxRequestBytes && !settings.AllowPartialMessage {
+		s.state = stateDone
+		s.sendLocalResponse(413, nil, []byte("Payload Too Large"))
+		return types.ActionPause
+	}
+
+	size := bodySize
+	if size > settings.MaxRequestBytes {
+		size = settings.MaxRequestBytes
+	}
+	if size > 0 {
+		body, err := proxywasm.GetHttpRequestBody(0, size)
+		if err != nil {
+			proxywasm.LogErrorf("extauthz: failed to get request body: %v", err)
+			return s.fail()
+		}
+		s.request.Body = body
+	}
+	return s.check()
+}
+
+// OnHttpResponseHeaders must be called from types.HttpContext.OnHttpResponseHeaders
+// when Authorizer.AllowedClientHeadersOnSuccess is set.
+func (s *Stream) OnHttpResponseHeaders(int, bool) types.Action {
+	for _, h := range s.responseHeaders {
+		if err := proxywasm.AddHttpResponseHeader(h[0], h[1]); err != nil {
+			proxywasm.LogErrorf("extauthz: failed to add response header %q: %v", h[0], err)
+		}
+	}
+	s.responseHeaders = nil
+	return types.ActionContinue
+}
+
+func (s *Stream) check() types.Action {
+	s.state = stateChecking
+	if err := s.authorizer.Transport.Check(s.request, s.onCheckResponse); err != nil {
+		proxywasm.LogErrorf("extauthz: failed to send check request: %v", err)
+		return s.fail()
+	}
+	return types.ActionPause
+}
+
+func (s *Stream) onCheckResponse(res *CheckResponse, err error) {
+	if s.state != stateChecking {
+		return
+	}
+	s.state = stateDone
+	a := s.authorizer
+
+	if err != nil {
+		proxywasm.LogWarnf("extauthz: %v", err)
+		if s.fail() == types.ActionContinue {
+			s.resume()
+		}
+		return
+	}
+
+	if !res.Allowed {
+		status := res.Status
+		if status < 400 || status > 599 {
+			status = DefaultStatusOnDenied
+		}
+		if override, ok := a.StatusOverrides[status]; ok {
+			status = override
+		}
+		var headers [][2]string
+		for _, h := range res.Headers {
+			if isPseudoHeader(h[0]) || strings.EqualFold(h[0], "content-length") {
+				continue
+			}
+			if a.AllowedClientHeaders == nil || matchHeader(a.AllowedClientHeaders, h[0]) {
+				headers = append(headers, h)
+			}
+		}
+		s.sendLocalResponse(status, headers, res.Body)
+		return
+	}
+
+	for _, h := range res.Headers {
+		if matchHeader(a.AllowedUpstreamHeaders, h[0]) {
+			if err := proxywasm.ReplaceHttpRequestHeader(h[0], h[1]); err != nil {
+				proxywasm.LogErrorf("extauthz: failed to set request header %q: %v", h[0], err)
+			}
+		}
+		if matchHeader(a.AllowedClientHeadersOnSuccess, h[0]) {
+			s.responseHeaders = append(s.responseHeaders, h)
+		}
+	}
+	s.resume()
+}
+
+// fail handles an unavailable authorization service and returns the action of the
+// current callback.
+func (s *Stream) fail() types.Action {
+	s.state = stateDone
+	a := s.authorizer
+	if a.FailureModeAllow {
+		if a.FailureModeAllowHeaderAdd {
+			if err := proxywasm.ReplaceHttpRequestHeader(FailureModeAllowedHeader, "true"); err != nil {
+				proxywasm.LogErrorf("extauthz: failed to set request header: %v", err)
+			}
+		}
+		return types.ActionContinue
+	}
+	status := a.StatusOnError
+	if status == 0 {
+		status = DefaultStatusOnError
+	}
+	s.sendLocalResponse(status, nil, nil)
+	return types.ActionPause
+}
+
+func (s *Stream) resume() {
+	if err := proxywasm.ResumeHttpRequest(); err != nil {
+		proxywasm.LogErrorf("extauthz: failed to resume request: %v", err)
+	}
+}
+
+func (s *Stream) sendLocalResponse(status uint32, headers [][2]string, body []byte) {
+	if err := proxywasm.SendHttpResponse(status, headers, body, -1); err != nil {
+		proxywasm.LogErrorf("extauthz: failed to send local response: %v", err)
+		s.resume()
+	}
+}
+
+func (a *Authorizer) newCheckRequest(headers [][2]string) *CheckRequest {
+	req := &CheckRequest{}
+	for _, h := range headers {
+		switch strings.ToLower(h[0]) {
+		case ":method":
+			req.Method = h[1]
+		case ":path":
+			req.Path = h[1]
+		case ":authority":
+			req.Authority = h[1]
+		default:
+			if !isPseudoHeader(h[0]) && matchHeader(a.AllowedRequestHeaders, h[0]) {
+				req.Headers = append(req.Headers, h)
+			}
+		}
+	}
+	req.Headers = append(req.Headers, a.HeadersToAdd...)
+	return req
+}
+
+func matchHeader(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			if len(name) >= len(prefix) && strings.EqualFold(name[:len(prefix)], prefix) {
+				return true
+			}
+		} else if strings.EqualFold(p, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func isPseudoHeader(name string) bool {
+	return strings.HasPrefix(name, ":")
+}
+
+func parseStatus(headers [][2]string) uint32 {
+	for _, h := range headers {
+		if h[0] == ":status" {
+			status, err := strconv.ParseUint(h[1], 10, 32)
+			if err != nil {
+				return 0
+			}
+			return uint32(status)
+		}
+	}
+	return 0
+}
@@ -0,0 +1,297 @@
+package extauthz
+
+import (
+	"testing"
+
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/proxytest"
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/types"
+	"github.com/stretchr/testify/require"
+)
+
+type testVMContext struct {
+	types.DefaultVMContext
+	authorizer *Authorizer
+}
+
+func (v *testVMContext) NewPluginContext(uint32) types.PluginContext {
+	return &testPluginContext{authorizer: v.authorizer}
+}
+
+type testPluginContext struct {
+	types.DefaultPluginContext
+	authorizer *Authorizer
+}
+
+func (p *testPluginContext) NewHttpContext(uint32) types.HttpContext {
+	return &testHttpContext{authz: p.authorizer.NewStream()}
+}
+
+type testHttpContext struct {
+	types.DefaultHttpContext
+	authz *Stream
+}
+
+func (h *testHttpContext) OnHttpRequestHeaders(numHeaders int, endOfStream bool) types.Action {
+	return h.authz.OnHttpRequestHeaders(numHeaders, endOfStream)
+}
+
+func (h *testHttpContext) OnHttpRequestBody(bodySize int, endOfStream bool) types.Action {
+	return h.authz.OnHttpRequestBody(bodySize, endOfStream)
+}
+
+func (h *testHttpContext) OnHttpResponseHeaders(numHeaders int, endOfStream bool) types.Action {
+	return h.authz.OnHttpResponseHeaders(numHeaders, endOfStream)
+}
+
+var requestHeaders = [][2]string{
+	{":method", "POST"},
+	{":path", "/resource?id=1"},
+	{":authority", "example.com"},
+	{"authorization", "Bearer token"},
+	{"x-user-id", "alice"},
+	{"x-user-role", "admin"},
+	{"cookie", "secret"},
+}
+
+func newHost(t *testing.T, a *Authorizer) (proxytest.HostEmulator, func()) {
+	if a.Transport == nil {
+		a.Transport = &HTTPTransport{Cluster: "authz", PathPrefix: "/check"}
+	}
+	opt := proxytest.NewEmulatorOption().WithVMContext(&testVMContext{authorizer: a})
+	host, reset := proxytest.NewHostEmulator(opt)
+	require.Equal(t, types.OnPluginStartStatusOK, host.StartPlugin())
+	return host, reset
+}
+
+func TestStream_allowed(t *testing.T) {
+	host, reset := newHost(t, &Authorizer{
+		AllowedRequestHeaders:         []string{"Authorization", "x-user-*"},
+		HeadersToAdd:                  [][2]string{{"x-authz-source", "wasm"}},
+		AllowedUpstreamHeaders:        []string{"x-auth-user"},
+		AllowedClientHeadersOnSuccess: []string{"set-cookie"},
+	})
+	defer reset()
+
+	id := host.InitializeHttpContext()
+	require.Equal(t, types.ActionPause, host.CallOnRequestHeaders(id, requestHeaders, true))
+
+	attrs := host.GetCalloutAttributesFromContext(id)
+	require.Len(t, attrs, 1)
+	require.Equal(t, "authz", attrs[0].Upstream)
+	require.Equal(t, [][2]string{
+		{":method", "POST"},
+		{":path", "/check/resource?id=1"},
+		{":authority", "example.com"},
+		{"content-length", "0"},
+		{"authorization", "Bearer token"},
+		{"x-user-id", "alice"},
+		{"x-user-role", "admin"},
+		{"x-authz-source", "wasm"},
+	}, attrs[0].Headers)
+
+	host.CallOnHttpCallResponse(attrs[0].CalloutID, [][2]string{
+		{":status", "200"},
+		{"x-auth-user", "alice@example.com"},
+		{"set-cookie", "session=1"},
+		{"x-ignored", "1"},
+	}, nil, nil)
+	require.Equal(t, types.ActionContinue, host.GetCurrentHttpStreamAction(id))
+	require.Nil(t, host.GetSentLocalResponse(id))
+
+	headers := host.GetCurrentRequestHeaders(id)
+	require.Contains(t, headers, [2]string{"x-auth-user", "alice@example.com"})
+	require.NotContains(t, headers, [2]string{"x-ignored", "1"})
+
+	require.Equal(t, types.ActionContinue, host.CallOnResponseHeaders(id, [][2]string{{":status", "200"}}, false))
+	require.Contains(t, host.GetCurrentResponseHeaders(id), [2]string{"set-cookie", "session=1"})
+}
+
+func TestStream_denied(t *testing.T) {
+	for _, tc := range []struct {
+		name            string
+		authorizer      *Authorizer
+		status          string
+		expectedStatus  uint32
+		expectedHeaders [][2]string
+	}{
+		{
+			name:            "all client headers",
+			authorizer:      &Authorizer{},
+			expectedStatus:  401,
+			expectedHeaders: [][2]string{{"www-authenticate", "Bearer"}, {"x-reason", "expired"}},
+		},
+		{
+			name:            "allowed client headers",
+			authorizer:      &Authorizer{AllowedClientHeaders: []string{"WWW-Authenticate"}},
+			expectedStatus:  401,
+			expectedHeaders: [][2]string{{"www-authenticate", "Bearer"}},
+		},
+		{
+			name:            "status override",
+			authorizer:      &Authorizer{AllowedClientHeaders: []string{}, StatusOverrides: map[uint32]uint32{401: 403}},
+			expectedStatus:  403,
+			expectedHeaders: [][2]string{},
+		},
+		{
+			name:            "missing status",
+			authorizer:      &Authorizer{AllowedClientHeaders: []string{}},
+			status:          "invalid",
+			expectedStatus:  DefaultStatusOnDenied,
+			expectedHeaders: [][2]string{},
+		},
+		{
+			name:            "redirect status",
+			authorizer:      &Authorizer{AllowedClientHeaders: []string{}},
+			status:          "302",
+			expectedStatus:  DefaultStatusOnDenied,
+			expectedHeaders: [][2]string{},
+		},
+		{
+			name:            "default status override",
+			authorizer:      &Authorizer{AllowedClientHeaders: []string{}, StatusOverrides: map[uint32]uint32{403: 404}},
+			status:          "invalid",
+			expectedStatus:  404,
+			expectedHeaders: [][2]string{},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			host, reset := newHost(t, tc.authorizer)
+			defer reset()
+
+			id := host.InitializeHttpContext()
+			require.Equal(t, types.ActionPause, host.CallOnRequestHeaders(id, requestHeaders, true))
+			attrs := host.GetCalloutAttributesFromContext(id)
+			require.Len(t, attrs, 1)
+			// Only pseudo headers and content-length are sent by default.
+			require.Len(t, attrs[0].Headers, 4)
+
+			status := tc.status
+			if status == "" {
+				status = "401"
+			}
+			host.CallOnHttpCallResponse(attrs[0].CalloutID, [][2]string{
+				{":status", status},
+				{"content-length", "6"},
+				{"www-authenticate", "Bearer"},
+				{"x-reason", "expired"},
+			}, nil, []byte("denied"))
+
+			res := host.GetSentLocalResponse(id)
+			require.NotNil(t, res)
+			require.Equal(t, tc.expectedStatus, res.StatusCode)
+			require.Equal(t, tc.expectedHeaders, res.Headers)
+			require.Equal(t, []byte("denied"), res.Data)
+			require.Equal(t, types.ActionPause, host.GetCurrentHttpStreamAction(id))
+		})
+	}
+}
+
+func TestStream_failure(t *testing.T) {
+	t.Run("deny", func(t *testing.T) {
+		host, reset := newHost(t, &Authorizer{StatusOnError: 503})
+		defer reset()
+
+		id := host.InitializeHttpContext()
+		require.Equal(t, types.ActionPause, host.CallOnRequestHeaders(id, requestHeaders, true))
+		attrs := host.GetCalloutAttributesFromContext(id)
+		require.Len(t, attrs, 1)
+
+		// Timeouts are delivered as responses without headers.
+		host.CallOnHttpCallResponse(attrs[0].CalloutID, nil, nil, nil)
+		res := host.GetSentLocalResponse(id)
+		require.NotNil(t, res)
+		require.Equal(t, uint32(503), res.StatusCode)
+	})
+
+	t.Run("allow", func(t *testing.T) {
+		host, reset := newHost(t, &Authorizer{FailureModeAllow: true, FailureModeAllowHeaderAdd: true})
+		defer reset()
+
+		id := host.InitializeHttpContext()
+		require.Equal(t, types.ActionPause, host.CallOnRequestHeaders(id, requestHeaders, true))
+		attrs := host.GetCalloutAttributesFromContext(id)
+		require.Len(t, attrs, 1)
+
+		host.CallOnHttpCallResponse(attrs[0].CalloutID, nil, nil, nil)
+		require.Nil(t, host.GetSentLocalResponse(id))
+		require.Equal(t, types.ActionContinue, host.GetCurrentHttpStreamAction(id))
+		require.Contains(t, host.GetCurrentRequestHeaders(id), [2]string{FailureModeAllowedHeader, "true"})
+	})
+
+	t.Run("server error", func(t *testing.T) {
+		host, reset := newHost(t, &Authorizer{FailureModeAllow: true})
+		defer reset()
+
+		id := host.InitializeHttpContext()
+		require.Equal(t, types.ActionPause, host.CallOnRequestHeaders(id, requestHeaders, true))
+		attrs := host.GetCalloutAttributesFromContext(id)
+		require.Len(t, attrs, 1)
+
+		host.CallOnHttpCallResponse(attrs[0].CalloutID, [][2]string{{":status", "503"}}, nil, []byte("unavailable"))
+		require.Nil(t, host.GetSentLocalResponse(id))
+		require.Equal(t, types.ActionContinue, host.GetCurrentHttpStreamAction(id))
+		require.NotContains(t, host.GetCurrentRequestHeaders(id), [2]string{FailureModeAllowedHeader, "true"})
+	})
+
+	t.Run("dispatch error", func(t *testing.T) {
+		host, reset := newHost(t, &Authorizer{Transport: failingTransport{}})
+		defer reset()
+
+		id := host.InitializeHttpContext()
+		require.Equal(t, types.ActionPause, host.CallOnRequestHeaders(id, requestHeaders, true))
+		res := host.GetSentLocalResponse(id)
+		require.NotNil(t, res)
+		require.Equal(t, uint32(DefaultStatusOnError), res.StatusCode)
+	})
+}
+
+type failingTransport struct{}
+
+func (failingTransport) Check(*CheckRequest, func(*CheckResponse, error)) error {
+	return ErrFailure
+}
+
+func TestStream_withRequestBody(t *testing.T) {
+	t.Run("buffered", func(t *testing.T) {
+		host, reset := newHost(t, &Authorizer{WithRequestBody: &BufferSettings{MaxRequestBytes: 8}})
+		defer reset()
+
+		id := host.InitializeHttpContext()
+		require.Equal(t, types.ActionPause, host.CallOnRequestHeaders(id, requestHeaders, false))
+		require.Equal(t, types.ActionPause, host.CallOnRequestBody(id, []byte("abc"), false))
+		require.Empty(t, host.GetCalloutAttributesFromContext(id))
+		require.Equal(t, types.ActionPause, host.CallOnRequestBody(id, []byte("def"), true))
+
+		attrs := host.GetCalloutAttributesFromContext(id)
+		require.Len(t, attrs, 1)
+		require.Equal(t, []byte("abcdef"), attrs[0].Body)
+		require.Contains(t, attrs[0].Headers, [2]string{"content-length", "6"})
+
+		host.CallOnHttpCallResponse(attrs[0].CalloutID, [][2]string{{":status", "200"}}, nil, nil)
+		require.Equal(t, types.ActionContinue, host.GetCurrentHttpStreamAction(id))
+	})
+
+	t.Run("partial", func(t *testing.T) {
+		host, reset := newHost(t, &Authorizer{WithRequestBody: &BufferSettings{MaxRequestBytes: 4, AllowPartialMessage: true}})
+		defer reset()
+
+		id := host.InitializeHttpContext()
+		require.Equal(t, types.ActionPause, host.CallOnRequestHeaders(id, requestHeaders, false))
+		require.Equal(t, types.ActionPause, host.CallOnRequestBody(id, []byte("abcdef"), false))
+
+		attrs := host.GetCalloutAttributesFromContext(id)
+		require.Len(t, attrs, 1)
+		require.Equal(t, []byte("abcd"), attrs[0].Body)
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		host, reset := newHost(t, &Authorizer{WithRequestBody: &BufferSettings{MaxRequestBytes: 4}})
+		defer reset()
+
+		id := host.InitializeHttpContext()
+		require.Equal(t, types.ActionPause, host.CallOnRequestHeaders(id, requestHeaders, false))
+		require.Equal(t, types.ActionPause, host.CallOnRequestBody(id, []byte("abcdef"), false))
+		require.Empty(t, host.GetCalloutAttributesFromContext(id))
+		require.Equal(t, uint32(413), host.GetSentLocalResponse(id).StatusCode)
+	})
+}
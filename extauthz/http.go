@@ -0,0 +1,81 @@
+package extauthz
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm"
+)
+
+// DefaultTimeout is the timeout of check requests when HTTPTransport.Timeout is zero.
+const DefaultTimeout = 200 * time.Millisecond
+
+// HTTPTransport sends check requests to an HTTP authorization service with
+// proxywasm.DispatchHttpCall, following the ext_authz HTTP service protocol: the
+// check request keeps the method of the original request, its path is PathPrefix
+// followed by the original path, and a 200 response allows the request. A 5xx
+// response means the service is unavailable, as does a timeout.
+type HTTPTransport struct {
+	// Cluster is the name of the cluster of the authorization service.
+	Cluster string
+	// Authority overrides the ":authority" header of check requests. Empty means the
+	// authority of the original request.
+	Authority string
+	// PathPrefix is prepended to the path of check requests.
+	PathPrefix string
+	// Timeout of check requests. Defaults to DefaultTimeout.
+	Timeout time.Duration
+}
+
+// Check implements Transport.
+func (t *HTTPTransport) Check(req *CheckRequest, callback func(*CheckResponse, error)) error {
+	authority := t.Authority
+	if authority == "" {
+		authority = req.Authority
+	}
+	headers := make([][2]string, 0, len(req.Headers)+4)
+	headers = append(headers,
+		[2]string{":method", req.Method},
+		[2]string{":path", t.PathPrefix + req.Path},
+		[2]string{":authority", authority},
+		[2]string{"content-length", strconv.Itoa(len(req.Body))},
+	)
+	headers = append(headers, req.Headers...)
+
+	timeout := t.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	_, err := proxywasm.DispatchHttpCall(t.Cluster, headers, req.Body, nil, uint32(timeout.Milliseconds()),
+		func(numHeaders, bodySize, _ int) {
+			// The host reports timeouts and connection failures as a response without headers.
+			if numHeaders == 0 {
+				callback(nil, ErrFailure)
+				return
+			}
+			headers, err := proxywasm.GetHttpCallResponseHeaders()
+			if err != nil {
+				callback(nil, err)
+				return
+			}
+			res := &CheckResponse{Status: parseStatus(headers)}
+			if res.Status >= 500 {
+				callback(nil, ErrFailure)
+				return
+			}
+			res.Allowed = res.Status == 200
+			for _, h := range headers {
+				if !isPseudoHeader(h[0]) {
+					res.Headers = append(res.Headers, h)
+				}
+			}
+			if bodySize > 0 {
+				if res.Body, err = proxywasm.GetHttpCallResponseBody(0, bodySize); err != nil {
+					callback(nil, err)
+					return
+				}
+			}
+			callback(res, nil)
+		})
+	return err
+}
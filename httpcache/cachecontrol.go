@@ -0,0 +1,71 @@
+package httpcache
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// This file hosts the parser of the Cache-Control header as described in:
+// https://www.rfc-editor.org/rfc/rfc9111#section-5.2
+
+type cacheControl struct {
+	noStore bool
+	noCache bool
+	private bool
+	public  bool
+
+	maxAge     time.Duration
+	hasMaxAge  bool
+	sMaxAge    time.Duration
+	hasSMaxAge bool
+}
+
+func parseCacheControl(value string) cacheControl {
+	var cc cacheControl
+	for _, directive := range strings.Split(value, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		arg = strings.Trim(arg, `"`)
+		switch strings.ToLower(name) {
+		case "no-store":
+			cc.noStore = true
+		case "no-cache":
+			cc.noCache = true
+		case "private":
+			cc.private = true
+		case "public":
+			cc.public = true
+		case "max-age":
+			cc.maxAge, cc.hasMaxAge = parseDeltaSeconds(arg)
+		case "s-maxage":
+			cc.sMaxAge, cc.hasSMaxAge = parseDeltaSeconds(arg)
+		}
+	}
+	return cc
+}
+
+func parseDeltaSeconds(v string) (time.Duration, bool) {
+	n, err := strconv.ParseUint(v, 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(n) * time.Second, true
+}
+
+// freshnessLifetime returns how long a response may be served from a shared cache.
+func freshnessLifetime(cc cacheControl, expires string, now time.Time) (time.Duration, bool) {
+	switch {
+	case cc.hasSMaxAge:
+		return cc.sMaxAge, true
+	case cc.hasMaxAge:
+		return cc.maxAge, true
+	case expires != "":
+		t, err := time.Parse(time.RFC1123, expires)
+		if err != nil {
+			// Invalid dates, like "0", mean the response is already expired.
+			return 0, true
+		}
+		return t.Sub(now), true
+	}
+	return 0, false
+}
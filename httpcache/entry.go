@@ -0,0 +1,81 @@
+package httpcache
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+var errCorruptedEntry = errors.New("httpcache: corrupted entry")
+
+// entry is a cached response. It is stored in the shared data as:
+//
+//	status (4) | stored at (8) | expires (8) | number of headers (4) |
+//	for each header: key length (4) | key | value length (4) | value |
+//	body
+//
+// Integers are little endian and times are unix nanoseconds.
+type entry struct {
+	status   uint32
+	storedAt time.Time
+	expires  time.Time
+	headers  [][2]string
+	body     []byte
+}
+
+func (e *entry) marshal() []byte {
+	size := 24 + len(e.body)
+	for _, h := range e.headers {
+		size += 8 + len(h[0]) + len(h[1])
+	}
+	b := make([]byte, 0, size)
+	b = binary.LittleEndian.AppendUint32(b, e.status)
+	b = binary.LittleEndian.AppendUint64(b, uint64(e.storedAt.UnixNano()))
+	b = binary.LittleEndian.AppendUint64(b, uint64(e.expires.UnixNano()))
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(e.headers)))
+	for _, h := range e.headers {
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(h[0])))
+		b = append(b, h[0]...)
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(h[1])))
+		b = append(b, h[1]...)
+	}
+	return append(b, e.body...)
+}
+
+func unmarshalEntry(b []byte) (*entry, error) {
+	if len(b) < 24 {
+		return nil, errCorruptedEntry
+	}
+	e := &entry{
+		status:   binary.LittleEndian.Uint32(b),
+		storedAt: time.Unix(0, int64(binary.LittleEndian.Uint64(b[4:]))),
+		expires:  time.Unix(0, int64(binary.LittleEndian.Uint64(b[12:]))),
+	}
+	n := binary.LittleEndian.Uint32(b[20:])
+	b = b[24:]
+	readString := func() (string, bool) {
+		if len(b) < 4 {
+			return "", false
+		}
+		l := binary.LittleEndian.Uint32(b)
+		if uint64(len(b)-4) < uint64(l) {
+			return "", false
+		}
+		s := string(b[4 : 4+l])
+		b = b[4+l:]
+		return s, true
+	}
+	for i := uint32(0); i < n; i++ {
+		k, ok := readString()
+		if !ok {
+			return nil, errCorruptedEntry
+		}
+		v, ok := readString()
+		if !ok {
+			return nil, errCorruptedEntry
+		}
+		e.headers = append(e.headers, [2]string{k, v})
+	}
+	e.body = b
+	return e, nil
+}
@@ -0,0 +1,59 @@
+package httpcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEntry(t *testing.T) {
+	e := &entry{
+		status:   200,
+		storedAt: time.Unix(1700000000, 0),
+		expires:  time.Unix(1700000060, 0),
+		headers:  [][2]string{{"content-type", "text/plain"}, {"x-empty", ""}},
+		body:     []byte("hello"),
+	}
+	actual, err := unmarshalEntry(e.marshal())
+	require.NoError(t, err)
+	require.Equal(t, e.status, actual.status)
+	require.True(t, e.storedAt.Equal(actual.storedAt))
+	require.True(t, e.expires.Equal(actual.expires))
+	require.Equal(t, e.headers, actual.headers)
+	require.Equal(t, e.body, actual.body)
+
+	data := e.marshal()
+	for _, size := range []int{0, 10, 24, 30} {
+		_, err := unmarshalEntry(data[:size])
+		require.ErrorIs(t, err, errCorruptedEntry, size)
+	}
+}
+
+func TestParseCacheControl(t *testing.T) {
+	cc := parseCacheControl(`public, max-age=60, s-maxage="120", no-cache`)
+	require.True(t, cc.public)
+	require.True(t, cc.noCache)
+	require.False(t, cc.noStore)
+	require.Equal(t, time.Minute, cc.maxAge)
+	require.Equal(t, 2*time.Minute, cc.sMaxAge)
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		cacheControl string
+		expires      string
+		ttl          time.Duration
+		ok           bool
+	}{
+		{cacheControl: "max-age=60, s-maxage=120", ttl: 2 * time.Minute, ok: true},
+		{cacheControl: "max-age=60", expires: "Mon, 01 Jan 2024 01:00:00 GMT", ttl: time.Minute, ok: true},
+		{expires: "Mon, 01 Jan 2024 01:00:00 GMT", ttl: time.Hour, ok: true},
+		{expires: "0", ok: true},
+		{cacheControl: "max-age=invalid"},
+		{},
+	} {
+		ttl, ok := freshnessLifetime(parseCacheControl(tc.cacheControl), tc.expires, now)
+		require.Equal(t, tc.ok, ok, tc)
+		require.Equal(t, tc.ttl, ttl, tc)
+	}
+}
@@ -0,0 +1,364 @@
+// Package httpcache implements a shared HTTP response cache for plugins, backed by the
+// shared data of the host so that all the VMs with the same "vm_id" share the cache.
+//
+// Only GET and HEAD requests are cached. The cache key is computed from the method,
+// the authority, the path and the request headers listed in Config.KeyHeaders, and
+// the request headers named in the "vary" header of the response. Responses are only
+// stored when they are cacheable for a shared cache according to their Cache-Control
+// and Expires headers, or Config.DefaultTTL. Responses with trailers are not cached.
+//
+// As the shared data does not support deletion, evicted entries are overwritten with
+// an empty value. Entries are evicted in least recently stored or hit order once
+// Config.MaxEntries is reached, based on an index that is itself kept in the shared data.
+//
+// A Cache is usually created in types.PluginContext.OnPluginStart and each
+// types.HttpContext delegates its callbacks to a Stream obtained from Cache.NewStream.
+package httpcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm"
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/types"
+)
+
+const (
+	defaultMaxObjectSize    = 1 << 20
+	defaultMaxEntries       = 1024
+	defaultSharedDataPrefix = "httpcache/"
+	defaultMetricPrefix     = "httpcache"
+)
+
+// Config is the configuration of a Cache.
+type Config struct {
+	// KeyHeaders lists the request headers that are part of the cache key.
+	KeyHeaders []string
+	// MaxObjectSize is the maximum size of a cached body. Defaults to 1 MiB.
+	MaxObjectSize int
+	// MaxEntries is the maximum number of cached responses. Defaults to 1024.
+	MaxEntries int
+	// DefaultTTL is the lifetime of responses without explicit freshness information.
+	// Zero means such responses are not cached.
+	DefaultTTL time.Duration
+	// SharedDataPrefix is the prefix of the shared data keys. Defaults to "httpcache/".
+	SharedDataPrefix string
+	// MetricPrefix is the prefix of the counters "<prefix>.hits", "<prefix>.misses",
+	// "<prefix>.stores" and "<prefix>.evictions". Defaults to "httpcache".
+	MetricPrefix string
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// Cache is a response cache shared by all the streams of a plugin.
+type Cache struct {
+	config Config
+
+	hits      proxywasm.MetricCounter
+	misses    proxywasm.MetricCounter
+	stores    proxywasm.MetricCounter
+	evictions proxywasm.MetricCounter
+}
+
+// New returns a Cache and defines its metrics.
+func New(config Config) *Cache {
+	if config.MaxObjectSize == 0 {
+		config.MaxObjectSize = defaultMaxObjectSize
+	}
+	if config.MaxEntries == 0 {
+		config.MaxEntries = defaultMaxEntries
+	}
+	if config.SharedDataPrefix == "" {
+		config.SharedDataPrefix = defaultSharedDataPrefix
+	}
+	if config.MetricPrefix == "" {
+		config.MetricPrefix = defaultMetricPrefix
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	return &Cache{
+		config:    config,
+		hits:      proxywasm.DefineCounterMetric(config.MetricPrefix + ".hits"),
+		misses:    proxywasm.DefineCounterMetric(config.MetricPrefix + ".misses"),
+		stores:    proxywasm.DefineCounterMetric(config.MetricPrefix + ".stores"),
+		evictions: proxywasm.DefineCounterMetric(config.MetricPrefix + ".evictions"),
+	}
+}
+
+// NewStream returns the per-request state. It is usually called from
+// types.PluginContext.NewHttpContext.
+func (c *Cache) NewStream() *Stream {
+	return &Stream{cache: c}
+}
+
+// Stream serves or captures the response of a single HTTP request.
+type Stream struct {
+	cache *Cache
+
+	// capturing is set on misses of cacheable requests.
+	capturing      bool
+	baseKey        string
+	requestHeaders [][2]string
+	authorized     bool
+
+	// pending is the response being captured, nil if it is not cacheable.
+	pending *entry
+	vary    []string
+}
+
+// OnHttpRequestHeaders must be called from types.HttpContext.OnHttpRequestHeaders.
+func (s *Stream) OnHttpRequestHeaders(int, bool) types.Action {
+	headers, err := proxywasm.GetHttpRequestHeaders()
+	if err != nil {
+		proxywasm.LogErrorf("httpcache: failed to get request headers: %v", err)
+		return types.ActionContinue
+	}
+	method := headerValue(headers, ":method")
+	if method != "GET" && method != "HEAD" {
+		return types.ActionContinue
+	}
+	cc := parseCacheControl(headerValue(headers, "cache-control"))
+	if cc.noStore {
+		return types.ActionContinue
+	}
+
+	c := s.cache
+	s.requestHeaders = headers
+	s.authorized = headerValue(headers, "authorization") != ""
+	s.baseKey = c.baseKey(headers)
+	s.capturing = true
+
+	if !cc.noCache {
+		if e, hash := c.lookup(s.baseKey, headers); e != nil {
+			c.hits.Increment(1)
+			c.touch(hash, false)
+			s.capturing = false
+			s.serve(e)
+			return types.ActionPause
+		}
+	}
+	c.misses.Increment(1)
+	return types.ActionContinue
+}
+
+// OnHttpResponseHeaders must be called from types.HttpContext.OnHttpResponseHeaders.
+func (s *Stream) OnHttpResponseHeaders(_ int, endOfStream bool) types.Action {
+	if !s.capturing {
+		return types.ActionContinue
+	}
+	s.capturing = false
+	headers, err := proxywasm.GetHttpResponseHeaders()
+	if err != nil {
+		proxywasm.LogErrorf("httpcache: failed to get response headers: %v", err)
+		return types.ActionContinue
+	}
+	s.pending, s.vary = s.cache.newEntry(headers, s.authorized)
+	if s.pending != nil && endOfStream {
+		s.store()
+	}
+	return types.ActionContinue
+}
+
+// OnHttpResponseBody must be called from types.HttpContext.OnHttpResponseBody.
+func (s *Stream) OnHttpResponseBody(bodySize int, endOfStream bool) types.Action {
+	if s.pending == nil {
+		return types.ActionContinue
+	}
+	if bodySize > 0 {
+		chunk, err := proxywasm.GetHttpResponseBody(0, bodySize)
+		if err != nil {
+			proxywasm.LogErrorf("httpcache: failed to get response body: %v", err)
+			s.pending = nil
+			return types.ActionContinue
+		}
+		if len(s.pending.body)+len(chunk) > s.cache.config.MaxObjectSize {
+			s.pending = nil
+			return types.ActionContinue
+		}
+		s.pending.body = append(s.pending.body, chunk...)
+	}
+	if endOfStream {
+		s.store()
+	}
+	return types.ActionContinue
+}
+
+func (s *Stream) serve(e *entry) {
+	age := s.cache.config.Now().Sub(e.storedAt) / time.Second
+	headers := make([][2]string, 0, len(e.headers)+1)
+	headers = append(headers, e.headers...)
+	headers = append(headers, [2]string{"age", strconv.FormatInt(int64(age), 10)})
+	if err := proxywasm.SendHttpResponse(e.status, headers, e.body, -1); err != nil {
+		proxywasm.LogErrorf("httpcache: failed to send cached response: %v", err)
+		_ = proxywasm.ResumeHttpRequest()
+	}
+}
+
+func (s *Stream) store() {
+	c := s.cache
+	e := s.pending
+	s.pending = nil
+
+	if err := proxywasm.SetSharedData(c.varyKey(s.baseKey), []byte(strings.Join(s.vary, "\n")), 0); err != nil {
+		proxywasm.LogErrorf("httpcache: failed to store vary record: %v", err)
+		return
+	}
+	hash := c.variantHash(s.baseKey, s.vary, s.requestHeaders)
+	if err := proxywasm.SetSharedData(c.entryKey(hash), e.marshal(), 0); err != nil {
+		proxywasm.LogErrorf("httpcache: failed to store entry: %v", err)
+		return
+	}
+	c.stores.Increment(1)
+	c.touch(hash, true)
+}
+
+// cacheableStatuses are the statuses cacheable by default, as described in:
+// https://www.rfc-editor.org/rfc/rfc9110#section-15.1
+var cacheableStatuses = map[uint32]bool{
+	200: true, 203: true, 204: true, 300: true, 301: true, 308: true,
+	404: true, 405: true, 410: true, 414: true, 501: true,
+}
+
+// hopByHopHeaders are not stored, as well as pseudo headers and content-length.
+var hopByHopHeaders = map[string]bool{
+	"connection": true, "keep-alive": true, "proxy-authenticate": true, "proxy-authorization": true,
+	"te": true, "trailer": true, "transfer-encoding": true, "upgrade": true, "content-length": true,
+}
+
+// newEntry returns the entry to capture for the response headers, or nil if the
+// response is not cacheable. It also returns the names of the headers listed in "vary".
+func (c *Cache) newEntry(headers [][2]string, authorized bool) (*entry, []string) {
+	status, err := strconv.ParseUint(headerValue(headers, ":status"), 10, 32)
+	if err != nil || !cacheableStatuses[uint32(status)] {
+		return nil, nil
+	}
+	cc := parseCacheControl(headerValue(headers, "cache-control"))
+	if cc.noStore || cc.noCache || cc.private {
+		return nil, nil
+	}
+	// Responses to authorized requests are only stored when explicitly allowed.
+	if authorized && !cc.public && !cc.hasSMaxAge {
+		return nil, nil
+	}
+	if headerValue(headers, "set-cookie") != "" {
+		return nil, nil
+	}
+	if cl := headerValue(headers, "content-length"); cl != "" {
+		if n, err := strconv.Atoi(cl); err != nil || n > c.config.MaxObjectSize {
+			return nil, nil
+		}
+	}
+
+	var vary []string
+	for _, h := range headers {
+		if h[0] != "vary" {
+			continue
+		}
+		for _, v := range strings.Split(h[1], ",") {
+			v = strings.ToLower(strings.TrimSpace(v))
+			if v == "*" {
+				return nil, nil
+			}
+			if v != "" {
+				vary = append(vary, v)
+			}
+		}
+	}
+
+	now := c.config.Now()
+	ttl, ok := freshnessLifetime(cc, headerValue(headers, "expires"), now)
+	if !ok {
+		ttl = c.config.DefaultTTL
+	}
+	if ttl <= 0 {
+		return nil, nil
+	}
+
+	e := &entry{status: uint32(status), storedAt: now, expires: now.Add(ttl)}
+	for _, h := range headers {
+		if strings.HasPrefix(h[0], ":") || hopByHopHeaders[h[0]] || h[0] == "age" {
+			continue
+		}
+		e.headers = append(e.headers, h)
+	}
+	return e, vary
+}
+
+// lookup returns the fresh entry for the request headers and its hash, or nil.
+func (c *Cache) lookup(baseKey string, headers [][2]string) (*entry, string) {
+	record, _, err := proxywasm.GetSharedData(c.varyKey(baseKey))
+	if err != nil {
+		if !errors.Is(err, types.ErrorStatusNotFound) {
+			proxywasm.LogErrorf("httpcache: failed to get vary record: %v", err)
+		}
+		return nil, ""
+	}
+	var vary []string
+	if len(record) > 0 {
+		vary = strings.Split(string(record), "\n")
+	}
+	hash := c.variantHash(baseKey, vary, headers)
+	data, _, err := proxywasm.GetSharedData(c.entryKey(hash))
+	if err != nil || len(data) == 0 {
+		// Not found or evicted.
+		return nil, ""
+	}
+	e, err := unmarshalEntry(data)
+	if err != nil {
+		proxywasm.LogErrorf("httpcache: %v", err)
+		return nil, ""
+	}
+	if !c.config.Now().Before(e.expires) {
+		return nil, ""
+	}
+	return e, hash
+}
+
+func (c *Cache) baseKey(headers [][2]string) string {
+	var b strings.Builder
+	for _, name := range []string{":method", ":authority", ":path"} {
+		b.WriteString(headerValue(headers, name))
+		b.WriteByte('\n')
+	}
+	for _, name := range c.config.KeyHeaders {
+		b.WriteString(headerValue(headers, strings.ToLower(name)))
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+func (c *Cache) variantHash(baseKey string, vary []string, headers [][2]string) string {
+	h := sha256.New()
+	h.Write([]byte(baseKey))
+	for _, name := range vary {
+		h.Write([]byte(name))
+		h.Write([]byte{0})
+		h.Write([]byte(headerValue(headers, name)))
+		h.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+func (c *Cache) varyKey(baseKey string) string {
+	sum := sha256.Sum256([]byte(baseKey))
+	return c.config.SharedDataPrefix + "vary/" + hex.EncodeToString(sum[:16])
+}
+
+func (c *Cache) entryKey(hash string) string {
+	return c.config.SharedDataPrefix + "entry/" + hash
+}
+
+// headerValue returns the value of the header, whose name must be lower-cased.
+func headerValue(headers [][2]string, name string) string {
+	for _, h := range headers {
+		if strings.ToLower(h[0]) == name {
+			return h[1]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,224 @@
+package httpcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/proxytest"
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/types"
+	"github.com/stretchr/testify/require"
+)
+
+type testVMContext struct {
+	types.DefaultVMContext
+	config Config
+}
+
+func (v *testVMContext) NewPluginContext(uint32) types.PluginContext {
+	return &testPluginContext{config: v.config}
+}
+
+type testPluginContext struct {
+	types.DefaultPluginContext
+	config Config
+	cache  *Cache
+}
+
+func (p *testPluginContext) OnPluginStart(int) types.OnPluginStartStatus {
+	p.cache = New(p.config)
+	return types.OnPluginStartStatusOK
+}
+
+func (p *testPluginContext) NewHttpContext(uint32) types.HttpContext {
+	return &testHttpContext{cache: p.cache.NewStream()}
+}
+
+type testHttpContext struct {
+	types.DefaultHttpContext
+	cache *Stream
+}
+
+func (h *testHttpContext) OnHttpRequestHeaders(numHeaders int, endOfStream bool) types.Action {
+	return h.cache.OnHttpRequestHeaders(numHeaders, endOfStream)
+}
+
+func (h *testHttpContext) OnHttpResponseHeaders(numHeaders int, endOfStream bool) types.Action {
+	return h.cache.OnHttpResponseHeaders(numHeaders, endOfStream)
+}
+
+func (h *testHttpContext) OnHttpResponseBody(bodySize int, endOfStream bool) types.Action {
+	return h.cache.OnHttpResponseBody(bodySize, endOfStream)
+}
+
+type testHost struct {
+	proxytest.HostEmulator
+	t   *testing.T
+	now time.Time
+}
+
+func newTestHost(t *testing.T, config Config) (*testHost, func()) {
+	h := &testHost{t: t, now: time.Unix(1700000000, 0)}
+	config.Now = func() time.Time { return h.now }
+	opt := proxytest.NewEmulatorOption().WithVMContext(&testVMContext{config: config})
+	host, reset := proxytest.NewHostEmulator(opt)
+	h.HostEmulator = host
+	require.Equal(t, types.OnPluginStartStatusOK, host.StartPlugin())
+	return h, reset
+}
+
+// request sends the request headers and, unless the request is answered from the cache,
+// the response. It returns the local response, which is nil on misses.
+func (h *testHost) request(requestHeaders, responseHeaders [][2]string, body ...string) *proxytest.LocalHttpResponse {
+	id := h.InitializeHttpContext()
+	if h.CallOnRequestHeaders(id, requestHeaders, true) == types.ActionPause {
+		return h.GetSentLocalResponse(id)
+	}
+	h.CallOnResponseHeaders(id, responseHeaders, len(body) == 0)
+	for i, chunk := range body {
+		h.CallOnResponseBody(id, []byte(chunk), i == len(body)-1)
+	}
+	h.CompleteHttpContext(id)
+	return nil
+}
+
+func (h *testHost) counter(name string) uint64 {
+	v, err := h.GetCounterMetric("httpcache." + name)
+	require.NoError(h.t, err)
+	return v
+}
+
+func get(path string, headers ...[2]string) [][2]string {
+	return append([][2]string{{":method", "GET"}, {":authority", "example.com"}, {":path", path}}, headers...)
+}
+
+func ok(headers ...[2]string) [][2]string {
+	return append([][2]string{{":status", "200"}}, headers...)
+}
+
+func TestCache(t *testing.T) {
+	host, reset := newTestHost(t, Config{})
+	defer reset()
+
+	response := ok([2]string{"cache-control", "max-age=60"}, [2]string{"content-type", "text/plain"},
+		[2]string{"content-length", "11"}, [2]string{"connection", "keep-alive"})
+	require.Nil(t, host.request(get("/a"), response, "hello ", "world"))
+	require.Equal(t, uint64(1), host.counter("misses"))
+	require.Equal(t, uint64(1), host.counter("stores"))
+
+	host.now = host.now.Add(10 * time.Second)
+	res := host.request(get("/a"), nil)
+	require.NotNil(t, res)
+	require.Equal(t, uint32(200), res.StatusCode)
+	require.Equal(t, "hello world", string(res.Data))
+	require.Equal(t, [][2]string{
+		{"cache-control", "max-age=60"},
+		{"content-type", "text/plain"},
+		{"age", "10"},
+	}, res.Headers)
+	require.Equal(t, uint64(1), host.counter("hits"))
+
+	// Other paths and authorities are distinct entries.
+	require.Nil(t, host.request(get("/b"), ok()))
+	require.Nil(t, host.request([][2]string{{":method", "GET"}, {":authority", "other.com"}, {":path", "/a"}}, ok()))
+
+	// Requests with no-cache are not served from the cache.
+	require.Nil(t, host.request(get("/a", [2]string{"cache-control", "no-cache"}), response, "fresh"))
+	require.Equal(t, "fresh", string(host.request(get("/a"), nil).Data))
+
+	host.now = host.now.Add(time.Minute)
+	require.Nil(t, host.request(get("/a"), ok()))
+}
+
+func TestCache_vary(t *testing.T) {
+	host, reset := newTestHost(t, Config{KeyHeaders: []string{"X-Tenant"}})
+	defer reset()
+
+	response := ok([2]string{"cache-control", "s-maxage=60"}, [2]string{"vary", "Accept-Encoding"})
+	require.Nil(t, host.request(get("/", [2]string{"accept-encoding", "gzip"}), response, "gzip"))
+	require.Nil(t, host.request(get("/", [2]string{"accept-encoding", "br"}), response, "br"))
+
+	require.Equal(t, "gzip", string(host.request(get("/", [2]string{"accept-encoding", "gzip"}), nil).Data))
+	require.Equal(t, "br", string(host.request(get("/", [2]string{"accept-encoding", "br"}), nil).Data))
+
+	// Key headers are part of the key.
+	require.Nil(t, host.request(get("/", [2]string{"accept-encoding", "gzip"}, [2]string{"x-tenant", "a"}), ok()))
+}
+
+func TestCache_notCacheable(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		request  [][2]string
+		response [][2]string
+	}{
+		{name: "post", request: [][2]string{{":method", "POST"}, {":path", "/"}}, response: ok([2]string{"cache-control", "max-age=60"})},
+		{name: "request no-store", request: get("/", [2]string{"cache-control", "no-store"}), response: ok([2]string{"cache-control", "max-age=60"})},
+		{name: "no freshness", request: get("/"), response: ok()},
+		{name: "expired", request: get("/"), response: ok([2]string{"expires", "0"})},
+		{name: "no-store", request: get("/"), response: ok([2]string{"cache-control", "no-store, max-age=60"})},
+		{name: "no-cache", request: get("/"), response: ok([2]string{"cache-control", "no-cache, max-age=60"})},
+		{name: "private", request: get("/"), response: ok([2]string{"cache-control", "private, max-age=60"})},
+		{name: "status", request: get("/"), response: [][2]string{{":status", "500"}, {"cache-control", "max-age=60"}}},
+		{name: "vary star", request: get("/"), response: ok([2]string{"cache-control", "max-age=60"}, [2]string{"vary", "*"})},
+		{name: "set-cookie", request: get("/"), response: ok([2]string{"cache-control", "max-age=60"}, [2]string{"set-cookie", "a=b"})},
+		{name: "authorization", request: get("/", [2]string{"authorization", "Bearer x"}), response: ok([2]string{"cache-control", "max-age=60"})},
+		{name: "content-length", request: get("/"), response: ok([2]string{"cache-control", "max-age=60"}, [2]string{"content-length", "100"})},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			host, reset := newTestHost(t, Config{MaxObjectSize: 10})
+			defer reset()
+
+			require.Nil(t, host.request(tc.request, tc.response, "body"))
+			require.Nil(t, host.request(tc.request, tc.response, "body"))
+			require.Equal(t, uint64(0), host.counter("stores"))
+		})
+	}
+
+	t.Run("body too large", func(t *testing.T) {
+		host, reset := newTestHost(t, Config{MaxObjectSize: 10})
+		defer reset()
+
+		response := ok([2]string{"cache-control", "max-age=60"})
+		require.Nil(t, host.request(get("/"), response, "0123456", "789", "0"))
+		require.Equal(t, uint64(0), host.counter("stores"))
+	})
+
+	t.Run("authorization with public", func(t *testing.T) {
+		host, reset := newTestHost(t, Config{})
+		defer reset()
+
+		request := get("/", [2]string{"authorization", "Bearer x"})
+		require.Nil(t, host.request(request, ok([2]string{"cache-control", "public, max-age=60"})))
+		require.NotNil(t, host.request(request, nil))
+	})
+
+	t.Run("default ttl", func(t *testing.T) {
+		host, reset := newTestHost(t, Config{DefaultTTL: time.Minute})
+		defer reset()
+
+		require.Nil(t, host.request(get("/"), ok()))
+		require.NotNil(t, host.request(get("/"), nil))
+	})
+}
+
+func TestCache_eviction(t *testing.T) {
+	host, reset := newTestHost(t, Config{MaxEntries: 2})
+	defer reset()
+
+	response := ok([2]string{"cache-control", "max-age=60"})
+	require.Nil(t, host.request(get("/1"), response, "1"))
+	require.Nil(t, host.request(get("/2"), response, "2"))
+	// Hitting /1 makes /2 the least recently used entry.
+	require.NotNil(t, host.request(get("/1"), nil))
+	require.Nil(t, host.request(get("/3"), response, "3"))
+	require.Equal(t, uint64(1), host.counter("evictions"))
+
+	require.Nil(t, host.request(get("/2"), response, "2"))
+	require.Equal(t, uint64(2), host.counter("evictions"))
+	for _, path := range []string{"/2", "/3"} {
+		res := host.request(get(path), nil)
+		require.NotNil(t, res, path)
+		require.Equal(t, path[1:], string(res.Data))
+	}
+	require.Nil(t, host.request(get("/1"), response, "1"))
+	require.Equal(t, uint64(3), host.counter("evictions"))
+}
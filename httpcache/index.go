@@ -0,0 +1,73 @@
+package httpcache
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm"
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/types"
+)
+
+// indexStoreAttempts is the number of attempts to update the index on CAS mismatch
+// after storing an entry. Updates after hits are attempted once, which is what
+// makes the eviction order approximate.
+const indexStoreAttempts = 3
+
+// touch moves the entry hash to the most recently used end of the index and evicts
+// the least recently used entries beyond Config.MaxEntries.
+//
+// The index is a newline separated list of entry hashes, least recently used first.
+func (c *Cache) touch(hash string, stored bool) {
+	attempts := 1
+	if stored {
+		attempts = indexStoreAttempts
+	}
+	key := c.config.SharedDataPrefix + "index"
+	for i := 0; i < attempts; i++ {
+		data, cas, err := proxywasm.GetSharedData(key)
+		if err != nil && !errors.Is(err, types.ErrorStatusNotFound) {
+			proxywasm.LogErrorf("httpcache: failed to get index: %v", err)
+			return
+		}
+
+		var hashes []string
+		if len(data) > 0 {
+			hashes = strings.Split(string(data), "\n")
+		}
+		found := false
+		for j, h := range hashes {
+			if h == hash {
+				hashes = append(hashes[:j], hashes[j+1:]...)
+				found = true
+				break
+			}
+		}
+		if !found && !stored {
+			// The entry has been evicted in the meantime.
+			return
+		}
+		hashes = append(hashes, hash)
+
+		var evicted []string
+		if over := len(hashes) - c.config.MaxEntries; over > 0 {
+			evicted, hashes = hashes[:over], hashes[over:]
+		}
+
+		err = proxywasm.SetSharedData(key, []byte(strings.Join(hashes, "\n")), cas)
+		if errors.Is(err, types.ErrorStatusCasMismatch) {
+			continue
+		}
+		if err != nil {
+			proxywasm.LogErrorf("httpcache: failed to set index: %v", err)
+			return
+		}
+		for _, h := range evicted {
+			if err := proxywasm.SetSharedData(c.entryKey(h), nil, 0); err != nil {
+				proxywasm.LogErrorf("httpcache: failed to evict entry: %v", err)
+				continue
+			}
+			c.evictions.Increment(1)
+		}
+		return
+	}
+}
@@ -0,0 +1,99 @@
+// Package export buffers telemetry, such as access log entries and spans, and posts it
+// in batches to a collector with proxywasm.DispatchHttpCall from types.PluginContext.OnTick.
+package export
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm"
+)
+
+// Request is an export request posted to a collector.
+type Request struct {
+	Cluster string
+	Headers [][2]string
+	Body    []byte
+	Timeout time.Duration
+}
+
+// Queue buffers items until they are exported by Flush. A single export is in flight at a time.
+// Log messages are prefixed with the name of the package, such as "tracing", and count the
+// items with the plural noun, such as "spans".
+type Queue[T any] struct {
+	items     []T
+	exporting bool
+	dropped   uint64
+}
+
+// Add buffers the item, or drops it if maxSize items are already buffered.
+func (q *Queue[T]) Add(item T, maxSize int) {
+	if len(q.items) >= maxSize {
+		q.dropped++
+		return
+	}
+	q.items = append(q.items, item)
+}
+
+// Len returns the number of buffered items.
+func (q *Queue[T]) Len() int {
+	return len(q.items)
+}
+
+// Dropped returns the number of items dropped since they were last logged.
+func (q *Queue[T]) Dropped() uint64 {
+	return q.dropped
+}
+
+// LogDropped logs the number of items dropped since the last call.
+func (q *Queue[T]) LogDropped(name, noun string) {
+	if q.dropped > 0 {
+		proxywasm.LogWarnf("%s: %d %s dropped since the queue is full", name, q.dropped, noun)
+		q.dropped = 0
+	}
+}
+
+// Flush logs the dropped items and posts the request encoding the next batch of at
+// most maxBatchSize items, unless an export is already in flight.
+func (q *Queue[T]) Flush(name, noun string, maxBatchSize int, encode func(batch []T) (*Request, error)) {
+	q.LogDropped(name, noun)
+	if q.exporting || len(q.items) == 0 {
+		return
+	}
+	batch := q.items
+	if len(batch) > maxBatchSize {
+		batch = batch[:maxBatchSize]
+	}
+	req, err := encode(batch)
+	if err == nil {
+		err = q.post(req, fmt.Sprintf("%d %s", len(batch), noun), name)
+	}
+	if err != nil {
+		proxywasm.LogErrorf("%s: failed to export %s: %v", name, noun, err)
+		return
+	}
+	q.exporting = true
+	q.items = append([]T(nil), q.items[len(batch):]...)
+}
+
+// post dispatches the request exporting the items described by what, such as "2 spans".
+func (q *Queue[T]) post(req *Request, what, name string) error {
+	_, err := proxywasm.DispatchHttpCall(req.Cluster, req.Headers, req.Body, nil, uint32(req.Timeout.Milliseconds()),
+		func(numHeaders, _, _ int) {
+			q.exporting = false
+			if numHeaders == 0 {
+				proxywasm.LogWarnf("%s: failed to export %s to %s", name, what, req.Cluster)
+				return
+			}
+			headers, err := proxywasm.GetHttpCallResponseHeaders()
+			if err != nil {
+				return
+			}
+			for _, h := range headers {
+				if h[0] == ":status" && (len(h[1]) != 3 || h[1][0] != '2') {
+					proxywasm.LogWarnf("%s: failed to export %s to %s: status %s", name, what, req.Cluster, h[1])
+				}
+			}
+		})
+	return err
+}
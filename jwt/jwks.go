@@ -0,0 +1,163 @@
+package jwt
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+)
+
+// This file hosts the JSON Web Key Set parser as described in:
+// https://datatracker.ietf.org/doc/html/rfc7517
+
+// Key is a single verification key of a KeySet.
+type Key struct {
+	// ID is the "kid" of the key.
+	ID string
+	// Algorithm is the "alg" of the key. Empty means the key type decides.
+	Algorithm string
+
+	// key is one of *rsa.PublicKey, *ecdsa.PublicKey or []byte.
+	key any
+}
+
+// KeySet is a set of verification keys, usually decoded from a JWKS document.
+type KeySet struct {
+	Keys []Key
+}
+
+// NewHMACKeySet returns a KeySet holding a single HS256 secret.
+func NewHMACKeySet(kid string, secret []byte) *KeySet {
+	return &KeySet{Keys: []Key{{ID: kid, Algorithm: AlgorithmHS256, key: secret}}}
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	// oct
+	K string `json:"k"`
+}
+
+// ParseJWKS decodes a JWKS document. Keys with an unsupported type or curve, and keys
+// whose "use" is not "sig", are skipped. An error is returned if the document is not
+// valid JSON or a supported key cannot be decoded.
+func ParseJWKS(data []byte) (*KeySet, error) {
+	var doc struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("jwt: invalid jwks: %w", err)
+	}
+
+	set := &KeySet{}
+	for _, jwk := range doc.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		var key any
+		var err error
+		switch jwk.Kty {
+		case "RSA":
+			key, err = decodeRSAKey(jwk)
+		case "EC":
+			if jwk.Crv != "P-256" {
+				continue
+			}
+			key, err = decodeECKey(jwk)
+		case "oct":
+			key, err = base64.RawURLEncoding.DecodeString(jwk.K)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("jwt: invalid jwk %q: %w", jwk.Kid, err)
+		}
+		set.Keys = append(set.Keys, Key{ID: jwk.Kid, Algorithm: jwk.Alg, key: key})
+	}
+	return set, nil
+}
+
+func decodeRSAKey(jwk jsonWebKey) (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+	if err != nil {
+		return nil, err
+	}
+	exponent := new(big.Int).SetBytes(e)
+	if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 || exponent.Int64() < 2 {
+		return nil, fmt.Errorf("invalid exponent")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+}
+
+func decodeECKey(jwk jsonWebKey) (*ecdsa.PublicKey, error) {
+	x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+	if err != nil {
+		return nil, err
+	}
+	y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+	if err != nil {
+		return nil, err
+	}
+	if len(x) != 32 || len(y) != 32 {
+		return nil, fmt.Errorf("invalid coordinate length")
+	}
+	// Validate the point through crypto/ecdh since elliptic.Curve.IsOnCurve is deprecated.
+	point := make([]byte, 0, 65)
+	point = append(point, 4)
+	point = append(point, x...)
+	point = append(point, y...)
+	if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+		return nil, err
+	}
+	return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+}
+
+// lookup returns the keys usable for the given kid and alg.
+func (s *KeySet) lookup(kid, alg string) []Key {
+	if s == nil {
+		return nil
+	}
+	var ret []Key
+	for _, k := range s.Keys {
+		if kid != "" && k.ID != kid {
+			continue
+		}
+		if k.Algorithm != "" && k.Algorithm != alg {
+			continue
+		}
+		if !keyMatchesAlgorithm(k.key, alg) {
+			continue
+		}
+		ret = append(ret, k)
+	}
+	return ret
+}
+
+func keyMatchesAlgorithm(key any, alg string) bool {
+	switch key.(type) {
+	case *rsa.PublicKey:
+		return alg == AlgorithmRS256
+	case *ecdsa.PublicKey:
+		return alg == AlgorithmES256
+	case []byte:
+		return alg == AlgorithmHS256
+	}
+	return false
+}
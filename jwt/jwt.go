@@ -0,0 +1,269 @@
+// Package jwt provides JSON Web Token verification for plugins, together with a JWKS
+// provider that fetches signing keys through proxywasm.DispatchHttpCall and caches them
+// in the shared data of the host.
+//
+// Only the standard library is used, so the package compiles for GOOS=wasip1.
+// Supported algorithms are RS256, ES256 and HS256.
+package jwt
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Supported signing algorithms.
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmES256 = "ES256"
+	AlgorithmHS256 = "HS256"
+)
+
+var (
+	// ErrMalformed means the token is not a valid compact JWS.
+	ErrMalformed = errors.New("jwt: malformed token")
+	// ErrUnsupportedAlgorithm means the "alg" header of the token is not supported.
+	ErrUnsupportedAlgorithm = errors.New("jwt: unsupported algorithm")
+	// ErrKeyNotFound means no key in the key set matches the "kid" and "alg" of the token.
+	ErrKeyNotFound = errors.New("jwt: no matching key found")
+	// ErrInvalidSignature means the signature does not verify against any matching key.
+	ErrInvalidSignature = errors.New("jwt: invalid signature")
+	// ErrExpired means the "exp" claim is in the past.
+	ErrExpired = errors.New("jwt: token is expired")
+	// ErrNotYetValid means the "nbf" claim is in the future.
+	ErrNotYetValid = errors.New("jwt: token is not valid yet")
+	// ErrInvalidTimeClaim means the "exp" or "nbf" claim is present but not a number.
+	ErrInvalidTimeClaim = errors.New("jwt: invalid time claim")
+	// ErrInvalidIssuer means the "iss" claim does not match the expected issuer.
+	ErrInvalidIssuer = errors.New("jwt: invalid issuer")
+	// ErrInvalidAudience means the "aud" claim does not contain any of the expected audiences.
+	ErrInvalidAudience = errors.New("jwt: invalid audience")
+)
+
+// Header is the decoded JOSE header of a token.
+type Header struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid,omitempty"`
+	Type      string `json:"typ,omitempty"`
+}
+
+// Claims is the decoded payload of a token. Numbers are kept as json.Number so that
+// integer claims are not rounded when forwarded.
+type Claims map[string]any
+
+// String returns the claim of the given name if it is a string.
+func (c Claims) String(name string) (string, bool) {
+	v, ok := c[name].(string)
+	return v, ok
+}
+
+// Time returns the claim of the given name interpreted as a NumericDate.
+func (c Claims) Time(name string) (time.Time, bool) {
+	n, ok := c[name].(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	sec, frac := math.Modf(f)
+	return time.Unix(int64(sec), int64(frac*1e9)).UTC(), true
+}
+
+// validTime returns the time claim with the name if present, failing if it is not a number.
+func (c Claims) validTime(name string) (time.Time, bool, error) {
+	if _, ok := c[name]; !ok {
+		return time.Time{}, false, nil
+	}
+	t, ok := c.Time(name)
+	if !ok {
+		return time.Time{}, false, fmt.Errorf("%w: %q", ErrInvalidTimeClaim, name)
+	}
+	return t, true, nil
+}
+
+// Audience returns the "aud" claim, which may either be a single string or a list of strings.
+func (c Claims) Audience() []string {
+	switch aud := c["aud"].(type) {
+	case string:
+		return []string{aud}
+	case []any:
+		ret := make([]string, 0, len(aud))
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				ret = append(ret, s)
+			}
+		}
+		return ret
+	}
+	return nil
+}
+
+// Token is a parsed, not yet verified, token.
+type Token struct {
+	Raw    string
+	Header Header
+	Claims Claims
+	// RawClaims is the decoded JSON payload.
+	RawClaims []byte
+
+	signingInput string
+	signature    []byte
+}
+
+// Parse decodes a compact serialized token. The signature is not verified; use Token.Verify.
+func Parse(raw string) (*Token, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+
+	headerBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrMalformed, err)
+	}
+	var header Header
+	if err := json.Unmarshal(headerBytes, &header); err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrMalformed, err)
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("%w: payload: %v", ErrMalformed, err)
+	}
+	claims := Claims{}
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+	if err := dec.Decode(&claims); err != nil {
+		return nil, fmt.Errorf("%w: payload: %v", ErrMalformed, err)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature: %v", ErrMalformed, err)
+	}
+
+	return &Token{
+		Raw:          raw,
+		Header:       header,
+		Claims:       claims,
+		RawClaims:    payload,
+		signingInput: raw[:len(parts[0])+1+len(parts[1])],
+		signature:    signature,
+	}, nil
+}
+
+// Verify checks the signature of the token against the keys in the set. Only keys
+// whose "kid" (when the token has one) and algorithm match the token are tried.
+func (t *Token) Verify(keys *KeySet) error {
+	switch t.Header.Algorithm {
+	case AlgorithmRS256, AlgorithmES256, AlgorithmHS256:
+	default:
+		return fmt.Errorf("%w: %q", ErrUnsupportedAlgorithm, t.Header.Algorithm)
+	}
+
+	candidates := keys.lookup(t.Header.KeyID, t.Header.Algorithm)
+	if len(candidates) == 0 {
+		return ErrKeyNotFound
+	}
+
+	digest := sha256.Sum256([]byte(t.signingInput))
+	for _, k := range candidates {
+		if verifySignature(t.Header.Algorithm, k.key, t.signingInput, digest[:], t.signature) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+func verifySignature(alg string, key any, signingInput string, digest, signature []byte) bool {
+	switch alg {
+	case AlgorithmRS256:
+		pub, ok := key.(*rsa.PublicKey)
+		return ok && rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest, signature) == nil
+	case AlgorithmES256:
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok || len(signature) != 64 {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(pub, digest, r, s)
+	case AlgorithmHS256:
+		secret, ok := key.([]byte)
+		if !ok {
+			return false
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(signingInput))
+		return hmac.Equal(mac.Sum(nil), signature)
+	}
+	return false
+}
+
+// Validator checks the registered claims of a verified token.
+type Validator struct {
+	// Issuer is the expected "iss" claim. Empty means the issuer is not checked.
+	Issuer string
+	// Audiences lists accepted "aud" values. Empty means the audience is not checked.
+	Audiences []string
+	// Leeway is the allowed clock skew when checking "exp" and "nbf".
+	Leeway time.Duration
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// Validate checks the "exp", "nbf", "iss" and "aud" claims.
+func (v *Validator) Validate(c Claims) error {
+	now := time.Now()
+	if v.Now != nil {
+		now = v.Now()
+	}
+
+	exp, hasExp, err := c.validTime("exp")
+	if err != nil {
+		return err
+	}
+	if hasExp && !now.Before(exp.Add(v.Leeway)) {
+		return ErrExpired
+	}
+	nbf, hasNbf, err := c.validTime("nbf")
+	if err != nil {
+		return err
+	}
+	if hasNbf && now.Add(v.Leeway).Before(nbf) {
+		return ErrNotYetValid
+	}
+	if v.Issuer != "" {
+		if iss, _ := c.String("iss"); iss != v.Issuer {
+			return ErrInvalidIssuer
+		}
+	}
+	if len(v.Audiences) > 0 && !containsAny(c.Audience(), v.Audiences) {
+		return ErrInvalidAudience
+	}
+	return nil
+}
+
+func containsAny(have, want []string) bool {
+	for _, h := range have {
+		for _, w := range want {
+			if h == w {
+				return true
+			}
+		}
+	}
+	return false
+}
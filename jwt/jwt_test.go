@@ -0,0 +1,201 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testSigner signs tokens with a generated key and exposes the matching JWK.
+type testSigner struct {
+	kid    string
+	alg    string
+	rsa    *rsa.PrivateKey
+	ec     *ecdsa.PrivateKey
+	secret []byte
+}
+
+func newRSASigner(t *testing.T, kid string) *testSigner {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	return &testSigner{kid: kid, alg: AlgorithmRS256, rsa: key}
+}
+
+func newECSigner(t *testing.T, kid string) *testSigner {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return &testSigner{kid: kid, alg: AlgorithmES256, ec: key}
+}
+
+func (s *testSigner) sign(t *testing.T, claims map[string]any) string {
+	header, err := json.Marshal(Header{Algorithm: s.alg, KeyID: s.kid, Type: "JWT"})
+	require.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(input))
+
+	var signature []byte
+	switch s.alg {
+	case AlgorithmRS256:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, s.rsa, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+	case AlgorithmES256:
+		r, ss, err := ecdsa.Sign(rand.Reader, s.ec, digest[:])
+		require.NoError(t, err)
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		ss.FillBytes(signature[32:])
+	case AlgorithmHS256:
+		mac := hmac.New(sha256.New, s.secret)
+		mac.Write([]byte(input))
+		signature = mac.Sum(nil)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func (s *testSigner) jwk() map[string]string {
+	b64 := base64.RawURLEncoding.EncodeToString
+	switch s.alg {
+	case AlgorithmRS256:
+		return map[string]string{
+			"kty": "RSA", "kid": s.kid, "alg": s.alg, "use": "sig",
+			"n": b64(s.rsa.N.Bytes()),
+			"e": b64([]byte{1, 0, 1}),
+		}
+	case AlgorithmES256:
+		x, y := make([]byte, 32), make([]byte, 32)
+		s.ec.X.FillBytes(x)
+		s.ec.Y.FillBytes(y)
+		return map[string]string{
+			"kty": "EC", "kid": s.kid, "crv": "P-256",
+			"x": b64(x), "y": b64(y),
+		}
+	}
+	return map[string]string{"kty": "oct", "kid": s.kid, "k": b64(s.secret)}
+}
+
+func jwksDocument(t *testing.T, signers ...*testSigner) []byte {
+	keys := make([]map[string]string, 0, len(signers))
+	for _, s := range signers {
+		keys = append(keys, s.jwk())
+	}
+	doc, err := json.Marshal(map[string]any{"keys": keys})
+	require.NoError(t, err)
+	return doc
+}
+
+func TestVerify(t *testing.T) {
+	rsaSigner := newRSASigner(t, "rsa")
+	ecSigner := newECSigner(t, "ec")
+	hmacSigner := &testSigner{kid: "hmac", alg: AlgorithmHS256, secret: []byte("secret")}
+
+	keys, err := ParseJWKS(jwksDocument(t, rsaSigner, ecSigner, hmacSigner))
+	require.NoError(t, err)
+	require.Len(t, keys.Keys, 3)
+
+	for _, s := range []*testSigner{rsaSigner, ecSigner, hmacSigner} {
+		t.Run(s.alg, func(t *testing.T) {
+			token, err := Parse(s.sign(t, map[string]any{"sub": "alice"}))
+			require.NoError(t, err)
+			require.Equal(t, s.alg, token.Header.Algorithm)
+			require.NoError(t, token.Verify(keys))
+
+			sub, ok := token.Claims.String("sub")
+			require.True(t, ok)
+			require.Equal(t, "alice", sub)
+		})
+	}
+
+	t.Run("tampered payload", func(t *testing.T) {
+		raw := rsaSigner.sign(t, map[string]any{"sub": "alice"})
+		other := rsaSigner.sign(t, map[string]any{"sub": "mallory"})
+		parts, otherParts := strings.Split(raw, "."), strings.Split(other, ".")
+		token, err := Parse(parts[0] + "." + otherParts[1] + "." + parts[2])
+		require.NoError(t, err)
+		require.ErrorIs(t, token.Verify(keys), ErrInvalidSignature)
+	})
+
+	t.Run("unknown kid", func(t *testing.T) {
+		token, err := Parse(newECSigner(t, "unknown").sign(t, map[string]any{}))
+		require.NoError(t, err)
+		require.ErrorIs(t, token.Verify(keys), ErrKeyNotFound)
+	})
+
+	t.Run("algorithm confusion", func(t *testing.T) {
+		// An HS256 token must not verify against the RSA key of the same kid.
+		s := &testSigner{kid: "rsa", alg: AlgorithmHS256, secret: rsaSigner.rsa.N.Bytes()}
+		token, err := Parse(s.sign(t, map[string]any{}))
+		require.NoError(t, err)
+		require.ErrorIs(t, token.Verify(keys), ErrKeyNotFound)
+	})
+
+	t.Run("unsupported algorithm", func(t *testing.T) {
+		token, err := Parse(base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + ".e30.")
+		require.NoError(t, err)
+		require.ErrorIs(t, token.Verify(keys), ErrUnsupportedAlgorithm)
+	})
+
+	t.Run("hmac key set", func(t *testing.T) {
+		token, err := Parse(hmacSigner.sign(t, map[string]any{}))
+		require.NoError(t, err)
+		require.NoError(t, token.Verify(NewHMACKeySet("hmac", []byte("secret"))))
+		require.ErrorIs(t, token.Verify(NewHMACKeySet("hmac", []byte("other"))), ErrInvalidSignature)
+	})
+}
+
+func TestParse_malformed(t *testing.T) {
+	for _, raw := range []string{"", "a.b", "a.b.c.d", "!!.e30.", "e30.!!.", "e30.e30.!!", "e30.bm90LWpzb24."} {
+		_, err := Parse(raw)
+		require.ErrorIs(t, err, ErrMalformed, raw)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	v := &Validator{
+		Issuer:    "https://issuer.example.com",
+		Audiences: []string{"api", "web"},
+		Leeway:    30 * time.Second,
+		Now:       func() time.Time { return now },
+	}
+
+	for _, tc := range []struct {
+		name   string
+		claims string
+		err    error
+	}{
+		{name: "valid", claims: `{"iss":"https://issuer.example.com","aud":"api","exp":1700000100,"nbf":1699999900}`},
+		{name: "audience list", claims: `{"iss":"https://issuer.example.com","aud":["other","web"]}`},
+		{name: "expired within leeway", claims: `{"iss":"https://issuer.example.com","aud":"api","exp":1699999990}`},
+		{name: "expired", claims: `{"iss":"https://issuer.example.com","aud":"api","exp":1699999900}`, err: ErrExpired},
+		{name: "not yet valid", claims: `{"iss":"https://issuer.example.com","aud":"api","nbf":1700000100}`, err: ErrNotYetValid},
+		{name: "non-numeric exp", claims: `{"iss":"https://issuer.example.com","aud":"api","exp":"tomorrow"}`, err: ErrInvalidTimeClaim},
+		{name: "non-numeric nbf", claims: `{"iss":"https://issuer.example.com","aud":"api","nbf":null}`, err: ErrInvalidTimeClaim},
+		{name: "wrong issuer", claims: `{"iss":"https://evil.example.com","aud":"api"}`, err: ErrInvalidIssuer},
+		{name: "missing audience", claims: `{"iss":"https://issuer.example.com"}`, err: ErrInvalidAudience},
+		{name: "wrong audience", claims: `{"iss":"https://issuer.example.com","aud":["other"]}`, err: ErrInvalidAudience},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			token, err := Parse("e30." + base64.RawURLEncoding.EncodeToString([]byte(tc.claims)) + ".")
+			require.NoError(t, err)
+			err = v.Validate(token.Claims)
+			if tc.err == nil {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, tc.err)
+			}
+		})
+	}
+}
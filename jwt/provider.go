@@ -0,0 +1,306 @@
+package jwt
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm"
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/types"
+)
+
+const (
+	defaultRefreshInterval = 10 * time.Minute
+	defaultFetchTimeout    = 5 * time.Second
+)
+
+// Provider verifies bearer tokens of HTTP requests against a remote JWKS.
+//
+// The JWKS is fetched from JWKSCluster with proxywasm.DispatchHttpCall whenever
+// Provider.OnTick is called and the cached copy is older than RefreshInterval. The
+// document is cached in the shared data of the host, so all the VMs sharing the same
+// "vm_id" reuse a single fetch. Provider.OnTick should therefore be called from
+// types.PluginContext.OnTick, after the tick period has been set with
+// proxywasm.SetTickPeriodMilliSeconds.
+//
+// Provider.Authenticate and Provider.HandleRequest are only available during
+// types.HttpContext.OnHttpRequestHeaders.
+type Provider struct {
+	// Issuer is the expected "iss" claim. Empty means the issuer is not checked.
+	Issuer string
+	// Audiences lists accepted "aud" values. Empty means the audience is not checked.
+	Audiences []string
+	// Leeway is the allowed clock skew when checking "exp" and "nbf".
+	Leeway time.Duration
+
+	// JWKSCluster is the name of the cluster serving the JWKS.
+	JWKSCluster string
+	// JWKSAuthority is the ":authority" header of the JWKS request.
+	JWKSAuthority string
+	// JWKSPath is the ":path" header of the JWKS request.
+	JWKSPath string
+	// RefreshInterval is the maximum age of the cached JWKS. Defaults to 10 minutes.
+	RefreshInterval time.Duration
+	// FetchTimeout is the timeout of the JWKS request. Defaults to 5 seconds.
+	FetchTimeout time.Duration
+	// SharedDataKey is the key of the cached JWKS in the shared data.
+	// Defaults to "jwks/" + JWKSCluster + JWKSPath.
+	SharedDataKey string
+
+	// ClaimToHeaders maps claim names to request headers that receive the claim
+	// value after successful verification. Non-string claims are JSON encoded.
+	ClaimToHeaders map[string]string
+	// PayloadHeader, if set, is the request header that receives the base64url encoded payload.
+	PayloadHeader string
+	// PayloadProperty, if set, is the property path that receives the JSON payload
+	// with proxywasm.SetProperty. In Envoy, this ends up in the filter state.
+	PayloadProperty []string
+	// StripAuthorization removes the "authorization" header after successful verification.
+	StripAuthorization bool
+
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+
+	fetching bool
+	cas      uint32
+	keys     *KeySet
+}
+
+// Start dispatches the first JWKS fetch. It is usually called from
+// types.PluginContext.OnPluginStart.
+func (p *Provider) Start() error {
+	if p.JWKSCluster == "" {
+		return errors.New("jwt: JWKSCluster must not be empty")
+	}
+	return p.fetch()
+}
+
+// OnTick refreshes the cached JWKS if it is older than RefreshInterval.
+func (p *Provider) OnTick() {
+	fetchedAt, _, _, err := p.loadCache()
+	if err == nil && p.now().Sub(fetchedAt) < p.refreshInterval() {
+		return
+	}
+	if err := p.fetch(); err != nil {
+		proxywasm.LogErrorf("jwt: failed to fetch jwks from %s: %v", p.JWKSCluster, err)
+	}
+}
+
+func (p *Provider) fetch() error {
+	if p.fetching {
+		return nil
+	}
+	headers := [][2]string{
+		{":method", "GET"},
+		{":path", p.JWKSPath},
+		{":authority", p.JWKSAuthority},
+		{"accept", "application/json"},
+	}
+	timeout := p.FetchTimeout
+	if timeout == 0 {
+		timeout = defaultFetchTimeout
+	}
+	if _, err := proxywasm.DispatchHttpCall(p.JWKSCluster, headers, nil, nil,
+		uint32(timeout.Milliseconds()), p.onJWKSResponse); err != nil {
+		return err
+	}
+	p.fetching = true
+	return nil
+}
+
+func (p *Provider) onJWKSResponse(numHeaders, bodySize, numTrailers int) {
+	p.fetching = false
+
+	if status := callResponseStatus(); status != "200" {
+		proxywasm.LogErrorf("jwt: jwks request to %s failed with status %q", p.JWKSCluster, status)
+		return
+	}
+	body, err := proxywasm.GetHttpCallResponseBody(0, bodySize)
+	if err != nil {
+		proxywasm.LogErrorf("jwt: failed to read jwks response body: %v", err)
+		return
+	}
+	if _, err := ParseJWKS(body); err != nil {
+		proxywasm.LogErrorf("jwt: %v", err)
+		return
+	}
+
+	// A corrupted entry is overwritten like a missing one, since it can't be read either way.
+	_, _, cas, err := p.loadCache()
+	if err != nil && !errors.Is(err, types.ErrorStatusNotFound) && !errors.Is(err, errCorruptedCache) {
+		proxywasm.LogErrorf("jwt: failed to read cached jwks: %v", err)
+		return
+	}
+	value := make([]byte, 8, 8+len(body))
+	binary.LittleEndian.PutUint64(value, uint64(p.now().UnixNano()))
+	value = append(value, body...)
+	if err := proxywasm.SetSharedData(p.sharedDataKey(), value, cas); err != nil {
+		// On CAS mismatch another VM has stored a fresher copy in the meantime.
+		if !errors.Is(err, types.ErrorStatusCasMismatch) {
+			proxywasm.LogErrorf("jwt: failed to cache jwks: %v", err)
+		}
+		return
+	}
+	proxywasm.LogDebugf("jwt: jwks refreshed from %s", p.JWKSCluster)
+}
+
+// KeySet returns the cached JWKS. The decoded key set is reused until the shared data changes.
+func (p *Provider) KeySet() (*KeySet, error) {
+	_, doc, cas, err := p.loadCache()
+	if err != nil {
+		return nil, err
+	}
+	if p.keys != nil && p.cas == cas {
+		return p.keys, nil
+	}
+	keys, err := ParseJWKS(doc)
+	if err != nil {
+		return nil, err
+	}
+	p.keys, p.cas = keys, cas
+	return keys, nil
+}
+
+// errCorruptedCache means the shared data entry is too short to hold the fetch time.
+var errCorruptedCache = errors.New("jwt: corrupted jwks cache")
+
+// loadCache returns the fetch time and the JWKS document stored in the shared data.
+func (p *Provider) loadCache() (fetchedAt time.Time, doc []byte, cas uint32, err error) {
+	value, cas, err := proxywasm.GetSharedData(p.sharedDataKey())
+	if err != nil {
+		return time.Time{}, nil, 0, err
+	}
+	if len(value) < 8 {
+		return time.Time{}, nil, cas, errCorruptedCache
+	}
+	return time.Unix(0, int64(binary.LittleEndian.Uint64(value[:8]))), value[8:], cas, nil
+}
+
+// Authenticate verifies the bearer token of the current request and forwards the claims
+// as configured. Only available during types.HttpContext.OnHttpRequestHeaders.
+func (p *Provider) Authenticate() (Claims, error) {
+	authorization, err := proxywasm.GetHttpRequestHeader("authorization")
+	if err != nil {
+		return nil, fmt.Errorf("jwt: missing authorization header")
+	}
+	raw, ok := strings.CutPrefix(authorization, "Bearer ")
+	if !ok {
+		return nil, fmt.Errorf("jwt: authorization header is not a bearer token")
+	}
+
+	token, err := Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, err
+	}
+	keys, err := p.KeySet()
+	if err != nil {
+		return nil, fmt.Errorf("jwt: jwks unavailable: %w", err)
+	}
+	if err := token.Verify(keys); err != nil {
+		return nil, err
+	}
+	v := Validator{Issuer: p.Issuer, Audiences: p.Audiences, Leeway: p.Leeway, Now: p.Now}
+	if err := v.Validate(token.Claims); err != nil {
+		return nil, err
+	}
+
+	if err := p.forward(token); err != nil {
+		return nil, err
+	}
+	return token.Claims, nil
+}
+
+// HandleRequest calls Authenticate and, on failure, sends a 401 response.
+// The returned action should be returned from types.HttpContext.OnHttpRequestHeaders.
+func (p *Provider) HandleRequest() types.Action {
+	if _, err := p.Authenticate(); err != nil {
+		proxywasm.LogDebugf("jwt: request rejected: %v", err)
+		if err := proxywasm.SendHttpResponse(401, [][2]string{
+			{"www-authenticate", `Bearer error="invalid_token"`},
+		}, []byte("Jwt verification fails"), -1); err != nil {
+			proxywasm.LogErrorf("jwt: failed to send local response: %v", err)
+		}
+		return types.ActionPause
+	}
+	return types.ActionContinue
+}
+
+func (p *Provider) forward(token *Token) error {
+	for claim, header := range p.ClaimToHeaders {
+		v, ok := token.Claims[claim]
+		if !ok {
+			continue
+		}
+		var value string
+		switch v := v.(type) {
+		case string:
+			value = v
+		case json.Number:
+			value = v.String()
+		default:
+			b, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			value = string(b)
+		}
+		if err := proxywasm.ReplaceHttpRequestHeader(header, value); err != nil {
+			return fmt.Errorf("jwt: failed to forward claim %q: %w", claim, err)
+		}
+	}
+	if p.PayloadHeader != "" {
+		payload := base64.RawURLEncoding.EncodeToString(token.RawClaims)
+		if err := proxywasm.ReplaceHttpRequestHeader(p.PayloadHeader, payload); err != nil {
+			return fmt.Errorf("jwt: failed to forward payload: %w", err)
+		}
+	}
+	if len(p.PayloadProperty) > 0 {
+		if err := proxywasm.SetProperty(p.PayloadProperty, token.RawClaims); err != nil {
+			return fmt.Errorf("jwt: failed to set payload property: %w", err)
+		}
+	}
+	if p.StripAuthorization {
+		if err := proxywasm.RemoveHttpRequestHeader("authorization"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func callResponseStatus() string {
+	headers, err := proxywasm.GetHttpCallResponseHeaders()
+	if err != nil {
+		return ""
+	}
+	for _, h := range headers {
+		if h[0] == ":status" {
+			return h[1]
+		}
+	}
+	return ""
+}
+
+func (p *Provider) now() time.Time {
+	if p.Now != nil {
+		return p.Now()
+	}
+	return time.Now()
+}
+
+func (p *Provider) refreshInterval() time.Duration {
+	if p.RefreshInterval == 0 {
+		return defaultRefreshInterval
+	}
+	return p.RefreshInterval
+}
+
+func (p *Provider) sharedDataKey() string {
+	if p.SharedDataKey != "" {
+		return p.SharedDataKey
+	}
+	return "jwks/" + p.JWKSCluster + p.JWKSPath
+}
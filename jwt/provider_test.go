@@ -0,0 +1,227 @@
+package jwt
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm"
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/proxytest"
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/types"
+	"github.com/stretchr/testify/require"
+)
+
+type testVMContext struct {
+	types.DefaultVMContext
+	provider *Provider
+}
+
+func (v *testVMContext) NewPluginContext(uint32) types.PluginContext {
+	return &testPluginContext{provider: v.provider}
+}
+
+type testPluginContext struct {
+	types.DefaultPluginContext
+	provider *Provider
+}
+
+func (p *testPluginContext) OnPluginStart(int) types.OnPluginStartStatus {
+	if err := p.provider.Start(); err != nil {
+		return types.OnPluginStartStatusFailed
+	}
+	return types.OnPluginStartStatusOK
+}
+
+func (p *testPluginContext) OnTick() { p.provider.OnTick() }
+
+func (p *testPluginContext) NewHttpContext(uint32) types.HttpContext {
+	return &testHttpContext{provider: p.provider}
+}
+
+type testHttpContext struct {
+	types.DefaultHttpContext
+	provider *Provider
+}
+
+func (h *testHttpContext) OnHttpRequestHeaders(int, bool) types.Action {
+	return h.provider.HandleRequest()
+}
+
+// respondJWKS answers the latest JWKS callout with the given status and body.
+// The emulator keeps the attributes of answered callouts, so the number of callouts
+// dispatched so far is passed in as well.
+func respondJWKS(t *testing.T, host proxytest.HostEmulator, callouts int, status string, body []byte) {
+	attrs := host.GetCalloutAttributesFromContext(proxytest.PluginContextID)
+	require.Len(t, attrs, callouts)
+	latest := attrs[len(attrs)-1]
+	require.Equal(t, "jwks_cluster", latest.Upstream)
+	require.Contains(t, latest.Headers, [2]string{":path", "/.well-known/jwks.json"})
+	host.CallOnHttpCallResponse(latest.CalloutID, [][2]string{{":status", status}}, nil, body)
+}
+
+func TestProvider(t *testing.T) {
+	signer := newRSASigner(t, "key-1")
+	now := time.Unix(1700000000, 0)
+	provider := &Provider{
+		Issuer:          "https://issuer.example.com",
+		Audiences:       []string{"api"},
+		JWKSCluster:     "jwks_cluster",
+		JWKSAuthority:   "issuer.example.com",
+		JWKSPath:        "/.well-known/jwks.json",
+		RefreshInterval: time.Minute,
+		ClaimToHeaders:  map[string]string{"sub": "x-jwt-sub", "exp": "x-jwt-exp"},
+		PayloadHeader:   "x-jwt-payload",
+		PayloadProperty: []string{"jwt_payload"},
+		Now:             func() time.Time { return now },
+	}
+
+	opt := proxytest.NewEmulatorOption().WithVMContext(&testVMContext{provider: provider})
+	host, reset := proxytest.NewHostEmulator(opt)
+	defer reset()
+
+	require.Equal(t, types.OnPluginStartStatusOK, host.StartPlugin())
+
+	t.Run("rejected before the jwks is fetched", func(t *testing.T) {
+		id := host.InitializeHttpContext()
+		action := host.CallOnRequestHeaders(id, [][2]string{
+			{"authorization", "Bearer " + signer.sign(t, map[string]any{"iss": "https://issuer.example.com", "aud": "api"})},
+		}, false)
+		require.Equal(t, types.ActionPause, action)
+		require.Equal(t, uint32(401), host.GetSentLocalResponse(id).StatusCode)
+	})
+
+	respondJWKS(t, host, 1, "200", jwksDocument(t, signer))
+
+	t.Run("valid token", func(t *testing.T) {
+		raw := signer.sign(t, map[string]any{
+			"iss": "https://issuer.example.com", "aud": "api", "sub": "alice", "exp": now.Unix() + 60,
+		})
+		id := host.InitializeHttpContext()
+		action := host.CallOnRequestHeaders(id, [][2]string{{"authorization", "Bearer " + raw}}, false)
+		require.Equal(t, types.ActionContinue, action)
+		require.Nil(t, host.GetSentLocalResponse(id))
+
+		token, err := Parse(raw)
+		require.NoError(t, err)
+		headers := host.GetCurrentRequestHeaders(id)
+		require.Contains(t, headers, [2]string{"x-jwt-sub", "alice"})
+		require.Contains(t, headers, [2]string{"x-jwt-exp", "1700000060"})
+		require.Contains(t, headers, [2]string{"x-jwt-payload", base64.RawURLEncoding.EncodeToString(token.RawClaims)})
+
+		payload, err := host.GetProperty([]string{"jwt_payload"})
+		require.NoError(t, err)
+		require.Equal(t, token.RawClaims, payload)
+	})
+
+	for _, tc := range []struct {
+		name          string
+		authorization string
+	}{
+		{name: "missing header"},
+		{name: "not a bearer token", authorization: "Basic YWxpY2U6c2VjcmV0"},
+		{name: "expired", authorization: "Bearer " + signer.sign(t, map[string]any{
+			"iss": "https://issuer.example.com", "aud": "api", "exp": now.Unix() - 1,
+		})},
+		{name: "wrong audience", authorization: "Bearer " + signer.sign(t, map[string]any{
+			"iss": "https://issuer.example.com", "aud": "other",
+		})},
+		{name: "unknown key", authorization: "Bearer " + newRSASigner(t, "key-2").sign(t, map[string]any{
+			"iss": "https://issuer.example.com", "aud": "api",
+		})},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var headers [][2]string
+			if tc.authorization != "" {
+				headers = append(headers, [2]string{"authorization", tc.authorization})
+			}
+			id := host.InitializeHttpContext()
+			require.Equal(t, types.ActionPause, host.CallOnRequestHeaders(id, headers, false))
+			res := host.GetSentLocalResponse(id)
+			require.NotNil(t, res)
+			require.Equal(t, uint32(401), res.StatusCode)
+			require.Contains(t, res.Headers, [2]string{"www-authenticate", `Bearer error="invalid_token"`})
+		})
+	}
+
+	t.Run("refresh", func(t *testing.T) {
+		// Fresh cache: no fetch.
+		host.Tick()
+		require.Len(t, host.GetCalloutAttributesFromContext(proxytest.PluginContextID), 1)
+
+		// Stale cache: rotate to a new key.
+		now = now.Add(2 * time.Minute)
+		rotated := newECSigner(t, "key-2")
+		host.Tick()
+		respondJWKS(t, host, 2, "200", jwksDocument(t, rotated))
+
+		id := host.InitializeHttpContext()
+		action := host.CallOnRequestHeaders(id, [][2]string{
+			{"authorization", "Bearer " + rotated.sign(t, map[string]any{"iss": "https://issuer.example.com", "aud": "api"})},
+		}, false)
+		require.Equal(t, types.ActionContinue, action)
+
+		// A failed refresh keeps the previously cached keys.
+		now = now.Add(2 * time.Minute)
+		host.Tick()
+		respondJWKS(t, host, 3, "503", nil)
+		require.Contains(t, host.GetErrorLogs(), `jwt: jwks request to jwks_cluster failed with status "503"`)
+
+		id = host.InitializeHttpContext()
+		action = host.CallOnRequestHeaders(id, [][2]string{
+			{"authorization", "Bearer " + rotated.sign(t, map[string]any{"iss": "https://issuer.example.com", "aud": "api"})},
+		}, false)
+		require.Equal(t, types.ActionContinue, action)
+	})
+}
+
+// corruptingVMContext stores a value too short to be a JWKS cache entry on VM start.
+type corruptingVMContext struct {
+	testVMContext
+	key string
+}
+
+func (v *corruptingVMContext) OnVMStart(int) types.OnVMStartStatus {
+	if err := proxywasm.SetSharedData(v.key, []byte{1}, 0); err != nil {
+		return false
+	}
+	return true
+}
+
+func TestProvider_corruptedCache(t *testing.T) {
+	signer := &testSigner{kid: "hmac", alg: AlgorithmHS256, secret: []byte("secret")}
+	provider := &Provider{JWKSCluster: "jwks_cluster", JWKSPath: "/.well-known/jwks.json"}
+
+	vm := &corruptingVMContext{testVMContext: testVMContext{provider: provider}, key: provider.sharedDataKey()}
+	host, reset := proxytest.NewHostEmulator(proxytest.NewEmulatorOption().WithVMContext(vm))
+	defer reset()
+
+	require.Equal(t, types.OnVMStartStatusOK, host.StartVM())
+	require.Equal(t, types.OnPluginStartStatusOK, host.StartPlugin())
+	headers := [][2]string{{"authorization", "Bearer " + signer.sign(t, map[string]any{"sub": "alice"})}}
+	require.Equal(t, types.ActionPause, host.CallOnRequestHeaders(host.InitializeHttpContext(), headers, false))
+
+	// The corrupted entry is overwritten by the fetched JWKS.
+	respondJWKS(t, host, 1, "200", jwksDocument(t, signer))
+	require.Empty(t, host.GetErrorLogs())
+	require.Equal(t, types.ActionContinue, host.CallOnRequestHeaders(host.InitializeHttpContext(), headers, false))
+}
+
+func TestProvider_stripAuthorization(t *testing.T) {
+	signer := &testSigner{kid: "hmac", alg: AlgorithmHS256, secret: []byte("secret")}
+	provider := &Provider{JWKSCluster: "jwks_cluster", JWKSPath: "/.well-known/jwks.json", StripAuthorization: true}
+
+	opt := proxytest.NewEmulatorOption().WithVMContext(&testVMContext{provider: provider})
+	host, reset := proxytest.NewHostEmulator(opt)
+	defer reset()
+
+	require.Equal(t, types.OnPluginStartStatusOK, host.StartPlugin())
+	respondJWKS(t, host, 1, "200", jwksDocument(t, signer))
+
+	id := host.InitializeHttpContext()
+	action := host.CallOnRequestHeaders(id, [][2]string{
+		{"authorization", "Bearer " + signer.sign(t, map[string]any{"sub": "alice"})},
+		{"x-other", "value"},
+	}, false)
+	require.Equal(t, types.ActionContinue, action)
+	require.Equal(t, [][2]string{{"x-other", "value"}}, host.GetCurrentRequestHeaders(id))
+}
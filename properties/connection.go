@@ -1,43 +1,50 @@
 package properties
 
+import "github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm"
+
 // This file hosts helper functions to retrieve connection-related properties as described in:
 // https://www.envoyproxy.io/docs/envoy/latest/intro/arch_overview/advanced/attributes#connection-attributes
 
 var (
-	sourceAddress                  = []string{"source", "address"}
-	sourcePort                     = []string{"source", "port"}
-	destinationAddress             = []string{"destination", "address"}
-	destinationPort                = []string{"destination", "port"}
-	connectionID                   = []string{"connection", "id"}
-	connectionMtls                 = []string{"connection", "mtls"}
-	connectionRequestedServerName  = []string{"connection", "requested_server_name"}
-	connectionTlsVersion           = []string{"connection", "tls_version"}
-	connectionSubjectLocalCert     = []string{"connection", "subject_local_certificate"}
-	connectionSubjectPeerCert      = []string{"connection", "subject_peer_certificate"}
-	connectionDnsSanLocalCert      = []string{"connection", "dns_san_local_certificate"}
-	connectionDnsSanPeerCert       = []string{"connection", "dns_san_peer_certificate"}
-	connectionUriSanLocalCert      = []string{"connection", "uri_san_local_certificate"}
-	connectionUriSanPeerCert       = []string{"connection", "uri_san_peer_certificate"}
-	connectionSha256PeerCertDigest = []string{"connection", "sha256_peer_certificate_digest"}
-	connectionTerminationDetails   = []string{"connection", "termination_details"}
+	sourceAddress                  = proxywasm.NewPropertyPath("source", "address")
+	sourcePort                     = proxywasm.NewPropertyPath("source", "port")
+	destinationAddress             = proxywasm.NewPropertyPath("destination", "address")
+	destinationPort                = proxywasm.NewPropertyPath("destination", "port")
+	connectionID                   = proxywasm.NewPropertyPath("connection", "id")
+	connectionMtls                 = proxywasm.NewPropertyPath("connection", "mtls")
+	connectionRequestedServerName  = proxywasm.NewPropertyPath("connection", "requested_server_name")
+	connectionTlsVersion           = proxywasm.NewPropertyPath("connection", "tls_version")
+	connectionSubjectLocalCert     = proxywasm.NewPropertyPath("connection", "subject_local_certificate")
+	connectionSubjectPeerCert      = proxywasm.NewPropertyPath("connection", "subject_peer_certificate")
+	connectionDnsSanLocalCert      = proxywasm.NewPropertyPath("connection", "dns_san_local_certificate")
+	connectionDnsSanPeerCert       = proxywasm.NewPropertyPath("connection", "dns_san_peer_certificate")
+	connectionUriSanLocalCert      = proxywasm.NewPropertyPath("connection", "uri_san_local_certificate")
+	connectionUriSanPeerCert       = proxywasm.NewPropertyPath("connection", "uri_san_peer_certificate")
+	connectionSha256PeerCertDigest = proxywasm.NewPropertyPath("connection", "sha256_peer_certificate_digest")
+	connectionTerminationDetails   = proxywasm.NewPropertyPath("connection", "termination_details")
+	connectionTransportFailure     = proxywasm.NewPropertyPath("connection", "transport_failure_reason")
 )
 
-// GetDownstreamRemoteAddress returns the remote address of the downstream connection.
+// GetDownstreamRemoteAddress returns the remote address of the downstream connection,
+// i.e. the source.address attribute.
 func GetDownstreamRemoteAddress() (string, error) {
 	return getPropertyString(sourceAddress)
 }
 
-// GetDownstreamRemotePort returns the remote port of the downstream connection.
+// GetDownstreamRemotePort returns the remote port of the downstream connection,
+// i.e. the source.port attribute.
 func GetDownstreamRemotePort() (uint64, error) {
 	return getPropertyUint64(sourcePort)
 }
 
-// GetDownstreamLocalAddress returns the local address of the downstream connection.
+// GetDownstreamLocalAddress returns the local address of the downstream connection,
+// i.e. the destination.address attribute.
 func GetDownstreamLocalAddress() (string, error) {
 	return getPropertyString(destinationAddress)
 }
 
-// GetDownstreamLocalPort returns the local port of the downstream connection.
+// GetDownstreamLocalPort returns the local port of the downstream connection,
+// i.e. the destination.port attribute.
 func GetDownstreamLocalPort() (uint64, error) {
 	return getPropertyUint64(destinationPort)
 }
@@ -48,10 +55,19 @@ func GetDownstreamConnectionID() (uint64, error) {
 }
 
 // IsDownstreamConnectionTls returns true if the downstream connection is TLS.
+//
+// Deprecated: it reads connection.mtls, which is only true when the peer presented a
+// certificate. Use IsDownstreamConnectionMtls, which is named after the attribute.
 func IsDownstreamConnectionTls() (bool, error) {
 	return getPropertyBool(connectionMtls)
 }
 
+// IsDownstreamConnectionMtls returns true if TLS is applied to the downstream connection
+// and the peer certificate is presented.
+func IsDownstreamConnectionMtls() (bool, error) {
+	return getPropertyBool(connectionMtls)
+}
+
 // GetDownstreamRequestedServerName returns the requested server name of the
 // downstream connection.
 func GetDownstreamRequestedServerName() (string, error) {
@@ -110,3 +126,9 @@ func GetDownstreamSha256PeerCertificateDigest() (string, error) {
 func GetDownstreamTerminationDetails() (string, error) {
 	return getPropertyString(connectionTerminationDetails)
 }
+
+// GetDownstreamTransportFailureReason returns the transport failure reason of the downstream
+// connection, e.g. certificate validation failed.
+func GetDownstreamTransportFailureReason() (string, error) {
+	return getPropertyString(connectionTransportFailure)
+}
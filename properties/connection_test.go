@@ -8,7 +8,7 @@ import (
 )
 
 func TestGetDownstreamRemoteAddress(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(sourceAddress, []byte("10.244.0.1:63649"))
+	opt := proxytest.NewEmulatorOption().WithProperty(sourceAddress.Path(), []byte("10.244.0.1:63649"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -19,7 +19,7 @@ func TestGetDownstreamRemoteAddress(t *testing.T) {
 
 func TestGetDownstreamRemotePort(t *testing.T) {
 
-	opt := proxytest.NewEmulatorOption().WithProperty(sourcePort, serializeUint64(63649))
+	opt := proxytest.NewEmulatorOption().WithProperty(sourcePort.Path(), serializeUint64(63649))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -29,7 +29,7 @@ func TestGetDownstreamRemotePort(t *testing.T) {
 }
 
 func TestGetDownstreamLocalAddress(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(destinationAddress, []byte("10.244.0.13:80"))
+	opt := proxytest.NewEmulatorOption().WithProperty(destinationAddress.Path(), []byte("10.244.0.13:80"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -39,7 +39,7 @@ func TestGetDownstreamLocalAddress(t *testing.T) {
 }
 
 func TestGetDownstreamLocalPort(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(destinationPort, serializeUint64(80))
+	opt := proxytest.NewEmulatorOption().WithProperty(destinationPort.Path(), serializeUint64(80))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -49,7 +49,7 @@ func TestGetDownstreamLocalPort(t *testing.T) {
 }
 
 func TestGetDownstreamConnectionID(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(connectionID, serializeUint64(1771))
+	opt := proxytest.NewEmulatorOption().WithProperty(connectionID.Path(), serializeUint64(1771))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -78,7 +78,7 @@ func TestIsDownstreamConnectionTls(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			opt := proxytest.NewEmulatorOption().WithProperty(connectionMtls, serializeBool(tt.input))
+			opt := proxytest.NewEmulatorOption().WithProperty(connectionMtls.Path(), serializeBool(tt.input))
 			_, reset := proxytest.NewHostEmulator(opt)
 			defer reset()
 
@@ -89,7 +89,7 @@ func TestIsDownstreamConnectionTls(t *testing.T) {
 	}
 }
 func TestGetDownstreamRequestedServerName(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(connectionRequestedServerName, []byte("example.com"))
+	opt := proxytest.NewEmulatorOption().WithProperty(connectionRequestedServerName.Path(), []byte("example.com"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -99,7 +99,7 @@ func TestGetDownstreamRequestedServerName(t *testing.T) {
 }
 
 func TestGetDownstreamTlsVersion(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(connectionTlsVersion, []byte("TLSv1.3"))
+	opt := proxytest.NewEmulatorOption().WithProperty(connectionTlsVersion.Path(), []byte("TLSv1.3"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -109,7 +109,7 @@ func TestGetDownstreamTlsVersion(t *testing.T) {
 }
 
 func TestGetDownstreamSubjectLocalCertificate(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(connectionSubjectLocalCert,
+	opt := proxytest.NewEmulatorOption().WithProperty(connectionSubjectLocalCert.Path(),
 		[]byte("CN=example.com,OU=IT,O=example,L=San Francisco,ST=California,C=US"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
@@ -120,7 +120,7 @@ func TestGetDownstreamSubjectLocalCertificate(t *testing.T) {
 }
 
 func TestGetDownstreamSubjectPeerCertificate(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(connectionSubjectPeerCert,
+	opt := proxytest.NewEmulatorOption().WithProperty(connectionSubjectPeerCert.Path(),
 		[]byte("CN=example.com,OU=IT,O=example,L=San Francisco,ST=California,C=US"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
@@ -131,7 +131,7 @@ func TestGetDownstreamSubjectPeerCertificate(t *testing.T) {
 }
 
 func TestGetDownstreamDnsSanLocalCertificate(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(connectionDnsSanLocalCert, []byte("example.com"))
+	opt := proxytest.NewEmulatorOption().WithProperty(connectionDnsSanLocalCert.Path(), []byte("example.com"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -141,7 +141,7 @@ func TestGetDownstreamDnsSanLocalCertificate(t *testing.T) {
 }
 
 func TestGetDownstreamDnsSanPeerCertificate(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(connectionDnsSanPeerCert, []byte("example.com"))
+	opt := proxytest.NewEmulatorOption().WithProperty(connectionDnsSanPeerCert.Path(), []byte("example.com"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -151,7 +151,7 @@ func TestGetDownstreamDnsSanPeerCertificate(t *testing.T) {
 }
 
 func TestGetDownstreamUriSanLocalCertificate(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(connectionUriSanLocalCert, []byte("example.com"))
+	opt := proxytest.NewEmulatorOption().WithProperty(connectionUriSanLocalCert.Path(), []byte("example.com"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -161,7 +161,7 @@ func TestGetDownstreamUriSanLocalCertificate(t *testing.T) {
 }
 
 func TestGetDownstreamUriSanPeerCertificate(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(connectionUriSanPeerCert, []byte("example.com"))
+	opt := proxytest.NewEmulatorOption().WithProperty(connectionUriSanPeerCert.Path(), []byte("example.com"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -171,7 +171,7 @@ func TestGetDownstreamUriSanPeerCertificate(t *testing.T) {
 }
 
 func TestGetDownstreamSha256PeerCertificateDigest(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(connectionSha256PeerCertDigest,
+	opt := proxytest.NewEmulatorOption().WithProperty(connectionSha256PeerCertDigest.Path(),
 		[]byte("b714f3d6f83efc2fddf80b8feda3e3b21b3e27b5"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
@@ -182,7 +182,7 @@ func TestGetDownstreamSha256PeerCertificateDigest(t *testing.T) {
 }
 
 func TestGetDownstreamTerminationDetails(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(connectionTerminationDetails,
+	opt := proxytest.NewEmulatorOption().WithProperty(connectionTerminationDetails.Path(),
 		[]byte("connection closed"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
@@ -191,3 +191,23 @@ func TestGetDownstreamTerminationDetails(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, "connection closed", result)
 }
+
+func TestIsDownstreamConnectionMtls(t *testing.T) {
+	opt := proxytest.NewEmulatorOption().WithProperty(connectionMtls.Path(), serializeBool(true))
+	_, reset := proxytest.NewHostEmulator(opt)
+	defer reset()
+
+	result, err := IsDownstreamConnectionMtls()
+	require.NoError(t, err)
+	require.True(t, result)
+}
+
+func TestGetDownstreamTransportFailureReason(t *testing.T) {
+	opt := proxytest.NewEmulatorOption().WithProperty(connectionTransportFailure.Path(), []byte("TLS_error:|268435581:SSL routines"))
+	_, reset := proxytest.NewHostEmulator(opt)
+	defer reset()
+
+	result, err := GetDownstreamTransportFailureReason()
+	require.NoError(t, err)
+	require.Equal(t, "TLS_error:|268435581:SSL routines", result)
+}
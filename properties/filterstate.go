@@ -0,0 +1,96 @@
+package properties
+
+import (
+	"fmt"
+
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm"
+)
+
+// This file hosts helper functions to publish data to later filters and access logs, and
+// to read the dynamic metadata set by other filters.
+//
+// Filter state entries are set with proxywasm.SetProperty on the single-segment path of
+// the key. Envoy stores them as filter state objects named "wasm.<key>", which other
+// filters and access logs can read, e.g. with %FILTER_STATE(wasm.<key>)%. Keys must not
+// collide with the names of the attributes such as "request" or "metadata".
+//
+// Dynamic metadata entries are read on the {"metadata", "filter_metadata", <namespace>, <key>}
+// path. There are no setters for dynamic metadata since the proxy-wasm ABI has no hostcall
+// writing it: Envoy accepts proxywasm.SetProperty on the path but stores the value as filter
+// state, which the path never reads back. Plugins publish data with the filter state setters
+// instead, which access logs and other filters read as "wasm.<key>".
+//
+// Values are either raw bytes, strings, or google.protobuf.Struct messages encoded in the
+// protobuf wire format, which hosts can expose as typed objects.
+
+// SetFilterState sets the filter state entry of the key to the bytes.
+func SetFilterState(key string, value []byte) error {
+	return proxywasm.SetPropertyPath(filterStatePath(key), value)
+}
+
+// SetFilterStateString sets the filter state entry of the key to the string.
+func SetFilterStateString(key, value string) error {
+	return proxywasm.SetPropertyPath(filterStatePath(key), []byte(value))
+}
+
+// SetFilterStateStruct sets the filter state entry of the key to the protobuf encoding of
+// the struct. Values of the struct must be nil, bool, numbers, string, []any or map[string]any.
+func SetFilterStateStruct(key string, value map[string]any) error {
+	return setPropertyStruct(filterStatePath(key), value)
+}
+
+// GetFilterState returns the filter state entry of the key.
+func GetFilterState(key string) ([]byte, error) {
+	return proxywasm.GetPropertyPath(filterStatePath(key))
+}
+
+// GetFilterStateString returns the filter state entry of the key as a string.
+func GetFilterStateString(key string) (string, error) {
+	return getPropertyString(filterStatePath(key))
+}
+
+// GetFilterStateStruct returns the filter state entry of the key decoded as a
+// google.protobuf.Struct, as set by SetFilterStateStruct.
+func GetFilterStateStruct(key string) (map[string]any, error) {
+	return getPropertyStruct(filterStatePath(key))
+}
+
+// GetDynamicMetadata returns the dynamic metadata entry of the key in the namespace.
+func GetDynamicMetadata(namespace, key string) ([]byte, error) {
+	return proxywasm.GetPropertyPath(dynamicMetadataPath(namespace, key))
+}
+
+// GetDynamicMetadataString returns the dynamic metadata entry of the key in the namespace as a string.
+func GetDynamicMetadataString(namespace, key string) (string, error) {
+	return getPropertyString(dynamicMetadataPath(namespace, key))
+}
+
+// GetDynamicMetadataStruct returns the dynamic metadata entry of the key in the namespace
+// decoded as a google.protobuf.Struct.
+func GetDynamicMetadataStruct(namespace, key string) (map[string]any, error) {
+	return getPropertyStruct(dynamicMetadataPath(namespace, key))
+}
+
+func filterStatePath(key string) proxywasm.PropertyPath {
+	return proxywasm.NewPropertyPath(key)
+}
+
+func dynamicMetadataPath(namespace, key string) proxywasm.PropertyPath {
+	return proxywasm.NewPropertyPath("metadata", "filter_metadata", namespace, key)
+}
+
+func setPropertyStruct(path proxywasm.PropertyPath, value map[string]any) error {
+	bs, err := serializeStruct(value)
+	if err != nil {
+		return fmt.Errorf("invalid struct: %w", err)
+	}
+	return proxywasm.SetPropertyPath(path, bs)
+}
+
+func getPropertyStruct(path proxywasm.PropertyPath) (map[string]any, error) {
+	bs, err := proxywasm.GetPropertyPath(path)
+	if err != nil {
+		return nil, err
+	}
+	return deserializeStruct(bs)
+}
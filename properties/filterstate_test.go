@@ -0,0 +1,70 @@
+package properties
+
+import (
+	"testing"
+
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm"
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/proxytest"
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterState(t *testing.T) {
+	host, reset := proxytest.NewHostEmulator(proxytest.NewEmulatorOption())
+	defer reset()
+
+	require.NoError(t, SetFilterState("raw", []byte{0, 1, 2}))
+	raw, err := GetFilterState("raw")
+	require.NoError(t, err)
+	require.Equal(t, []byte{0, 1, 2}, raw)
+
+	require.NoError(t, SetFilterStateString("tenant", "acme"))
+	tenant, err := GetFilterStateString("tenant")
+	require.NoError(t, err)
+	require.Equal(t, "acme", tenant)
+	data, err := host.GetProperty([]string{"tenant"})
+	require.NoError(t, err)
+	require.Equal(t, []byte("acme"), data)
+
+	require.NoError(t, SetFilterStateStruct("decision", map[string]any{"allowed": true, "rules": []any{"r1"}}))
+	decision, err := GetFilterStateStruct("decision")
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"allowed": true, "rules": []any{"r1"}}, decision)
+
+	_, err = GetFilterStateString("unknown")
+	require.Error(t, err)
+	require.Error(t, SetFilterStateStruct("invalid", map[string]any{"key": make(chan int)}))
+}
+
+func TestDynamicMetadata(t *testing.T) {
+	claims, err := serializeStruct(map[string]any{"sub": "alice", "exp": 1700000000})
+	require.NoError(t, err)
+	opt := proxytest.NewEmulatorOption().
+		WithProperty([]string{"metadata", "filter_metadata", "my.filter", "user"}, []byte("alice")).
+		WithProperty([]string{"metadata", "filter_metadata", "my.filter", "raw"}, []byte{1}).
+		WithProperty([]string{"metadata", "filter_metadata", "my.filter", "claims"}, claims)
+	_, reset := proxytest.NewHostEmulator(opt)
+	defer reset()
+
+	user, err := GetDynamicMetadataString("my.filter", "user")
+	require.NoError(t, err)
+	require.Equal(t, "alice", user)
+
+	raw, err := GetDynamicMetadata("my.filter", "raw")
+	require.NoError(t, err)
+	require.Equal(t, []byte{1}, raw)
+
+	decoded, err := GetDynamicMetadataStruct("my.filter", "claims")
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"sub": "alice", "exp": 1700000000.0}, decoded)
+
+	_, err = GetDynamicMetadataString("other.filter", "user")
+	require.Error(t, err)
+
+	// Envoy keeps writes to the path as filter state, so the emulator rejects them.
+	err = proxywasm.SetProperty([]string{"metadata", "filter_metadata", "my.filter", "user"}, []byte("bob"))
+	require.ErrorIs(t, err, types.ErrorStatusBadArgument)
+	user, err = GetDynamicMetadataString("my.filter", "user")
+	require.NoError(t, err)
+	require.Equal(t, "alice", user)
+}
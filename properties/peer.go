@@ -0,0 +1,374 @@
+package properties
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm"
+)
+
+// This file hosts helper functions to retrieve the Istio peer metadata properties set by the
+// metadata exchange filters. Depending on the Istio version, peers are encoded either as a
+// wasm.common.FlatNode FlatBuffers table, as described in:
+// https://github.com/istio/proxy/blob/master/extensions/common/node_info.fbs
+//
+// or as a google.protobuf.Struct with the same keys as the node metadata, e.g. "NAMESPACE".
+
+var (
+	downstreamPeer = proxywasm.NewPropertyPath("downstream_peer")
+	upstreamPeer   = proxywasm.NewPropertyPath("upstream_peer")
+)
+
+// Field indexes of the wasm.common.FlatNode and wasm.common.KeyVal tables.
+const (
+	flatNodeName         = 0
+	flatNodeNamespace    = 1
+	flatNodeLabels       = 2
+	flatNodeOwner        = 3
+	flatNodeWorkloadName = 4
+	flatNodeIstioVersion = 7
+	flatNodeMeshID       = 8
+	flatNodeClusterID    = 9
+	flatNodeFieldCount   = 11
+
+	flatKeyValKey   = 0
+	flatKeyValValue = 1
+)
+
+// GetDownstreamPeer returns the workload identity of the downstream peer.
+func GetDownstreamPeer() (IstioPeer, error) {
+	return getIstioPeer(downstreamPeer)
+}
+
+// GetUpstreamPeer returns the workload identity of the upstream peer.
+func GetUpstreamPeer() (IstioPeer, error) {
+	return getIstioPeer(upstreamPeer)
+}
+
+// SerializeIstioPeerFlatNode encodes the peer as a wasm.common.FlatNode FlatBuffers table, e.g. to
+// set the downstream_peer property with proxytest.EmulatorOption.WithProperty.
+// ServiceAccount, App and Version are not part of the table.
+func SerializeIstioPeerFlatNode(peer IstioPeer) []byte {
+	b := &flatBuilder{buf: make([]byte, 4)}
+	fields := make([]func() int, flatNodeFieldCount)
+	for i, s := range [flatNodeFieldCount]string{
+		flatNodeName:         peer.Name,
+		flatNodeNamespace:    peer.Namespace,
+		flatNodeOwner:        peer.Owner,
+		flatNodeWorkloadName: peer.WorkloadName,
+		flatNodeIstioVersion: peer.IstioVersion,
+		flatNodeMeshID:       peer.MeshID,
+		flatNodeClusterID:    peer.ClusterID,
+	} {
+		if s != "" {
+			fields[i] = func() int { return b.string(s) }
+		}
+	}
+	if len(peer.Labels) > 0 {
+		keys := make([]string, 0, len(peer.Labels))
+		for k := range peer.Labels {
+			keys = append(keys, k)
+		}
+		// Labels are sorted by key since they are looked up with binary search.
+		sort.Strings(keys)
+		labels := make([]func() int, len(keys))
+		for i, k := range keys {
+			labels[i] = func() int {
+				return b.table([]func() int{
+					flatKeyValKey:   func() int { return b.string(k) },
+					flatKeyValValue: func() int { return b.string(peer.Labels[k]) },
+				})
+			}
+		}
+		fields[flatNodeLabels] = func() int { return b.vector(labels) }
+	}
+	root := b.table(fields)
+	binary.LittleEndian.PutUint32(b.buf, uint32(root))
+	return b.buf
+}
+
+// SerializeIstioPeerStruct encodes the peer as a google.protobuf.Struct with the keys of the
+// node metadata, e.g. to set the upstream_peer property with proxytest.EmulatorOption.WithProperty.
+// App and Version are not part of the struct.
+func SerializeIstioPeerStruct(peer IstioPeer) []byte {
+	m := map[string]any{}
+	for k, v := range map[string]string{
+		"NAME":            peer.Name,
+		"NAMESPACE":       peer.Namespace,
+		"WORKLOAD_NAME":   peer.WorkloadName,
+		"SERVICE_ACCOUNT": peer.ServiceAccount,
+		"OWNER":           peer.Owner,
+		"CLUSTER_ID":      peer.ClusterID,
+		"MESH_ID":         peer.MeshID,
+		"ISTIO_VERSION":   peer.IstioVersion,
+	} {
+		if v != "" {
+			m[k] = v
+		}
+	}
+	if len(peer.Labels) > 0 {
+		labels := make(map[string]any, len(peer.Labels))
+		for k, v := range peer.Labels {
+			labels[k] = v
+		}
+		m["LABELS"] = labels
+	}
+	// Strings and maps of strings are always serializable.
+	bs, _ := serializeStruct(m)
+	return bs
+}
+
+func getIstioPeer(path proxywasm.PropertyPath) (IstioPeer, error) {
+	bs, err := proxywasm.GetPropertyPath(path)
+	if err != nil {
+		return IstioPeer{}, err
+	}
+	peer, err := deserializeIstioPeer(bs)
+	if err != nil {
+		return IstioPeer{}, fmt.Errorf("invalid peer metadata: %w", err)
+	}
+	return peer, nil
+}
+
+// deserializeIstioPeer decodes either encoding of the peer. Structs start with the tag of
+// their first field, 0x0a, while FlatBuffers start with the offset of the root table which
+// is a multiple of 4.
+func deserializeIstioPeer(bs []byte) (IstioPeer, error) {
+	var peer IstioPeer
+	if len(bs) > 0 && bs[0] == 0x0a {
+		m, err := deserializeStruct(bs)
+		if err != nil {
+			return IstioPeer{}, err
+		}
+		str := func(key string) string {
+			s, _ := m[key].(string)
+			return s
+		}
+		peer = IstioPeer{
+			Name:           str("NAME"),
+			Namespace:      str("NAMESPACE"),
+			WorkloadName:   str("WORKLOAD_NAME"),
+			ServiceAccount: str("SERVICE_ACCOUNT"),
+			Owner:          str("OWNER"),
+			ClusterID:      str("CLUSTER_ID"),
+			MeshID:         str("MESH_ID"),
+			IstioVersion:   str("ISTIO_VERSION"),
+		}
+		if labels, ok := m["LABELS"].(map[string]any); ok {
+			peer.Labels = make(map[string]string, len(labels))
+			for k, v := range labels {
+				if s, ok := v.(string); ok {
+					peer.Labels[k] = s
+				}
+			}
+		}
+	} else {
+		r := flatReader(bs)
+		root, err := r.offset(0)
+		if err != nil {
+			return IstioPeer{}, err
+		}
+		for i, s := range [flatNodeFieldCount]*string{
+			flatNodeName:         &peer.Name,
+			flatNodeNamespace:    &peer.Namespace,
+			flatNodeOwner:        &peer.Owner,
+			flatNodeWorkloadName: &peer.WorkloadName,
+			flatNodeIstioVersion: &peer.IstioVersion,
+			flatNodeMeshID:       &peer.MeshID,
+			flatNodeClusterID:    &peer.ClusterID,
+		} {
+			if s == nil {
+				continue
+			}
+			if *s, err = r.stringField(root, i); err != nil {
+				return IstioPeer{}, err
+			}
+		}
+		labels, err := r.vectorField(root, flatNodeLabels)
+		if err != nil {
+			return IstioPeer{}, err
+		}
+		if len(labels) > 0 {
+			peer.Labels = make(map[string]string, len(labels))
+		}
+		for _, label := range labels {
+			k, err := r.stringField(label, flatKeyValKey)
+			if err != nil {
+				return IstioPeer{}, err
+			}
+			v, err := r.stringField(label, flatKeyValValue)
+			if err != nil {
+				return IstioPeer{}, err
+			}
+			peer.Labels[k] = v
+		}
+	}
+	peer.App = firstLabel(peer.Labels, "app", "app.kubernetes.io/name", "service.istio.io/canonical-name")
+	peer.Version = firstLabel(peer.Labels, "version", "app.kubernetes.io/version", "service.istio.io/canonical-revision")
+	return peer, nil
+}
+
+func firstLabel(labels map[string]string, keys ...string) string {
+	for _, k := range keys {
+		if v := labels[k]; v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// flatBuilder writes FlatBuffers front to back: objects referenced by a table or a vector
+// are written after it, so that all offsets point forward as required by the format.
+type flatBuilder struct {
+	buf []byte
+}
+
+func (b *flatBuilder) align() {
+	for len(b.buf)%4 != 0 {
+		b.buf = append(b.buf, 0)
+	}
+}
+
+func (b *flatBuilder) putOffset(at, target int) {
+	binary.LittleEndian.PutUint32(b.buf[at:], uint32(target-at))
+}
+
+// table writes a table whose fields are offsets to the objects written by the functions.
+// Fields without function are absent.
+func (b *flatBuilder) table(fields []func() int) int {
+	present := 0
+	for _, f := range fields {
+		if f != nil {
+			present++
+		}
+	}
+	b.align()
+	vtable := len(b.buf)
+	b.buf = binary.LittleEndian.AppendUint16(b.buf, uint16(4+2*len(fields)))
+	b.buf = binary.LittleEndian.AppendUint16(b.buf, uint16(4+4*present))
+	slot := 4
+	for _, f := range fields {
+		if f == nil {
+			b.buf = binary.LittleEndian.AppendUint16(b.buf, 0)
+			continue
+		}
+		b.buf = binary.LittleEndian.AppendUint16(b.buf, uint16(slot))
+		slot += 4
+	}
+	b.align()
+	table := len(b.buf)
+	b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(int32(table-vtable)))
+	b.buf = append(b.buf, make([]byte, 4*present)...)
+	slot = table + 4
+	for _, f := range fields {
+		if f != nil {
+			b.putOffset(slot, f())
+			slot += 4
+		}
+	}
+	return table
+}
+
+func (b *flatBuilder) vector(items []func() int) int {
+	b.align()
+	vector := len(b.buf)
+	b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(items)))
+	b.buf = append(b.buf, make([]byte, 4*len(items))...)
+	for i, item := range items {
+		b.putOffset(vector+4+4*i, item())
+	}
+	return vector
+}
+
+func (b *flatBuilder) string(s string) int {
+	b.align()
+	pos := len(b.buf)
+	b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(s)))
+	b.buf = append(b.buf, s...)
+	b.buf = append(b.buf, 0)
+	return pos
+}
+
+// flatReader reads FlatBuffers with bounds checks.
+type flatReader []byte
+
+func (r flatReader) uint32(pos int) (uint32, error) {
+	if pos < 0 || pos+4 > len(r) {
+		return 0, fmt.Errorf("flatbuffers offset %d out of range", pos)
+	}
+	return binary.LittleEndian.Uint32(r[pos:]), nil
+}
+
+// offset returns the position of the object referenced by the offset at pos.
+func (r flatReader) offset(pos int) (int, error) {
+	v, err := r.uint32(pos)
+	if err != nil {
+		return 0, err
+	}
+	return pos + int(v), nil
+}
+
+// field returns the position of the field of the table, or -1 if the field is absent.
+func (r flatReader) field(table, field int) (int, error) {
+	soffset, err := r.uint32(table)
+	if err != nil {
+		return 0, err
+	}
+	vtable := table - int(int32(soffset))
+	if vtable < 0 || vtable+4 > len(r) {
+		return 0, fmt.Errorf("flatbuffers vtable %d out of range", vtable)
+	}
+	size := int(binary.LittleEndian.Uint16(r[vtable:]))
+	entry := vtable + 4 + 2*field
+	if entry+2 > vtable+size || entry+2 > len(r) {
+		return -1, nil
+	}
+	if off := int(binary.LittleEndian.Uint16(r[entry:])); off != 0 {
+		return table + off, nil
+	}
+	return -1, nil
+}
+
+func (r flatReader) stringField(table, field int) (string, error) {
+	pos, err := r.field(table, field)
+	if err != nil || pos < 0 {
+		return "", err
+	}
+	if pos, err = r.offset(pos); err != nil {
+		return "", err
+	}
+	n, err := r.uint32(pos)
+	if err != nil {
+		return "", err
+	}
+	if uint64(pos)+4+uint64(n) > uint64(len(r)) {
+		return "", fmt.Errorf("flatbuffers string at %d out of range", pos)
+	}
+	return string(r[pos+4 : pos+4+int(n)]), nil
+}
+
+// vectorField returns the positions of the tables of a vector field.
+func (r flatReader) vectorField(table, field int) ([]int, error) {
+	pos, err := r.field(table, field)
+	if err != nil || pos < 0 {
+		return nil, err
+	}
+	if pos, err = r.offset(pos); err != nil {
+		return nil, err
+	}
+	n, err := r.uint32(pos)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(pos)+4+4*uint64(n) > uint64(len(r)) {
+		return nil, fmt.Errorf("flatbuffers vector at %d out of range", pos)
+	}
+	items := make([]int, n)
+	for i := range items {
+		if items[i], err = r.offset(pos + 4 + 4*i); err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
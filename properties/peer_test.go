@@ -0,0 +1,155 @@
+package properties
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/proxytest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetPeers(t *testing.T) {
+	peer := IstioPeer{
+		Name:         "productpage-v1-6b746f74dc-9stvs",
+		Namespace:    "default",
+		WorkloadName: "productpage-v1",
+		Owner:        "kubernetes://apis/apps/v1/namespaces/default/deployments/productpage-v1",
+		ClusterID:    "Kubernetes",
+		MeshID:       "cluster.local",
+		IstioVersion: "1.22.0",
+		Labels: map[string]string{
+			"app.kubernetes.io/name":    "productpage",
+			"version":                   "v1",
+			"security.istio.io/tlsMode": "istio",
+		},
+	}
+	expected := peer
+	expected.App, expected.Version = "productpage", "v1"
+
+	t.Run("flatbuffers", func(t *testing.T) {
+		opt := proxytest.NewEmulatorOption().WithProperty(downstreamPeer.Path(), SerializeIstioPeerFlatNode(peer))
+		_, reset := proxytest.NewHostEmulator(opt)
+		defer reset()
+
+		actual, err := GetDownstreamPeer()
+		require.NoError(t, err)
+		require.Equal(t, expected, actual)
+
+		_, err = GetUpstreamPeer()
+		require.Error(t, err)
+	})
+
+	t.Run("istio flatbuffers", func(t *testing.T) {
+		bs, err := hex.DecodeString(istioFlatNode)
+		require.NoError(t, err)
+		actual, err := deserializeIstioPeer(bs)
+		require.NoError(t, err)
+		require.Equal(t, IstioPeer{
+			Name:         "ratings-v1-7d9bd8d9c4-fhmlw",
+			Namespace:    "bookinfo",
+			WorkloadName: "ratings-v1",
+			Owner:        "kubernetes://apis/apps/v1/namespaces/bookinfo/deployments/ratings-v1",
+			ClusterID:    "Kubernetes",
+			MeshID:       "cluster.local",
+			IstioVersion: "1.20.3",
+			Labels: map[string]string{
+				"app":                                 "ratings",
+				"pod-template-hash":                   "7d9bd8d9c4",
+				"service.istio.io/canonical-name":     "ratings",
+				"service.istio.io/canonical-revision": "v1",
+				"version":                             "v1",
+			},
+			App:     "ratings",
+			Version: "v1",
+		}, actual)
+	})
+
+	t.Run("struct", func(t *testing.T) {
+		peer := peer
+		peer.ServiceAccount = "bookinfo-productpage"
+		expected := expected
+		expected.ServiceAccount = peer.ServiceAccount
+
+		opt := proxytest.NewEmulatorOption().WithProperty(upstreamPeer.Path(), SerializeIstioPeerStruct(peer))
+		_, reset := proxytest.NewHostEmulator(opt)
+		defer reset()
+
+		actual, err := GetUpstreamPeer()
+		require.NoError(t, err)
+		require.Equal(t, expected, actual)
+	})
+
+	t.Run("minimal", func(t *testing.T) {
+		for _, bs := range [][]byte{
+			SerializeIstioPeerFlatNode(IstioPeer{Namespace: "default"}),
+			SerializeIstioPeerStruct(IstioPeer{Namespace: "default"}),
+		} {
+			actual, err := deserializeIstioPeer(bs)
+			require.NoError(t, err)
+			require.Equal(t, IstioPeer{Namespace: "default"}, actual)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		valid := SerializeIstioPeerFlatNode(peer)
+		for _, bs := range [][]byte{
+			{},
+			{0x08, 0x00},
+			valid[:len(valid)-8],
+			{0xff, 0xff, 0xff, 0x00},
+			{0x0a, 0xff},
+		} {
+			_, err := deserializeIstioPeer(bs)
+			require.Error(t, err, bs)
+		}
+	})
+}
+
+// istioFlatNode is a wasm.common.FlatNode encoded like the metadata exchange filter of Istio
+// does with the C++ FlatBufferBuilder: the strings and labels are created in the order of the
+// node metadata, the labels are sorted with CreateVectorOfSortedTables, and the table is
+// built by FlatNodeBuilder. Unlike SerializeIstioPeerFlatNode, tables share vtables and the
+// platform_metadata, app_containers and instance_ips fields are set.
+const istioFlatNode = "" +
+	"2000000000001a0030002c0028001000" +
+	"240020000c0008001c00180014000400" +
+	"1a0000002c0000003400000038000000" +
+	"3c0000005c0200001401000018020000" +
+	"500000008c000000d4000000e0000000" +
+	"020000002c0200000802000001000000" +
+	"24000000010000003800000005000000" +
+	"80010000ec0000001c0100009c010000" +
+	"5001000007000000726174696e677300" +
+	"0a000000726174696e67732d76310000" +
+	"90feffff1c000000040000000d000000" +
+	"697374696f2d74657374696e67000000" +
+	"0b0000006763705f70726f6a65637400" +
+	"440000006b756265726e657465733a2f" +
+	"2f617069732f617070732f76312f6e61" +
+	"6d657370616365732f626f6f6b696e66" +
+	"6f2f6465706c6f796d656e74732f7261" +
+	"74696e67732d76310000000008000000" +
+	"626f6f6b696e666f000000001b000000" +
+	"726174696e67732d76312d3764396264" +
+	"38643963342d66686d6c77000d000000" +
+	"636c75737465722e6c6f63616c000000" +
+	"50ffffff18000000040000000a000000" +
+	"37643962643864396334000011000000" +
+	"706f642d74656d706c6174652d686173" +
+	"6800000084ffffff1400000004000000" +
+	"07000000726174696e6773001f000000" +
+	"736572766963652e697374696f2e696f" +
+	"2f63616e6f6e6963616c2d6e616d6500" +
+	"c0ffffff100000000400000002000000" +
+	"763100000700000076657273696f6e00" +
+	"e0ffffff140000000400000007000000" +
+	"726174696e6773000300000061707000" +
+	"08000c00040008000800000010000000" +
+	"04000000020000007631000023000000" +
+	"736572766963652e697374696f2e696f" +
+	"2f63616e6f6e6963616c2d7265766973" +
+	"696f6e0006000000312e32302e330000" +
+	"19000000666538303a3a613037353a31" +
+	"3166663a666535653a66316364000000" +
+	"0a00000031302e35322e302e33340000" +
+	"0a0000004b756265726e657465730000"
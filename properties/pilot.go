@@ -1,5 +1,7 @@
 package properties
 
+import "github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm"
+
 // This file hosts helper functions to retrieve node-metadata-related properties as described in:
 // https://www.envoyproxy.io/docs/envoy/latest/intro/arch_overview/advanced/attributes#wasm-attributes
 // https://www.envoyproxy.io/docs/envoy/latest/api-v3/config/core/v3/base.proto#envoy-v3-api-msg-config-core-v3-node
@@ -8,25 +10,25 @@ package properties
 // https://pkg.go.dev/istio.io/istio/pilot/pkg/model
 
 var (
-	nodeMetaAnnotations         = []string{"node", "metadata", "ANNOTATIONS"}
-	nodeMetaAppContainers       = []string{"node", "metadata", "APP_CONTAINERS"}
-	nodeMetaClusterId           = []string{"node", "metadata", "CLUSTER_ID"}
-	nodeMetaEnvoyPrometheusPort = []string{"node", "metadata", "ENVOY_PROMETHEUS_PORT"}
-	nodeMetaEnvoyStatusPort     = []string{"node", "metadata", "ENVOY_STATUS_PORT"}
-	nodeMetaInstanceIps         = []string{"node", "metadata", "INSTANCE_IPS"}
-	nodeMetaInterceptionMode    = []string{"node", "metadata", "INTERCEPTION_MODE"}
-	nodeMetaIstioProxySha       = []string{"node", "metadata", "ISTIO_PROXY_SHA"}
-	nodeMetaIstioVersion        = []string{"node", "metadata", "ISTIO_VERSION"}
-	nodeMetaLabels              = []string{"node", "metadata", "LABELS"}
-	nodeMetaMeshId              = []string{"node", "metadata", "MESH_ID"}
-	nodeMetaName                = []string{"node", "metadata", "NAME"}
-	nodeMetaNamespace           = []string{"node", "metadata", "NAMESPACE"}
-	nodeMetaNodeName            = []string{"node", "metadata", "NODE_NAME"}
-	nodeMetaOwner               = []string{"node", "metadata", "OWNER"}
-	nodeMetaPilotSan            = []string{"node", "metadata", "PILOT_SAN"}
-	nodeMetaPodPorts            = []string{"node", "metadata", "POD_PORTS"}
-	nodeMetaServiceAccount      = []string{"node", "metadata", "SERVICE_ACCOUNT"}
-	nodeMetaWorkloadName        = []string{"node", "metadata", "WORKLOAD_NAME"}
+	nodeMetaAnnotations         = proxywasm.NewPropertyPath("node", "metadata", "ANNOTATIONS")
+	nodeMetaAppContainers       = proxywasm.NewPropertyPath("node", "metadata", "APP_CONTAINERS")
+	nodeMetaClusterId           = proxywasm.NewPropertyPath("node", "metadata", "CLUSTER_ID")
+	nodeMetaEnvoyPrometheusPort = proxywasm.NewPropertyPath("node", "metadata", "ENVOY_PROMETHEUS_PORT")
+	nodeMetaEnvoyStatusPort     = proxywasm.NewPropertyPath("node", "metadata", "ENVOY_STATUS_PORT")
+	nodeMetaInstanceIps         = proxywasm.NewPropertyPath("node", "metadata", "INSTANCE_IPS")
+	nodeMetaInterceptionMode    = proxywasm.NewPropertyPath("node", "metadata", "INTERCEPTION_MODE")
+	nodeMetaIstioProxySha       = proxywasm.NewPropertyPath("node", "metadata", "ISTIO_PROXY_SHA")
+	nodeMetaIstioVersion        = proxywasm.NewPropertyPath("node", "metadata", "ISTIO_VERSION")
+	nodeMetaLabels              = proxywasm.NewPropertyPath("node", "metadata", "LABELS")
+	nodeMetaMeshId              = proxywasm.NewPropertyPath("node", "metadata", "MESH_ID")
+	nodeMetaName                = proxywasm.NewPropertyPath("node", "metadata", "NAME")
+	nodeMetaNamespace           = proxywasm.NewPropertyPath("node", "metadata", "NAMESPACE")
+	nodeMetaNodeName            = proxywasm.NewPropertyPath("node", "metadata", "NODE_NAME")
+	nodeMetaOwner               = proxywasm.NewPropertyPath("node", "metadata", "OWNER")
+	nodeMetaPilotSan            = proxywasm.NewPropertyPath("node", "metadata", "PILOT_SAN")
+	nodeMetaPodPorts            = proxywasm.NewPropertyPath("node", "metadata", "POD_PORTS")
+	nodeMetaServiceAccount      = proxywasm.NewPropertyPath("node", "metadata", "SERVICE_ACCOUNT")
+	nodeMetaWorkloadName        = proxywasm.NewPropertyPath("node", "metadata", "WORKLOAD_NAME")
 )
 
 // GetNodeMetaAnnotations returns the node annotations
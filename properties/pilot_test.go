@@ -46,7 +46,7 @@ func TestGetNodeMetaAnnotations(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			opt := proxytest.NewEmulatorOption().WithProperty(nodeMetaAnnotations, serializeStringMap(tt.input))
+			opt := proxytest.NewEmulatorOption().WithProperty(nodeMetaAnnotations.Path(), serializeStringMap(tt.input))
 			_, reset := proxytest.NewHostEmulator(opt)
 			defer reset()
 
@@ -58,7 +58,7 @@ func TestGetNodeMetaAnnotations(t *testing.T) {
 }
 
 func TestGetNodeMetaAppContainers(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(nodeMetaAppContainers, []byte("metadata"))
+	opt := proxytest.NewEmulatorOption().WithProperty(nodeMetaAppContainers.Path(), []byte("metadata"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -68,7 +68,7 @@ func TestGetNodeMetaAppContainers(t *testing.T) {
 }
 
 func TestGetNodeMetaClusterId(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(nodeMetaClusterId, []byte("Kubernetes"))
+	opt := proxytest.NewEmulatorOption().WithProperty(nodeMetaClusterId.Path(), []byte("Kubernetes"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -78,7 +78,7 @@ func TestGetNodeMetaClusterId(t *testing.T) {
 }
 
 func TestGetNodeMetaEnvoyPrometheusPort(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(nodeMetaEnvoyPrometheusPort, serializeFloat64(15090))
+	opt := proxytest.NewEmulatorOption().WithProperty(nodeMetaEnvoyPrometheusPort.Path(), serializeFloat64(15090))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -88,7 +88,7 @@ func TestGetNodeMetaEnvoyPrometheusPort(t *testing.T) {
 }
 
 func TestGetNodeMetaEnvoyStatusPort(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(nodeMetaEnvoyStatusPort, serializeFloat64(15021))
+	opt := proxytest.NewEmulatorOption().WithProperty(nodeMetaEnvoyStatusPort.Path(), serializeFloat64(15021))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -98,7 +98,7 @@ func TestGetNodeMetaEnvoyStatusPort(t *testing.T) {
 }
 
 func TestGetNodeMetaInstanceIps(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(nodeMetaInstanceIps, []byte("10.244.0.13"))
+	opt := proxytest.NewEmulatorOption().WithProperty(nodeMetaInstanceIps.Path(), []byte("10.244.0.13"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -142,7 +142,7 @@ func TestGetNodeMetaInterceptionMode(t *testing.T) {
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			opt := proxytest.NewEmulatorOption().WithProperty(nodeMetaInterceptionMode, test.propertyValue)
+			opt := proxytest.NewEmulatorOption().WithProperty(nodeMetaInterceptionMode.Path(), test.propertyValue)
 			_, reset := proxytest.NewHostEmulator(opt)
 			defer reset()
 
@@ -159,7 +159,7 @@ func TestGetNodeMetaInterceptionMode(t *testing.T) {
 }
 
 func TestGetNodeMetaIstioProxySha(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(nodeMetaIstioProxySha, []byte("3c27a1b0cf381ca854ccc3a2034e88c206928da2"))
+	opt := proxytest.NewEmulatorOption().WithProperty(nodeMetaIstioProxySha.Path(), []byte("3c27a1b0cf381ca854ccc3a2034e88c206928da2"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -169,7 +169,7 @@ func TestGetNodeMetaIstioProxySha(t *testing.T) {
 }
 
 func TestGetNodeMetaIstioVersion(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(nodeMetaIstioVersion, []byte("1.18.2-tetrate-v0"))
+	opt := proxytest.NewEmulatorOption().WithProperty(nodeMetaIstioVersion.Path(), []byte("1.18.2-tetrate-v0"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -212,7 +212,7 @@ func TestGetNodeMetaLabels(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			opt := proxytest.NewEmulatorOption().WithProperty(nodeMetaLabels, serializeStringMap(tt.input))
+			opt := proxytest.NewEmulatorOption().WithProperty(nodeMetaLabels.Path(), serializeStringMap(tt.input))
 			_, reset := proxytest.NewHostEmulator(opt)
 			defer reset()
 
@@ -224,7 +224,7 @@ func TestGetNodeMetaLabels(t *testing.T) {
 }
 
 func TestGetNodeMetaMeshId(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(nodeMetaMeshId, []byte("cluster.local"))
+	opt := proxytest.NewEmulatorOption().WithProperty(nodeMetaMeshId.Path(), []byte("cluster.local"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -234,7 +234,7 @@ func TestGetNodeMetaMeshId(t *testing.T) {
 }
 
 func TestGetNodeMetaName(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(nodeMetaName, []byte("istio-ingress-67cddc6d57-kk2cr"))
+	opt := proxytest.NewEmulatorOption().WithProperty(nodeMetaName.Path(), []byte("istio-ingress-67cddc6d57-kk2cr"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -244,7 +244,7 @@ func TestGetNodeMetaName(t *testing.T) {
 }
 
 func TestGetNodeMetaNamespace(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(nodeMetaNamespace, []byte("istio-ingress"))
+	opt := proxytest.NewEmulatorOption().WithProperty(nodeMetaNamespace.Path(), []byte("istio-ingress"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -254,7 +254,7 @@ func TestGetNodeMetaNamespace(t *testing.T) {
 }
 
 func TestGetNodeMetaNodeName(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(nodeMetaNodeName, []byte("istio-wasm-control-plane"))
+	opt := proxytest.NewEmulatorOption().WithProperty(nodeMetaNodeName.Path(), []byte("istio-wasm-control-plane"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -264,7 +264,7 @@ func TestGetNodeMetaNodeName(t *testing.T) {
 }
 
 func TestGetNodeMetaOwner(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(nodeMetaOwner, []byte("kubernetes://apis/apps/v1/namespaces/istio-ingress/deployments/istio-ingress"))
+	opt := proxytest.NewEmulatorOption().WithProperty(nodeMetaOwner.Path(), []byte("kubernetes://apis/apps/v1/namespaces/istio-ingress/deployments/istio-ingress"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -293,7 +293,7 @@ func TestGetNodeMetaPilotSan(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			opt := proxytest.NewEmulatorOption().WithProperty(nodeMetaPilotSan, serializeStringSlice(tt.input))
+			opt := proxytest.NewEmulatorOption().WithProperty(nodeMetaPilotSan.Path(), serializeStringSlice(tt.input))
 			_, reset := proxytest.NewHostEmulator(opt)
 			defer reset()
 
@@ -305,7 +305,7 @@ func TestGetNodeMetaPilotSan(t *testing.T) {
 }
 
 func TestGetNodeMetaPodPorts(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(nodeMetaPodPorts, []byte(`[{"name":"http-envoy-prom","containerPort":15090,"protocol":"TCP"}]`))
+	opt := proxytest.NewEmulatorOption().WithProperty(nodeMetaPodPorts.Path(), []byte(`[{"name":"http-envoy-prom","containerPort":15090,"protocol":"TCP"}]`))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -315,7 +315,7 @@ func TestGetNodeMetaPodPorts(t *testing.T) {
 }
 
 func TestGetNodeMetaServiceAccount(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(nodeMetaServiceAccount, []byte("istio-ingress"))
+	opt := proxytest.NewEmulatorOption().WithProperty(nodeMetaServiceAccount.Path(), []byte("istio-ingress"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -325,7 +325,7 @@ func TestGetNodeMetaServiceAccount(t *testing.T) {
 }
 
 func TestGetNodeMetaWorkloadName(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(nodeMetaWorkloadName, []byte("istio-ingress"))
+	opt := proxytest.NewEmulatorOption().WithProperty(nodeMetaWorkloadName.Path(), []byte("istio-ingress"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
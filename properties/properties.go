@@ -3,4 +3,92 @@
 // WARNING: There's absolutely no guarantee that all properties will be available across versions, and the availability is totally
 // dependent of the configuration, so users are highly encouraged to ensure that plugins work as expected when deploying
 // the plugins using these properties.
+//
+// Every getter crosses the ABI, unless proxywasm.EnablePropertyCache is called in which case
+// properties are memoized per stream until SetProperty is called or the stream ends.
+//
+// The following table lists the attribute path each getter reads, following the Envoy attribute reference:
+// https://www.envoyproxy.io/docs/envoy/latest/intro/arch_overview/advanced/attributes
+//
+//	Attribute                                  Getter
+//	---------------------------------------    ---------------------------------------------
+//	request.path                               GetRequestPath
+//	request.url_path                           GetRequestUrlPath
+//	request.host                               GetRequestHost
+//	request.scheme                             GetRequestScheme
+//	request.method                             GetRequestMethod
+//	request.headers                            GetRequestHeaders
+//	request.referer                            GetRequestReferer
+//	request.useragent                          GetRequestUserAgent
+//	request.time                               GetRequestTime
+//	request.id                                 GetRequestId
+//	request.protocol                           GetRequestProtocol
+//	request.query                              GetRequestQuery
+//	request.duration                           GetRequestDuration
+//	request.size                               GetRequestSize
+//	request.total_size                         GetRequestTotalSize
+//	response.code                              GetResponseCode
+//	response.code_details                      GetResponseCodeDetails
+//	response.flags                             GetResponseFlags
+//	response.grpc_status                       GetResponseGrpcStatusCode
+//	response.headers                           GetResponseHeaders
+//	response.trailers                          GetResponseTrailers
+//	response.size                              GetResponseSize
+//	response.total_size                        GetResponseTotalSize
+//	response.backend_latency                   GetResponseBackendLatency
+//	source.address                             GetDownstreamRemoteAddress
+//	source.port                                GetDownstreamRemotePort
+//	destination.address                        GetDownstreamLocalAddress
+//	destination.port                           GetDownstreamLocalPort
+//	connection.id                              GetDownstreamConnectionID
+//	connection.mtls                            IsDownstreamConnectionMtls
+//	connection.requested_server_name           GetDownstreamRequestedServerName
+//	connection.tls_version                     GetDownstreamTlsVersion
+//	connection.subject_local_certificate       GetDownstreamSubjectLocalCertificate
+//	connection.subject_peer_certificate        GetDownstreamSubjectPeerCertificate
+//	connection.dns_san_local_certificate       GetDownstreamDnsSanLocalCertificate
+//	connection.dns_san_peer_certificate        GetDownstreamDnsSanPeerCertificate
+//	connection.uri_san_local_certificate       GetDownstreamUriSanLocalCertificate
+//	connection.uri_san_peer_certificate        GetDownstreamUriSanPeerCertificate
+//	connection.sha256_peer_certificate_digest  GetDownstreamSha256PeerCertificateDigest
+//	connection.transport_failure_reason        GetDownstreamTransportFailureReason
+//	connection.termination_details             GetDownstreamTerminationDetails
+//	upstream.address                           GetUpstreamAddress
+//	upstream.port                              GetUpstreamPort
+//	upstream.tls_version                       GetUpstreamTlsVersion
+//	upstream.subject_local_certificate         GetUpstreamSubjectLocalCertificate
+//	upstream.subject_peer_certificate          GetUpstreamSubjectPeerCertificate
+//	upstream.dns_san_local_certificate         GetUpstreamDnsSanLocalCertificate
+//	upstream.dns_san_peer_certificate          GetUpstreamDnsSanPeerCertificate
+//	upstream.uri_san_local_certificate         GetUpstreamUriSanLocalCertificate
+//	upstream.uri_san_peer_certificate          GetUpstreamUriSanPeerCertificate
+//	upstream.sha256_peer_certificate_digest    GetUpstreamSha256PeerCertificateDigest
+//	upstream.local_address                     GetUpstreamLocalAddress
+//	upstream.transport_failure_reason          GetUpstreamTransportFailureReason
+//	upstream.request_attempt_count             GetUpstreamRequestAttemptCount
+//	upstream.cx_pool_ready_duration            GetUpstreamConnectionPoolReadyDuration
+//	metadata.filter_metadata.<ns>.<key>        GetDynamicMetadata and variants (read-only)
+//	<key> (filter state "wasm.<key>")          GetFilterState, SetFilterState and variants
+//	plugin_name                                GetPluginName
+//	plugin_root_id                             GetPluginRootId
+//	plugin_vm_id                               GetPluginVmId
+//	cluster_name                               GetClusterName
+//	route_name                                 GetRouteName
+//	listener_direction                         GetListenerDirection
+//	node.*                                     GetNode*, GetNodeMeta* and GetNodeProxyConfig*
+//	xds.cluster_name                           GetXdsClusterName
+//	xds.cluster_metadata                       GetClusterFilterMetadata, GetXdsClusterMetadata
+//	xds.route_name                             GetXdsRouteName
+//	xds.route_metadata                         GetRouteFilterMetadata, GetXdsRouteMetadata
+//	xds.upstream_host_metadata                 GetUpstreamHostFilterMetadata, GetXdsUpstreamHostMetadata
+//	xds.listener_metadata                      GetListenerFilterMetadata
+//	xds.filter_chain_name                      GetXdsListenerFilterChainName
+//	downstream_peer                            GetDownstreamPeer
+//	upstream_peer                              GetUpstreamPeer
+//
+// GetRequestInfo and GetResponseInfo read most of the request and response attributes at once,
+// tolerating the ones which are unavailable.
+//
+// The filter_state and upstream_filter_state maps and the whole metadata attribute have no getter
+// since their values are host-specific; use proxywasm.GetProperty for them.
 package properties
@@ -0,0 +1,231 @@
+package properties
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// This file hosts the protobuf wire encoding of google.protobuf.Struct as described in:
+// https://protobuf.dev/reference/protobuf/google.protobuf/#struct
+//
+// Structs are represented as map[string]any whose values are nil, bool, float64, string,
+// []any or map[string]any, the same as encoding/json.
+
+// Field numbers of google.protobuf.Struct, Value and ListValue, and of
+// envoy.config.core.v3.Metadata.
+const (
+	metadataFilterMetadataField = 1
+	structFieldsField           = 1
+	mapEntryKeyField            = 1
+	mapEntryValueField          = 2
+	valueNullField              = 1
+	valueNumberField            = 2
+	valueStringField            = 3
+	valueBoolField              = 4
+	valueStructField            = 5
+	valueListField              = 6
+	listValueValuesField        = 1
+)
+
+// Protobuf wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// serializeStruct encodes the map as a google.protobuf.Struct.
+// Map keys are sorted so that the encoding is deterministic.
+func serializeStruct(m map[string]any) ([]byte, error) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var bs []byte
+	for _, k := range keys {
+		value, err := serializeStructValue(m[k])
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of %q: %w", k, err)
+		}
+		entry := appendProtoBytes(nil, mapEntryKeyField, []byte(k))
+		entry = appendProtoBytes(entry, mapEntryValueField, value)
+		bs = appendProtoBytes(bs, structFieldsField, entry)
+	}
+	return bs, nil
+}
+
+// serializeStructValue encodes the value as a google.protobuf.Value.
+func serializeStructValue(v any) ([]byte, error) {
+	var bs []byte
+	switch v := v.(type) {
+	case nil:
+		bs = appendProtoTag(bs, valueNullField, wireVarint)
+		bs = binary.AppendUvarint(bs, 0)
+	case bool:
+		bs = appendProtoTag(bs, valueBoolField, wireVarint)
+		bs = binary.AppendUvarint(bs, uint64(serializeBool(v)[0]))
+	case string:
+		bs = appendProtoBytes(bs, valueStringField, []byte(v))
+	case float64:
+		bs = appendProtoTag(bs, valueNumberField, wireFixed64)
+		bs = binary.LittleEndian.AppendUint64(bs, math.Float64bits(v))
+	case float32:
+		return serializeStructValue(float64(v))
+	case int:
+		return serializeStructValue(float64(v))
+	case int32:
+		return serializeStructValue(float64(v))
+	case int64:
+		return serializeStructValue(float64(v))
+	case uint32:
+		return serializeStructValue(float64(v))
+	case uint64:
+		return serializeStructValue(float64(v))
+	case map[string]any:
+		s, err := serializeStruct(v)
+		if err != nil {
+			return nil, err
+		}
+		bs = appendProtoBytes(bs, valueStructField, s)
+	case []any:
+		var list []byte
+		for _, item := range v {
+			value, err := serializeStructValue(item)
+			if err != nil {
+				return nil, err
+			}
+			list = appendProtoBytes(list, listValueValuesField, value)
+		}
+		bs = appendProtoBytes(bs, valueListField, list)
+	default:
+		return nil, fmt.Errorf("unsupported type %T", v)
+	}
+	return bs, nil
+}
+
+// deserializeStruct decodes a google.protobuf.Struct.
+func deserializeStruct(bs []byte) (map[string]any, error) {
+	m := map[string]any{}
+	err := walkProtoFields(bs, func(field, _ int, entry []byte) error {
+		if field != structFieldsField {
+			return nil
+		}
+		var key string
+		var value any
+		err := walkProtoFields(entry, func(field, _ int, data []byte) (err error) {
+			switch field {
+			case mapEntryKeyField:
+				key = string(data)
+			case mapEntryValueField:
+				value, err = deserializeStructValue(data)
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		m[key] = value
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// deserializeStructValue decodes a google.protobuf.Value. Values without a kind are nil.
+func deserializeStructValue(bs []byte) (any, error) {
+	var value any
+	err := walkProtoFields(bs, func(field, wireType int, data []byte) (err error) {
+		switch field {
+		case valueNullField:
+			value = nil
+		case valueNumberField:
+			if wireType != wireFixed64 || len(data) != 8 {
+				return fmt.Errorf("invalid protobuf number_value")
+			}
+			value = math.Float64frombits(binary.LittleEndian.Uint64(data))
+		case valueStringField:
+			value = string(data)
+		case valueBoolField:
+			value = len(data) > 0 && data[0] != 0
+		case valueStructField:
+			value, err = deserializeStruct(data)
+		case valueListField:
+			list := []any{}
+			err = walkProtoFields(data, func(field, _ int, data []byte) error {
+				if field != listValueValuesField {
+					return nil
+				}
+				item, err := deserializeStructValue(data)
+				list = append(list, item)
+				return err
+			})
+			value = list
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// walkProtoFields calls fn with the number, wire type and payload of each field of the message.
+// The payload of varint fields is the decoded value in a single byte, which is enough
+// for the enums and booleans of google.protobuf.Value. Fixed-size payloads are given raw.
+func walkProtoFields(bs []byte, fn func(field, wireType int, data []byte) error) error {
+	for len(bs) > 0 {
+		tag, n := binary.Uvarint(bs)
+		if n <= 0 {
+			return fmt.Errorf("invalid protobuf tag")
+		}
+		bs = bs[n:]
+		var data []byte
+		switch tag & 7 {
+		case wireVarint:
+			v, n := binary.Uvarint(bs)
+			if n <= 0 {
+				return fmt.Errorf("invalid protobuf varint")
+			}
+			data, bs = []byte{byte(min(v, 1))}, bs[n:]
+		case wireFixed64:
+			if len(bs) < 8 {
+				return fmt.Errorf("truncated protobuf fixed64")
+			}
+			data, bs = bs[:8], bs[8:]
+		case wireBytes:
+			size, n := binary.Uvarint(bs)
+			if n <= 0 || uint64(len(bs)-n) < size {
+				return fmt.Errorf("truncated protobuf bytes")
+			}
+			data, bs = bs[n:n+int(size)], bs[n+int(size):]
+		case wireFixed32:
+			if len(bs) < 4 {
+				return fmt.Errorf("truncated protobuf fixed32")
+			}
+			data, bs = bs[:4], bs[4:]
+		default:
+			return fmt.Errorf("unsupported protobuf wire type %d", tag&7)
+		}
+		if err := fn(int(tag>>3), int(tag&7), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func appendProtoTag(bs []byte, field, wireType int) []byte {
+	return binary.AppendUvarint(bs, uint64(field)<<3|uint64(wireType))
+}
+
+func appendProtoBytes(bs []byte, field int, data []byte) []byte {
+	bs = appendProtoTag(bs, field, wireBytes)
+	bs = binary.AppendUvarint(bs, uint64(len(data)))
+	return append(bs, data...)
+}
@@ -0,0 +1,70 @@
+package properties
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSerializeStruct(t *testing.T) {
+	input := map[string]any{
+		"string": "value",
+		"number": 1.5,
+		"int":    42,
+		"bool":   true,
+		"null":   nil,
+		"list":   []any{"a", 2.0, false},
+		"nested": map[string]any{"key": "value"},
+	}
+	bs, err := serializeStruct(input)
+	require.NoError(t, err)
+
+	// Struct{fields: {"string": Value{string_value: "value"}}}
+	single, err := serializeStruct(map[string]any{"string": "value"})
+	require.NoError(t, err)
+	require.Equal(t, []byte{
+		0x0a, 0x11,
+		0x0a, 0x06, 's', 't', 'r', 'i', 'n', 'g',
+		0x12, 0x07, 0x1a, 0x05, 'v', 'a', 'l', 'u', 'e',
+	}, single)
+
+	actual, err := deserializeStruct(bs)
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{
+		"string": "value",
+		"number": 1.5,
+		"int":    42.0,
+		"bool":   true,
+		"null":   nil,
+		"list":   []any{"a", 2.0, false},
+		"nested": map[string]any{"key": "value"},
+	}, actual)
+
+	_, err = serializeStruct(map[string]any{"invalid": struct{}{}})
+	require.Error(t, err)
+	_, err = deserializeStruct(single[:len(single)-1])
+	require.Error(t, err)
+}
+
+func TestDeserializeStructValue_invalidNumber(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input []byte
+	}{
+		// Value{number_value} encoded as a varint.
+		{name: "varint", input: []byte{0x10, 0x01}},
+		// Value{number_value} encoded as a fixed32.
+		{name: "fixed32", input: []byte{0x15, 0x00, 0x00, 0x80, 0x3f}},
+		// Value{number_value} truncated to 4 bytes.
+		{name: "truncated", input: []byte{0x11, 0x00, 0x00, 0xf8, 0x3f}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := deserializeStructValue(tc.input)
+			require.Error(t, err)
+		})
+	}
+
+	value, err := deserializeStructValue([]byte{0x11, 0, 0, 0, 0, 0, 0, 0xf8, 0x3f})
+	require.NoError(t, err)
+	require.Equal(t, 1.5, value)
+}
@@ -2,6 +2,7 @@ package properties
 
 import (
 	"fmt"
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm"
 )
 
 // This file hosts helper functions to retrieve node-metadata-related properties as described in:
@@ -12,25 +13,25 @@ import (
 // https://istio.io/latest/docs/reference/config/istio.mesh.v1alpha1/#ProxyConfig
 
 var (
-	nodeMetaProxyConfigBinaryPath                     = []string{"node", "metadata", "PROXY_CONFIG", "binaryPath"}
-	nodeMetaProxyConfigConcurrency                    = []string{"node", "metadata", "PROXY_CONFIG", "concurrency"}
-	nodeMetaProxyConfigConfigPath                     = []string{"node", "metadata", "PROXY_CONFIG", "configPath"}
-	nodeProxyConfigControlPlaneAuthPolicy             = []string{"node", "metadata", "PROXY_CONFIG", "controlPlaneAuthPolicy"}
-	nodeProxyConfigDiscoveryAddress                   = []string{"node", "metadata", "PROXY_CONFIG", "discoveryAddress"}
-	nodeProxyConfigDrainDuration                      = []string{"node", "metadata", "PROXY_CONFIG", "drainDuration"}
-	nodeProxyConfigExtraStatTags                      = []string{"node", "metadata", "PROXY_CONFIG", "extraStatTags"}
-	nodeProxyConfigHoldApplicationUntilProxyStarts    = []string{"node", "metadata", "PROXY_CONFIG", "holdApplicationUntilProxyStarts"}
-	nodeProxyConfigProxyAdminPort                     = []string{"node", "metadata", "PROXY_CONFIG", "proxyAdminPort"}
-	nodeProxyConfigProxyStatsMatcherInclusionPrefixes = []string{"node", "metadata", "PROXY_CONFIG", "proxyStatsMatcher", "inclusionPrefixes"}
-	nodeProxyConfigProxyStatsMatcherInclusionRegexps  = []string{"node", "metadata", "PROXY_CONFIG", "proxyStatsMatcher", "inclusionRegexps"}
-	nodeProxyConfigProxyStatsMatcherInclusionSuffixes = []string{"node", "metadata", "PROXY_CONFIG", "proxyStatsMatcher", "inclusionSuffixes"}
-	nodeProxyConfigServiceCluster                     = []string{"node", "metadata", "PROXY_CONFIG", "serviceCluster"}
-	nodeProxyConfigStatNameLength                     = []string{"node", "metadata", "PROXY_CONFIG", "statNameLength"}
-	nodeProxyConfigStatusPort                         = []string{"node", "metadata", "PROXY_CONFIG", "statusPort"}
-	nodeProxyConfigTerminationDrainDuration           = []string{"node", "metadata", "PROXY_CONFIG", "terminationDrainDuration"}
-	nodeProxyConfigTracingDatadogAddress              = []string{"node", "metadata", "PROXY_CONFIG", "tracing", "datadog", "address"}
-	nodeProxyConfigTracingOpenCensusAgentAddress      = []string{"node", "metadata", "PROXY_CONFIG", "tracing", "opencensusagent", "address"}
-	nodeProxyConfigTracingZipkinAddress               = []string{"node", "metadata", "PROXY_CONFIG", "tracing", "zipkin", "address"}
+	nodeMetaProxyConfigBinaryPath                     = proxywasm.NewPropertyPath("node", "metadata", "PROXY_CONFIG", "binaryPath")
+	nodeMetaProxyConfigConcurrency                    = proxywasm.NewPropertyPath("node", "metadata", "PROXY_CONFIG", "concurrency")
+	nodeMetaProxyConfigConfigPath                     = proxywasm.NewPropertyPath("node", "metadata", "PROXY_CONFIG", "configPath")
+	nodeProxyConfigControlPlaneAuthPolicy             = proxywasm.NewPropertyPath("node", "metadata", "PROXY_CONFIG", "controlPlaneAuthPolicy")
+	nodeProxyConfigDiscoveryAddress                   = proxywasm.NewPropertyPath("node", "metadata", "PROXY_CONFIG", "discoveryAddress")
+	nodeProxyConfigDrainDuration                      = proxywasm.NewPropertyPath("node", "metadata", "PROXY_CONFIG", "drainDuration")
+	nodeProxyConfigExtraStatTags                      = proxywasm.NewPropertyPath("node", "metadata", "PROXY_CONFIG", "extraStatTags")
+	nodeProxyConfigHoldApplicationUntilProxyStarts    = proxywasm.NewPropertyPath("node", "metadata", "PROXY_CONFIG", "holdApplicationUntilProxyStarts")
+	nodeProxyConfigProxyAdminPort                     = proxywasm.NewPropertyPath("node", "metadata", "PROXY_CONFIG", "proxyAdminPort")
+	nodeProxyConfigProxyStatsMatcherInclusionPrefixes = proxywasm.NewPropertyPath("node", "metadata", "PROXY_CONFIG", "proxyStatsMatcher", "inclusionPrefixes")
+	nodeProxyConfigProxyStatsMatcherInclusionRegexps  = proxywasm.NewPropertyPath("node", "metadata", "PROXY_CONFIG", "proxyStatsMatcher", "inclusionRegexps")
+	nodeProxyConfigProxyStatsMatcherInclusionSuffixes = proxywasm.NewPropertyPath("node", "metadata", "PROXY_CONFIG", "proxyStatsMatcher", "inclusionSuffixes")
+	nodeProxyConfigServiceCluster                     = proxywasm.NewPropertyPath("node", "metadata", "PROXY_CONFIG", "serviceCluster")
+	nodeProxyConfigStatNameLength                     = proxywasm.NewPropertyPath("node", "metadata", "PROXY_CONFIG", "statNameLength")
+	nodeProxyConfigStatusPort                         = proxywasm.NewPropertyPath("node", "metadata", "PROXY_CONFIG", "statusPort")
+	nodeProxyConfigTerminationDrainDuration           = proxywasm.NewPropertyPath("node", "metadata", "PROXY_CONFIG", "terminationDrainDuration")
+	nodeProxyConfigTracingDatadogAddress              = proxywasm.NewPropertyPath("node", "metadata", "PROXY_CONFIG", "tracing", "datadog", "address")
+	nodeProxyConfigTracingOpenCensusAgentAddress      = proxywasm.NewPropertyPath("node", "metadata", "PROXY_CONFIG", "tracing", "opencensusagent", "address")
+	nodeProxyConfigTracingZipkinAddress               = proxywasm.NewPropertyPath("node", "metadata", "PROXY_CONFIG", "tracing", "zipkin", "address")
 )
 
 // GetNodeMetaProxyConfigBinaryPath returns the path to the proxy binary
@@ -8,7 +8,7 @@ import (
 )
 
 func TestGetNodeMetaProxyConfigBinaryPath(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(nodeMetaProxyConfigBinaryPath, []byte("/usr/local/bin/envoy"))
+	opt := proxytest.NewEmulatorOption().WithProperty(nodeMetaProxyConfigBinaryPath.Path(), []byte("/usr/local/bin/envoy"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -18,7 +18,7 @@ func TestGetNodeMetaProxyConfigBinaryPath(t *testing.T) {
 }
 
 func TestGetNodeMetaProxyConfigConcurrency(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(nodeMetaProxyConfigConcurrency, serializeFloat64(4))
+	opt := proxytest.NewEmulatorOption().WithProperty(nodeMetaProxyConfigConcurrency.Path(), serializeFloat64(4))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -28,7 +28,7 @@ func TestGetNodeMetaProxyConfigConcurrency(t *testing.T) {
 }
 
 func TestGetNodeMetaProxyConfigConfigPath(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(nodeMetaProxyConfigConfigPath, []byte("./etc/istio/proxy"))
+	opt := proxytest.NewEmulatorOption().WithProperty(nodeMetaProxyConfigConfigPath.Path(), []byte("./etc/istio/proxy"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -38,7 +38,7 @@ func TestGetNodeMetaProxyConfigConfigPath(t *testing.T) {
 }
 
 func TestGetNodeProxyConfigControlPlaneAuthPolicy(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(nodeProxyConfigControlPlaneAuthPolicy, []byte("MUTUAL_TLS"))
+	opt := proxytest.NewEmulatorOption().WithProperty(nodeProxyConfigControlPlaneAuthPolicy.Path(), []byte("MUTUAL_TLS"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -48,7 +48,7 @@ func TestGetNodeProxyConfigControlPlaneAuthPolicy(t *testing.T) {
 }
 
 func TestGetNodeProxyConfigDiscoveryAddress(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(nodeProxyConfigDiscoveryAddress, []byte("istiod.istio-system.svc:15012"))
+	opt := proxytest.NewEmulatorOption().WithProperty(nodeProxyConfigDiscoveryAddress.Path(), []byte("istiod.istio-system.svc:15012"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -58,7 +58,7 @@ func TestGetNodeProxyConfigDiscoveryAddress(t *testing.T) {
 }
 
 func TestGetNodeProxyConfigDrainDuration(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(nodeProxyConfigDrainDuration, []byte("45s"))
+	opt := proxytest.NewEmulatorOption().WithProperty(nodeProxyConfigDrainDuration.Path(), []byte("45s"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -68,7 +68,7 @@ func TestGetNodeProxyConfigDrainDuration(t *testing.T) {
 }
 
 func TestGetNodeProxyConfigExtraStatTags(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(nodeProxyConfigExtraStatTags, serializeStringSlice([]string{"tag1", "tag2", "tag3"}))
+	opt := proxytest.NewEmulatorOption().WithProperty(nodeProxyConfigExtraStatTags.Path(), serializeStringSlice([]string{"tag1", "tag2", "tag3"}))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -97,7 +97,7 @@ func TestGetNodeProxyConfigHoldApplicationUntilProxyStarts(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			opt := proxytest.NewEmulatorOption().WithProperty(nodeProxyConfigHoldApplicationUntilProxyStarts, serializeBool(tt.input))
+			opt := proxytest.NewEmulatorOption().WithProperty(nodeProxyConfigHoldApplicationUntilProxyStarts.Path(), serializeBool(tt.input))
 			_, reset := proxytest.NewHostEmulator(opt)
 			defer reset()
 
@@ -109,7 +109,7 @@ func TestGetNodeProxyConfigHoldApplicationUntilProxyStarts(t *testing.T) {
 }
 
 func TestGetNodeProxyConfigProxyAdminPort(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(nodeProxyConfigProxyAdminPort, serializeFloat64(15000))
+	opt := proxytest.NewEmulatorOption().WithProperty(nodeProxyConfigProxyAdminPort.Path(), serializeFloat64(15000))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -129,9 +129,9 @@ func TestGetNodeProxyConfigProxyStatsMatcher(t *testing.T) {
 		{
 			name: "full matcher",
 			emulatorOption: proxytest.NewEmulatorOption().
-				WithProperty(nodeProxyConfigProxyStatsMatcherInclusionPrefixes, serializeStringSlice([]string{"prefix1", "prefix2"})).
-				WithProperty(nodeProxyConfigProxyStatsMatcherInclusionRegexps, serializeStringSlice([]string{"regexp1", "regexp2"})).
-				WithProperty(nodeProxyConfigProxyStatsMatcherInclusionSuffixes, serializeStringSlice([]string{"suffix1", "suffix2"})),
+				WithProperty(nodeProxyConfigProxyStatsMatcherInclusionPrefixes.Path(), serializeStringSlice([]string{"prefix1", "prefix2"})).
+				WithProperty(nodeProxyConfigProxyStatsMatcherInclusionRegexps.Path(), serializeStringSlice([]string{"regexp1", "regexp2"})).
+				WithProperty(nodeProxyConfigProxyStatsMatcherInclusionSuffixes.Path(), serializeStringSlice([]string{"suffix1", "suffix2"})),
 			expectedMatcher: IstioProxyStatsMatcher{
 				InclusionPrefixes: []string{"prefix1", "prefix2"},
 				InclusionRegexps:  []string{"regexp1", "regexp2"},
@@ -142,7 +142,7 @@ func TestGetNodeProxyConfigProxyStatsMatcher(t *testing.T) {
 		{
 			name: "only prefixes",
 			emulatorOption: proxytest.NewEmulatorOption().
-				WithProperty(nodeProxyConfigProxyStatsMatcherInclusionPrefixes, serializeStringSlice([]string{"prefix1", "prefix2"})),
+				WithProperty(nodeProxyConfigProxyStatsMatcherInclusionPrefixes.Path(), serializeStringSlice([]string{"prefix1", "prefix2"})),
 			expectedMatcher: IstioProxyStatsMatcher{
 				InclusionPrefixes: []string{"prefix1", "prefix2"},
 			},
@@ -151,7 +151,7 @@ func TestGetNodeProxyConfigProxyStatsMatcher(t *testing.T) {
 		{
 			name: "only regexps",
 			emulatorOption: proxytest.NewEmulatorOption().
-				WithProperty(nodeProxyConfigProxyStatsMatcherInclusionRegexps, serializeStringSlice([]string{"regexp1", "regexp2"})),
+				WithProperty(nodeProxyConfigProxyStatsMatcherInclusionRegexps.Path(), serializeStringSlice([]string{"regexp1", "regexp2"})),
 			expectedMatcher: IstioProxyStatsMatcher{
 				InclusionRegexps: []string{"regexp1", "regexp2"},
 			},
@@ -160,7 +160,7 @@ func TestGetNodeProxyConfigProxyStatsMatcher(t *testing.T) {
 		{
 			name: "only suffixes",
 			emulatorOption: proxytest.NewEmulatorOption().
-				WithProperty(nodeProxyConfigProxyStatsMatcherInclusionSuffixes, serializeStringSlice([]string{"suffix1", "suffix2"})),
+				WithProperty(nodeProxyConfigProxyStatsMatcherInclusionSuffixes.Path(), serializeStringSlice([]string{"suffix1", "suffix2"})),
 			expectedMatcher: IstioProxyStatsMatcher{
 				InclusionSuffixes: []string{"suffix1", "suffix2"},
 			},
@@ -194,7 +194,7 @@ func TestGetNodeProxyConfigProxyStatsMatcher(t *testing.T) {
 }
 
 func TestGetNodeProxyConfigServiceCluster(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(nodeProxyConfigServiceCluster, []byte("service-cluster-name"))
+	opt := proxytest.NewEmulatorOption().WithProperty(nodeProxyConfigServiceCluster.Path(), []byte("service-cluster-name"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -204,7 +204,7 @@ func TestGetNodeProxyConfigServiceCluster(t *testing.T) {
 }
 
 func TestGetNodeProxyConfigStatNameLength(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(nodeProxyConfigStatNameLength, serializeFloat64(256))
+	opt := proxytest.NewEmulatorOption().WithProperty(nodeProxyConfigStatNameLength.Path(), serializeFloat64(256))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -214,7 +214,7 @@ func TestGetNodeProxyConfigStatNameLength(t *testing.T) {
 }
 
 func TestGetNodeProxyConfigStatusPort(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(nodeProxyConfigStatusPort, serializeFloat64(15020))
+	opt := proxytest.NewEmulatorOption().WithProperty(nodeProxyConfigStatusPort.Path(), serializeFloat64(15020))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -224,7 +224,7 @@ func TestGetNodeProxyConfigStatusPort(t *testing.T) {
 }
 
 func TestGetNodeProxyConfigTerminationDrainDuration(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(nodeProxyConfigTerminationDrainDuration, []byte("5s"))
+	opt := proxytest.NewEmulatorOption().WithProperty(nodeProxyConfigTerminationDrainDuration.Path(), []byte("5s"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -234,7 +234,7 @@ func TestGetNodeProxyConfigTerminationDrainDuration(t *testing.T) {
 }
 
 func TestGetNodeProxyConfigTracingDatadogAddress(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(nodeProxyConfigTracingDatadogAddress, []byte("datadog-agent.sre.svc.cluster.local:8126"))
+	opt := proxytest.NewEmulatorOption().WithProperty(nodeProxyConfigTracingDatadogAddress.Path(), []byte("datadog-agent.sre.svc.cluster.local:8126"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -244,7 +244,7 @@ func TestGetNodeProxyConfigTracingDatadogAddress(t *testing.T) {
 }
 
 func TestGetNodeProxyConfigTracingOpenCensusAgentAddress(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(nodeProxyConfigTracingOpenCensusAgentAddress, []byte("opencensus-agent.sre.svc.cluster.local:55678"))
+	opt := proxytest.NewEmulatorOption().WithProperty(nodeProxyConfigTracingOpenCensusAgentAddress.Path(), []byte("opencensus-agent.sre.svc.cluster.local:55678"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -254,7 +254,7 @@ func TestGetNodeProxyConfigTracingOpenCensusAgentAddress(t *testing.T) {
 }
 
 func TestGetNodeProxyConfigTracingZipkinAddress(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(nodeProxyConfigTracingZipkinAddress, []byte("zipkin.sre.svc.cluster.local:9411"))
+	opt := proxytest.NewEmulatorOption().WithProperty(nodeProxyConfigTracingZipkinAddress.Path(), []byte("zipkin.sre.svc.cluster.local:9411"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
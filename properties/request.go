@@ -1,26 +1,32 @@
 package properties
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm"
+)
 
 // This file hosts helper functions to retrieve request-related properties as described in:
 // https://www.envoyproxy.io/docs/envoy/latest/intro/arch_overview/advanced/attributes#request-attributes
 
 var (
-	requestPath      = []string{"request", "path"}
-	requestUrlPath   = []string{"request", "url_path"}
-	requestHost      = []string{"request", "host"}
-	requestScheme    = []string{"request", "scheme"}
-	requestMethod    = []string{"request", "method"}
-	requestHeaders   = []string{"request", "headers"}
-	requestReferer   = []string{"request", "referer"}
-	requestUserAgent = []string{"request", "useragent"}
-	requestTime      = []string{"request", "time"}
-	requestId        = []string{"request", "id"}
-	requestProtocol  = []string{"request", "protocol"}
-	requestQuery     = []string{"request", "query"}
-	requestDuration  = []string{"request", "duration"}
-	requestSize      = []string{"request", "size"}
-	requestTotalSize = []string{"request", "total_size"}
+	requestPath      = proxywasm.NewPropertyPath("request", "path")
+	requestUrlPath   = proxywasm.NewPropertyPath("request", "url_path")
+	requestHost      = proxywasm.NewPropertyPath("request", "host")
+	requestScheme    = proxywasm.NewPropertyPath("request", "scheme")
+	requestMethod    = proxywasm.NewPropertyPath("request", "method")
+	requestHeaders   = proxywasm.NewPropertyPath("request", "headers")
+	requestReferer   = proxywasm.NewPropertyPath("request", "referer")
+	requestUserAgent = proxywasm.NewPropertyPath("request", "useragent")
+	requestTime      = proxywasm.NewPropertyPath("request", "time")
+	requestId        = proxywasm.NewPropertyPath("request", "id")
+	requestProtocol  = proxywasm.NewPropertyPath("request", "protocol")
+	requestQuery     = proxywasm.NewPropertyPath("request", "query")
+	requestDuration  = proxywasm.NewPropertyPath("request", "duration")
+	requestSize      = proxywasm.NewPropertyPath("request", "size")
+	requestTotalSize = proxywasm.NewPropertyPath("request", "total_size")
 )
 
 // GetRequestPath return the path portion of the URL.
@@ -101,3 +107,27 @@ func GetRequestSize() (uint64, error) {
 func GetRequestTotalSize() (uint64, error) {
 	return getPropertyUint64(requestTotalSize)
 }
+
+// GetRequestInfo returns a snapshot of the request attributes. Attributes which cannot be read
+// are listed in RequestInfo.Unavailable instead of failing the call, and an error is only
+// returned when none of them can be read.
+func GetRequestInfo() (RequestInfo, error) {
+	var info RequestInfo
+	u := &info.Unavailable
+	errs := []error{
+		readAttribute(u, requestPath, getPropertyString, &info.Path),
+		readAttribute(u, requestMethod, getPropertyString, &info.Method),
+		readAttribute(u, requestHost, getPropertyString, &info.Host),
+		readAttribute(u, requestScheme, getPropertyString, &info.Scheme),
+		readAttribute(u, requestProtocol, getPropertyString, &info.Protocol),
+		readAttribute(u, requestHeaders, getPropertyStringMap, &info.Headers),
+		readAttribute(u, requestId, getPropertyString, &info.ID),
+		readAttribute(u, requestTime, getPropertyTimestamp, &info.Time),
+		readAttribute(u, requestSize, getPropertyUint64, &info.Size),
+		readAttribute(u, requestTotalSize, getPropertyUint64, &info.TotalSize),
+	}
+	if len(info.Unavailable) == len(errs) {
+		return info, fmt.Errorf("no request attribute available: %w", errors.Join(errs...))
+	}
+	return info, nil
+}
@@ -4,12 +4,14 @@ import (
 	"testing"
 	"time"
 
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm"
 	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/proxytest"
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/types"
 	"github.com/stretchr/testify/require"
 )
 
 func TestGetRequestPath(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(requestPath, []byte("/headers"))
+	opt := proxytest.NewEmulatorOption().WithProperty(requestPath.Path(), []byte("/headers"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -19,7 +21,7 @@ func TestGetRequestPath(t *testing.T) {
 }
 
 func TestGetRequestUrlPath(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(requestUrlPath, []byte("/headers"))
+	opt := proxytest.NewEmulatorOption().WithProperty(requestUrlPath.Path(), []byte("/headers"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -29,7 +31,7 @@ func TestGetRequestUrlPath(t *testing.T) {
 }
 
 func TestGetRequestHost(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(requestHost, []byte("wasm.httpbin.org"))
+	opt := proxytest.NewEmulatorOption().WithProperty(requestHost.Path(), []byte("wasm.httpbin.org"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -58,7 +60,7 @@ func TestGetRequestScheme(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			opt := proxytest.NewEmulatorOption().WithProperty(requestScheme, []byte(tt.input))
+			opt := proxytest.NewEmulatorOption().WithProperty(requestScheme.Path(), []byte(tt.input))
 			_, reset := proxytest.NewHostEmulator(opt)
 			defer reset()
 
@@ -70,7 +72,7 @@ func TestGetRequestScheme(t *testing.T) {
 }
 
 func TestGetRequestMethod(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(requestMethod, []byte("GET"))
+	opt := proxytest.NewEmulatorOption().WithProperty(requestMethod.Path(), []byte("GET"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -129,7 +131,7 @@ func TestGetRequestHeaders(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			opt := proxytest.NewEmulatorOption().WithProperty(requestHeaders, serializeStringMap(tt.input))
+			opt := proxytest.NewEmulatorOption().WithProperty(requestHeaders.Path(), serializeStringMap(tt.input))
 			_, reset := proxytest.NewHostEmulator(opt)
 			defer reset()
 
@@ -141,7 +143,7 @@ func TestGetRequestHeaders(t *testing.T) {
 }
 
 func TestGetRequestReferer(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(requestReferer, []byte("https://site.com/page"))
+	opt := proxytest.NewEmulatorOption().WithProperty(requestReferer.Path(), []byte("https://site.com/page"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -151,7 +153,7 @@ func TestGetRequestReferer(t *testing.T) {
 }
 
 func TestGetRequestUserAgent(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(requestUserAgent, []byte("curl/7.81.0"))
+	opt := proxytest.NewEmulatorOption().WithProperty(requestUserAgent.Path(), []byte("curl/7.81.0"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -162,7 +164,7 @@ func TestGetRequestUserAgent(t *testing.T) {
 
 func TestGetRequestTime(t *testing.T) {
 	now := time.Now().UTC()
-	opt := proxytest.NewEmulatorOption().WithProperty(requestTime, serializeTimestamp(now))
+	opt := proxytest.NewEmulatorOption().WithProperty(requestTime.Path(), serializeTimestamp(now))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -172,7 +174,7 @@ func TestGetRequestTime(t *testing.T) {
 }
 
 func TestGetRequestId(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(requestId, []byte("7490e0f7-87f0-4c81-92aa-8ea3d5896189"))
+	opt := proxytest.NewEmulatorOption().WithProperty(requestId.Path(), []byte("7490e0f7-87f0-4c81-92aa-8ea3d5896189"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -182,7 +184,7 @@ func TestGetRequestId(t *testing.T) {
 }
 
 func TestGetRequestProtocol(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(requestProtocol, []byte("HTTP/1.1"))
+	opt := proxytest.NewEmulatorOption().WithProperty(requestProtocol.Path(), []byte("HTTP/1.1"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -192,7 +194,7 @@ func TestGetRequestProtocol(t *testing.T) {
 }
 
 func TestGetRequestQuery(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(requestQuery, []byte("?page=1&limit=10"))
+	opt := proxytest.NewEmulatorOption().WithProperty(requestQuery.Path(), []byte("?page=1&limit=10"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -202,7 +204,7 @@ func TestGetRequestQuery(t *testing.T) {
 }
 
 func TestGetRequestDuration(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(requestDuration, serializeUint64(1000))
+	opt := proxytest.NewEmulatorOption().WithProperty(requestDuration.Path(), serializeUint64(1000))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -212,7 +214,7 @@ func TestGetRequestDuration(t *testing.T) {
 }
 
 func TestGetRequestSize(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(requestSize, serializeUint64(256))
+	opt := proxytest.NewEmulatorOption().WithProperty(requestSize.Path(), serializeUint64(256))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -222,7 +224,7 @@ func TestGetRequestSize(t *testing.T) {
 }
 
 func TestGetRequestTotalSize(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(requestTotalSize, serializeUint64(1024))
+	opt := proxytest.NewEmulatorOption().WithProperty(requestTotalSize.Path(), serializeUint64(1024))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -230,3 +232,63 @@ func TestGetRequestTotalSize(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, uint64(1024), result)
 }
+
+func TestGetRequestInfo(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+	opt := proxytest.NewEmulatorOption().
+		WithProperty(requestPath.Path(), []byte("/headers?a=b")).
+		WithProperty(requestMethod.Path(), []byte("GET")).
+		WithProperty(requestHost.Path(), []byte("example.com")).
+		WithProperty(requestScheme.Path(), []byte("https")).
+		WithProperty(requestHeaders.Path(), serializeStringMap(map[string]string{"accept": "*/*"})).
+		WithProperty(requestId.Path(), []byte("abc")).
+		WithProperty(requestTime.Path(), serializeTimestamp(now)).
+		WithProperty(requestTotalSize.Path(), serializeUint64(128))
+	_, reset := proxytest.NewHostEmulator(opt)
+	defer reset()
+
+	info, err := GetRequestInfo()
+	require.NoError(t, err)
+	require.Equal(t, RequestInfo{
+		Path:        "/headers?a=b",
+		Method:      "GET",
+		Host:        "example.com",
+		Scheme:      "https",
+		Headers:     map[string]string{"accept": "*/*"},
+		ID:          "abc",
+		Time:        now,
+		TotalSize:   128,
+		Unavailable: []string{"request.protocol", "request.size"},
+	}, info)
+}
+
+func TestGetRequestInfoUnavailable(t *testing.T) {
+	_, reset := proxytest.NewHostEmulator(proxytest.NewEmulatorOption())
+	defer reset()
+
+	info, err := GetRequestInfo()
+	require.ErrorIs(t, err, types.ErrorStatusNotFound)
+	require.Len(t, info.Unavailable, 10)
+}
+
+// BenchmarkGetRequestPath compares reading a property by a path serialized on each call and
+// by a precomputed one. Hostcalls are not recorded so that the host does not dominate the cost.
+func BenchmarkGetRequestPath(b *testing.B) {
+	opt := proxytest.NewEmulatorOption().WithProperty(requestPath.Path(), []byte("/headers")).WithoutCallRecording()
+	_, reset := proxytest.NewHostEmulator(opt)
+	defer reset()
+
+	b.Run("GetProperty", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			_, _ = proxywasm.GetProperty([]string{"request", "path"})
+		}
+	})
+
+	b.Run("GetPropertyPath", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			_, _ = proxywasm.GetPropertyPath(requestPath)
+		}
+	})
+}
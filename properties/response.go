@@ -1,17 +1,25 @@
 package properties
 
+import (
+	"errors"
+	"fmt"
+
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm"
+)
+
 // This file hosts helper functions to retrieve response-related properties as described in:
 // https://www.envoyproxy.io/docs/envoy/latest/intro/arch_overview/advanced/attributes#response-attributes
 
 var (
-	responseCode           = []string{"response", "code"}
-	responseCodeDetails    = []string{"response", "code_details"}
-	responseFlags          = []string{"response", "flags"}
-	responseGrpcStatusCode = []string{"response", "grpc_status"}
-	responseHeaders        = []string{"response", "headers"}
-	responseTrailers       = []string{"response", "trailers"}
-	responseSize           = []string{"response", "size"}
-	responseTotalSize      = []string{"response", "total_size"}
+	responseCode           = proxywasm.NewPropertyPath("response", "code")
+	responseCodeDetails    = proxywasm.NewPropertyPath("response", "code_details")
+	responseFlags          = proxywasm.NewPropertyPath("response", "flags")
+	responseGrpcStatusCode = proxywasm.NewPropertyPath("response", "grpc_status")
+	responseHeaders        = proxywasm.NewPropertyPath("response", "headers")
+	responseTrailers       = proxywasm.NewPropertyPath("response", "trailers")
+	responseSize           = proxywasm.NewPropertyPath("response", "size")
+	responseTotalSize      = proxywasm.NewPropertyPath("response", "total_size")
+	responseBackendLatency = proxywasm.NewPropertyPath("response", "backend_latency")
 )
 
 // GetResponseCode returns the response HTTP status code.
@@ -25,7 +33,8 @@ func GetResponseCodeDetails() (string, error) {
 }
 
 // GetResponseFlags returns additional details about the response beyond the standard
-// response code encoded as a bit-vector.
+// response code encoded as a bit-vector. Convert it to ResponseFlags to test the flags or to get
+// their short codes, e.g. ResponseFlags(flags).Has(UpstreamConnectionFailure).
 //
 // https://www.envoyproxy.io/docs/envoy/latest/configuration/observability/access_log/usage#config-access-log-format-response-flags
 func GetResponseFlags() (uint64, error) {
@@ -57,3 +66,34 @@ func GetResponseSize() (uint64, error) {
 func GetResponseTotalSize() (uint64, error) {
 	return getPropertyUint64(responseTotalSize)
 }
+
+// GetResponseBackendLatency returns the duration between the first byte sent to and the last
+// byte received from the upstream backend, approximated to nano-seconds.
+func GetResponseBackendLatency() (uint64, error) {
+	return getPropertyUint64(responseBackendLatency)
+}
+
+// GetResponseInfo returns a snapshot of the response attributes. Attributes which cannot be read
+// are listed in ResponseInfo.Unavailable instead of failing the call, and an error is only
+// returned when none of them can be read.
+func GetResponseInfo() (ResponseInfo, error) {
+	var info ResponseInfo
+	u := &info.Unavailable
+	errs := []error{
+		readAttribute(u, responseCode, getPropertyUint64, &info.Code),
+		readAttribute(u, responseCodeDetails, getPropertyString, &info.CodeDetails),
+		readAttribute(u, responseFlags, getResponseFlags, &info.Flags),
+		readAttribute(u, responseGrpcStatusCode, getPropertyUint64, &info.GrpcStatus),
+		readAttribute(u, responseSize, getPropertyUint64, &info.Size),
+		readAttribute(u, responseTotalSize, getPropertyUint64, &info.TotalSize),
+	}
+	if len(info.Unavailable) == len(errs) {
+		return info, fmt.Errorf("no response attribute available: %w", errors.Join(errs...))
+	}
+	return info, nil
+}
+
+func getResponseFlags(path proxywasm.PropertyPath) (ResponseFlags, error) {
+	flags, err := getPropertyUint64(path)
+	return ResponseFlags(flags), err
+}
@@ -4,11 +4,12 @@ import (
 	"testing"
 
 	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/proxytest"
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/types"
 	"github.com/stretchr/testify/require"
 )
 
 func TestGetResponseCode(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(responseCode, serializeUint64(200))
+	opt := proxytest.NewEmulatorOption().WithProperty(responseCode.Path(), serializeUint64(200))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -18,7 +19,7 @@ func TestGetResponseCode(t *testing.T) {
 }
 
 func TestGetResponseCodeDetails(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(responseCodeDetails, []byte("Not Found"))
+	opt := proxytest.NewEmulatorOption().WithProperty(responseCodeDetails.Path(), []byte("Not Found"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -28,7 +29,7 @@ func TestGetResponseCodeDetails(t *testing.T) {
 }
 
 func TestGetResponseFlags(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(responseFlags, serializeUint64(123))
+	opt := proxytest.NewEmulatorOption().WithProperty(responseFlags.Path(), serializeUint64(123))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -38,7 +39,7 @@ func TestGetResponseFlags(t *testing.T) {
 }
 
 func TestGetResponseGrpcStatusCode(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(responseGrpcStatusCode, serializeUint64(200))
+	opt := proxytest.NewEmulatorOption().WithProperty(responseGrpcStatusCode.Path(), serializeUint64(200))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -87,7 +88,7 @@ func TestGetResponseHeaders(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			opt := proxytest.NewEmulatorOption().WithProperty(responseHeaders, serializeStringMap(tt.input))
+			opt := proxytest.NewEmulatorOption().WithProperty(responseHeaders.Path(), serializeStringMap(tt.input))
 			_, reset := proxytest.NewHostEmulator(opt)
 			defer reset()
 
@@ -122,7 +123,7 @@ func TestGetResponseTrailers(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			opt := proxytest.NewEmulatorOption().WithProperty(responseTrailers, serializeStringMap(tt.input))
+			opt := proxytest.NewEmulatorOption().WithProperty(responseTrailers.Path(), serializeStringMap(tt.input))
 			_, reset := proxytest.NewHostEmulator(opt)
 			defer reset()
 
@@ -134,7 +135,7 @@ func TestGetResponseTrailers(t *testing.T) {
 }
 
 func TestGetResponseSize(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(responseSize, serializeUint64(512))
+	opt := proxytest.NewEmulatorOption().WithProperty(responseSize.Path(), serializeUint64(512))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -144,7 +145,7 @@ func TestGetResponseSize(t *testing.T) {
 }
 
 func TestGetResponseTotalSize(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(responseTotalSize, serializeUint64(2048))
+	opt := proxytest.NewEmulatorOption().WithProperty(responseTotalSize.Path(), serializeUint64(2048))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -152,3 +153,51 @@ func TestGetResponseTotalSize(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, uint64(2048), result)
 }
+
+func TestGetResponseBackendLatency(t *testing.T) {
+	opt := proxytest.NewEmulatorOption().WithProperty(responseBackendLatency.Path(), serializeUint64(25000000))
+	_, reset := proxytest.NewHostEmulator(opt)
+	defer reset()
+
+	result, err := GetResponseBackendLatency()
+	require.NoError(t, err)
+	require.Equal(t, uint64(25000000), result)
+}
+
+func TestGetResponseInfo(t *testing.T) {
+	opt := proxytest.NewEmulatorOption().
+		WithProperty(responseCode.Path(), serializeUint64(503)).
+		WithProperty(responseCodeDetails.Path(), []byte("upstream_reset_before_response_started")).
+		WithProperty(responseFlags.Path(), serializeUint64(0x2)).
+		WithProperty(responseSize.Path(), serializeUint64(19)).
+		WithProperty(responseTotalSize.Path(), serializeUint64(120))
+	_, reset := proxytest.NewHostEmulator(opt)
+	defer reset()
+
+	info, err := GetResponseInfo()
+	require.NoError(t, err)
+	require.Equal(t, ResponseInfo{
+		Code:        503,
+		CodeDetails: "upstream_reset_before_response_started",
+		Flags:       NoHealthyUpstream,
+		Size:        19,
+		TotalSize:   120,
+		Unavailable: []string{"response.grpc_status"},
+	}, info)
+}
+
+func TestGetResponseInfoUnavailable(t *testing.T) {
+	_, reset := proxytest.NewHostEmulator(proxytest.NewEmulatorOption())
+	defer reset()
+
+	info, err := GetResponseInfo()
+	require.ErrorIs(t, err, types.ErrorStatusNotFound)
+	require.Equal(t, ResponseInfo{Unavailable: []string{
+		"response.code",
+		"response.code_details",
+		"response.flags",
+		"response.grpc_status",
+		"response.size",
+		"response.total_size",
+	}}, info)
+}
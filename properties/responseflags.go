@@ -0,0 +1,137 @@
+package properties
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ResponseFlags is the bit-vector of the response.flags attribute, giving additional details
+// about the response or the connection beyond the response code.
+//
+// https://www.envoyproxy.io/docs/envoy/latest/configuration/observability/access_log/usage#config-access-log-format-response-flags
+type ResponseFlags uint64
+
+const (
+	// FailedLocalHealthCheck (LH) means that the local service failed its health check.
+	FailedLocalHealthCheck ResponseFlags = 1 << iota
+	// NoHealthyUpstream (UH) means that there was no healthy upstream host.
+	NoHealthyUpstream
+	// UpstreamRequestTimeout (UT) means that the upstream request timed out.
+	UpstreamRequestTimeout
+	// LocalReset (LR) means that the connection was reset locally.
+	LocalReset
+	// UpstreamRemoteReset (UR) means that the upstream connection was reset remotely.
+	UpstreamRemoteReset
+	// UpstreamConnectionFailure (UF) means that the connection to the upstream failed.
+	UpstreamConnectionFailure
+	// UpstreamConnectionTermination (UC) means that the upstream connection was terminated.
+	UpstreamConnectionTermination
+	// UpstreamOverflow (UO) means that the upstream circuit breaker overflowed.
+	UpstreamOverflow
+	// NoRouteFound (NR) means that no route nor filter chain matched the request.
+	NoRouteFound
+	// DelayInjected (DI) means that the request was delayed by the fault filter.
+	DelayInjected
+	// FaultInjected (FI) means that the request was aborted by the fault filter.
+	FaultInjected
+	// RateLimited (RL) means that the request was rate limited locally.
+	RateLimited
+	// UnauthorizedExternalService (UAEX) means that the request was denied by the external
+	// authorization service.
+	UnauthorizedExternalService
+	// RateLimitServiceError (RLSE) means that the request was rejected because of an error of
+	// the rate limit service.
+	RateLimitServiceError
+	// DownstreamConnectionTermination (DC) means that the downstream connection was terminated.
+	DownstreamConnectionTermination
+	// UpstreamRetryLimitExceeded (URX) means that the upstream retry or connect attempt limit
+	// was reached.
+	UpstreamRetryLimitExceeded
+	// StreamIdleTimeout (SI) means that the stream idle timeout was reached.
+	StreamIdleTimeout
+	// InvalidEnvoyRequestHeaders (IH) means that the request had invalid values of the
+	// strictly-checked x-envoy-* headers.
+	InvalidEnvoyRequestHeaders
+	// DownstreamProtocolError (DPE) means that the downstream request had a protocol error.
+	DownstreamProtocolError
+	// UpstreamMaxStreamDurationReached (UMSDR) means that the upstream request reached the
+	// maximum stream duration.
+	UpstreamMaxStreamDurationReached
+	// ResponseFromCacheFilter (RFCF) means that the response was served by the cache filter.
+	ResponseFromCacheFilter
+	// NoFilterConfigFound (NFCF) means that the request was terminated because no filter
+	// configuration was received from the discovery service.
+	NoFilterConfigFound
+	// DurationTimeout (DT) means that the request or the connection exceeded max_connection_duration
+	// or max_downstream_connection_duration.
+	DurationTimeout
+	// UpstreamProtocolError (UPE) means that the upstream response had a protocol error.
+	UpstreamProtocolError
+	// NoClusterFound (NC) means that the upstream cluster was not found.
+	NoClusterFound
+	// OverloadManager (OM) means that the overload manager terminated the request.
+	OverloadManager
+	// DnsResolutionFailed (DF) means that the DNS resolution of the upstream failed.
+	DnsResolutionFailed
+	// DropOverLoad (DO) means that the request was dropped by the drop overload configuration.
+	DropOverLoad
+	// DownstreamRemoteReset (DR) means that the downstream connection was reset remotely.
+	DownstreamRemoteReset
+	// UnconditionalDropOverload (UDO) means that the request was dropped unconditionally by
+	// the drop overload configuration.
+	UnconditionalDropOverload
+)
+
+// responseFlagCodes are the short codes of the flags, in the order of their bits.
+var responseFlagCodes = [...]string{
+	"LH", "UH", "UT", "LR", "UR", "UF", "UC", "UO", "NR", "DI",
+	"FI", "RL", "UAEX", "RLSE", "DC", "URX", "SI", "IH", "DPE", "UMSDR",
+	"RFCF", "NFCF", "DT", "UPE", "NC", "OM", "DF", "DO", "DR", "UDO",
+}
+
+// Has reports whether all the given flags are set.
+func (f ResponseFlags) Has(flags ResponseFlags) bool {
+	return f&flags == flags
+}
+
+// String returns the comma-separated short codes of the flags as Envoy writes them in access
+// logs, e.g. "UF,URX", or "-" when no flag is set. Unknown bits are ignored.
+func (f ResponseFlags) String() string {
+	var codes []string
+	for i, code := range responseFlagCodes {
+		if f&(1<<i) != 0 {
+			codes = append(codes, code)
+		}
+	}
+	if len(codes) == 0 {
+		return "-"
+	}
+	return strings.Join(codes, ",")
+}
+
+// ParseResponseFlags converts the comma-separated short codes written by Envoy in access
+// logs, e.g. "UF,URX", to the flags. Both "-" and the empty string mean no flag.
+// It returns an error for unknown codes.
+func ParseResponseFlags(s string) (ResponseFlags, error) {
+	if s == "" || s == "-" {
+		return 0, nil
+	}
+	var flags ResponseFlags
+	for _, code := range strings.Split(s, ",") {
+		flag, ok := responseFlag(strings.TrimSpace(code))
+		if !ok {
+			return 0, fmt.Errorf("invalid response flag: %s", code)
+		}
+		flags |= flag
+	}
+	return flags, nil
+}
+
+func responseFlag(code string) (ResponseFlags, bool) {
+	for i, c := range responseFlagCodes {
+		if c == code {
+			return 1 << i, true
+		}
+	}
+	return 0, false
+}
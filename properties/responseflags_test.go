@@ -0,0 +1,101 @@
+package properties
+
+import (
+	"testing"
+
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/proxytest"
+	"github.com/stretchr/testify/require"
+)
+
+// responseFlagTests is the table of Envoy response flags with their bit values as of
+// envoy/stream_info/stream_info.h and their short codes in access logs.
+var responseFlagTests = []struct {
+	flag  ResponseFlags
+	value uint64
+	code  string
+}{
+	{FailedLocalHealthCheck, 0x1, "LH"},
+	{NoHealthyUpstream, 0x2, "UH"},
+	{UpstreamRequestTimeout, 0x4, "UT"},
+	{LocalReset, 0x8, "LR"},
+	{UpstreamRemoteReset, 0x10, "UR"},
+	{UpstreamConnectionFailure, 0x20, "UF"},
+	{UpstreamConnectionTermination, 0x40, "UC"},
+	{UpstreamOverflow, 0x80, "UO"},
+	{NoRouteFound, 0x100, "NR"},
+	{DelayInjected, 0x200, "DI"},
+	{FaultInjected, 0x400, "FI"},
+	{RateLimited, 0x800, "RL"},
+	{UnauthorizedExternalService, 0x1000, "UAEX"},
+	{RateLimitServiceError, 0x2000, "RLSE"},
+	{DownstreamConnectionTermination, 0x4000, "DC"},
+	{UpstreamRetryLimitExceeded, 0x8000, "URX"},
+	{StreamIdleTimeout, 0x10000, "SI"},
+	{InvalidEnvoyRequestHeaders, 0x20000, "IH"},
+	{DownstreamProtocolError, 0x40000, "DPE"},
+	{UpstreamMaxStreamDurationReached, 0x80000, "UMSDR"},
+	{ResponseFromCacheFilter, 0x100000, "RFCF"},
+	{NoFilterConfigFound, 0x200000, "NFCF"},
+	{DurationTimeout, 0x400000, "DT"},
+	{UpstreamProtocolError, 0x800000, "UPE"},
+	{NoClusterFound, 0x1000000, "NC"},
+	{OverloadManager, 0x2000000, "OM"},
+	{DnsResolutionFailed, 0x4000000, "DF"},
+	{DropOverLoad, 0x8000000, "DO"},
+	{DownstreamRemoteReset, 0x10000000, "DR"},
+	{UnconditionalDropOverload, 0x20000000, "UDO"},
+}
+
+func TestResponseFlags(t *testing.T) {
+	require.Len(t, responseFlagCodes, len(responseFlagTests))
+	for _, tt := range responseFlagTests {
+		t.Run(tt.code, func(t *testing.T) {
+			require.Equal(t, tt.value, uint64(tt.flag))
+			require.Equal(t, tt.code, tt.flag.String())
+			require.True(t, tt.flag.Has(tt.flag))
+
+			flags, err := ParseResponseFlags(tt.code)
+			require.NoError(t, err)
+			require.Equal(t, tt.flag, flags)
+		})
+	}
+}
+
+func TestResponseFlags_String(t *testing.T) {
+	require.Equal(t, "-", ResponseFlags(0).String())
+	require.Equal(t, "UF,URX", (UpstreamRetryLimitExceeded | UpstreamConnectionFailure).String())
+	require.Equal(t, "UH", (NoHealthyUpstream | 1<<63).String())
+}
+
+func TestResponseFlags_Has(t *testing.T) {
+	flags := UpstreamConnectionFailure | UpstreamRetryLimitExceeded
+	require.True(t, flags.Has(UpstreamConnectionFailure))
+	require.True(t, flags.Has(UpstreamConnectionFailure|UpstreamRetryLimitExceeded))
+	require.False(t, flags.Has(UpstreamConnectionFailure|NoRouteFound))
+	require.False(t, flags.Has(RateLimited))
+}
+
+func TestParseResponseFlags(t *testing.T) {
+	for _, s := range []string{"", "-"} {
+		flags, err := ParseResponseFlags(s)
+		require.NoError(t, err)
+		require.Zero(t, flags)
+	}
+
+	flags, err := ParseResponseFlags("URX,UF")
+	require.NoError(t, err)
+	require.Equal(t, UpstreamConnectionFailure|UpstreamRetryLimitExceeded, flags)
+
+	_, err = ParseResponseFlags("UF,XX")
+	require.EqualError(t, err, "invalid response flag: XX")
+}
+
+func TestGetResponseFlags_typed(t *testing.T) {
+	opt := proxytest.NewEmulatorOption().WithProperty(responseFlags.Path(), serializeUint64(0x8020))
+	_, reset := proxytest.NewHostEmulator(opt)
+	defer reset()
+
+	result, err := GetResponseFlags()
+	require.NoError(t, err)
+	require.Equal(t, "UF,URX", ResponseFlags(result).String())
+}
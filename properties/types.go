@@ -1,6 +1,9 @@
 package properties
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 // EnvoyTrafficDirection identifies the direction of the traffic relative to the local Envoy.
 //
@@ -125,3 +128,57 @@ func ParseIstioTrafficInterceptionMode(s string) (IstioTrafficInterceptionMode,
 		return IstioTrafficInterceptionModeRedirect, fmt.Errorf("invalid IstioTrafficInterceptionMode: %s", s)
 	}
 }
+
+// IstioPeer holds the workload identity of a peer of the proxy, as exchanged by the Istio
+// metadata exchange of the downstream_peer and upstream_peer properties.
+//
+// https://istio.io/latest/docs/reference/config/proxy_extensions/metadata_exchange/
+type IstioPeer struct {
+	// Name is the name of the workload instance, e.g. the pod name.
+	Name           string
+	Namespace      string
+	WorkloadName   string
+	ServiceAccount string
+	Owner          string
+	ClusterID      string
+	MeshID         string
+	IstioVersion   string
+	Labels         map[string]string
+	// App is the application name, derived from the "app", "app.kubernetes.io/name" or
+	// "service.istio.io/canonical-name" labels.
+	App string
+	// Version is the application version, derived from the "version", "app.kubernetes.io/version"
+	// or "service.istio.io/canonical-revision" labels.
+	Version string
+}
+
+// RequestInfo is a snapshot of the request attributes, as returned by GetRequestInfo.
+type RequestInfo struct {
+	Path     string
+	Method   string
+	Host     string
+	Scheme   string
+	Protocol string
+	// Headers are indexed by the lower-cased header name.
+	Headers   map[string]string
+	ID        string
+	Time      time.Time
+	Size      uint64
+	TotalSize uint64
+	// Unavailable lists the attributes which could not be read, e.g. "request.size".
+	// The corresponding fields are left to their zero value.
+	Unavailable []string
+}
+
+// ResponseInfo is a snapshot of the response attributes, as returned by GetResponseInfo.
+type ResponseInfo struct {
+	Code        uint64
+	CodeDetails string
+	Flags       ResponseFlags
+	GrpcStatus  uint64
+	Size        uint64
+	TotalSize   uint64
+	// Unavailable lists the attributes which could not be read, e.g. "response.grpc_status".
+	// The corresponding fields are left to their zero value.
+	Unavailable []string
+}
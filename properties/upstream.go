@@ -1,21 +1,25 @@
 package properties
 
+import "github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm"
+
 // This file hosts helper functions to retrieve upstream-related properties as described in:
 // https://www.envoyproxy.io/docs/envoy/latest/intro/arch_overview/advanced/attributes#upstream-attributes
 
 var (
-	upstreamAddress                     = []string{"upstream", "address"}
-	upstreamPort                        = []string{"upstream", "port"}
-	upstreamTlsVersion                  = []string{"upstream", "tls_version"}
-	upstreamSubjectLocalCertificate     = []string{"upstream", "subject_local_certificate"}
-	upstreamSubjectPeerCertificate      = []string{"upstream", "subject_peer_certificate"}
-	upstreamDnsSanLocalCertificate      = []string{"upstream", "dns_san_local_certificate"}
-	upstreamDnsSanPeerCertificate       = []string{"upstream", "dns_san_peer_certificate"}
-	upstreamUriSanLocalCertificate      = []string{"upstream", "uri_san_local_certificate"}
-	upstreamUriSanPeerCertificate       = []string{"upstream", "uri_san_peer_certificate"}
-	upstreamSha256PeerCertificateDigest = []string{"upstream", "sha256_peer_certificate_digest"}
-	upstreamLocalAddress                = []string{"upstream", "local_address"}
-	upstreamTransportFailureReason      = []string{"upstream", "transport_failure_reason"}
+	upstreamAddress                     = proxywasm.NewPropertyPath("upstream", "address")
+	upstreamPort                        = proxywasm.NewPropertyPath("upstream", "port")
+	upstreamTlsVersion                  = proxywasm.NewPropertyPath("upstream", "tls_version")
+	upstreamSubjectLocalCertificate     = proxywasm.NewPropertyPath("upstream", "subject_local_certificate")
+	upstreamSubjectPeerCertificate      = proxywasm.NewPropertyPath("upstream", "subject_peer_certificate")
+	upstreamDnsSanLocalCertificate      = proxywasm.NewPropertyPath("upstream", "dns_san_local_certificate")
+	upstreamDnsSanPeerCertificate       = proxywasm.NewPropertyPath("upstream", "dns_san_peer_certificate")
+	upstreamUriSanLocalCertificate      = proxywasm.NewPropertyPath("upstream", "uri_san_local_certificate")
+	upstreamUriSanPeerCertificate       = proxywasm.NewPropertyPath("upstream", "uri_san_peer_certificate")
+	upstreamSha256PeerCertificateDigest = proxywasm.NewPropertyPath("upstream", "sha256_peer_certificate_digest")
+	upstreamLocalAddress                = proxywasm.NewPropertyPath("upstream", "local_address")
+	upstreamTransportFailureReason      = proxywasm.NewPropertyPath("upstream", "transport_failure_reason")
+	upstreamRequestAttemptCount         = proxywasm.NewPropertyPath("upstream", "request_attempt_count")
+	upstreamCxPoolReadyDuration         = proxywasm.NewPropertyPath("upstream", "cx_pool_ready_duration")
 )
 
 // GetUpstreamAddress returns the upstream connection remote address.
@@ -85,3 +89,15 @@ func GetUpstreamLocalAddress() (string, error) {
 func GetUpstreamTransportFailureReason() (string, error) {
 	return getPropertyString(upstreamTransportFailureReason)
 }
+
+// GetUpstreamRequestAttemptCount returns the number of times the request was attempted upstream,
+// i.e. one plus the number of retries.
+func GetUpstreamRequestAttemptCount() (uint64, error) {
+	return getPropertyUint64(upstreamRequestAttemptCount)
+}
+
+// GetUpstreamConnectionPoolReadyDuration returns the duration from the creation of the upstream
+// request until the connection pool was ready, approximated to nano-seconds.
+func GetUpstreamConnectionPoolReadyDuration() (uint64, error) {
+	return getPropertyUint64(upstreamCxPoolReadyDuration)
+}
@@ -8,7 +8,7 @@ import (
 )
 
 func TestGetUpstreamAddress(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(upstreamAddress, []byte("127.0.0.1"))
+	opt := proxytest.NewEmulatorOption().WithProperty(upstreamAddress.Path(), []byte("127.0.0.1"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -18,7 +18,7 @@ func TestGetUpstreamAddress(t *testing.T) {
 }
 
 func TestGetUpstreamPort(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(upstreamPort, serializeUint64(8080))
+	opt := proxytest.NewEmulatorOption().WithProperty(upstreamPort.Path(), serializeUint64(8080))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -28,7 +28,7 @@ func TestGetUpstreamPort(t *testing.T) {
 }
 
 func TestGetUpstreamTlsVersion(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(upstreamTlsVersion, []byte("TLSv1.3"))
+	opt := proxytest.NewEmulatorOption().WithProperty(upstreamTlsVersion.Path(), []byte("TLSv1.3"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -38,7 +38,7 @@ func TestGetUpstreamTlsVersion(t *testing.T) {
 }
 
 func TestGetUpstreamSubjectLocalCertificate(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(upstreamSubjectLocalCertificate,
+	opt := proxytest.NewEmulatorOption().WithProperty(upstreamSubjectLocalCertificate.Path(),
 		[]byte("CN=example.com,OU=IT,O=example,L=San Francisco,ST=California,C=US"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
@@ -49,7 +49,7 @@ func TestGetUpstreamSubjectLocalCertificate(t *testing.T) {
 }
 
 func TestGetUpstreamSubjectPeerCertificate(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(upstreamSubjectPeerCertificate,
+	opt := proxytest.NewEmulatorOption().WithProperty(upstreamSubjectPeerCertificate.Path(),
 		[]byte("CN=example.com,OU=IT,O=example,L=San Francisco,ST=California,C=US"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
@@ -60,7 +60,7 @@ func TestGetUpstreamSubjectPeerCertificate(t *testing.T) {
 }
 
 func TestGetUpstreamDnsSanLocalCertificate(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(upstreamDnsSanLocalCertificate, []byte("example.com"))
+	opt := proxytest.NewEmulatorOption().WithProperty(upstreamDnsSanLocalCertificate.Path(), []byte("example.com"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -70,7 +70,7 @@ func TestGetUpstreamDnsSanLocalCertificate(t *testing.T) {
 }
 
 func TestGetUpstreamDnsSanPeerCertificate(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(upstreamDnsSanPeerCertificate, []byte("example.com"))
+	opt := proxytest.NewEmulatorOption().WithProperty(upstreamDnsSanPeerCertificate.Path(), []byte("example.com"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -80,7 +80,7 @@ func TestGetUpstreamDnsSanPeerCertificate(t *testing.T) {
 }
 
 func TestGetUpstreamUriSanLocalCertificate(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(upstreamUriSanLocalCertificate, []byte("example.com"))
+	opt := proxytest.NewEmulatorOption().WithProperty(upstreamUriSanLocalCertificate.Path(), []byte("example.com"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -90,7 +90,7 @@ func TestGetUpstreamUriSanLocalCertificate(t *testing.T) {
 }
 
 func TestGetUpstreamUriSanPeerCertificate(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(upstreamUriSanPeerCertificate, []byte("example.com"))
+	opt := proxytest.NewEmulatorOption().WithProperty(upstreamUriSanPeerCertificate.Path(), []byte("example.com"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -100,7 +100,7 @@ func TestGetUpstreamUriSanPeerCertificate(t *testing.T) {
 }
 
 func TestGetUpstreamSha256PeerCertificateDigest(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(upstreamSha256PeerCertificateDigest,
+	opt := proxytest.NewEmulatorOption().WithProperty(upstreamSha256PeerCertificateDigest.Path(),
 		[]byte("b714f3d6f83efc2fddf80b8feda3e3b21b3e27b5"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
@@ -111,7 +111,7 @@ func TestGetUpstreamSha256PeerCertificateDigest(t *testing.T) {
 }
 
 func TestGetUpstreamLocalAddress(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(upstreamLocalAddress, []byte("192.168.1.1"))
+	opt := proxytest.NewEmulatorOption().WithProperty(upstreamLocalAddress.Path(), []byte("192.168.1.1"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -121,7 +121,7 @@ func TestGetUpstreamLocalAddress(t *testing.T) {
 }
 
 func TestGetUpstreamTransportFailureReason(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(upstreamTransportFailureReason, []byte("connection closed"))
+	opt := proxytest.NewEmulatorOption().WithProperty(upstreamTransportFailureReason.Path(), []byte("connection closed"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -129,3 +129,23 @@ func TestGetUpstreamTransportFailureReason(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, "connection closed", result)
 }
+
+func TestGetUpstreamRequestAttemptCount(t *testing.T) {
+	opt := proxytest.NewEmulatorOption().WithProperty(upstreamRequestAttemptCount.Path(), serializeUint64(3))
+	_, reset := proxytest.NewHostEmulator(opt)
+	defer reset()
+
+	result, err := GetUpstreamRequestAttemptCount()
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), result)
+}
+
+func TestGetUpstreamConnectionPoolReadyDuration(t *testing.T) {
+	opt := proxytest.NewEmulatorOption().WithProperty(upstreamCxPoolReadyDuration.Path(), serializeUint64(1500000))
+	_, reset := proxytest.NewHostEmulator(opt)
+	defer reset()
+
+	result, err := GetUpstreamConnectionPoolReadyDuration()
+	require.NoError(t, err)
+	require.Equal(t, uint64(1500000), result)
+}
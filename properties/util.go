@@ -1,22 +1,35 @@
 package properties
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm"
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/types"
 )
 
+// istioFilterMetadataPath holds the paths of the fields of istio filter metadata.
+type istioFilterMetadataPath struct {
+	config, services proxywasm.PropertyPath
+}
+
+// newIstioFilterMetadataPath serializes the paths of the fields of the istio filter metadata at path.
+func newIstioFilterMetadataPath(path ...string) istioFilterMetadataPath {
+	p := proxywasm.NewPropertyPath(path...)
+	return istioFilterMetadataPath{config: p.Append("config"), services: p.Append("services")}
+}
+
 // getIstioFilterMetadata parses istio filter metadata
-func getIstioFilterMetadata(path []string) (IstioFilterMetadata, error) {
+func getIstioFilterMetadata(path istioFilterMetadataPath) (IstioFilterMetadata, error) {
 	result := IstioFilterMetadata{}
 
-	config, err := getPropertyString(append(path, "config"))
+	config, err := getPropertyString(path.config)
 	if err != nil {
 		return IstioFilterMetadata{}, nil
 	}
 	result.Config = config
 
-	services, err := getPropertyByteSliceSlice(append(path, "services"))
+	services, err := getPropertyByteSliceSlice(path.services)
 	if err != nil || services == nil {
 		return result, nil
 	}
@@ -44,9 +57,63 @@ func getIstioFilterMetadata(path []string) (IstioFilterMetadata, error) {
 	return result, nil
 }
 
+// getFilterMetadata decodes the envoy.config.core.v3.Metadata message of the property and
+// returns the google.protobuf.Struct of the namespace in its filter_metadata.
+func getFilterMetadata(path proxywasm.PropertyPath, namespace string) (map[string]any, error) {
+	bs, err := proxywasm.GetPropertyPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var result map[string]any
+	found := false
+	err = walkProtoFields(bs, func(field, _ int, entry []byte) error {
+		if field != metadataFilterMetadataField {
+			return nil
+		}
+		var key string
+		var value []byte
+		if err := walkProtoFields(entry, func(field, _ int, data []byte) error {
+			switch field {
+			case mapEntryKeyField:
+				key = string(data)
+			case mapEntryValueField:
+				value = data
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		if key != namespace {
+			return nil
+		}
+		found = true
+		result, err = deserializeStruct(value)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid metadata: %w", err)
+	} else if !found {
+		return nil, fmt.Errorf("filter metadata %q: %w", namespace, types.ErrorStatusNotFound)
+	}
+	return result, nil
+}
+
+// readAttribute reads the property into dst, or records its path in unavailable when it
+// cannot be read so that snapshots such as RequestInfo tolerate missing attributes.
+func readAttribute[T any](unavailable *[]string, path proxywasm.PropertyPath, get func(proxywasm.PropertyPath) (T, error), dst *T) error {
+	v, err := get(path)
+	if err != nil {
+		*unavailable = append(*unavailable, path.String())
+		return err
+	}
+	*dst = v
+	return nil
+}
+
 // getPropertyBool returns a bool property.
-func getPropertyBool(path []string) (bool, error) {
-	bs, err := proxywasm.GetProperty(path)
+func getPropertyBool(path proxywasm.PropertyPath) (bool, error) {
+	bs, err := proxywasm.GetPropertyPath(path)
 	if err != nil {
 		return false, err
 	}
@@ -56,8 +123,8 @@ func getPropertyBool(path []string) (bool, error) {
 
 // getPropertyByteSliceMap retrieves a complex property object as a map of byte slices.
 // to be used when dealing with mixed type properties
-func getPropertyByteSliceMap(path []string) (map[string][]byte, error) { //nolint:unused
-	bs, err := proxywasm.GetProperty(path)
+func getPropertyByteSliceMap(path proxywasm.PropertyPath) (map[string][]byte, error) { //nolint:unused
+	bs, err := proxywasm.GetPropertyPath(path)
 	if err != nil {
 		return nil, err
 	}
@@ -66,8 +133,8 @@ func getPropertyByteSliceMap(path []string) (map[string][]byte, error) { //nolin
 }
 
 // getPropertyByteSliceSlice retrieves a complex property object as a string slice.
-func getPropertyByteSliceSlice(path []string) ([][]byte, error) {
-	bs, err := proxywasm.GetProperty(path)
+func getPropertyByteSliceSlice(path proxywasm.PropertyPath) ([][]byte, error) {
+	bs, err := proxywasm.GetPropertyPath(path)
 	if err != nil {
 		return nil, err
 	}
@@ -75,8 +142,8 @@ func getPropertyByteSliceSlice(path []string) ([][]byte, error) {
 }
 
 // getPropertyFloat64 returns a float64 property.
-func getPropertyFloat64(path []string) (float64, error) {
-	bs, err := proxywasm.GetProperty(path)
+func getPropertyFloat64(path proxywasm.PropertyPath) (float64, error) {
+	bs, err := proxywasm.GetPropertyPath(path)
 	if err != nil {
 		return 0, err
 	}
@@ -85,8 +152,8 @@ func getPropertyFloat64(path []string) (float64, error) {
 }
 
 // getPropertyString returns a string property.
-func getPropertyString(path []string) (string, error) {
-	bs, err := proxywasm.GetProperty(path)
+func getPropertyString(path proxywasm.PropertyPath) (string, error) {
+	bs, err := proxywasm.GetPropertyPath(path)
 	if err != nil {
 		return "", err
 	}
@@ -95,8 +162,8 @@ func getPropertyString(path []string) (string, error) {
 
 // getPropertyStringMap retrieves a complex property object as a map of string
 // to be used when dealing with string only type properties.
-func getPropertyStringMap(path []string) (map[string]string, error) {
-	bs, err := proxywasm.GetProperty(path)
+func getPropertyStringMap(path proxywasm.PropertyPath) (map[string]string, error) {
+	bs, err := proxywasm.GetPropertyPath(path)
 	if err != nil {
 		return nil, err
 	}
@@ -105,8 +172,8 @@ func getPropertyStringMap(path []string) (map[string]string, error) {
 }
 
 // getPropertyStringSlice retrieves a  complex property object as a string slice.
-func getPropertyStringSlice(path []string) ([]string, error) {
-	bs, err := proxywasm.GetProperty(path)
+func getPropertyStringSlice(path proxywasm.PropertyPath) ([]string, error) {
+	bs, err := proxywasm.GetPropertyPath(path)
 	if err != nil {
 		return nil, err
 	}
@@ -115,8 +182,8 @@ func getPropertyStringSlice(path []string) ([]string, error) {
 }
 
 // getPropertyTimestamp returns a timestamp property.
-func getPropertyTimestamp(path []string) (time.Time, error) {
-	bs, err := proxywasm.GetProperty(path)
+func getPropertyTimestamp(path proxywasm.PropertyPath) (time.Time, error) {
+	bs, err := proxywasm.GetPropertyPath(path)
 	if err != nil {
 		return time.Now().UTC(), err
 	}
@@ -125,8 +192,8 @@ func getPropertyTimestamp(path []string) (time.Time, error) {
 }
 
 // getPropertyUint64 returns a uint64 property.
-func getPropertyUint64(path []string) (uint64, error) {
-	bs, err := proxywasm.GetProperty(path)
+func getPropertyUint64(path proxywasm.PropertyPath) (uint64, error) {
+	bs, err := proxywasm.GetPropertyPath(path)
 	if err != nil {
 		return 0, err
 	}
@@ -4,6 +4,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm"
 	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/proxytest"
 	"github.com/stretchr/testify/require"
 )
@@ -13,7 +14,7 @@ func TestGetPropertyBool(t *testing.T) {
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
-	result, err := getPropertyBool([]string{"someBoolPath"})
+	result, err := getPropertyBool(proxywasm.NewPropertyPath("someBoolPath"))
 	require.NoError(t, err)
 	require.Equal(t, true, result)
 }
@@ -28,7 +29,7 @@ func TestGetPropertyByteSliceMap(t *testing.T) {
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
-	result, err := getPropertyByteSliceMap([]string{"someByteSliceMapPath"})
+	result, err := getPropertyByteSliceMap(proxywasm.NewPropertyPath("someByteSliceMapPath"))
 	require.NoError(t, err)
 	require.Equal(t, input, result)
 }
@@ -43,7 +44,7 @@ func TestGetPropertyByteSliceSlice(t *testing.T) {
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
-	result, err := getPropertyByteSliceSlice([]string{"someByteSliceSlicePath"})
+	result, err := getPropertyByteSliceSlice(proxywasm.NewPropertyPath("someByteSliceSlicePath"))
 	require.NoError(t, err)
 	require.Equal(t, input, result)
 }
@@ -53,7 +54,7 @@ func TestGetPropertyFloat64(t *testing.T) {
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
-	result, err := getPropertyFloat64([]string{"someFloat64Path"})
+	result, err := getPropertyFloat64(proxywasm.NewPropertyPath("someFloat64Path"))
 	require.NoError(t, err)
 	require.Equal(t, 3.14, result)
 }
@@ -63,7 +64,7 @@ func TestGetPropertyString(t *testing.T) {
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
-	result, err := getPropertyString([]string{"someStringPath"})
+	result, err := getPropertyString(proxywasm.NewPropertyPath("someStringPath"))
 	require.NoError(t, err)
 	require.Equal(t, "testString", result)
 }
@@ -78,7 +79,7 @@ func TestGetPropertyStringMap(t *testing.T) {
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
-	result, err := getPropertyStringMap([]string{"someStringMapPath"})
+	result, err := getPropertyStringMap(proxywasm.NewPropertyPath("someStringMapPath"))
 	require.NoError(t, err)
 	require.Equal(t, input, result)
 }
@@ -90,7 +91,7 @@ func TestGetPropertyStringSlice(t *testing.T) {
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
-	result, err := getPropertyStringSlice([]string{"someStringSlicePath"})
+	result, err := getPropertyStringSlice(proxywasm.NewPropertyPath("someStringSlicePath"))
 	require.NoError(t, err)
 	require.Equal(t, input, result)
 }
@@ -102,7 +103,7 @@ func TestGetPropertyTimestamp(t *testing.T) {
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
-	result, err := getPropertyTimestamp([]string{"someTimestampPath"})
+	result, err := getPropertyTimestamp(proxywasm.NewPropertyPath("someTimestampPath"))
 	require.NoError(t, err)
 	require.Equal(t, now, result)
 }
@@ -112,7 +113,7 @@ func TestGetPropertyUint64(t *testing.T) {
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
-	result, err := getPropertyUint64([]string{"someUint64Path"})
+	result, err := getPropertyUint64(proxywasm.NewPropertyPath("someUint64Path"))
 	require.NoError(t, err)
 	require.Equal(t, uint64(12345), result)
 }
@@ -11,28 +11,28 @@ import (
 // https://www.envoyproxy.io/docs/envoy/latest/intro/arch_overview/advanced/attributes#wasm-attributes
 
 var (
-	pluginName                = []string{"plugin_name"}
-	pluginRootId              = []string{"plugin_root_id"}
-	pluginVmId                = []string{"plugin_vm_id"}
-	clusterName               = []string{"cluster_name"}
-	routeName                 = []string{"route_name"}
-	listenerDirection         = []string{"listener_direction"}
-	nodeId                    = []string{"node", "id"}
-	nodeCluster               = []string{"node", "cluster"}
-	nodeDynamicParams         = []string{"node", "dynamic_parameters", "params"}
-	nodeLocalityRegion        = []string{"node", "locality", "region"}
-	nodeLocalityZone          = []string{"node", "locality", "zone"}
-	nodeLocalitySubzone       = []string{"node", "locality", "subzone"}
-	nodeUserAgentName         = []string{"node", "user_agent_name"}
-	nodeUserAgentVersion      = []string{"node", "user_agent_version"}
-	nodeUserAgentBuildVersion = []string{"node", "user_agent_build_version", "metadata"}
-	nodeExtensions            = []string{"node", "extensions"}
-	nodeClientFeatures        = []string{"node", "client_features"}
-	nodeListeningAddresses    = []string{"node", "listening_addresses"}
-	clusterMetadata           = []string{"node", "cluster_metadata", "filter_metadata", "istio"}
-	listenerMetadata          = []string{"node", "listener_metadata", "filter_metadata", "istio"}
-	routeMetadata             = []string{"node", "route_metadata", "filter_metadata", "istio"}
-	upstreamHostMetadata      = []string{"node", "upstream_host_metadata", "filter_metadata", "istio"}
+	pluginName                = proxywasm.NewPropertyPath("plugin_name")
+	pluginRootId              = proxywasm.NewPropertyPath("plugin_root_id")
+	pluginVmId                = proxywasm.NewPropertyPath("plugin_vm_id")
+	clusterName               = proxywasm.NewPropertyPath("cluster_name")
+	routeName                 = proxywasm.NewPropertyPath("route_name")
+	listenerDirection         = proxywasm.NewPropertyPath("listener_direction")
+	nodeId                    = proxywasm.NewPropertyPath("node", "id")
+	nodeCluster               = proxywasm.NewPropertyPath("node", "cluster")
+	nodeDynamicParams         = proxywasm.NewPropertyPath("node", "dynamic_parameters", "params")
+	nodeLocalityRegion        = proxywasm.NewPropertyPath("node", "locality", "region")
+	nodeLocalityZone          = proxywasm.NewPropertyPath("node", "locality", "zone")
+	nodeLocalitySubzone       = proxywasm.NewPropertyPath("node", "locality", "subzone")
+	nodeUserAgentName         = proxywasm.NewPropertyPath("node", "user_agent_name")
+	nodeUserAgentVersion      = proxywasm.NewPropertyPath("node", "user_agent_version")
+	nodeUserAgentBuildVersion = proxywasm.NewPropertyPath("node", "user_agent_build_version", "metadata")
+	nodeExtensions            = proxywasm.NewPropertyPath("node", "extensions")
+	nodeClientFeatures        = proxywasm.NewPropertyPath("node", "client_features")
+	nodeListeningAddresses    = proxywasm.NewPropertyPath("node", "listening_addresses")
+	clusterMetadata           = newIstioFilterMetadataPath("node", "cluster_metadata", "filter_metadata", "istio")
+	listenerMetadata          = newIstioFilterMetadataPath("node", "listener_metadata", "filter_metadata", "istio")
+	routeMetadata             = newIstioFilterMetadataPath("node", "route_metadata", "filter_metadata", "istio")
+	upstreamHostMetadata      = newIstioFilterMetadataPath("node", "upstream_host_metadata", "filter_metadata", "istio")
 )
 
 // GetPluginName returns the plugin name.
@@ -207,7 +207,7 @@ func GetNodeExtensions() ([]EnvoyExtension, error) {
 // described in the Envoy API repository for a given major version of an API. Client
 // features use reverse DNS naming scheme, for example "com.acme.feature".
 func GetNodeClientFeatures() ([]string, error) {
-	result, err := proxywasm.GetProperty(nodeClientFeatures)
+	result, err := proxywasm.GetPropertyPath(nodeClientFeatures)
 	if err != nil {
 		return []string{}, err
 	}
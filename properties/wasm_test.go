@@ -8,7 +8,7 @@ import (
 )
 
 func TestGetPluginName(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(pluginName, []byte("istio-ingress.print-properties"))
+	opt := proxytest.NewEmulatorOption().WithProperty(pluginName.Path(), []byte("istio-ingress.print-properties"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -18,7 +18,7 @@ func TestGetPluginName(t *testing.T) {
 }
 
 func TestGetPluginRootId(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(pluginRootId, []byte("print-properties"))
+	opt := proxytest.NewEmulatorOption().WithProperty(pluginRootId.Path(), []byte("print-properties"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -28,7 +28,7 @@ func TestGetPluginRootId(t *testing.T) {
 }
 
 func TestGetPluginVmId(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(pluginVmId, []byte("plugin-vm-id-value"))
+	opt := proxytest.NewEmulatorOption().WithProperty(pluginVmId.Path(), []byte("plugin-vm-id-value"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -38,7 +38,7 @@ func TestGetPluginVmId(t *testing.T) {
 }
 
 func TestGetClusterName(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(clusterName, []byte("outbound|80||httpbin.org"))
+	opt := proxytest.NewEmulatorOption().WithProperty(clusterName.Path(), []byte("outbound|80||httpbin.org"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -48,7 +48,7 @@ func TestGetClusterName(t *testing.T) {
 }
 
 func TestGetRouteName(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(routeName, []byte("route-name-value"))
+	opt := proxytest.NewEmulatorOption().WithProperty(routeName.Path(), []byte("route-name-value"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -82,7 +82,7 @@ func TestGetListenerDirection(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			opt := proxytest.NewEmulatorOption().WithProperty(listenerDirection, serializeUint64(tt.input))
+			opt := proxytest.NewEmulatorOption().WithProperty(listenerDirection.Path(), serializeUint64(tt.input))
 			_, reset := proxytest.NewHostEmulator(opt)
 			defer reset()
 
@@ -94,7 +94,7 @@ func TestGetListenerDirection(t *testing.T) {
 }
 
 func TestGetNodeId(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(nodeId, []byte("router~10.244.0.22~istio-ingress-6d78c67d85-qsbtz.istio-ingress~istio-ingress.svc.cluster.local"))
+	opt := proxytest.NewEmulatorOption().WithProperty(nodeId.Path(), []byte("router~10.244.0.22~istio-ingress-6d78c67d85-qsbtz.istio-ingress~istio-ingress.svc.cluster.local"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -104,7 +104,7 @@ func TestGetNodeId(t *testing.T) {
 }
 
 func TestGetNodeCluster(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(nodeCluster, []byte("istio-ingress.istio-ingress"))
+	opt := proxytest.NewEmulatorOption().WithProperty(nodeCluster.Path(), []byte("istio-ingress.istio-ingress"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -114,7 +114,7 @@ func TestGetNodeCluster(t *testing.T) {
 }
 
 func TestGetNodeDynamicParams(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(nodeDynamicParams, []byte("dynamic-params-value"))
+	opt := proxytest.NewEmulatorOption().WithProperty(nodeDynamicParams.Path(), []byte("dynamic-params-value"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -125,9 +125,9 @@ func TestGetNodeDynamicParams(t *testing.T) {
 
 func TestGetNodeLocality(t *testing.T) {
 	opt := proxytest.NewEmulatorOption().
-		WithProperty(nodeLocalityRegion, []byte("region-value")).
-		WithProperty(nodeLocalityZone, []byte("zone-value")).
-		WithProperty(nodeLocalitySubzone, []byte("subzone-value"))
+		WithProperty(nodeLocalityRegion.Path(), []byte("region-value")).
+		WithProperty(nodeLocalityZone.Path(), []byte("zone-value")).
+		WithProperty(nodeLocalitySubzone.Path(), []byte("subzone-value"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -139,7 +139,7 @@ func TestGetNodeLocality(t *testing.T) {
 }
 
 func TestGetNodeUserAgentName(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(nodeUserAgentName, []byte("envoy"))
+	opt := proxytest.NewEmulatorOption().WithProperty(nodeUserAgentName.Path(), []byte("envoy"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -149,7 +149,7 @@ func TestGetNodeUserAgentName(t *testing.T) {
 }
 
 func TestGetNodeUserAgentVersion(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(nodeUserAgentVersion, []byte("1.12.2"))
+	opt := proxytest.NewEmulatorOption().WithProperty(nodeUserAgentVersion.Path(), []byte("1.12.2"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -159,7 +159,7 @@ func TestGetNodeUserAgentVersion(t *testing.T) {
 }
 
 func TestGetNodeUserAgentBuildVersion(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(nodeUserAgentBuildVersion, []byte("build-version-value"))
+	opt := proxytest.NewEmulatorOption().WithProperty(nodeUserAgentBuildVersion.Path(), []byte("build-version-value"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -169,7 +169,7 @@ func TestGetNodeUserAgentBuildVersion(t *testing.T) {
 }
 
 func TestGetNodeClientFeatures(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(nodeClientFeatures, serializeProtoStringSlice([]string{"feature1-data", "feature2-data"}))
+	opt := proxytest.NewEmulatorOption().WithProperty(nodeClientFeatures.Path(), serializeProtoStringSlice([]string{"feature1-data", "feature2-data"}))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -179,7 +179,7 @@ func TestGetNodeClientFeatures(t *testing.T) {
 }
 
 func TestGetNodeListeningAddresses(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(nodeListeningAddresses, serializeStringSlice([]string{"192.168.0.10", "10.0.0.20"}))
+	opt := proxytest.NewEmulatorOption().WithProperty(nodeListeningAddresses.Path(), serializeStringSlice([]string{"192.168.0.10", "10.0.0.20"}))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
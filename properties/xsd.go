@@ -1,15 +1,22 @@
 package properties
 
+import "github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm"
+
 // This file hosts helper functions to retrieve xsd-configuration-related properties as described in:
 // https://www.envoyproxy.io/docs/envoy/latest/intro/arch_overview/advanced/attributes#configuration-attributes
 
 var (
-	xdsClusterName             = []string{"xds", "cluster_name"}
-	xdsClusterMetadata         = []string{"xds", "cluster_metadata", "filter_metadata", "istio"}
-	xdsRouteName               = []string{"xds", "route_name"}
-	xdsRouteMetadata           = []string{"xds", "route_metadata", "filter_metadata", "istio"}
-	xdsUpstreamHostMetadata    = []string{"xds", "upstream_host_metadata", "filter_metadata", "istio"}
-	xdsListenerFilterChainName = []string{"xds", "filter_chain_name"}
+	xdsClusterName             = proxywasm.NewPropertyPath("xds", "cluster_name")
+	xdsClusterMetadata         = newIstioFilterMetadataPath("xds", "cluster_metadata", "filter_metadata", "istio")
+	xdsRouteName               = proxywasm.NewPropertyPath("xds", "route_name")
+	xdsRouteMetadata           = newIstioFilterMetadataPath("xds", "route_metadata", "filter_metadata", "istio")
+	xdsUpstreamHostMetadata    = newIstioFilterMetadataPath("xds", "upstream_host_metadata", "filter_metadata", "istio")
+	xdsListenerFilterChainName = proxywasm.NewPropertyPath("xds", "filter_chain_name")
+
+	xdsClusterMetadataProto      = proxywasm.NewPropertyPath("xds", "cluster_metadata")
+	xdsListenerMetadataProto     = proxywasm.NewPropertyPath("xds", "listener_metadata")
+	xdsRouteMetadataProto        = proxywasm.NewPropertyPath("xds", "route_metadata")
+	xdsUpstreamHostMetadataProto = proxywasm.NewPropertyPath("xds", "upstream_host_metadata")
 )
 
 // GetXdsClusterName returns the upstream cluster name.
@@ -20,6 +27,7 @@ func GetXdsClusterName() (string, error) {
 }
 
 // GetXdsClusterMetadata returns the upstream cluster metadata.
+// It only reads the "istio" namespace, use GetClusterFilterMetadata for other namespaces.
 func GetXdsClusterMetadata() (IstioFilterMetadata, error) {
 	return getIstioFilterMetadata(xdsClusterMetadata)
 }
@@ -32,11 +40,13 @@ func GetXdsRouteName() (string, error) {
 }
 
 // GetXdsRouteMetadata returns the upstream route metadata.
+// It only reads the "istio" namespace, use GetRouteFilterMetadata for other namespaces.
 func GetXdsRouteMetadata() (IstioFilterMetadata, error) {
 	return getIstioFilterMetadata(xdsRouteMetadata)
 }
 
 // GetXdsUpstreamHostMetadata returns the upstream host metadata.
+// It only reads the "istio" namespace, use GetUpstreamHostFilterMetadata for other namespaces.
 func GetXdsUpstreamHostMetadata() (IstioFilterMetadata, error) {
 	return getIstioFilterMetadata(xdsUpstreamHostMetadata)
 }
@@ -45,3 +55,27 @@ func GetXdsUpstreamHostMetadata() (IstioFilterMetadata, error) {
 func GetXdsListenerFilterChainName() (string, error) {
 	return getPropertyString(xdsListenerFilterChainName)
 }
+
+// GetClusterFilterMetadata returns the filter metadata of the upstream cluster in the given namespace,
+// e.g. "envoy.lb". Values of the map are nil, bool, float64, string, []any or map[string]any.
+func GetClusterFilterMetadata(namespace string) (map[string]any, error) {
+	return getFilterMetadata(xdsClusterMetadataProto, namespace)
+}
+
+// GetListenerFilterMetadata returns the filter metadata of the listener in the given namespace.
+// Values of the map are nil, bool, float64, string, []any or map[string]any.
+func GetListenerFilterMetadata(namespace string) (map[string]any, error) {
+	return getFilterMetadata(xdsListenerMetadataProto, namespace)
+}
+
+// GetRouteFilterMetadata returns the filter metadata of the route in the given namespace.
+// Values of the map are nil, bool, float64, string, []any or map[string]any.
+func GetRouteFilterMetadata(namespace string) (map[string]any, error) {
+	return getFilterMetadata(xdsRouteMetadataProto, namespace)
+}
+
+// GetUpstreamHostFilterMetadata returns the filter metadata of the upstream host in the given namespace.
+// Values of the map are nil, bool, float64, string, []any or map[string]any.
+func GetUpstreamHostFilterMetadata(namespace string) (map[string]any, error) {
+	return getFilterMetadata(xdsUpstreamHostMetadataProto, namespace)
+}
@@ -3,12 +3,14 @@ package properties
 import (
 	"testing"
 
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm"
 	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/proxytest"
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/types"
 	"github.com/stretchr/testify/require"
 )
 
 func TestGetXdsClusterName(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(xdsClusterName, []byte("outbound|80||httpbin.org"))
+	opt := proxytest.NewEmulatorOption().WithProperty(xdsClusterName.Path(), []byte("outbound|80||httpbin.org"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -18,7 +20,7 @@ func TestGetXdsClusterName(t *testing.T) {
 }
 
 func TestGetXdsRouteName(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(xdsRouteName, []byte("routename"))
+	opt := proxytest.NewEmulatorOption().WithProperty(xdsRouteName.Path(), []byte("routename"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -27,7 +29,7 @@ func TestGetXdsRouteName(t *testing.T) {
 	require.Equal(t, "routename", result)
 }
 func TestGetXdsListenerFilterChainName(t *testing.T) {
-	opt := proxytest.NewEmulatorOption().WithProperty(xdsListenerFilterChainName, []byte("mychain"))
+	opt := proxytest.NewEmulatorOption().WithProperty(xdsListenerFilterChainName.Path(), []byte("mychain"))
 	_, reset := proxytest.NewHostEmulator(opt)
 	defer reset()
 
@@ -35,3 +37,64 @@ func TestGetXdsListenerFilterChainName(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, "mychain", result)
 }
+
+// serializeMetadata encodes an envoy.config.core.v3.Metadata message with the filter metadata.
+func serializeMetadata(t *testing.T, filterMetadata map[string]map[string]any) []byte {
+	var bs []byte
+	for namespace, fields := range filterMetadata {
+		s, err := serializeStruct(fields)
+		require.NoError(t, err)
+		entry := appendProtoBytes(nil, mapEntryKeyField, []byte(namespace))
+		entry = appendProtoBytes(entry, mapEntryValueField, s)
+		bs = appendProtoBytes(bs, metadataFilterMetadataField, entry)
+	}
+	// typed_filter_metadata is ignored.
+	return appendProtoBytes(bs, 2, []byte{0x0a, 0x00})
+}
+
+func TestGetFilterMetadata(t *testing.T) {
+	metadata := serializeMetadata(t, map[string]map[string]any{
+		"envoy.lb": {"canary": true, "weight": 10},
+		"custom": {
+			"owner":  "team-a",
+			"limits": map[string]any{"rps": 100.5, "burst": nil},
+			"tags":   []any{"a", map[string]any{"b": false}},
+		},
+	})
+
+	for _, tc := range []struct {
+		path proxywasm.PropertyPath
+		get  func(string) (map[string]any, error)
+	}{
+		{path: xdsClusterMetadataProto, get: GetClusterFilterMetadata},
+		{path: xdsListenerMetadataProto, get: GetListenerFilterMetadata},
+		{path: xdsRouteMetadataProto, get: GetRouteFilterMetadata},
+		{path: xdsUpstreamHostMetadataProto, get: GetUpstreamHostFilterMetadata},
+	} {
+		t.Run(tc.path.String(), func(t *testing.T) {
+			opt := proxytest.NewEmulatorOption().WithProperty(tc.path.Path(), metadata)
+			_, reset := proxytest.NewHostEmulator(opt)
+			defer reset()
+
+			lb, err := tc.get("envoy.lb")
+			require.NoError(t, err)
+			require.Equal(t, map[string]any{"canary": true, "weight": 10.0}, lb)
+
+			custom, err := tc.get("custom")
+			require.NoError(t, err)
+			require.Equal(t, map[string]any{
+				"owner":  "team-a",
+				"limits": map[string]any{"rps": 100.5, "burst": nil},
+				"tags":   []any{"a", map[string]any{"b": false}},
+			}, custom)
+
+			_, err = tc.get("unknown")
+			require.ErrorIs(t, err, types.ErrorStatusNotFound)
+		})
+	}
+
+	_, reset := proxytest.NewHostEmulator(proxytest.NewEmulatorOption())
+	defer reset()
+	_, err := GetClusterFilterMetadata("envoy.lb")
+	require.ErrorIs(t, err, types.ErrorStatusNotFound)
+}
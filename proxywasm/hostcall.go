@@ -18,6 +18,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"strings"
 	"unsafe"
 
 	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/internal"
@@ -564,15 +565,32 @@ func GetProperty(path []string) ([]byte, error) {
 	if len(path) == 0 {
 		return nil, errors.New("path must not be empty")
 	}
+	return getProperty(internal.SerializePropertyPath(path))
+}
+
+// GetPropertyPath is the same as GetProperty but takes a path serialized beforehand by
+// NewPropertyPath, so that no allocation is made for the path.
+func GetPropertyPath(path PropertyPath) ([]byte, error) {
+	if len(path.raw) == 0 {
+		return nil, errors.New("path must not be empty")
+	}
+	return getProperty(path.raw)
+}
+
+func getProperty(raw []byte) ([]byte, error) {
+	if value, ok := internal.GetCachedProperty(raw); ok {
+		return value, nil
+	}
+
 	var ret *byte
 	var retSize int32
-	raw := internal.SerializePropertyPath(path)
-
 	err := internal.StatusToError(internal.ProxyGetProperty(&raw[0], int32(len(raw)), unsafe.Pointer(&ret), &retSize))
 	if err != nil {
 		return nil, err
 	}
-	return unsafe.Slice(ret, retSize), nil
+	value := unsafe.Slice(ret, retSize)
+	internal.CacheProperty(raw, value)
+	return value, nil
 }
 
 // GetPropertyMap is the same as GetProperty but can be used to decode map-typed properties.
@@ -596,15 +614,73 @@ func GetPropertyMap(path []string) ([][2]string, error) {
 func SetProperty(path []string, data []byte) error {
 	if len(path) == 0 {
 		return errors.New("path must not be empty")
-	} else if len(data) == 0 {
+	}
+	return setProperty(internal.SerializePropertyPath(path), data)
+}
+
+// SetPropertyPath is the same as SetProperty but takes a path serialized beforehand by
+// NewPropertyPath, so that no allocation is made for the path.
+func SetPropertyPath(path PropertyPath, data []byte) error {
+	if len(path.raw) == 0 {
+		return errors.New("path must not be empty")
+	}
+	return setProperty(path.raw, data)
+}
+
+func setProperty(raw []byte, data []byte) error {
+	if len(data) == 0 {
 		return errors.New("data must not be empty")
 	}
-	raw := internal.SerializePropertyPath(path)
+	// Setting a property may change others, e.g. the dynamic metadata, so drop them all.
+	internal.InvalidatePropertyCache()
 	return internal.StatusToError(internal.ProxySetProperty(
 		&raw[0], int32(len(raw)), &data[0], int32(len(data)),
 	))
 }
 
+// PropertyPath is a property path serialized once by NewPropertyPath, typically into a
+// package-level variable, so that static properties such as request.path are read with
+// GetPropertyPath without serializing their path on each call.
+type PropertyPath struct {
+	path []string
+	raw  []byte
+}
+
+// NewPropertyPath serializes the path for GetPropertyPath and SetPropertyPath.
+func NewPropertyPath(path ...string) PropertyPath {
+	path = append([]string(nil), path...)
+	return PropertyPath{path: path, raw: internal.SerializePropertyPath(path)}
+}
+
+// Append returns the path extended with the given elements. The receiver is not modified.
+func (p PropertyPath) Append(path ...string) PropertyPath {
+	return NewPropertyPath(append(p.Path(), path...)...)
+}
+
+// Path returns a copy of the elements of the path, as taken by GetProperty.
+func (p PropertyPath) Path() []string {
+	return append([]string(nil), p.path...)
+}
+
+// String returns the elements of the path joined with dots, e.g. "request.path".
+func (p PropertyPath) String() string {
+	return strings.Join(p.path, ".")
+}
+
+// EnablePropertyCache enables the memoization of the properties read by GetProperty and
+// GetPropertyMap, and therefore by the getters of the properties package. Each stream has
+// its own cache which is dropped by SetProperty in the stream and at the end of the stream,
+// before OnHttpStreamDone and OnStreamDone. Properties read by plugin contexts, e.g. in
+// OnTick, are not cached. The hit rate of each cache is logged at debug level when its
+// context is deleted.
+//
+// The cache is opt-in because properties which change during a stream, such as request.size
+// or response.code, are only read once until the end of the stream. Bytes returned from the
+// cache are shared and must not be modified. It is typically called in OnVMStart.
+func EnablePropertyCache() {
+	internal.EnablePropertyCache()
+}
+
 // CallForeignFunction calls a foreign function of given funcName defined by host implementations.
 // Foreign functions are host-specific functions, so please refer to the doc of your host implementation for detail.
 func CallForeignFunction(funcName string, param []byte) (ret []byte, err error) {
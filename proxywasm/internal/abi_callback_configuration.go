@@ -25,7 +25,7 @@ func proxyOnVMStart(_ uint32, vmConfigurationSize int32) types.OnVMStartStatus {
 	if recordTiming {
 		defer logTiming("proxyOnVMStart", time.Now())
 	}
-	return currentState.vmContext.OnVMStart(int(vmConfigurationSize))
+	return vmState().vmContext.OnVMStart(int(vmConfigurationSize))
 }
 
 //go:wasmexport proxy_on_configure
@@ -33,10 +33,10 @@ func proxyOnConfigure(pluginContextID uint32, pluginConfigurationSize int32) typ
 	if recordTiming {
 		defer logTiming("proxyOnConfigure", time.Now())
 	}
-	ctx, ok := currentState.pluginContexts[pluginContextID]
+	ctx, ok := vmState().pluginContexts[pluginContextID]
 	if !ok {
 		panic("invalid context on proxy_on_configure")
 	}
-	currentState.setActiveContextID(pluginContextID)
+	vmState().setActiveContextID(pluginContextID)
 	return ctx.context.OnPluginStart(int(pluginConfigurationSize))
 }
@@ -25,11 +25,11 @@ func proxyOnNewConnection(contextID uint32) types.Action {
 	if recordTiming {
 		defer logTiming("proxyOnNewConnection", time.Now())
 	}
-	ctx, ok := currentState.tcpContexts[contextID]
+	ctx, ok := vmState().tcpContexts[contextID]
 	if !ok {
 		panic("invalid context")
 	}
-	currentState.setActiveContextID(contextID)
+	vmState().setActiveContextID(contextID)
 	return ctx.OnNewConnection()
 }
 
@@ -38,11 +38,11 @@ func proxyOnDownstreamData(contextID uint32, dataSize int32, endOfStream bool) t
 	if recordTiming {
 		defer logTiming("proxyOnDownstreamData", time.Now())
 	}
-	ctx, ok := currentState.tcpContexts[contextID]
+	ctx, ok := vmState().tcpContexts[contextID]
 	if !ok {
 		panic("invalid context")
 	}
-	currentState.setActiveContextID(contextID)
+	vmState().setActiveContextID(contextID)
 	return ctx.OnDownstreamData(int(dataSize), endOfStream)
 }
 
@@ -51,11 +51,11 @@ func proxyOnDownstreamConnectionClose(contextID uint32, pType types.PeerType) {
 	if recordTiming {
 		defer logTiming("proxyOnDownstreamConnectionClose", time.Now())
 	}
-	ctx, ok := currentState.tcpContexts[contextID]
+	ctx, ok := vmState().tcpContexts[contextID]
 	if !ok {
 		panic("invalid context")
 	}
-	currentState.setActiveContextID(contextID)
+	vmState().setActiveContextID(contextID)
 	ctx.OnDownstreamClose(pType)
 }
 
@@ -64,11 +64,11 @@ func proxyOnUpstreamData(contextID uint32, dataSize int32, endOfStream bool) typ
 	if recordTiming {
 		defer logTiming("proxyOnUpstreamData", time.Now())
 	}
-	ctx, ok := currentState.tcpContexts[contextID]
+	ctx, ok := vmState().tcpContexts[contextID]
 	if !ok {
 		panic("invalid context")
 	}
-	currentState.setActiveContextID(contextID)
+	vmState().setActiveContextID(contextID)
 	return ctx.OnUpstreamData(int(dataSize), endOfStream)
 }
 
@@ -77,10 +77,10 @@ func proxyOnUpstreamConnectionClose(contextID uint32, pType types.PeerType) {
 	if recordTiming {
 		defer logTiming("proxyOnUpstreamConnectionClose", time.Now())
 	}
-	ctx, ok := currentState.tcpContexts[contextID]
+	ctx, ok := vmState().tcpContexts[contextID]
 	if !ok {
 		panic("invalid context")
 	}
-	currentState.setActiveContextID(contextID)
+	vmState().setActiveContextID(contextID)
 	ctx.OnUpstreamClose(pType)
 }
@@ -25,12 +25,12 @@ func proxyOnRequestHeaders(contextID uint32, numHeaders int32, endOfStream bool)
 	if recordTiming {
 		defer logTiming("proxyOnRequestHeaders", time.Now())
 	}
-	ctx, ok := currentState.httpContexts[contextID]
+	ctx, ok := vmState().httpContexts[contextID]
 	if !ok {
 		panic("invalid context on proxy_on_request_headers")
 	}
 
-	currentState.setActiveContextID(contextID)
+	vmState().setActiveContextID(contextID)
 	return ctx.OnHttpRequestHeaders(int(numHeaders), endOfStream)
 }
 
@@ -39,11 +39,11 @@ func proxyOnRequestBody(contextID uint32, bodySize int32, endOfStream bool) type
 	if recordTiming {
 		defer logTiming("proxyOnRequestBody", time.Now())
 	}
-	ctx, ok := currentState.httpContexts[contextID]
+	ctx, ok := vmState().httpContexts[contextID]
 	if !ok {
 		panic("invalid context on proxy_on_request_body")
 	}
-	currentState.setActiveContextID(contextID)
+	vmState().setActiveContextID(contextID)
 	return ctx.OnHttpRequestBody(int(bodySize), endOfStream)
 }
 
@@ -52,11 +52,11 @@ func proxyOnRequestTrailers(contextID uint32, numTrailers int32) types.Action {
 	if recordTiming {
 		defer logTiming("proxyOnRequestTrailers", time.Now())
 	}
-	ctx, ok := currentState.httpContexts[contextID]
+	ctx, ok := vmState().httpContexts[contextID]
 	if !ok {
 		panic("invalid context on proxy_on_request_trailers")
 	}
-	currentState.setActiveContextID(contextID)
+	vmState().setActiveContextID(contextID)
 	return ctx.OnHttpRequestTrailers(int(numTrailers))
 }
 
@@ -65,11 +65,11 @@ func proxyOnResponseHeaders(contextID uint32, numHeaders int32, endOfStream bool
 	if recordTiming {
 		defer logTiming("proxyOnResponseHeaders", time.Now())
 	}
-	ctx, ok := currentState.httpContexts[contextID]
+	ctx, ok := vmState().httpContexts[contextID]
 	if !ok {
 		panic("invalid context id on proxy_on_response_headers")
 	}
-	currentState.setActiveContextID(contextID)
+	vmState().setActiveContextID(contextID)
 	return ctx.OnHttpResponseHeaders(int(numHeaders), endOfStream)
 }
 
@@ -78,11 +78,11 @@ func proxyOnResponseBody(contextID uint32, bodySize int32, endOfStream bool) typ
 	if recordTiming {
 		defer logTiming("proxyOnResponseBody", time.Now())
 	}
-	ctx, ok := currentState.httpContexts[contextID]
+	ctx, ok := vmState().httpContexts[contextID]
 	if !ok {
 		panic("invalid context id on proxy_on_response_headers")
 	}
-	currentState.setActiveContextID(contextID)
+	vmState().setActiveContextID(contextID)
 	return ctx.OnHttpResponseBody(int(bodySize), endOfStream)
 }
 
@@ -91,11 +91,11 @@ func proxyOnResponseTrailers(contextID uint32, numTrailers int32) types.Action {
 	if recordTiming {
 		defer logTiming("proxyOnResponseTrailers", time.Now())
 	}
-	ctx, ok := currentState.httpContexts[contextID]
+	ctx, ok := vmState().httpContexts[contextID]
 	if !ok {
 		panic("invalid context id on proxy_on_response_headers")
 	}
-	currentState.setActiveContextID(contextID)
+	vmState().setActiveContextID(contextID)
 	return ctx.OnHttpResponseTrailers(int(numTrailers))
 }
 
@@ -104,7 +104,7 @@ func proxyOnHttpCallResponse(pluginContextID, calloutID uint32, numHeaders, body
 	if recordTiming {
 		defer logTiming("proxyOnHttpCallResponse", time.Now())
 	}
-	root, ok := currentState.pluginContexts[pluginContextID]
+	root, ok := vmState().pluginContexts[pluginContextID]
 	if !ok {
 		panic("http_call_response on invalid plugin context")
 	}
@@ -115,7 +115,7 @@ func proxyOnHttpCallResponse(pluginContextID, calloutID uint32, numHeaders, body
 	}
 
 	ctxID := cb.callerContextID
-	currentState.setActiveContextID(ctxID)
+	vmState().setActiveContextID(ctxID)
 	delete(root.httpCallbacks, calloutID)
 
 	// Check if the context is already deleted.
@@ -124,7 +124,7 @@ func proxyOnHttpCallResponse(pluginContextID, calloutID uint32, numHeaders, body
 	// In that case, if the callback continues response or make local reply, then the subsequent
 	// callbacks (for example OnHttpResponseHeaders) would follow and result in calling callback
 	// for already-deleted context id. See https://github.com/proxy-wasm/proxy-wasm-go-sdk/issues/261 for detail.
-	if _, ok := currentState.contextIDToRootID[ctxID]; ok {
+	if _, ok := vmState().contextIDToRootID[ctxID]; ok {
 		ProxySetEffectiveContext(ctxID)
 		cb.callback(int(numHeaders), int(bodySize), int(numTrailers))
 	}
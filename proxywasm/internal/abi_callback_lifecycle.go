@@ -24,9 +24,9 @@ func proxyOnContextCreate(contextID uint32, pluginContextID uint32) {
 		defer logTiming("proxyOnContextCreate", time.Now())
 	}
 	if pluginContextID == 0 {
-		currentState.createPluginContext(contextID)
-	} else if currentState.createHttpContext(contextID, pluginContextID) {
-	} else if currentState.createTcpContext(contextID, pluginContextID) {
+		vmState().createPluginContext(contextID)
+	} else if vmState().createHttpContext(contextID, pluginContextID) {
+	} else if vmState().createTcpContext(contextID, pluginContextID) {
 	} else {
 		panic("invalid context id on proxy_on_context_create")
 	}
@@ -37,11 +37,13 @@ func proxyOnLog(contextID uint32) {
 	if recordTiming {
 		defer logTiming("proxyOnLog", time.Now())
 	}
-	if ctx, ok := currentState.tcpContexts[contextID]; ok {
-		currentState.setActiveContextID(contextID)
+	// Properties such as sizes and durations are final at the end of the stream.
+	vmState().invalidatePropertyCache(contextID)
+	if ctx, ok := vmState().tcpContexts[contextID]; ok {
+		vmState().setActiveContextID(contextID)
 		ctx.OnStreamDone()
-	} else if ctx, ok := currentState.httpContexts[contextID]; ok {
-		currentState.setActiveContextID(contextID)
+	} else if ctx, ok := vmState().httpContexts[contextID]; ok {
+		vmState().setActiveContextID(contextID)
 		ctx.OnHttpStreamDone()
 	}
 }
@@ -51,8 +53,8 @@ func proxyOnDone(contextID uint32) bool {
 	if recordTiming {
 		defer logTiming("proxyOnDone", time.Now())
 	}
-	if ctx, ok := currentState.pluginContexts[contextID]; ok {
-		currentState.setActiveContextID(contextID)
+	if ctx, ok := vmState().pluginContexts[contextID]; ok {
+		vmState().setActiveContextID(contextID)
 		return ctx.context.OnPluginDone()
 	}
 	return true
@@ -63,14 +65,19 @@ func proxyOnDelete(contextID uint32) {
 	if recordTiming {
 		defer logTiming("proxyOnDelete", time.Now())
 	}
-	delete(currentState.contextIDToRootID, contextID)
-	if _, ok := currentState.tcpContexts[contextID]; ok {
-		delete(currentState.tcpContexts, contextID)
-	} else if _, ok = currentState.httpContexts[contextID]; ok {
-		delete(currentState.httpContexts, contextID)
-	} else if _, ok = currentState.pluginContexts[contextID]; ok {
-		delete(currentState.pluginContexts, contextID)
+	delete(vmState().contextIDToRootID, contextID)
+	vmState().deletePropertyCache(contextID)
+	if _, ok := vmState().tcpContexts[contextID]; ok {
+		delete(vmState().tcpContexts, contextID)
+	} else if _, ok = vmState().httpContexts[contextID]; ok {
+		delete(vmState().httpContexts, contextID)
+	} else if _, ok = vmState().pluginContexts[contextID]; ok {
+		delete(vmState().pluginContexts, contextID)
 	} else {
 		panic("invalid context on proxy_on_delete")
 	}
+	// Hostcalls made outside of callbacks must not act on the deleted context.
+	if vmState().activeContextID == contextID {
+		vmState().setActiveContextID(0)
+	}
 }
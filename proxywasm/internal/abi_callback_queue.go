@@ -21,11 +21,11 @@ func proxyOnQueueReady(contextID, queueID uint32) {
 	if recordTiming {
 		defer logTiming("proxyOnQueueReady", time.Now())
 	}
-	ctx, ok := currentState.pluginContexts[contextID]
+	ctx, ok := vmState().pluginContexts[contextID]
 	if !ok {
 		panic("invalid context")
 	}
 
-	currentState.setActiveContextID(contextID)
+	vmState().setActiveContextID(contextID)
 	ctx.context.OnQueueReady(queueID)
 }
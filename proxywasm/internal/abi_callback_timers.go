@@ -21,10 +21,10 @@ func proxyOnTick(pluginContextID uint32) {
 	if recordTiming {
 		defer logTiming("proxyOnTick", time.Now())
 	}
-	ctx, ok := currentState.pluginContexts[pluginContextID]
+	ctx, ok := vmState().pluginContexts[pluginContextID]
 	if !ok {
 		panic("invalid root_context_id")
 	}
-	currentState.setActiveContextID(pluginContextID)
+	vmState().setActiveContextID(pluginContextID)
 	ctx.context.OnTick()
 }
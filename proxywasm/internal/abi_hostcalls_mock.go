@@ -149,121 +149,121 @@ func (d DefaultProxyWAMSHost) ProxyGetMetric(metricID uint32, returnMetricValue
 }
 
 func ProxyLog(logLevel LogLevel, messageData *byte, messageSize int32) Status {
-	return currentHost.ProxyLog(logLevel, messageData, messageSize)
+	return mockHost().ProxyLog(logLevel, messageData, messageSize)
 }
 
 func ProxySetProperty(pathData *byte, pathSize int32, valueData *byte, valueSize int32) Status {
-	return currentHost.ProxySetProperty(pathData, pathSize, valueData, valueSize)
+	return mockHost().ProxySetProperty(pathData, pathSize, valueData, valueSize)
 }
 
 func ProxyGetProperty(pathData *byte, pathSize int32, returnValueData unsafe.Pointer, returnValueSize *int32) Status {
-	return currentHost.ProxyGetProperty(pathData, pathSize, returnValueData, returnValueSize)
+	return mockHost().ProxyGetProperty(pathData, pathSize, returnValueData, returnValueSize)
 }
 
 func ProxySendLocalResponse(statusCode uint32, statusCodeDetailData *byte,
 	statusCodeDetailsSize int32, bodyData *byte, bodySize int32, headersData *byte, headersSize int32, grpcStatus int32) Status {
-	return currentHost.ProxySendLocalResponse(statusCode,
+	return mockHost().ProxySendLocalResponse(statusCode,
 		statusCodeDetailData, statusCodeDetailsSize, bodyData, bodySize, headersData, headersSize, grpcStatus)
 }
 
 func ProxyGetSharedData(keyData *byte, keySize int32, returnValueData unsafe.Pointer, returnValueSize *int32, returnCas *uint32) Status {
-	return currentHost.ProxyGetSharedData(keyData, keySize, returnValueData, returnValueSize, returnCas)
+	return mockHost().ProxyGetSharedData(keyData, keySize, returnValueData, returnValueSize, returnCas)
 }
 
 func ProxySetSharedData(keyData *byte, keySize int32, valueData *byte, valueSize int32, cas uint32) Status {
-	return currentHost.ProxySetSharedData(keyData, keySize, valueData, valueSize, cas)
+	return mockHost().ProxySetSharedData(keyData, keySize, valueData, valueSize, cas)
 }
 
 func ProxyRegisterSharedQueue(nameData *byte, nameSize int32, returnID *uint32) Status {
-	return currentHost.ProxyRegisterSharedQueue(nameData, nameSize, returnID)
+	return mockHost().ProxyRegisterSharedQueue(nameData, nameSize, returnID)
 }
 
 func ProxyResolveSharedQueue(vmIDData *byte, vmIDSize int32, nameData *byte, nameSize int32, returnID *uint32) Status {
-	return currentHost.ProxyResolveSharedQueue(vmIDData, vmIDSize, nameData, nameSize, returnID)
+	return mockHost().ProxyResolveSharedQueue(vmIDData, vmIDSize, nameData, nameSize, returnID)
 }
 
 func ProxyDequeueSharedQueue(queueID uint32, returnValueData unsafe.Pointer, returnValueSize *int32) Status {
-	return currentHost.ProxyDequeueSharedQueue(queueID, returnValueData, returnValueSize)
+	return mockHost().ProxyDequeueSharedQueue(queueID, returnValueData, returnValueSize)
 }
 
 func ProxyEnqueueSharedQueue(queueID uint32, valueData *byte, valueSize int32) Status {
-	return currentHost.ProxyEnqueueSharedQueue(queueID, valueData, valueSize)
+	return mockHost().ProxyEnqueueSharedQueue(queueID, valueData, valueSize)
 }
 
 func ProxyGetHeaderMapValue(mapType MapType, keyData *byte, keySize int32, returnValueData unsafe.Pointer, returnValueSize *int32) Status {
-	return currentHost.ProxyGetHeaderMapValue(mapType, keyData, keySize, returnValueData, returnValueSize)
+	return mockHost().ProxyGetHeaderMapValue(mapType, keyData, keySize, returnValueData, returnValueSize)
 }
 
 func ProxyAddHeaderMapValue(mapType MapType, keyData *byte, keySize int32, valueData *byte, valueSize int32) Status {
-	return currentHost.ProxyAddHeaderMapValue(mapType, keyData, keySize, valueData, valueSize)
+	return mockHost().ProxyAddHeaderMapValue(mapType, keyData, keySize, valueData, valueSize)
 }
 
 func ProxyReplaceHeaderMapValue(mapType MapType, keyData *byte, keySize int32, valueData *byte, valueSize int32) Status {
-	return currentHost.ProxyReplaceHeaderMapValue(mapType, keyData, keySize, valueData, valueSize)
+	return mockHost().ProxyReplaceHeaderMapValue(mapType, keyData, keySize, valueData, valueSize)
 }
 
 func ProxyContinueStream(streamType StreamType) Status {
-	return currentHost.ProxyContinueStream(streamType)
+	return mockHost().ProxyContinueStream(streamType)
 }
 
 func ProxyCloseStream(streamType StreamType) Status {
-	return currentHost.ProxyCloseStream(streamType)
+	return mockHost().ProxyCloseStream(streamType)
 }
 func ProxyRemoveHeaderMapValue(mapType MapType, keyData *byte, keySize int32) Status {
-	return currentHost.ProxyRemoveHeaderMapValue(mapType, keyData, keySize)
+	return mockHost().ProxyRemoveHeaderMapValue(mapType, keyData, keySize)
 }
 
 func ProxyGetHeaderMapPairs(mapType MapType, returnValueData unsafe.Pointer, returnValueSize *int32) Status {
-	return currentHost.ProxyGetHeaderMapPairs(mapType, returnValueData, returnValueSize)
+	return mockHost().ProxyGetHeaderMapPairs(mapType, returnValueData, returnValueSize)
 }
 
 func ProxySetHeaderMapPairs(mapType MapType, mapData *byte, mapSize int32) Status {
-	return currentHost.ProxySetHeaderMapPairs(mapType, mapData, mapSize)
+	return mockHost().ProxySetHeaderMapPairs(mapType, mapData, mapSize)
 }
 
 func ProxyGetBufferBytes(bufferType BufferType, start int32, maxSize int32, returnBufferData unsafe.Pointer, returnBufferSize *int32) Status {
-	return currentHost.ProxyGetBufferBytes(bufferType, start, maxSize, returnBufferData, returnBufferSize)
+	return mockHost().ProxyGetBufferBytes(bufferType, start, maxSize, returnBufferData, returnBufferSize)
 }
 
 func ProxySetBufferBytes(bufferType BufferType, start int32, maxSize int32, bufferData *byte, bufferSize int32) Status {
-	return currentHost.ProxySetBufferBytes(bufferType, start, maxSize, bufferData, bufferSize)
+	return mockHost().ProxySetBufferBytes(bufferType, start, maxSize, bufferData, bufferSize)
 }
 
 func ProxyHttpCall(upstreamData *byte, upstreamSize int32, headerData *byte, headerSize int32, bodyData *byte,
 	bodySize int32, trailersData *byte, trailersSize int32, timeout uint32, calloutIDPtr *uint32) Status {
-	return currentHost.ProxyHttpCall(upstreamData, upstreamSize,
+	return mockHost().ProxyHttpCall(upstreamData, upstreamSize,
 		headerData, headerSize, bodyData, bodySize, trailersData, trailersSize, timeout, calloutIDPtr)
 }
 
 func ProxyCallForeignFunction(funcNamePtr *byte, funcNameSize int32, paramPtr *byte, paramSize int32, returnData unsafe.Pointer, returnSize *int32) Status {
-	return currentHost.ProxyCallForeignFunction(funcNamePtr, funcNameSize, paramPtr, paramSize, returnData, returnSize)
+	return mockHost().ProxyCallForeignFunction(funcNamePtr, funcNameSize, paramPtr, paramSize, returnData, returnSize)
 }
 
 func ProxySetTickPeriodMilliseconds(period uint32) Status {
-	return currentHost.ProxySetTickPeriodMilliseconds(period)
+	return mockHost().ProxySetTickPeriodMilliseconds(period)
 }
 
 func ProxySetEffectiveContext(contextID uint32) Status {
-	return currentHost.ProxySetEffectiveContext(contextID)
+	return mockHost().ProxySetEffectiveContext(contextID)
 }
 
 func ProxyDone() Status {
-	return currentHost.ProxyDone()
+	return mockHost().ProxyDone()
 }
 
 func ProxyDefineMetric(metricType MetricType,
 	metricNameData *byte, metricNameSize int32, returnMetricIDPtr *uint32) Status {
-	return currentHost.ProxyDefineMetric(metricType, metricNameData, metricNameSize, returnMetricIDPtr)
+	return mockHost().ProxyDefineMetric(metricType, metricNameData, metricNameSize, returnMetricIDPtr)
 }
 
 func ProxyIncrementMetric(metricID uint32, offset int64) Status {
-	return currentHost.ProxyIncrementMetric(metricID, offset)
+	return mockHost().ProxyIncrementMetric(metricID, offset)
 }
 
 func ProxyRecordMetric(metricID uint32, value uint64) Status {
-	return currentHost.ProxyRecordMetric(metricID, value)
+	return mockHost().ProxyRecordMetric(metricID, value)
 }
 
 func ProxyGetMetric(metricID uint32, returnMetricValue *uint64) Status {
-	return currentHost.ProxyGetMetric(metricID, returnMetricValue)
+	return mockHost().ProxyGetMetric(metricID, returnMetricValue)
 }
@@ -0,0 +1,101 @@
+// Copyright 2020-2024 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+)
+
+// propertyCache holds the properties read by a context, keyed by their serialized path.
+type propertyCache struct {
+	values       map[string][]byte
+	hits, misses int
+}
+
+// EnablePropertyCache makes GetCachedProperty and CacheProperty memoize properties per context.
+func EnablePropertyCache() {
+	vmState().propertyCacheEnabled = true
+}
+
+// GetCachedProperty returns the property of the serialized path cached for the active context.
+func GetCachedProperty(path []byte) ([]byte, bool) {
+	if !vmState().cachesProperties() {
+		return nil, false
+	}
+	c := vmState().propertyCache(vmState().activeContextID)
+	value, ok := c.values[string(path)]
+	if ok {
+		c.hits++
+	} else {
+		c.misses++
+	}
+	return value, ok
+}
+
+// CacheProperty caches the property of the serialized path for the active context.
+func CacheProperty(path []byte, value []byte) {
+	if !vmState().cachesProperties() {
+		return
+	}
+	vmState().propertyCache(vmState().activeContextID).values[string(path)] = value
+}
+
+// InvalidatePropertyCache drops the properties cached for the active context.
+func InvalidatePropertyCache() {
+	vmState().invalidatePropertyCache(vmState().activeContextID)
+}
+
+// cachesProperties returns whether the properties read by the active context are cached.
+// Only streams have a cache since it is dropped at their end: plugin contexts live as long
+// as the plugin, so the properties they read would never be refreshed.
+func (s *state) cachesProperties() bool {
+	if !s.propertyCacheEnabled {
+		return false
+	}
+	if _, ok := s.httpContexts[s.activeContextID]; ok {
+		return true
+	}
+	_, ok := s.tcpContexts[s.activeContextID]
+	return ok
+}
+
+func (s *state) propertyCache(contextID uint32) *propertyCache {
+	if s.propertyCaches == nil {
+		s.propertyCaches = make(map[uint32]*propertyCache)
+	}
+	c, ok := s.propertyCaches[contextID]
+	if !ok {
+		c = &propertyCache{values: make(map[string][]byte)}
+		s.propertyCaches[contextID] = c
+	}
+	return c
+}
+
+func (s *state) invalidatePropertyCache(contextID uint32) {
+	if c, ok := s.propertyCaches[contextID]; ok {
+		clear(c.values)
+	}
+}
+
+// deletePropertyCache drops the cache of the deleted context and logs its hit rate at debug level.
+func (s *state) deletePropertyCache(contextID uint32) {
+	c, ok := s.propertyCaches[contextID]
+	if !ok {
+		return
+	}
+	delete(s.propertyCaches, contextID)
+	msg := fmt.Sprintf("property cache of context %d: %d hits, %d misses", contextID, c.hits, c.misses)
+	ProxyLog(LogLevelDebug, StringBytePtr(msg), int32(len(msg)))
+}
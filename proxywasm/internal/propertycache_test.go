@@ -0,0 +1,108 @@
+// Copyright 2024 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/types"
+	"github.com/stretchr/testify/require"
+)
+
+type propertyCacheLogHost struct {
+	DefaultProxyWAMSHost
+	logs *[]string
+}
+
+func (h propertyCacheLogHost) ProxyLog(logLevel LogLevel, messageData *byte, messageSize int32) Status {
+	*h.logs = append(*h.logs, unsafe.String(messageData, messageSize))
+	return StatusOK
+}
+
+func TestPropertyCache(t *testing.T) {
+	var logs []string
+	release := RegisterMockWasmHost(propertyCacheLogHost{logs: &logs})
+	defer release()
+
+	var cID uint32 = 100
+	currentStateMux.Lock()
+	defer currentStateMux.Unlock()
+
+	currentState = &state{
+		pluginContexts:    map[uint32]*pluginContextState{cID - 1: {context: &types.DefaultPluginContext{}}},
+		httpContexts:      map[uint32]types.HttpContext{cID: &types.DefaultHttpContext{}},
+		contextIDToRootID: map[uint32]uint32{},
+		activeContextID:   cID,
+	}
+	path := SerializePropertyPath([]string{"request", "path"})
+
+	t.Run("disabled", func(t *testing.T) {
+		CacheProperty(path, []byte("/a"))
+		_, ok := GetCachedProperty(path)
+		require.False(t, ok)
+		require.Nil(t, currentState.propertyCaches)
+	})
+
+	EnablePropertyCache()
+
+	t.Run("per context", func(t *testing.T) {
+		_, ok := GetCachedProperty(path)
+		require.False(t, ok)
+		CacheProperty(path, []byte("/a"))
+		value, ok := GetCachedProperty(path)
+		require.True(t, ok)
+		require.Equal(t, []byte("/a"), value)
+
+		currentState.activeContextID = cID + 1
+		_, ok = GetCachedProperty(path)
+		require.False(t, ok)
+		currentState.deletePropertyCache(cID + 1)
+		currentState.activeContextID = cID
+	})
+
+	t.Run("plugin context", func(t *testing.T) {
+		// Plugin contexts are not cached since they never end before the plugin does.
+		currentState.activeContextID = cID - 1
+		CacheProperty(path, []byte("/a"))
+		_, ok := GetCachedProperty(path)
+		require.False(t, ok)
+		require.NotContains(t, currentState.propertyCaches, cID-1)
+		currentState.activeContextID = cID
+	})
+
+	t.Run("invalidate", func(t *testing.T) {
+		InvalidatePropertyCache()
+		_, ok := GetCachedProperty(path)
+		require.False(t, ok)
+
+		CacheProperty(path, []byte("/a"))
+		proxyOnLog(cID)
+		_, ok = GetCachedProperty(path)
+		require.False(t, ok)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		logs = nil
+		proxyOnDelete(cID)
+		require.NotContains(t, currentState.propertyCaches, cID)
+		require.Equal(t, []string{"property cache of context 100: 1 hits, 3 misses"}, logs)
+
+		// The deleted context is no longer active, so nothing is cached for it.
+		require.Zero(t, currentState.activeContextID)
+		CacheProperty(path, []byte("/a"))
+		require.Empty(t, currentState.propertyCaches)
+	})
+}
@@ -38,6 +38,15 @@ type state struct {
 
 	contextIDToRootID map[uint32]uint32
 	activeContextID   uint32
+
+	propertyCacheEnabled bool
+	propertyCaches       map[uint32]*propertyCache
+}
+
+// VMState is the state of the SDK in a VM. Hosts emulating several VMs in one process
+// keep one per VM, see BindVM.
+type VMState struct {
+	state *state
 }
 
 var currentState = &state{
@@ -48,11 +57,11 @@ var currentState = &state{
 }
 
 func SetVMContext(vmContext types.VMContext) {
-	currentState.vmContext = vmContext
+	vmState().vmContext = vmContext
 }
 
 func RegisterHttpCallout(calloutID uint32, callback func(numHeaders, bodySize, numTrailers int)) {
-	currentState.registerHttpCallOut(calloutID, callback)
+	vmState().registerHttpCallOut(calloutID, callback)
 }
 
 func (s *state) createPluginContext(contextID uint32) {
@@ -0,0 +1,56 @@
+// Copyright 2020-2024 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !wasm
+
+package internal
+
+// Outside wasm, a process may emulate several VMs, each with its own state and host. The VM
+// bound with BindVM is the one callbacks run in and hostcalls are sent to. Since the binding is
+// shared by all goroutines, callers hold a lock for as long as a VM is bound. Without a bound VM,
+// currentState and the host registered with RegisterMockWasmHost are used.
+
+var (
+	boundState *state
+	boundHost  ProxyWasmHost
+)
+
+// BindVM makes callbacks run with the state of the VM and hostcalls go to the host. The
+// returned function restores the previous binding.
+func BindVM(s *VMState, host ProxyWasmHost) (restore func()) {
+	prevState, prevHost := boundState, boundHost
+	boundState, boundHost = s.state, host
+	return func() {
+		boundState, boundHost = prevState, prevHost
+	}
+}
+
+// vmState returns the state of the bound VM.
+func vmState() *state {
+	if boundState != nil {
+		return boundState
+	}
+	return currentState
+}
+
+// mockHost returns the host of the bound VM.
+func mockHost() ProxyWasmHost {
+	if boundHost != nil {
+		return boundHost
+	}
+	if currentHost == nil {
+		panic("proxywasm: no VM is bound to serve the hostcall; run it in HostEmulator.RunInVM")
+	}
+	return currentHost
+}
@@ -20,18 +20,33 @@ import "github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/types"
 
 func VMStateReset() {
 	// (@mathetake) I assume that the currentState be protected by lock on hostMux
-	currentState = &state{
-		pluginContexts:    make(map[uint32]*pluginContextState),
-		httpContexts:      make(map[uint32]types.HttpContext),
-		tcpContexts:       make(map[uint32]types.TcpContext),
-		contextIDToRootID: make(map[uint32]uint32),
-	}
+	currentState = NewVMState().state
 }
 
 func VMStateGetActiveContextID() uint32 {
-	return currentState.activeContextID
+	return vmState().activeContextID
 }
 
 func VMStateSetActiveContextID(contextID uint32) {
-	currentState.activeContextID = contextID
+	vmState().activeContextID = contextID
+}
+
+// ActiveContextID returns the ID of the context the VM is running.
+func (s *VMState) ActiveContextID() uint32 {
+	return s.state.activeContextID
+}
+
+// SetActiveContextID sets the ID of the context the VM is running.
+func (s *VMState) SetActiveContextID(contextID uint32) {
+	s.state.activeContextID = contextID
+}
+
+// NewVMState returns the state of a new VM without any context.
+func NewVMState() *VMState {
+	return &VMState{state: &state{
+		pluginContexts:    make(map[uint32]*pluginContextState),
+		httpContexts:      make(map[uint32]types.HttpContext),
+		tcpContexts:       make(map[uint32]types.TcpContext),
+		contextIDToRootID: make(map[uint32]uint32),
+	}}
 }
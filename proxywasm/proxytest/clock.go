@@ -0,0 +1,113 @@
+// Copyright 2020-2024 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxytest
+
+import (
+	"slices"
+	"time"
+)
+
+type (
+	// clock is the virtual time of the host and the events scheduled on it.
+	clock struct {
+		start   time.Time
+		now     time.Duration // since start
+		timers  []*timer      // in the order of scheduling
+		running bool
+	}
+
+	// timer is an event in a VM, which belongs to a context and is cancelled with it.
+	timer struct {
+		at        time.Duration
+		vm        *vm
+		contextID uint32
+		fire      func()
+	}
+)
+
+// schedule makes fire run in the VM once the virtual time reaches at.
+func (c *clock) schedule(at time.Duration, v *vm, contextID uint32, fire func()) *timer {
+	t := &timer{at: at, vm: v, contextID: contextID, fire: fire}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// stop cancels the event if it has not fired yet.
+func (c *clock) stop(t *timer) {
+	c.timers = slices.DeleteFunc(c.timers, func(other *timer) bool {
+		return other == t
+	})
+}
+
+// cancel cancels the events of the context in the VM.
+func (c *clock) cancel(v *vm, contextID uint32) {
+	c.timers = slices.DeleteFunc(c.timers, func(t *timer) bool {
+		return t.vm == v && t.contextID == contextID
+	})
+}
+
+// runDue fires the events which are due, including the ones scheduled by them without delay.
+func (c *clock) runDue() {
+	c.runUntil(c.now)
+}
+
+// runUntil fires the events due until the given time in order, advancing the time to each of them.
+// Events scheduled at the same time fire in the order of scheduling.
+func (c *clock) runUntil(until time.Duration) {
+	// Calls into the plugins made by events run the due events themselves, which is left to the
+	// outermost call so that events keep their order.
+	if c.running {
+		return
+	}
+	c.running = true
+	defer func() { c.running = false }()
+
+	for {
+		next := -1
+		for i, t := range c.timers {
+			if t.at <= until && (next < 0 || t.at < c.timers[next].at) {
+				next = i
+			}
+		}
+		if next < 0 {
+			break
+		}
+
+		t := c.timers[next]
+		c.timers = slices.Delete(c.timers, next, next+1)
+		c.now = max(c.now, t.at)
+		func() {
+			defer t.vm.enter()()
+			t.fire()
+		}()
+	}
+	c.now = max(c.now, until)
+}
+
+// call enters the VM for a call into the plugin. The caller defers the returned function, which
+// fires the events due once the call returns, such as the responses of clusters.
+func (v *vm) call() (done func()) {
+	exit := v.enter()
+	return func() {
+		defer exit()
+		v.host.clock.runDue()
+	}
+}
+
+// impl HostEmulator
+func (h *hostEmulator) AdvanceTime(d time.Duration) {
+	defer h.vm.enter()()
+	h.vm.host.clock.runUntil(h.vm.host.clock.now + d)
+}
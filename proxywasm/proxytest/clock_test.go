@@ -0,0 +1,95 @@
+// Copyright 2020-2024 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxytest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdvanceTime_ticks(t *testing.T) {
+	opt := NewEmulatorOption().
+		WithVMContext(&multiPluginVMContext{}).
+		WithPlugin("a", []byte("config-a")).
+		WithPlugin("bbb", []byte("config-b"))
+	host, reset := NewHostEmulator(opt)
+	defer reset()
+
+	// The tick periods are 100ms and 300ms.
+	b, err := host.GetPluginContextID("bbb")
+	require.NoError(t, err)
+	require.Equal(t, types.OnPluginStartStatusOK, host.StartPlugin())
+	require.Equal(t, types.OnPluginStartStatusOK, host.StartPluginContext(b))
+
+	host.AdvanceTime(99 * time.Millisecond)
+	require.Empty(t, host.GetInfoLogs())
+
+	host.AdvanceTime(time.Millisecond)
+	require.Equal(t, []string{"tick in config-a"}, host.GetInfoLogs())
+
+	host.AdvanceTime(500 * time.Millisecond)
+	// Ticks at the same time are in the order they were scheduled.
+	require.Equal(t, []string{
+		"tick in config-a", // 100ms
+		"tick in config-a", // 200ms
+		"tick in config-b", // 300ms, scheduled at 0ms
+		"tick in config-a", // 300ms, scheduled at 200ms
+		"tick in config-a", // 400ms
+		"tick in config-a", // 500ms
+		"tick in config-b", // 600ms
+		"tick in config-a", // 600ms
+	}, host.GetInfoLogs())
+
+	// Restarting the plugin at 650ms sets the tick period again, so that the next tick is at 950ms.
+	host.AdvanceTime(50 * time.Millisecond)
+	require.Equal(t, types.OnPluginStartStatusOK, host.StartPluginContext(b))
+	host.AdvanceTime(299 * time.Millisecond)
+	require.NotContains(t, host.GetInfoLogs()[8:], "tick in config-b")
+	host.AdvanceTime(time.Millisecond)
+	require.Contains(t, host.GetInfoLogs()[8:], "tick in config-b")
+}
+
+func TestAdvanceTime_calloutTimeout(t *testing.T) {
+	host, reset := NewHostEmulator(NewEmulatorOption().WithVMContext(&calloutVMContext{}))
+	defer reset()
+
+	// The timeout of the callouts is 1s.
+	timedOut := host.InitializeHttpContext()
+	host.CallOnRequestHeaders(timedOut, [][2]string{{"x-cluster", "unknown"}}, true)
+	answered := host.InitializeHttpContext()
+	host.CallOnRequestHeaders(answered, [][2]string{{"x-cluster", "unknown"}}, true)
+
+	host.AdvanceTime(500 * time.Millisecond)
+	callouts := host.GetCalloutAttributesFromContext(answered)
+	require.Len(t, callouts, 1)
+	host.CallOnHttpCallResponse(callouts[0].CalloutID, [][2]string{{":status", "200"}}, nil, nil)
+	require.Equal(t, []string{`1 headers, status "200", body ""`}, host.GetInfoLogs())
+
+	host.AdvanceTime(499 * time.Millisecond)
+	require.Equal(t, types.ActionPause, host.GetCurrentHttpStreamAction(timedOut))
+	host.AdvanceTime(time.Millisecond)
+	require.Equal(t, types.ActionContinue, host.GetCurrentHttpStreamAction(timedOut))
+	require.Equal(t, []string{
+		`1 headers, status "200", body ""`,
+		`3 headers, status "504", body "upstream request timeout"`,
+	}, host.GetInfoLogs())
+
+	// The answered callout does not time out.
+	host.AdvanceTime(time.Second)
+	require.Len(t, host.GetInfoLogs(), 2)
+}
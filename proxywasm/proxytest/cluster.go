@@ -0,0 +1,72 @@
+// Copyright 2020-2024 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxytest
+
+import (
+	"log"
+	"time"
+)
+
+type (
+	// ClusterHandler answers the HTTP callouts dispatched to a cluster declared with
+	// EmulatorOption.WithCluster. Returning nil fails the callout as on a reset of the upstream
+	// connection: the plugin gets a response without headers, body and trailers.
+	ClusterHandler func(req HttpCalloutAttribute) *HttpCalloutResponse
+
+	// HttpCalloutResponse is the response of a cluster to an HTTP callout.
+	HttpCalloutResponse struct {
+		Headers  [][2]string
+		Trailers [][2]string
+		Body     []byte
+	}
+
+	cluster struct {
+		handler ClusterHandler
+		latency time.Duration
+	}
+)
+
+// timeoutResponse is the response of Envoy to callouts which time out.
+var timeoutResponse = &HttpCalloutResponse{
+	Headers: [][2]string{{":status", "504"}, {"content-length", "24"}, {"content-type", "text/plain"}},
+	Body:    []byte("upstream request timeout"),
+}
+
+// dispatchToCluster passes the callout to the handler of the cluster and schedules the delivery
+// of the response once the latency of the cluster has elapsed, or once the callout times out.
+func (r *rootHostEmulator) dispatchToCluster(c *cluster, req HttpCalloutAttribute, contextID uint32, timeout time.Duration) {
+	res := c.handler(req)
+	// Failed callouts get a response without headers, body and trailers as in Envoy.
+	if res == nil {
+		res = &HttpCalloutResponse{}
+	}
+	latency := c.latency
+	if timeout > 0 && latency >= timeout {
+		latency, res = timeout, timeoutResponse
+	}
+	r.scheduleCalloutResponse(req, contextID, latency, res)
+}
+
+// scheduleCalloutResponse schedules the delivery of the response to the callout after the delay,
+// unless the callout is answered with HostEmulator.CallOnHttpCallResponse before.
+func (r *rootHostEmulator) scheduleCalloutResponse(req HttpCalloutAttribute, contextID uint32, delay time.Duration, res *HttpCalloutResponse) {
+	clock := &r.vm.host.clock
+	r.httpCalloutTimers[req.CalloutID] = clock.schedule(clock.now+delay, r.vm, contextID, func() {
+		if res == timeoutResponse {
+			log.Printf("[http callout to %s] timed out", req.Upstream)
+		}
+		r.CallOnHttpCallResponse(req.CalloutID, res.Headers, res.Trailers, res.Body)
+	})
+}
@@ -0,0 +1,140 @@
+// Copyright 2020-2024 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxytest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm"
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/types"
+	"github.com/stretchr/testify/require"
+)
+
+type calloutVMContext struct {
+	types.DefaultVMContext
+	types.DefaultPluginContext
+}
+
+type calloutHttpContext struct {
+	types.DefaultHttpContext
+}
+
+// NewPluginContext implements the same method on types.VMContext.
+func (v *calloutVMContext) NewPluginContext(uint32) types.PluginContext {
+	return v
+}
+
+// NewHttpContext implements the same method on types.PluginContext.
+func (v *calloutVMContext) NewHttpContext(uint32) types.HttpContext {
+	return &calloutHttpContext{}
+}
+
+// OnHttpRequestHeaders implements the same method on types.HttpContext.
+func (h *calloutHttpContext) OnHttpRequestHeaders(int, bool) types.Action {
+	cluster, err := proxywasm.GetHttpRequestHeader("x-cluster")
+	if err != nil {
+		panic(err)
+	}
+	if _, err := proxywasm.DispatchHttpCall(cluster, [][2]string{{":path", "/check"}}, []byte("request"), nil, 1000,
+		func(numHeaders, bodySize, numTrailers int) {
+			var status string
+			headers, _ := proxywasm.GetHttpCallResponseHeaders()
+			for _, h := range headers {
+				if h[0] == ":status" {
+					status = h[1]
+				}
+			}
+			body, _ := proxywasm.GetHttpCallResponseBody(0, bodySize)
+			proxywasm.LogInfof("%d headers, status %q, body %q", numHeaders, status, body)
+			if err := proxywasm.ResumeHttpRequest(); err != nil {
+				panic(err)
+			}
+		}); err != nil {
+		panic(err)
+	}
+	return types.ActionPause
+}
+
+func TestCluster(t *testing.T) {
+	var requests []HttpCalloutAttribute
+	ok := func(req HttpCalloutAttribute) *HttpCalloutResponse {
+		requests = append(requests, req)
+		return &HttpCalloutResponse{Headers: [][2]string{{":status", "200"}}, Body: []byte("ok")}
+	}
+	opt := NewEmulatorOption().
+		WithVMContext(&calloutVMContext{}).
+		WithCluster("fast", ok).
+		WithCluster("slow", ok).
+		WithClusterLatency("slow", 100*time.Millisecond).
+		WithCluster("hanging", ok).
+		WithClusterLatency("hanging", time.Hour).
+		WithCluster("failing", func(HttpCalloutAttribute) *HttpCalloutResponse { return nil })
+	host, reset := NewHostEmulator(opt)
+	defer reset()
+
+	request := func(cluster string) uint32 {
+		id := host.InitializeHttpContext()
+		host.CallOnRequestHeaders(id, [][2]string{{"x-cluster", cluster}}, true)
+		return id
+	}
+
+	t.Run("answered after the call into the plugin", func(t *testing.T) {
+		id := request("fast")
+		require.Equal(t, types.ActionContinue, host.GetCurrentHttpStreamAction(id))
+		require.Equal(t, []string{`1 headers, status "200", body "ok"`}, host.GetInfoLogs())
+		require.Len(t, requests, 1)
+		require.Equal(t, "fast", requests[0].Upstream)
+		require.Equal(t, []byte("request"), requests[0].Body)
+		require.Contains(t, requests[0].Headers, [2]string{":path", "/check"})
+	})
+
+	t.Run("answered after the latency", func(t *testing.T) {
+		id := request("slow")
+		require.Equal(t, types.ActionPause, host.GetCurrentHttpStreamAction(id))
+		host.AdvanceTime(99 * time.Millisecond)
+		require.Equal(t, types.ActionPause, host.GetCurrentHttpStreamAction(id))
+		host.AdvanceTime(time.Millisecond)
+		require.Equal(t, types.ActionContinue, host.GetCurrentHttpStreamAction(id))
+		require.Len(t, host.GetInfoLogs(), 2)
+	})
+
+	t.Run("timed out", func(t *testing.T) {
+		id := request("hanging")
+		host.AdvanceTime(time.Second)
+		require.Equal(t, types.ActionContinue, host.GetCurrentHttpStreamAction(id))
+		require.Equal(t, `3 headers, status "504", body "upstream request timeout"`, host.GetInfoLogs()[2])
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		request("failing")
+		require.Equal(t, `0 headers, status "", body ""`, host.GetInfoLogs()[3])
+	})
+
+	t.Run("cancelled with the stream", func(t *testing.T) {
+		id := request("slow")
+		host.CompleteHttpContext(id)
+		host.AdvanceTime(time.Second)
+		require.Len(t, host.GetInfoLogs(), 4)
+	})
+
+	t.Run("answered by hand", func(t *testing.T) {
+		id := request("unknown")
+		callouts := host.GetCalloutAttributesFromContext(id)
+		require.Len(t, callouts, 1)
+		host.CallOnHttpCallResponse(callouts[0].CalloutID, [][2]string{{":status", "204"}}, nil, nil)
+		require.Equal(t, `1 headers, status "204", body ""`, host.GetInfoLogs()[4])
+	})
+}
@@ -0,0 +1,109 @@
+// Copyright 2020-2024 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxytest
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/internal"
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/types"
+)
+
+// fault makes hostcalls fail with a status instead of being served by the host.
+type fault struct {
+	name    string
+	status  internal.Status
+	filters []HostCallFilter
+	// nth is the only matching call which fails, counting from 1, or 0 if all of them fail.
+	nth     int
+	matched int
+}
+
+// fail returns whether the hostcall fails with the fault.
+func (f *fault) fail(c HostCall) bool {
+	if c.Name != f.name {
+		return false
+	}
+	for _, filter := range f.filters {
+		if !filter(c) {
+			return false
+		}
+	}
+	f.matched++
+	return f.nth == 0 || f.matched == f.nth
+}
+
+// CallsWithArg selects the hostcalls with arg among their arguments, such as the key of
+// proxy_set_shared_data or the cluster of proxy_http_call.
+func CallsWithArg(arg string) HostCallFilter {
+	return func(c HostCall) bool {
+		for _, a := range c.Args {
+			if s, ok := a.(string); ok && s == arg {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// impl HostEmulator
+func (h *hostEmulator) InjectFault(name string, err error, filters ...HostCallFilter) {
+	h.InjectNthFault(0, name, err, filters...)
+}
+
+// impl HostEmulator
+func (h *hostEmulator) InjectNthFault(n int, name string, err error, filters ...HostCallFilter) {
+	if n < 0 {
+		panic(fmt.Sprintf("invalid call number: %d", n))
+	}
+	h.vm.spy.faults = append(h.vm.spy.faults, &fault{name: name, status: errorToStatus(err), filters: filters, nth: n})
+}
+
+// impl HostEmulator
+func (h *hostEmulator) ClearFaults() {
+	h.vm.spy.faults = nil
+}
+
+// injectedFault returns the status of the first fault the hostcall fails with, or false if the
+// host serves it.
+func (s *spyHost) injectedFault(c HostCall) (internal.Status, bool) {
+	for _, f := range s.faults {
+		if f.fail(c) {
+			log.Printf("injected fault: %s fails with %v", c.Name, internal.StatusToError(f.status))
+			return f.status, true
+		}
+	}
+	return internal.StatusOK, false
+}
+
+// errorToStatus returns the status hostcalls return for err, as opposed to internal.StatusToError.
+func errorToStatus(err error) internal.Status {
+	switch err {
+	case types.ErrorStatusNotFound:
+		return internal.StatusNotFound
+	case types.ErrorStatusBadArgument:
+		return internal.StatusBadArgument
+	case types.ErrorStatusEmpty:
+		return internal.StatusEmpty
+	case types.ErrorStatusCasMismatch:
+		return internal.StatusCasMismatch
+	case types.ErrorInternalFailure:
+		return internal.StatusInternalFailure
+	case types.ErrorUnimplemented:
+		return internal.StatusUnimplemented
+	}
+	panic(fmt.Sprintf("%v is not an error status returned by hostcalls", err))
+}
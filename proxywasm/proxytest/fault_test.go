@@ -0,0 +1,68 @@
+// Copyright 2020-2024 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxytest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInjectFault(t *testing.T) {
+	host, reset := NewHostEmulator(NewEmulatorOption().WithVMContext(&spyVMContext{}))
+	defer reset()
+	host.DumpCallsOnFailure(t)
+
+	request := func(user string) uint32 {
+		id := host.InitializeHttpContext()
+		host.CallOnRequestHeaders(id, [][2]string{{"x-user", user}}, true)
+		return id
+	}
+	writes := func(id uint32) int {
+		return len(host.Calls(CallsInContext(id), CallsNamed("proxy_set_shared_data")))
+	}
+
+	t.Run("every call", func(t *testing.T) {
+		host.InjectFault("proxy_get_shared_data", types.ErrorInternalFailure, CallsWithArg("users/alice"))
+		defer host.ClearFaults()
+
+		require.Equal(t, 1, writes(request("alice")))
+		id := request("alice")
+		require.Equal(t, 1, writes(id))
+		require.Equal(t, types.ErrorInternalFailure, host.Calls(CallsInContext(id), CallsNamed("proxy_get_shared_data"))[0].Err)
+		request("bob")
+		require.Equal(t, 0, writes(request("bob")))
+	})
+
+	t.Run("cleared", func(t *testing.T) {
+		require.Equal(t, 0, writes(request("alice")))
+	})
+
+	t.Run("nth call", func(t *testing.T) {
+		host.InjectNthFault(2, "proxy_get_header_map_value", types.ErrorStatusNotFound)
+		defer host.ClearFaults()
+
+		require.Equal(t, 1, writes(request("carol")))
+		require.Empty(t, host.Calls(CallsInContext(request("dave")), CallsNamed("proxy_get_shared_data")))
+		require.Equal(t, 1, writes(request("dave")))
+	})
+
+	t.Run("unknown error", func(t *testing.T) {
+		require.Panics(t, func() { host.InjectFault("proxy_log", errors.New("unknown")) })
+		require.Panics(t, func() { host.InjectFault("proxy_log", nil) })
+	})
+}
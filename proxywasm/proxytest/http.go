@@ -15,6 +15,7 @@
 package proxytest
 
 import (
+	"bytes"
 	"log"
 	"strings"
 	"unsafe"
@@ -25,7 +26,11 @@ import (
 
 type (
 	httpHostEmulator struct {
+		vm          *vm
 		httpStreams map[uint32]*httpStreamState
+		// strict makes hostcalls fail outside of the lifecycle phases they are valid in, see
+		// EmulatorOption.WithStrictPhases.
+		strict bool
 	}
 	httpStreamState struct {
 		requestHeaders, responseHeaders   [][2]string
@@ -42,6 +47,10 @@ type (
 
 		action            types.Action
 		sentLocalResponse *LocalHttpResponse
+
+		// held are the callbacks whose headers, trailers or body the host still holds since the
+		// stream paused in them. Envoy releases them once the stream continues past the callback.
+		held map[string]bool
 	}
 	LocalHttpResponse struct {
 		StatusCode       uint32
@@ -52,16 +61,77 @@ type (
 	}
 )
 
-func newHttpHostEmulator() *httpHostEmulator {
-	host := &httpHostEmulator{httpStreams: map[uint32]*httpStreamState{}}
+func newHttpHostEmulator(vm *vm, strict bool) *httpHostEmulator {
+	host := &httpHostEmulator{vm: vm, httpStreams: map[uint32]*httpStreamState{}, strict: strict}
 	return host
 }
 
+// mapCallback returns the lifecycle callback the map is passed to.
+func mapCallback(mapType internal.MapType) string {
+	switch mapType {
+	case internal.MapTypeHttpRequestHeaders:
+		return "proxy_on_request_headers"
+	case internal.MapTypeHttpResponseHeaders:
+		return "proxy_on_response_headers"
+	case internal.MapTypeHttpRequestTrailers:
+		return "proxy_on_request_trailers"
+	case internal.MapTypeHttpResponseTrailers:
+		return "proxy_on_response_trailers"
+	default:
+		panic("unreachable: maybe a bug in this host emulation or SDK")
+	}
+}
+
+// bodyCallback returns the lifecycle callback the body is passed to.
+func bodyCallback(bt internal.BufferType) string {
+	switch bt {
+	case internal.BufferTypeHttpRequestBody:
+		return "proxy_on_request_body"
+	case internal.BufferTypeHttpResponseBody:
+		return "proxy_on_response_body"
+	default:
+		panic("unreachable: maybe a bug in this host emulation or SDK")
+	}
+}
+
+// mapStatus returns the status of the hostcalls on the map of the stream in strict mode. Envoy
+// provides maps from the callback they are passed to until the stream continues past it, and the
+// headers of the access logs in proxy_on_log.
+func (h *httpHostEmulator) mapStatus(stream *httpStreamState, mapType internal.MapType, write bool) internal.Status {
+	callback := mapCallback(mapType)
+	if !h.strict || h.vm.callback == callback || stream.held[callback] {
+		return internal.StatusOK
+	}
+	status := internal.StatusBadArgument
+	if h.vm.callback == "proxy_on_log" && !write {
+		if mapType != internal.MapTypeHttpRequestTrailers {
+			return internal.StatusOK
+		}
+		status = internal.StatusNotFound
+	}
+	log.Printf("strict phases: %s is not available %s", mapTypeName(mapType), h.vm.phase())
+	return status
+}
+
+// bodyStatus returns the status of the hostcalls on the body of the stream in strict mode. Envoy
+// provides bodies from the callback they are passed to until the stream continues past it.
+func (h *httpHostEmulator) bodyStatus(stream *httpStreamState, bt internal.BufferType) internal.Status {
+	callback := bodyCallback(bt)
+	if !h.strict || h.vm.callback == callback || stream.held[callback] {
+		return internal.StatusOK
+	}
+	log.Printf("strict phases: %s is not available %s", bufferTypeName(bt), h.vm.phase())
+	return internal.StatusNotFound
+}
+
 // impl internal.ProxyWasmHost: delegated from hostEmulator
 func (h *httpHostEmulator) httpHostEmulatorProxyGetBufferBytes(bt internal.BufferType, start int32, maxSize int32,
 	returnBufferData unsafe.Pointer, returnBufferSize *int32) internal.Status {
-	active := internal.VMStateGetActiveContextID()
+	active := h.vm.state.ActiveContextID()
 	stream := h.httpStreams[active]
+	if status := h.bodyStatus(stream, bt); status != internal.StatusOK {
+		return status
+	}
 	var buf []byte
 	switch bt {
 	case internal.BufferTypeHttpRequestBody:
@@ -91,8 +161,11 @@ func (h *httpHostEmulator) httpHostEmulatorProxyGetBufferBytes(bt internal.Buffe
 
 func (h *httpHostEmulator) httpHostEmulatorProxySetBufferBytes(bt internal.BufferType, start int32, maxSize int32,
 	bufferData *byte, bufferSize int32) internal.Status {
-	active := internal.VMStateGetActiveContextID()
+	active := h.vm.state.ActiveContextID()
 	stream := h.httpStreams[active]
+	if status := h.bodyStatus(stream, bt); status != internal.StatusOK {
+		return status
+	}
 	var targetBuf *[]byte
 	switch bt {
 	case internal.BufferTypeHttpRequestBody:
@@ -128,8 +201,11 @@ func (h *httpHostEmulator) httpHostEmulatorProxySetBufferBytes(bt internal.Buffe
 // impl internal.ProxyWasmHost: delegated from hostEmulator
 func (h *httpHostEmulator) httpHostEmulatorProxyGetHeaderMapValue(mapType internal.MapType, keyData *byte,
 	keySize int32, returnValueData unsafe.Pointer, returnValueSize *int32) internal.Status {
-	active := internal.VMStateGetActiveContextID()
+	active := h.vm.state.ActiveContextID()
 	stream := h.httpStreams[active]
+	if status := h.mapStatus(stream, mapType, false); status != internal.StatusOK {
+		return status
+	}
 
 	var headers [][2]string
 	switch mapType {
@@ -172,8 +248,11 @@ func (h *httpHostEmulator) ProxyAddHeaderMapValue(mapType internal.MapType, keyD
 
 	key := unsafe.String(keyData, keySize)
 	value := unsafe.String(valueData, valueSize)
-	active := internal.VMStateGetActiveContextID()
+	active := h.vm.state.ActiveContextID()
 	stream := h.httpStreams[active]
+	if status := h.mapStatus(stream, mapType, true); status != internal.StatusOK {
+		return status
+	}
 
 	switch mapType {
 	case internal.MapTypeHttpRequestHeaders:
@@ -208,8 +287,11 @@ func (h *httpHostEmulator) ProxyReplaceHeaderMapValue(mapType internal.MapType,
 	keySize int32, valueData *byte, valueSize int32) internal.Status {
 	key := unsafe.String(keyData, keySize)
 	value := unsafe.String(valueData, valueSize)
-	active := internal.VMStateGetActiveContextID()
+	active := h.vm.state.ActiveContextID()
 	stream := h.httpStreams[active]
+	if status := h.mapStatus(stream, mapType, true); status != internal.StatusOK {
+		return status
+	}
 
 	switch mapType {
 	case internal.MapTypeHttpRequestHeaders:
@@ -242,8 +324,11 @@ func replaceMapValue(base [][2]string, key, value string) [][2]string {
 // impl internal.ProxyWasmHost
 func (h *httpHostEmulator) ProxyRemoveHeaderMapValue(mapType internal.MapType, keyData *byte, keySize int32) internal.Status {
 	key := unsafe.String(keyData, keySize)
-	active := internal.VMStateGetActiveContextID()
+	active := h.vm.state.ActiveContextID()
 	stream := h.httpStreams[active]
+	if status := h.mapStatus(stream, mapType, true); status != internal.StatusOK {
+		return status
+	}
 
 	switch mapType {
 	case internal.MapTypeHttpRequestHeaders:
@@ -277,8 +362,11 @@ func removeHeaderMapValue(base [][2]string, key string) [][2]string {
 // impl internal.ProxyWasmHost: delegated from hostEmulator
 func (h *httpHostEmulator) httpHostEmulatorProxyGetHeaderMapPairs(mapType internal.MapType, returnValueData unsafe.Pointer,
 	returnValueSize *int32) internal.Status {
-	active := internal.VMStateGetActiveContextID()
+	active := h.vm.state.ActiveContextID()
 	stream := h.httpStreams[active]
+	if status := h.mapStatus(stream, mapType, false); status != internal.StatusOK {
+		return status
+	}
 
 	var m []byte
 	switch mapType {
@@ -310,8 +398,11 @@ func (h *httpHostEmulator) httpHostEmulatorProxyGetHeaderMapPairs(mapType intern
 // impl internal.ProxyWasmHost
 func (h *httpHostEmulator) ProxySetHeaderMapPairs(mapType internal.MapType, mapData *byte, mapSize int32) internal.Status {
 	m := deserializeRawBytePtrToMap(mapData, mapSize)
-	active := internal.VMStateGetActiveContextID()
+	active := h.vm.state.ActiveContextID()
 	stream := h.httpStreams[active]
+	if status := h.mapStatus(stream, mapType, true); status != internal.StatusOK {
+		return status
+	}
 
 	switch mapType {
 	case internal.MapTypeHttpRequestHeaders:
@@ -329,10 +420,21 @@ func (h *httpHostEmulator) ProxySetHeaderMapPairs(mapType internal.MapType, mapD
 }
 
 // impl internal.ProxyWasmHost
-func (h *httpHostEmulator) ProxyContinueStream(internal.StreamType) internal.Status {
-	active := internal.VMStateGetActiveContextID()
+func (h *httpHostEmulator) ProxyContinueStream(streamType internal.StreamType) internal.Status {
+	active := h.vm.state.ActiveContextID()
 	stream := h.httpStreams[active]
 	stream.action = types.ActionContinue
+	// The host releases the maps and body of the resumed direction once the stream continues past them.
+	switch streamType {
+	case internal.StreamTypeRequest:
+		delete(stream.held, "proxy_on_request_headers")
+		delete(stream.held, "proxy_on_request_body")
+		delete(stream.held, "proxy_on_request_trailers")
+	case internal.StreamTypeResponse:
+		delete(stream.held, "proxy_on_response_headers")
+		delete(stream.held, "proxy_on_response_body")
+		delete(stream.held, "proxy_on_response_trailers")
+	}
 	return internal.StatusOK
 }
 
@@ -340,8 +442,12 @@ func (h *httpHostEmulator) ProxyContinueStream(internal.StreamType) internal.Sta
 func (h *httpHostEmulator) ProxySendLocalResponse(statusCode uint32,
 	statusCodeDetailData *byte, statusCodeDetailsSize int32, bodyData *byte, bodySize int32,
 	headersData *byte, headersSize int32, grpcStatus int32) internal.Status {
-	active := internal.VMStateGetActiveContextID()
+	active := h.vm.state.ActiveContextID()
 	stream := h.httpStreams[active]
+	if h.strict && stream.sentLocalResponse != nil {
+		log.Printf("strict phases: a local response has already been sent %s", h.vm.phase())
+		return internal.StatusBadArgument
+	}
 	stream.sentLocalResponse = &LocalHttpResponse{
 		StatusCode:       statusCode,
 		StatusCodeDetail: unsafe.String(statusCodeDetailData, statusCodeDetailsSize),
@@ -352,16 +458,18 @@ func (h *httpHostEmulator) ProxySendLocalResponse(statusCode uint32,
 	return internal.StatusOK
 }
 
-// impl HostEmulator
-func (h *httpHostEmulator) InitializeHttpContext() (contextID uint32) {
-	contextID = getNextContextID()
-	internal.ProxyOnContextCreate(contextID, PluginContextID)
-	h.httpStreams[contextID] = &httpStreamState{action: types.ActionContinue}
-	return
+// initializeHttpContext creates the HTTP context with the ID under the plugin context.
+func (h *httpHostEmulator) initializeHttpContext(contextID, pluginContextID uint32) {
+	defer h.vm.call()()
+	defer h.vm.enterCallback("proxy_on_context_create")()
+	internal.ProxyOnContextCreate(contextID, pluginContextID)
+	h.httpStreams[contextID] = &httpStreamState{action: types.ActionContinue, held: map[string]bool{}}
 }
 
 // impl HostEmulator
 func (h *httpHostEmulator) CallOnRequestHeaders(contextID uint32, headers [][2]string, endOfStream bool) types.Action {
+	defer h.vm.call()()
+	defer h.vm.enterCallback("proxy_on_request_headers")()
 	cs, ok := h.httpStreams[contextID]
 	if !ok {
 		log.Fatalf("invalid context id: %d", contextID)
@@ -370,11 +478,14 @@ func (h *httpHostEmulator) CallOnRequestHeaders(contextID uint32, headers [][2]s
 	cs.requestHeaders = cloneWithLowerCaseMapKeys(headers)
 	cs.action = internal.ProxyOnRequestHeaders(contextID,
 		int32(len(headers)), endOfStream)
+	cs.held["proxy_on_request_headers"] = cs.action == types.ActionPause
 	return cs.action
 }
 
 // impl HostEmulator
 func (h *httpHostEmulator) CallOnResponseHeaders(contextID uint32, headers [][2]string, endOfStream bool) types.Action {
+	defer h.vm.call()()
+	defer h.vm.enterCallback("proxy_on_response_headers")()
 	cs, ok := h.httpStreams[contextID]
 	if !ok {
 		log.Fatalf("invalid context id: %d", contextID)
@@ -382,11 +493,14 @@ func (h *httpHostEmulator) CallOnResponseHeaders(contextID uint32, headers [][2]
 
 	cs.responseHeaders = cloneWithLowerCaseMapKeys(headers)
 	cs.action = internal.ProxyOnResponseHeaders(contextID, int32(len(headers)), endOfStream)
+	cs.held["proxy_on_response_headers"] = cs.action == types.ActionPause
 	return cs.action
 }
 
 // impl HostEmulator
 func (h *httpHostEmulator) CallOnRequestTrailers(contextID uint32, trailers [][2]string) types.Action {
+	defer h.vm.call()()
+	defer h.vm.enterCallback("proxy_on_request_trailers")()
 	cs, ok := h.httpStreams[contextID]
 	if !ok {
 		log.Fatalf("invalid context id: %d", contextID)
@@ -394,11 +508,14 @@ func (h *httpHostEmulator) CallOnRequestTrailers(contextID uint32, trailers [][2
 
 	cs.requestTrailers = cloneWithLowerCaseMapKeys(trailers)
 	cs.action = internal.ProxyOnRequestTrailers(contextID, int32(len(trailers)))
+	cs.held["proxy_on_request_trailers"] = cs.action == types.ActionPause
 	return cs.action
 }
 
 // impl HostEmulator
 func (h *httpHostEmulator) CallOnResponseTrailers(contextID uint32, trailers [][2]string) types.Action {
+	defer h.vm.call()()
+	defer h.vm.enterCallback("proxy_on_response_trailers")()
 	cs, ok := h.httpStreams[contextID]
 	if !ok {
 		log.Fatalf("invalid context id: %d", contextID)
@@ -406,11 +523,14 @@ func (h *httpHostEmulator) CallOnResponseTrailers(contextID uint32, trailers [][
 
 	cs.responseTrailers = cloneWithLowerCaseMapKeys(trailers)
 	cs.action = internal.ProxyOnResponseTrailers(contextID, int32(len(trailers)))
+	cs.held["proxy_on_response_trailers"] = cs.action == types.ActionPause
 	return cs.action
 }
 
 // impl HostEmulator
 func (h *httpHostEmulator) CallOnRequestBody(contextID uint32, body []byte, endOfStream bool) types.Action {
+	defer h.vm.call()()
+	defer h.vm.enterCallback("proxy_on_request_body")()
 	cs, ok := h.httpStreams[contextID]
 	if !ok {
 		log.Fatalf("invalid context id: %d", contextID)
@@ -419,6 +539,7 @@ func (h *httpHostEmulator) CallOnRequestBody(contextID uint32, body []byte, endO
 	cs.requestBody = append(cs.requestBodyBuffer, body...)
 	cs.action = internal.ProxyOnRequestBody(contextID,
 		int32(len(cs.requestBody)), endOfStream)
+	cs.held["proxy_on_request_body"] = cs.action == types.ActionPause
 	if cs.action == types.ActionPause {
 		// Buffering requested
 		cs.requestBodyBuffer = cs.requestBody
@@ -430,6 +551,8 @@ func (h *httpHostEmulator) CallOnRequestBody(contextID uint32, body []byte, endO
 
 // impl HostEmulator
 func (h *httpHostEmulator) CallOnResponseBody(contextID uint32, body []byte, endOfStream bool) types.Action {
+	defer h.vm.call()()
+	defer h.vm.enterCallback("proxy_on_response_body")()
 	cs, ok := h.httpStreams[contextID]
 	if !ok {
 		log.Fatalf("invalid context id: %d", contextID)
@@ -438,6 +561,7 @@ func (h *httpHostEmulator) CallOnResponseBody(contextID uint32, body []byte, end
 	cs.responseBody = append(cs.responseBodyBuffer, body...)
 	cs.action = internal.ProxyOnResponseBody(contextID,
 		int32(len(cs.responseBody)), endOfStream)
+	cs.held["proxy_on_response_body"] = cs.action == types.ActionPause
 	if cs.action == types.ActionPause {
 		// Buffering requested
 		cs.responseBodyBuffer = cs.responseBody
@@ -449,8 +573,11 @@ func (h *httpHostEmulator) CallOnResponseBody(contextID uint32, body []byte, end
 
 // impl HostEmulator
 func (h *httpHostEmulator) CompleteHttpContext(contextID uint32) {
+	defer h.vm.call()()
+	exit := h.vm.enterCallback("proxy_on_log")
 	internal.ProxyOnLog(contextID)
-	internal.ProxyOnDelete(contextID)
+	exit()
+	h.vm.deleteContext(contextID)
 }
 
 // impl HostEmulator
@@ -513,7 +640,7 @@ func (h *httpHostEmulator) GetProperty(path []string) ([]byte, error) {
 	var retSize int32
 	raw := internal.SerializePropertyPath(path)
 
-	err := internal.StatusToError(internal.ProxyGetProperty(&raw[0], int32(len(raw)), unsafe.Pointer(&ret), &retSize))
+	err := internal.StatusToError(h.vm.emulator.ProxyGetProperty(&raw[0], int32(len(raw)), unsafe.Pointer(&ret), &retSize))
 	if err != nil {
 		return nil, err
 	}
@@ -539,8 +666,6 @@ func (h *httpHostEmulator) SetProperty(path []string, data []byte) error {
 		log.Printf("data must not be empty")
 		return internal.StatusToError(internal.StatusBadArgument)
 	}
-	raw := internal.SerializePropertyPath(path)
-	return internal.StatusToError(internal.ProxySetProperty(
-		&raw[0], int32(len(raw)), &data[0], int32(len(data)),
-	))
+	h.vm.emulator.setProperty(string(internal.SerializePropertyPath(path)), bytes.Clone(data))
+	return nil
 }
@@ -148,4 +148,208 @@ func TestProperties(t *testing.T) {
 		_, err = host.GetProperty([]string{"non-existent path"})
 		require.Equal(t, err, internal.StatusToError(internal.StatusNotFound))
 	})
+
+	t.Run("Round trip properties set by plugins", func(t *testing.T) {
+		opt := NewEmulatorOption().WithVMContext(&testPlugin{}).WithProperty([]string{"empty"}, []byte{})
+		host, reset := NewHostEmulator(opt)
+		defer reset()
+
+		data, err := proxywasm.GetProperty([]string{"empty"})
+		require.NoError(t, err)
+		require.Empty(t, data)
+
+		// The host keeps its own copy of the data.
+		buf := []byte("value")
+		require.NoError(t, proxywasm.SetProperty([]string{"key"}, buf))
+		copy(buf, "xxxxx")
+		data, err = host.GetProperty([]string{"key"})
+		require.NoError(t, err)
+		require.Equal(t, []byte("value"), data)
+	})
+
+	t.Run("Attributes are not set by plugins", func(t *testing.T) {
+		opt := NewEmulatorOption().WithVMContext(&testPlugin{}).WithProperty([]string{"request", "path"}, []byte("/a"))
+		host, reset := NewHostEmulator(opt)
+		defer reset()
+
+		// Envoy keeps the writes as filter state, which attributes never read, so they are rejected.
+		err := proxywasm.SetProperty([]string{"request", "path"}, []byte("/b"))
+		require.ErrorIs(t, err, types.ErrorStatusBadArgument)
+		err = proxywasm.SetProperty([]string{"metadata", "filter_metadata", "ns", "key"}, []byte("value"))
+		require.ErrorIs(t, err, types.ErrorStatusBadArgument)
+		data, err := proxywasm.GetProperty([]string{"request", "path"})
+		require.NoError(t, err)
+		require.Equal(t, []byte("/a"), data)
+		_, err = host.GetProperty([]string{"metadata", "filter_metadata", "ns", "key"})
+		require.ErrorIs(t, err, types.ErrorStatusNotFound)
+
+		// Tests set them on behalf of the host.
+		require.NoError(t, host.SetProperty([]string{"metadata", "filter_metadata", "ns", "key"}, []byte("value")))
+		data, err = proxywasm.GetProperty([]string{"metadata", "filter_metadata", "ns", "key"})
+		require.NoError(t, err)
+		require.Equal(t, []byte("value"), data)
+	})
+
+	t.Run("Property paths", func(t *testing.T) {
+		opt := NewEmulatorOption().WithVMContext(&testPlugin{}).WithProperty([]string{"request", "path"}, []byte("/a"))
+		_, reset := NewHostEmulator(opt)
+		defer reset()
+
+		requestPath := proxywasm.NewPropertyPath("request", "path")
+		require.Equal(t, "request.path", requestPath.String())
+		require.Equal(t, []string{"request", "path"}, requestPath.Path())
+		data, err := proxywasm.GetPropertyPath(requestPath)
+		require.NoError(t, err)
+		require.Equal(t, []byte("/a"), data)
+
+		// Append does not modify the receiver.
+		wasm := proxywasm.NewPropertyPath("wasm")
+		key := wasm.Append("key")
+		require.Equal(t, "wasm", wasm.String())
+		require.Equal(t, "wasm.key", key.String())
+		require.NoError(t, proxywasm.SetPropertyPath(key, []byte("value")))
+		data, err = proxywasm.GetProperty([]string{"wasm", "key"})
+		require.NoError(t, err)
+		require.Equal(t, []byte("value"), data)
+
+		_, err = proxywasm.GetPropertyPath(proxywasm.NewPropertyPath())
+		require.EqualError(t, err, "path must not be empty")
+		require.EqualError(t, proxywasm.SetPropertyPath(key, nil), "data must not be empty")
+	})
+
+	t.Run("Cache properties per stream", func(t *testing.T) {
+		opt := NewEmulatorOption().WithVMContext(&testPlugin{}).WithProperty([]string{"key"}, []byte("a"))
+		host, reset := NewHostEmulator(opt)
+		defer reset()
+
+		proxywasm.EnablePropertyCache()
+		id := host.InitializeHttpContext()
+		host.CallOnRequestHeaders(id, nil, false)
+
+		data, err := proxywasm.GetProperty([]string{"key"})
+		require.NoError(t, err)
+		require.Equal(t, []byte("a"), data)
+
+		// Changes made by the host are not seen until the cache is dropped.
+		require.NoError(t, host.SetProperty([]string{"key"}, []byte("b")))
+		data, err = proxywasm.GetProperty([]string{"key"})
+		require.NoError(t, err)
+		require.Equal(t, []byte("a"), data)
+
+		require.NoError(t, proxywasm.SetProperty([]string{"other"}, []byte("c")))
+		data, err = proxywasm.GetProperty([]string{"key"})
+		require.NoError(t, err)
+		require.Equal(t, []byte("b"), data)
+
+		host.CompleteHttpContext(id)
+		require.Contains(t, host.GetDebugLogs(), fmt.Sprintf("property cache of context %d: 1 hits, 2 misses", id))
+	})
+}
+
+func TestStrictPhases_resume(t *testing.T) {
+	host, reset := NewHostEmulator(NewEmulatorOption().WithVMContext(&phaseVMContext{}).WithStrictPhases())
+	defer reset()
+	id := host.InitializeHttpContext()
+	host.CallOnRequestHeaders(id, [][2]string{{":path", "/"}}, false)
+
+	host.RunInVM(func() {
+		require.NoError(t, proxywasm.SetEffectiveContext(id))
+		_, err := proxywasm.GetHttpRequestHeader(":path")
+		require.NoError(t, err)
+
+		require.NoError(t, proxywasm.ResumeHttpRequest())
+		_, err = proxywasm.GetHttpRequestHeader(":path")
+		require.ErrorIs(t, err, types.ErrorStatusBadArgument)
+	})
+	require.Equal(t, types.ActionContinue, host.GetCurrentHttpStreamAction(id))
+}
+
+type phaseVMContext struct {
+	types.DefaultVMContext
+	types.DefaultPluginContext
+}
+
+type phaseHttpContext struct {
+	types.DefaultHttpContext
+}
+
+// NewPluginContext implements the same method on types.VMContext.
+func (v *phaseVMContext) NewPluginContext(uint32) types.PluginContext {
+	return v
+}
+
+// NewHttpContext implements the same method on types.PluginContext.
+func (v *phaseVMContext) NewHttpContext(uint32) types.HttpContext {
+	return &phaseHttpContext{}
+}
+
+// OnHttpRequestHeaders implements the same method on types.HttpContext.
+func (h *phaseHttpContext) OnHttpRequestHeaders(int, bool) types.Action {
+	_, err := proxywasm.GetHttpResponseHeaders()
+	proxywasm.LogInfof("response headers: %v", err)
+	_, err = proxywasm.GetHttpRequestBody(0, 10)
+	proxywasm.LogInfof("request body: %v", err)
+	return types.ActionPause
+}
+
+// OnHttpRequestBody implements the same method on types.HttpContext.
+func (h *phaseHttpContext) OnHttpRequestBody(int, bool) types.Action {
+	_, err := proxywasm.GetHttpRequestHeader(":path")
+	proxywasm.LogInfof("paused request headers: %v", err)
+	return types.ActionContinue
+}
+
+// OnHttpResponseHeaders implements the same method on types.HttpContext.
+func (h *phaseHttpContext) OnHttpResponseHeaders(int, bool) types.Action {
+	_, err := proxywasm.GetHttpRequestBody(0, 10)
+	proxywasm.LogInfof("continued request body: %v", err)
+	proxywasm.LogInfof("first local response: %v", proxywasm.SendHttpResponse(403, nil, nil, -1))
+	proxywasm.LogInfof("second local response: %v", proxywasm.SendHttpResponse(500, nil, nil, -1))
+	return types.ActionContinue
+}
+
+// OnHttpStreamDone implements the same method on types.HttpContext.
+func (h *phaseHttpContext) OnHttpStreamDone() {
+	_, err := proxywasm.GetHttpResponseHeader(":status")
+	proxywasm.LogInfof("logged response headers: %v", err)
+	_, err = proxywasm.GetHttpRequestTrailers()
+	proxywasm.LogInfof("logged request trailers: %v", err)
+	proxywasm.LogInfof("replaced response headers: %v", proxywasm.ReplaceHttpResponseHeader(":status", "200"))
+}
+
+func TestStrictPhases_http(t *testing.T) {
+	run := func(opt *EmulatorOption) []string {
+		host, reset := NewHostEmulator(opt.WithVMContext(&phaseVMContext{}))
+		defer reset()
+		id := host.InitializeHttpContext()
+		host.CallOnRequestHeaders(id, [][2]string{{":path", "/"}}, false)
+		host.CallOnRequestBody(id, []byte("body"), true)
+		host.CallOnResponseHeaders(id, [][2]string{{":status", "403"}}, true)
+		host.CompleteHttpContext(id)
+		return host.GetInfoLogs()
+	}
+
+	require.Equal(t, []string{
+		"response headers: error status returned by host: bad argument",
+		"request body: error status returned by host: not found",
+		"paused request headers: <nil>",
+		"continued request body: error status returned by host: not found",
+		"first local response: <nil>",
+		"second local response: error status returned by host: bad argument",
+		"logged response headers: <nil>",
+		"logged request trailers: error status returned by host: not found",
+		"replaced response headers: error status returned by host: bad argument",
+	}, run(NewEmulatorOption().WithStrictPhases()))
+
+	require.Equal(t, []string{
+		"response headers: <nil>",
+		"request body: error status returned by host: not found",
+		"paused request headers: <nil>",
+		"continued request body: <nil>",
+		"first local response: <nil>",
+		"second local response: <nil>",
+		"logged response headers: <nil>",
+		"logged request trailers: <nil>",
+		"replaced response headers: <nil>",
+	}, run(NewEmulatorOption()))
 }
@@ -15,6 +15,7 @@
 package proxytest
 
 import (
+	"bytes"
 	"log"
 	"unsafe"
 
@@ -23,26 +24,48 @@ import (
 )
 
 type networkHostEmulator struct {
+	vm           *vm
 	streamStates map[uint32]*streamState
+	// strict makes hostcalls fail outside of the lifecycle phases they are valid in, see
+	// EmulatorOption.WithStrictPhases.
+	strict bool
 }
 
 type streamState struct {
 	upstream, downstream []byte
 }
 
-func newNetworkHostEmulator() *networkHostEmulator {
+func newNetworkHostEmulator(vm *vm, strict bool) *networkHostEmulator {
 	host := &networkHostEmulator{
+		vm:           vm,
 		streamStates: map[uint32]*streamState{},
+		strict:       strict,
 	}
 
 	return host
 }
 
+// dataStatus returns the status of the hostcalls on the data of the connection in strict mode.
+// Envoy provides data only in the callback it is passed to.
+func (n *networkHostEmulator) dataStatus(bt internal.BufferType) internal.Status {
+	callback := "proxy_on_downstream_data"
+	if bt == internal.BufferTypeUpstreamData {
+		callback = "proxy_on_upstream_data"
+	}
+	if !n.strict || n.vm.callback == callback {
+		return internal.StatusOK
+	}
+	log.Printf("strict phases: %s is not available %s", bufferTypeName(bt), n.vm.phase())
+	return internal.StatusNotFound
+}
+
 // impl internal.ProxyWasmHost: delegated from hostEmulator
 func (n *networkHostEmulator) networkHostEmulatorProxyGetBufferBytes(bt internal.BufferType, start int32, maxSize int32,
 	returnBufferData unsafe.Pointer, returnBufferSize *int32) internal.Status {
-
-	active := internal.VMStateGetActiveContextID()
+	if status := n.dataStatus(bt); status != internal.StatusOK {
+		return status
+	}
+	active := n.vm.state.ActiveContextID()
 	stream := n.streamStates[active]
 	var buf []byte
 	switch bt {
@@ -71,8 +94,51 @@ func (n *networkHostEmulator) networkHostEmulatorProxyGetBufferBytes(bt internal
 	return internal.StatusOK
 }
 
+// impl internal.ProxyWasmHost: delegated from hostEmulator
+func (n *networkHostEmulator) networkHostEmulatorProxySetBufferBytes(bt internal.BufferType, start int32, maxSize int32,
+	bufferData *byte, bufferSize int32) internal.Status {
+	if status := n.dataStatus(bt); status != internal.StatusOK {
+		return status
+	}
+	active := n.vm.state.ActiveContextID()
+	stream := n.streamStates[active]
+	var targetBuf *[]byte
+	switch bt {
+	case internal.BufferTypeUpstreamData:
+		targetBuf = &stream.upstream
+	case internal.BufferTypeDownstreamData:
+		targetBuf = &stream.downstream
+	default:
+		panic("unreachable: maybe a bug in this host emulation or SDK")
+	}
+
+	// Copy the data since plugins may reuse the buffer after the call.
+	data := bytes.Clone(unsafe.Slice(bufferData, bufferSize))
+	if start == 0 {
+		if maxSize == 0 {
+			// Prepend
+			*targetBuf = append(data, *targetBuf...)
+			return internal.StatusOK
+		} else if maxSize >= int32(len(*targetBuf)) {
+			// Replace
+			*targetBuf = data
+			return internal.StatusOK
+		} else {
+			return internal.StatusBadArgument
+		}
+	} else if start >= int32(len(*targetBuf)) {
+		// Append.
+		*targetBuf = append(*targetBuf, data...)
+		return internal.StatusOK
+	} else {
+		return internal.StatusBadArgument
+	}
+}
+
 // impl HostEmulator
 func (n *networkHostEmulator) CallOnUpstreamData(contextID uint32, data []byte) types.Action {
+	defer n.vm.call()()
+	defer n.vm.enterCallback("proxy_on_upstream_data")()
 	stream, ok := n.streamStates[contextID]
 	if !ok {
 		log.Fatalf("invalid context id: %d", contextID)
@@ -95,6 +161,8 @@ func (n *networkHostEmulator) CallOnUpstreamData(contextID uint32, data []byte)
 
 // impl HostEmulator
 func (n *networkHostEmulator) CallOnDownstreamData(contextID uint32, data []byte) types.Action {
+	defer n.vm.call()()
+	defer n.vm.enterCallback("proxy_on_downstream_data")()
 	stream, ok := n.streamStates[contextID]
 	if !ok {
 		log.Fatalf("invalid context id: %d", contextID)
@@ -114,28 +182,45 @@ func (n *networkHostEmulator) CallOnDownstreamData(contextID uint32, data []byte
 	return action
 }
 
-// impl HostEmulator
-func (n *networkHostEmulator) InitializeConnection() (contextID uint32, action types.Action) {
-	contextID = getNextContextID()
-	internal.ProxyOnContextCreate(contextID, PluginContextID)
-	action = internal.ProxyOnNewConnection(contextID)
+// initializeConnection creates the TCP context with the ID under the plugin context and
+// executes types.TcpContext.OnNewConnection in the plugin.
+func (n *networkHostEmulator) initializeConnection(contextID, pluginContextID uint32) types.Action {
+	defer n.vm.call()()
+	if w, ok := n.vm.context.(*vmContext); ok {
+		// The wasm VM creates the kind of context the plugin implements, which the wrapper
+		// can't tell, so it's told a TCP context is expected.
+		w.tcp = true
+		defer func() { w.tcp = false }()
+	}
+	exit := n.vm.enterCallback("proxy_on_context_create")
+	internal.ProxyOnContextCreate(contextID, pluginContextID)
+	exit()
+	defer n.vm.enterCallback("proxy_on_new_connection")()
+	action := internal.ProxyOnNewConnection(contextID)
 	n.streamStates[contextID] = &streamState{}
-	return
+	return action
 }
 
 // impl HostEmulator
 func (n *networkHostEmulator) CloseUpstreamConnection(contextID uint32) {
+	defer n.vm.call()()
+	defer n.vm.enterCallback("proxy_on_upstream_connection_close")()
 	internal.ProxyOnUpstreamConnectionClose(contextID, types.PeerTypeLocal) // peerType will be removed in the next ABI
 }
 
 // impl HostEmulator
 func (n *networkHostEmulator) CloseDownstreamConnection(contextID uint32) {
+	defer n.vm.call()()
+	defer n.vm.enterCallback("proxy_on_downstream_connection_close")()
 	internal.ProxyOnDownstreamConnectionClose(contextID, types.PeerTypeLocal) // peerType will be removed in the next ABI
 }
 
 // impl HostEmulator
 func (n *networkHostEmulator) CompleteConnection(contextID uint32) {
+	defer n.vm.call()()
+	exit := n.vm.enterCallback("proxy_on_log")
 	internal.ProxyOnLog(contextID)
-	internal.ProxyOnDelete(contextID)
+	exit()
+	n.vm.deleteContext(contextID)
 	delete(n.streamStates, contextID)
 }
@@ -0,0 +1,91 @@
+// Copyright 2020-2024 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxytest
+
+import (
+	"testing"
+
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm"
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/types"
+	"github.com/stretchr/testify/require"
+)
+
+type bufferVMContext struct {
+	types.DefaultVMContext
+	types.DefaultPluginContext
+}
+
+type bufferTcpContext struct {
+	types.DefaultTcpContext
+}
+
+// NewPluginContext implements the same method on types.VMContext.
+func (v *bufferVMContext) NewPluginContext(uint32) types.PluginContext {
+	return v
+}
+
+// NewTcpContext implements the same method on types.PluginContext.
+func (v *bufferVMContext) NewTcpContext(uint32) types.TcpContext {
+	return &bufferTcpContext{}
+}
+
+// OnDownstreamData implements the same method on types.TcpContext.
+func (c *bufferTcpContext) OnDownstreamData(dataSize int, _ bool) types.Action {
+	if err := proxywasm.ReplaceDownstreamData([]byte("replaced")); err != nil {
+		panic(err)
+	}
+	if err := proxywasm.PrependDownstreamData([]byte("prepended ")); err != nil {
+		panic(err)
+	}
+	if err := proxywasm.AppendDownstreamData([]byte(" appended")); err != nil {
+		panic(err)
+	}
+	data, err := proxywasm.GetDownstreamData(0, 100)
+	if err != nil {
+		panic(err)
+	}
+	proxywasm.LogInfo(string(data))
+	return types.ActionContinue
+}
+
+// OnUpstreamData implements the same method on types.TcpContext.
+func (c *bufferTcpContext) OnUpstreamData(dataSize int, _ bool) types.Action {
+	_, err := proxywasm.GetDownstreamData(0, 100)
+	proxywasm.LogInfof("downstream data: %v", err)
+	return types.ActionContinue
+}
+
+func TestSetBufferBytes_network(t *testing.T) {
+	host, reset := NewHostEmulator(NewEmulatorOption().WithVMContext(&bufferVMContext{}))
+	defer reset()
+
+	id, action := host.InitializeConnection()
+	require.Equal(t, types.ActionContinue, action)
+	require.Equal(t, types.ActionContinue, host.CallOnDownstreamData(id, []byte("data")))
+	require.Equal(t, []string{"prepended replaced appended"}, host.GetInfoLogs())
+}
+
+func TestStrictPhases_network(t *testing.T) {
+	host, reset := NewHostEmulator(NewEmulatorOption().WithVMContext(&bufferVMContext{}).WithStrictPhases())
+	defer reset()
+
+	id, _ := host.InitializeConnection()
+	require.Equal(t, types.ActionContinue, host.CallOnDownstreamData(id, []byte("data")))
+	require.Equal(t, types.ActionContinue, host.CallOnUpstreamData(id, []byte("data")))
+	require.Equal(t, []string{
+		"prepended replaced appended",
+		"downstream data: error status returned by host: not found",
+	}, host.GetInfoLogs())
+}
@@ -15,6 +15,8 @@
 package proxytest
 
 import (
+	"time"
+
 	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/internal"
 	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/types"
 )
@@ -25,6 +27,17 @@ type EmulatorOption struct {
 	vmConfiguration     []byte
 	context             interface{}
 	properties          map[string][]byte
+	plugins             []pluginOption
+	vmID                string
+	clusters            map[string]*cluster
+	strictPhases        bool
+	noCallRecording     bool
+	parallel            bool
+}
+
+type pluginOption struct {
+	rootID        string
+	configuration []byte
 }
 
 // NewEmulatorOption creates a new EmulatorOption.
@@ -65,6 +78,86 @@ func (o *EmulatorOption) WithPluginConfiguration(data []byte) *EmulatorOption {
 	return o
 }
 
+// WithPlugin declares a plugin with the root ID and configuration. Each declared plugin gets its own
+// plugin context, created in the order of declaration. The first one is at PluginContextID and is
+// used by the methods of HostEmulator which don't take a plugin context ID, such as StartPlugin and
+// Tick. Once a plugin is declared, the configuration passed to WithPluginConfiguration is ignored.
+//
+// The root ID is returned for the plugin_root_id property unless the property is set with WithProperty.
+func (o *EmulatorOption) WithPlugin(rootID string, configuration []byte) *EmulatorOption {
+	o.plugins = append(o.plugins, pluginOption{rootID: rootID, configuration: configuration})
+	return o
+}
+
+// WithVMID sets the vm_id of the VM, which identifies the shared queues registered by its plugins.
+func (o *EmulatorOption) WithVMID(vmID string) *EmulatorOption {
+	o.vmID = vmID
+	return o
+}
+
+// WithCluster declares an upstream cluster whose handler answers the HTTP callouts dispatched to it.
+// Responses are delivered to the plugin once the latency of the cluster, set with WithClusterLatency,
+// has elapsed in the virtual time of the host: right after the call into the plugin which dispatched
+// the callout, or on HostEmulator.AdvanceTime. Callouts which are not answered within their timeout
+// get the 504 response of Envoy instead. Callouts to other upstreams are answered with
+// HostEmulator.CallOnHttpCallResponse.
+func (o *EmulatorOption) WithCluster(name string, handler ClusterHandler) *EmulatorOption {
+	o.cluster(name).handler = handler
+	return o
+}
+
+// WithClusterLatency sets the latency of the responses of the cluster declared with WithCluster.
+func (o *EmulatorOption) WithClusterLatency(name string, latency time.Duration) *EmulatorOption {
+	o.cluster(name).latency = latency
+	return o
+}
+
+func (o *EmulatorOption) cluster(name string) *cluster {
+	if o.clusters == nil {
+		o.clusters = map[string]*cluster{}
+	}
+	c, ok := o.clusters[name]
+	if !ok {
+		c = &cluster{}
+		o.clusters[name] = c
+	}
+	return c
+}
+
+// WithStrictPhases makes the host fail the hostcalls which Envoy fails outside of the lifecycle
+// phases they are valid in, with the same statuses, and log why:
+//   - HTTP headers, trailers and bodies are available from the callback they are passed to until
+//     the stream continues past it, so that, for example, response headers can't be read in
+//     OnHttpRequestHeaders and the request body can't be read before it arrives. Hostcalls on
+//     headers and trailers fail with types.ErrorStatusBadArgument while hostcalls on bodies fail
+//     with types.ErrorStatusNotFound. OnHttpStreamDone can read the request headers and the
+//     response headers and trailers.
+//   - A local response can be sent once per HTTP stream, after which proxywasm.SendHttpResponse
+//     fails with types.ErrorStatusBadArgument.
+//   - TCP data is available only in the callback it is passed to, and fails with
+//     types.ErrorStatusNotFound elsewhere.
+func (o *EmulatorOption) WithStrictPhases() *EmulatorOption {
+	o.strictPhases = true
+	return o
+}
+
+// WithoutCallRecording stops the emulator from recording the hostcalls returned by
+// HostEmulator.Calls, whose arguments are copied on each hostcall. Benchmarks use it so that
+// the cost of the recording does not hide the cost of the plugin. Faults can still be injected.
+func (o *EmulatorOption) WithoutCallRecording() *EmulatorOption {
+	o.noCallRecording = true
+	return o
+}
+
+// WithParallel lets the emulator run alongside the emulators of parallel tests. Its VM is only
+// bound to the SDK during the calls into the plugin and HostEmulator.RunInVM, so that calls into
+// different emulators take turns instead of waiting for reset. The plugin code called by the test,
+// e.g. proxywasm.GetProperty, must then run in HostEmulator.RunInVM.
+func (o *EmulatorOption) WithParallel() *EmulatorOption {
+	o.parallel = true
+	return o
+}
+
 // WithVMConfiguration sets the VM configuration.
 func (o *EmulatorOption) WithVMConfiguration(data []byte) *EmulatorOption {
 	o.vmConfiguration = data
@@ -15,9 +15,14 @@
 package proxytest
 
 import (
+	"bytes"
 	"fmt"
 	"log"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
 	"unsafe"
 
 	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm"
@@ -33,7 +38,14 @@ type HostEmulator interface {
 	StartVM() types.OnVMStartStatus
 	// StartPlugin executes types.PluginContext.OnPluginStart in the plugin.
 	StartPlugin() types.OnPluginStartStatus
-	// FinishVM executes types.PluginContext.OnPluginDone in the plugin.
+	// StartPluginContext executes types.PluginContext.OnPluginStart in the plugin context with ID
+	// pluginContextID, passing the configuration of that plugin.
+	StartPluginContext(pluginContextID uint32) types.OnPluginStartStatus
+	// GetPluginContextID returns the ID of the plugin context declared with the root ID in
+	// EmulatorOption.WithPlugin.
+	GetPluginContextID(rootID string) (uint32, error)
+	// FinishVM executes types.PluginContext.OnPluginDone in every plugin context. It returns true
+	// if all of them are done.
 	FinishVM() bool
 	// GetCalloutAttributesFromContext returns the current HTTP callout attributes for the given HTTP context in the
 	// host.
@@ -60,8 +72,13 @@ type HostEmulator interface {
 	GetCriticalLogs() []string
 	// GetTickPeriod returns the current tick period in the host.
 	GetTickPeriod() uint32
+	// GetPluginContextTickPeriod returns the current tick period of the plugin context with ID
+	// pluginContextID in the host.
+	GetPluginContextTickPeriod(pluginContextID uint32) uint32
 	// Tick executes types.PluginContext.OnTick in the plugin.
 	Tick()
+	// TickPluginContext executes types.PluginContext.OnTick in the plugin context with ID pluginContextID.
+	TickPluginContext(pluginContextID uint32)
 	// GetQueueSize gets the current size of the queue in the host.
 	GetQueueSize(queueID uint32) int
 	// RegisterForeignFunction registers the foreign function in the host.
@@ -69,6 +86,9 @@ type HostEmulator interface {
 
 	// InitializeConnection executes types.TcpContext.OnNewConnection in the plugin.
 	InitializeConnection() (contextID uint32, action types.Action)
+	// InitializeConnectionWithParent executes types.TcpContext.OnNewConnection in a new TCP context
+	// of the plugin context with ID pluginContextID.
+	InitializeConnectionWithParent(pluginContextID uint32) (contextID uint32, action types.Action)
 	// CallOnUpstreamData executes types.TcpContext.OnUpstreamData in the plugin.
 	CallOnUpstreamData(contextID uint32, data []byte) types.Action
 	// CallOnDownstreamData executes types.TcpContext.OnDownstreamData in the plugin.
@@ -82,6 +102,9 @@ type HostEmulator interface {
 
 	// InitializeHttpContext executes types.PluginContext.NewHttpContext in the plugin.
 	InitializeHttpContext() (contextID uint32)
+	// InitializeHttpContextWithParent executes types.PluginContext.NewHttpContext in the plugin
+	// context with ID pluginContextID.
+	InitializeHttpContextWithParent(pluginContextID uint32) (contextID uint32)
 	// CallOnResponseHeaders executes types.HttpContext.OnHttpResponseHeaders in the plugin.
 	// The number of headers and endOfStream are passed to the plugin and the content of headers are visible in
 	// the plugin for methods like proxywasm.GetHttpResponseHeaders.
@@ -129,45 +152,135 @@ type HostEmulator interface {
 	GetSentLocalResponse(contextID uint32) *LocalHttpResponse
 	// GetProperty returns property data from the host, for a given path.
 	GetProperty(path []string) ([]byte, error)
-	// SetProperty sets property data on the host, for a given path.
+	// SetProperty sets property data on the host, for a given path. Unlike plugins, tests can
+	// set the attributes of Envoy such as "metadata".
 	SetProperty(path []string, data []byte) error
+
+	// AdvanceTime advances the virtual time of the host by d. Events fire in order as they become due:
+	// the ticks of each plugin context according to its tick period, the responses of the clusters
+	// declared with EmulatorOption.WithCluster and the timeouts of HTTP callouts. The virtual time
+	// backs the clocks of VMs created with NewWasmVMContext, while plugins running natively read
+	// the real time.
+	AdvanceTime(d time.Duration)
+
+	// Calls returns the hostcalls made by the plugin in the order they were made, with the ones
+	// matching all of filters, such as CallsNamed("proxy_set_shared_data"), if any. No hostcalls
+	// are recorded with EmulatorOption.WithoutCallRecording.
+	Calls(filters ...HostCallFilter) []HostCall
+	// DumpCallsOnFailure logs the hostcalls made by the plugin when t has failed by the end of the
+	// test.
+	DumpCallsOnFailure(t testing.TB)
+	// InjectFault makes the hostcalls with the name, such as "proxy_set_shared_data", which match
+	// all of filters fail with err instead of being served by the host. err is one of the errors
+	// for the statuses returned by hostcalls, such as types.ErrorStatusCasMismatch.
+	InjectFault(name string, err error, filters ...HostCallFilter)
+	// InjectNthFault is like InjectFault but only the nth of the matching hostcalls made from now
+	// on fails, counting from 1.
+	InjectNthFault(n int, name string, err error, filters ...HostCallFilter)
+	// ClearFaults removes the faults injected by InjectFault and InjectNthFault.
+	ClearFaults()
+
+	// RunInVM runs f in the VM of the emulator, as in a call into the plugin: the hostcalls
+	// made by f, e.g. with proxywasm.GetSharedData, are served by the emulator. Emulators created
+	// with EmulatorOption.WithParallel only serve the plugin code called by the test in RunInVM.
+	RunInVM(f func())
+
+	// NewVM starts another VM configured by opt on the same host and returns its HostEmulator.
+	// VMs share the shared data and the shared queues of the host, where queues are identified by
+	// the vm_id of the VM which registered them, set with EmulatorOption.WithVMID. Plugins,
+	// contexts, logs, metrics and properties are per VM. The VM is released by the reset function
+	// returned by NewHostEmulator. The plugin code called by the test directly runs in the first
+	// VM, use HostEmulator.RunInVM of the returned emulator to run it in the new one.
+	NewVM(opt *EmulatorOption) HostEmulator
 }
 
 const (
-	PluginContextID uint32 = 1 // TODO: support multiple pluginContext
+	// PluginContextID is the ID of the first plugin context. The IDs of the other plugins declared
+	// with EmulatorOption.WithPlugin can be looked up with HostEmulator.GetPluginContextID.
+	PluginContextID uint32 = 1
 )
 
-var nextContextID = PluginContextID + 1
+var nextContextID atomic.Uint32
 
-type hostEmulator struct {
-	*rootHostEmulator
-	*networkHostEmulator
-	*httpHostEmulator
+// vmMux is held for as long as a VM is bound to the SDK, since the SDK finds the VM serving
+// hostcalls in package state. Emulators of parallel tests take turns, see
+// EmulatorOption.WithParallel.
+var vmMux sync.Mutex
 
-	effectiveContextID uint32
-	properties         map[string][]byte
-}
+// pluginRootIDPath is the serialized path of the plugin_root_id property.
+var pluginRootIDPath = string(internal.SerializePropertyPath([]string{"plugin_root_id"}))
+
+type (
+	hostEmulator struct {
+		*rootHostEmulator
+		*networkHostEmulator
+		*httpHostEmulator
+
+		vm                 *vm
+		effectiveContextID uint32
+		properties         map[string][]byte
+	}
+
+	// vm is a VM running against the emulated host: the context it runs, the state of
+	// the SDK in the VM and the emulator serving its hostcalls.
+	vm struct {
+		id       string
+		context  interface{}
+		state    *internal.VMState
+		emulator *hostEmulator
+		spy      *spyHost
+		host     *sharedHost
+		// callback is the name of the lifecycle callback running in the VM.
+		callback string
+	}
+)
 
 // NewHostEmulator returns a new HostEmulator that can be used to test a plugin. Plugin tests will
 // often involve calling methods on HostEmulator to invoke methods in the plugin while checking
 // the state within the host after plugin execution.
+//
+// Each emulator has its own VMs and host state. A single emulator must not be used concurrently.
+// The emulator keeps its VM bound to the SDK until reset is called, so that the test can call the
+// plugin code directly, e.g. proxywasm.GetProperty. Other emulators wait for reset, unless they
+// are created with EmulatorOption.WithParallel.
 func NewHostEmulator(opt *EmulatorOption) (host HostEmulator, reset func()) {
-	root := newRootHostEmulator(opt.pluginConfiguration, opt.vmConfiguration)
-	network := newNetworkHostEmulator()
-	http := newHttpHostEmulator()
+	shared := newSharedHost()
+	if opt.parallel {
+		return newVM(shared, opt), func() {}
+	}
+	vmMux.Lock()
+	shared.entered++
+	emulator := newVM(shared, opt)
+	restore := internal.BindVM(emulator.vm.state, emulator.vm.spy)
+	return emulator, func() {
+		restore()
+		shared.entered--
+		vmMux.Unlock()
+	}
+}
+
+// newVM starts a VM on the host and returns its emulator.
+func newVM(shared *sharedHost, opt *EmulatorOption) *hostEmulator {
+	v := &vm{id: opt.vmID, context: opt.context, state: internal.NewVMState(), host: shared}
 	emulator := &hostEmulator{
-		root,
-		network,
-		http,
-		0,
-		make(map[string][]byte),
+		rootHostEmulator:    newRootHostEmulator(v, opt.vmConfiguration, opt.clusters),
+		networkHostEmulator: newNetworkHostEmulator(v, opt.strictPhases),
+		httpHostEmulator:    newHttpHostEmulator(v, opt.strictPhases),
+		vm:                  v,
+		properties:          make(map[string][]byte),
+	}
+	v.emulator = emulator
+	v.spy = &spyHost{vm: v, recording: !opt.noCallRecording}
+	shared.vms = append(shared.vms, v)
+	if w, ok := opt.context.(*vmContext); ok {
+		w.clock = &shared.clock
 	}
 
 	for key, value := range opt.properties {
 		emulator.properties[key] = value
 	}
 
-	release := internal.RegisterMockWasmHost(emulator)
+	defer v.enter()()
 
 	// set up state
 	switch c := opt.context.(type) {
@@ -183,15 +296,84 @@ func NewHostEmulator(opt *EmulatorOption) (host HostEmulator, reset func()) {
 		panic("Unknown context passed to NewHostEmulator.")
 	}
 
-	// create plugin context: TODO: support multiple plugin contexts
-	internal.ProxyOnContextCreate(PluginContextID, 0)
+	// create plugin contexts
+	plugins := opt.plugins
+	if len(plugins) == 0 {
+		plugins = []pluginOption{{configuration: opt.pluginConfiguration}}
+	}
+	defer v.enterCallback("proxy_on_context_create")()
+	for i, p := range plugins {
+		id := PluginContextID
+		if i > 0 {
+			id = getNextContextID()
+		}
+		emulator.addPlugin(id, p.rootID, p.configuration)
+		internal.ProxyOnContextCreate(id, 0)
+	}
+	return emulator
+}
 
-	return emulator, func() {
-		defer release()
-		defer internal.VMStateReset()
+// enter makes v the VM called into, waiting for the VMs of other hosts to be unbound. The
+// returned function switches back to the VM bound before, if any.
+func (v *vm) enter() (exit func()) {
+	h := v.host
+	if h.entered == 0 {
+		vmMux.Lock()
+	}
+	h.entered++
+	restore := internal.BindVM(v.state, v.spy)
+	return func() {
+		restore()
+		h.entered--
+		if h.entered == 0 {
+			vmMux.Unlock()
+		}
+	}
+}
+
+// impl HostEmulator
+func (h *hostEmulator) RunInVM(f func()) {
+	defer h.vm.enter()()
+	f()
+}
+
+// enterCallback marks the lifecycle callback with the name as running in v, which the hostcalls
+// are recorded in. The returned function switches back to the previous callback.
+func (v *vm) enterCallback(name string) (exit func()) {
+	prev := v.callback
+	v.callback = name
+	return func() { v.callback = prev }
+}
+
+// phase describes the lifecycle callback running in v for logs.
+func (v *vm) phase() string {
+	if v.callback == "" {
+		return "outside of callbacks"
+	}
+	return "in " + v.callback
+}
+
+// deleteContext deletes the stream context in the VM, including in the guest of a wasm VM.
+func (v *vm) deleteContext(contextID uint32) {
+	defer v.enterCallback("proxy_on_delete")()
+	internal.ProxyOnDelete(contextID)
+	v.host.clock.cancel(v, contextID)
+	if w, ok := v.context.(*vmContext); ok {
+		w.deleteContext(contextID)
 	}
 }
 
+// impl HostEmulator
+func (h *hostEmulator) NewVM(opt *EmulatorOption) HostEmulator {
+	return newVM(h.vm.host, opt)
+}
+
+// getNextContextID returns a context ID unique in the process, since a wasm VM may be shared
+// by several emulators.
+func getNextContextID() uint32 {
+	return PluginContextID + nextContextID.Add(1)
+}
+
 func cloneWithLowerCaseMapKeys(m [][2]string) [][2]string {
 	r := make([][2]string, len(m))
 	for i, entry := range m {
@@ -208,9 +390,27 @@ func deserializeRawBytePtrToMap(aw *byte, size int32) [][2]string {
 	return m
 }
 
-func getNextContextID() (ret uint32) {
-	ret = nextContextID
-	nextContextID++
+// impl HostEmulator
+func (h *hostEmulator) InitializeConnection() (contextID uint32, action types.Action) {
+	return h.InitializeConnectionWithParent(PluginContextID)
+}
+
+// impl HostEmulator
+func (h *hostEmulator) InitializeConnectionWithParent(pluginContextID uint32) (contextID uint32, action types.Action) {
+	contextID = h.createContext(pluginContextID)
+	action = h.initializeConnection(contextID, pluginContextID)
+	return
+}
+
+// impl HostEmulator
+func (h *hostEmulator) InitializeHttpContext() (contextID uint32) {
+	return h.InitializeHttpContextWithParent(PluginContextID)
+}
+
+// impl HostEmulator
+func (h *hostEmulator) InitializeHttpContextWithParent(pluginContextID uint32) (contextID uint32) {
+	contextID = h.createContext(pluginContextID)
+	h.initializeHttpContext(contextID, pluginContextID)
 	return
 }
 
@@ -233,6 +433,8 @@ func (h *hostEmulator) ProxySetBufferBytes(bt internal.BufferType, start int32,
 	switch bt {
 	case internal.BufferTypeHttpRequestBody, internal.BufferTypeHttpResponseBody:
 		ret = h.httpHostEmulatorProxySetBufferBytes(bt, start, maxSize, bufferData, bufferSize)
+	case internal.BufferTypeDownstreamData, internal.BufferTypeUpstreamData:
+		ret = h.networkHostEmulatorProxySetBufferBytes(bt, start, maxSize, bufferData, bufferSize)
 	default:
 		panic(fmt.Sprintf("buffer type %d is not supported by proxytest frame work yet", bt))
 	}
@@ -275,37 +477,61 @@ func (h *hostEmulator) ProxySetEffectiveContext(contextID uint32) internal.Statu
 	// TODO(ikeeip): This is a workaround. Originally host uses both true context and
 	// effective context every time. We should implement this behavior hostEmulator too.
 	// see: https://github.com/proxy-wasm/proxy-wasm-cpp-host/blob/f38347360feaaf5b2a733f219c4d8c9660d626f0/src/exports.cc#L23
-	internal.VMStateSetActiveContextID(contextID)
+	h.vm.state.SetActiveContextID(contextID)
 	return internal.StatusOK
 }
 
+// attributes are the roots of the property paths Envoy resolves to its own attributes.
+// Envoy stores the properties set by plugins as filter state, which is only read back
+// for paths under none of these roots. The emulator rejects such writes with
+// StatusBadArgument so that tests catch them, while Envoy silently drops them.
+var attributes = map[string]bool{
+	"request": true, "response": true, "connection": true, "upstream": true, "source": true,
+	"destination": true, "metadata": true, "filter_state": true, "upstream_filter_state": true,
+	"node": true, "xds": true, "route_name": true, "cluster_name": true, "route_metadata": true,
+	"cluster_metadata": true, "upstream_host_metadata": true, "listener_direction": true,
+	"listener_metadata": true, "plugin_name": true, "plugin_root_id": true, "plugin_vm_id": true,
+}
+
 // impl internal.ProxyWasmHost
 func (h *hostEmulator) ProxySetProperty(pathPtr *byte, pathSize int32, dataPtr *byte, dataSize int32) internal.Status {
 	path := unsafe.String(pathPtr, pathSize)
-	data := unsafe.Slice(dataPtr, dataSize)
-	h.properties[path] = data
+	root, _, _ := strings.Cut(path, "\x00")
+	if attributes[root] {
+		log.Printf("property %q cannot be set by the plugin since %q is an attribute", path, root)
+		return internal.StatusBadArgument
+	}
+	// Copy the data since plugins may reuse the buffer after the call.
+	h.setProperty(path, bytes.Clone(unsafe.Slice(dataPtr, dataSize)))
 	return internal.StatusOK
 }
 
+// setProperty sets the property of the serialized path, including attributes.
+func (h *hostEmulator) setProperty(path string, data []byte) {
+	h.properties[path] = data
+}
+
 // impl internal.ProxyWasmHost
 func (h *hostEmulator) ProxyGetProperty(pathPtr *byte, pathSize int32, dataPtrPtr unsafe.Pointer, dataSizePtr *int32) internal.Status {
 	path := unsafe.String(pathPtr, pathSize)
-	if _, ok := h.properties[path]; !ok {
+	data, ok := h.properties[path]
+	if !ok && path == pluginRootIDPath {
+		rootID := h.activePlugin().rootID
+		data, ok = []byte(rootID), rootID != ""
+	}
+	if !ok {
 		return internal.StatusNotFound
 	}
-	data := h.properties[path]
+	if len(data) == 0 {
+		*dataSizePtr = 0
+		return internal.StatusOK
+	}
 	*(**byte)(dataPtrPtr) = &data[0]
 	dataSize := int32(len(data))
 	*dataSizePtr = dataSize
 	return internal.StatusOK
 }
 
-// impl internal.ProxyWasmHost
-func (h *hostEmulator) ProxyResolveSharedQueue(vmIDData *byte, vmIDSize int32, nameData *byte, nameSize int32, returnID *uint32) internal.Status {
-	log.Printf("ProxyResolveSharedQueue not implemented in the host emulator yet")
-	return 0
-}
-
 // impl internal.ProxyWasmHost
 func (h *hostEmulator) ProxyCloseStream(streamType internal.StreamType) internal.Status {
 	log.Printf("ProxyCloseStream not implemented in the host emulator yet")
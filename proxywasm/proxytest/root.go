@@ -15,9 +15,11 @@
 package proxytest
 
 import (
+	"bytes"
 	"fmt"
 	"log"
 	"strings"
+	"time"
 	"unsafe"
 
 	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/internal"
@@ -26,14 +28,15 @@ import (
 
 type (
 	rootHostEmulator struct {
+		vm *vm
+
 		activeCalloutID  uint32
 		logs             [internal.LogLevelMax][]string
-		tickPeriod       uint32
 		foreignFunctions map[string]func([]byte) []byte
 
-		queues        map[uint32][][]byte
-		queueNameID   map[string]uint32
-		sharedDataKVS map[string]*sharedData
+		plugins          map[uint32]*pluginState // key: pluginContextID
+		pluginContextIDs []uint32                // in the order of declaration
+		contextIDToRoot  map[uint32]uint32       // key: contextID, value: pluginContextID
 
 		httpContextIDToCalloutInfos map[uint32][]HttpCalloutAttribute // key: contextID
 		httpCalloutIDToContextID    map[uint32]uint32                 // key: calloutID
@@ -42,12 +45,21 @@ type (
 			trailers [][2]string
 			body     []byte
 		}
+		httpCalloutTimers map[uint32]*timer // key: calloutID
 
 		metricIDToType  map[uint32]internal.MetricType
 		metricNameToID  map[string]uint32
 		metricIDToValue map[uint32]uint64
 
-		pluginConfiguration, vmConfiguration []byte
+		vmConfiguration []byte
+		clusters        map[string]*cluster
+	}
+
+	pluginState struct {
+		rootID        string
+		configuration []byte
+		tickPeriod    uint32
+		tick          *timer
 	}
 
 	HttpCalloutAttribute struct {
@@ -58,18 +70,51 @@ type (
 		Body      []byte
 	}
 
+	// sharedHost is the state of the host shared by all the VMs running against it.
+	sharedHost struct {
+		vms   []*vm
+		clock clock
+		// entered counts the nested calls into the VMs, see vm.enter.
+		entered int
+
+		queues        map[uint32]*sharedQueue
+		queueIDs      map[queueKey]uint32
+		sharedDataKVS map[string]*sharedData
+	}
+
+	sharedQueue struct {
+		// vm and pluginContextID are where the queue is ready, i.e. the plugin which
+		// registered it last as in Envoy.
+		vm              *vm
+		pluginContextID uint32
+		data            [][]byte
+	}
+
+	queueKey struct {
+		vmID, name string
+	}
+
 	sharedData struct {
 		data []byte
 		cas  uint32
 	}
 )
 
-func newRootHostEmulator(pluginConfiguration, vmConfiguration []byte) *rootHostEmulator {
+func newSharedHost() *sharedHost {
+	return &sharedHost{
+		clock:         clock{start: time.Now()},
+		queues:        map[uint32]*sharedQueue{},
+		queueIDs:      map[queueKey]uint32{},
+		sharedDataKVS: map[string]*sharedData{},
+	}
+}
+
+func newRootHostEmulator(vm *vm, vmConfiguration []byte, clusters map[string]*cluster) *rootHostEmulator {
 	host := &rootHostEmulator{
+		vm:                          vm,
 		foreignFunctions:            map[string]func([]byte) []byte{},
-		queues:                      map[uint32][][]byte{},
-		queueNameID:                 map[string]uint32{},
-		sharedDataKVS:               map[string]*sharedData{},
+		plugins:                     map[uint32]*pluginState{},
+		contextIDToRoot:             map[uint32]uint32{},
 		metricIDToValue:             map[uint32]uint64{},
 		metricIDToType:              map[uint32]internal.MetricType{},
 		metricNameToID:              map[string]uint32{},
@@ -81,12 +126,57 @@ func newRootHostEmulator(pluginConfiguration, vmConfiguration []byte) *rootHostE
 			body     []byte
 		}{},
 
-		pluginConfiguration: pluginConfiguration,
-		vmConfiguration:     vmConfiguration,
+		httpCalloutTimers: map[uint32]*timer{},
+
+		vmConfiguration: vmConfiguration,
+		clusters:        map[string]*cluster{},
+	}
+	for name, c := range clusters {
+		if c.handler != nil {
+			host.clusters[name] = &cluster{handler: c.handler, latency: c.latency}
+		}
 	}
 	return host
 }
 
+// addPlugin declares a plugin whose context is created with the given ID.
+func (r *rootHostEmulator) addPlugin(pluginContextID uint32, rootID string, configuration []byte) {
+	r.plugins[pluginContextID] = &pluginState{rootID: rootID, configuration: configuration}
+	r.pluginContextIDs = append(r.pluginContextIDs, pluginContextID)
+	r.contextIDToRoot[pluginContextID] = pluginContextID
+}
+
+// createContext assigns an ID to a new stream context under the given plugin context.
+func (r *rootHostEmulator) createContext(pluginContextID uint32) uint32 {
+	r.plugin(pluginContextID)
+	contextID := getNextContextID()
+	r.contextIDToRoot[contextID] = pluginContextID
+	return contextID
+}
+
+// plugin returns the state of the plugin with the given context ID.
+func (r *rootHostEmulator) plugin(pluginContextID uint32) *pluginState {
+	p, ok := r.plugins[pluginContextID]
+	if !ok {
+		log.Fatalf("invalid plugin context id: %d", pluginContextID)
+	}
+	return p
+}
+
+// rootContextID returns the ID of the plugin context that the context belongs to. Hostcalls made
+// outside any context, e.g. in types.VMContext.OnVMStart, belong to the plugin at PluginContextID.
+func (r *rootHostEmulator) rootContextID(contextID uint32) uint32 {
+	if id, ok := r.contextIDToRoot[contextID]; ok {
+		return id
+	}
+	return PluginContextID
+}
+
+// activePlugin returns the state of the plugin that the active context belongs to.
+func (r *rootHostEmulator) activePlugin() *pluginState {
+	return r.plugins[r.rootContextID(r.vm.state.ActiveContextID())]
+}
+
 // impl internal.ProxyWasmHost
 func (r *rootHostEmulator) ProxyLog(logLevel internal.LogLevel, messageData *byte, messageSize int32) internal.Status {
 	str := unsafe.String(messageData, messageSize)
@@ -98,53 +188,95 @@ func (r *rootHostEmulator) ProxyLog(logLevel internal.LogLevel, messageData *byt
 
 // impl internal.ProxyWasmHost
 func (r *rootHostEmulator) ProxySetTickPeriodMilliseconds(period uint32) internal.Status {
-	r.tickPeriod = period
+	pluginContextID := r.rootContextID(r.vm.state.ActiveContextID())
+	p := r.plugin(pluginContextID)
+	p.tickPeriod = period
+
+	// The ticks follow the new period from now on.
+	clock := &r.vm.host.clock
+	if p.tick != nil {
+		clock.stop(p.tick)
+		p.tick = nil
+	}
+	if period > 0 {
+		r.scheduleTick(pluginContextID)
+	}
 	return internal.StatusOK
 }
 
+// scheduleTick schedules the next tick of the plugin after its tick period in the virtual time.
+func (r *rootHostEmulator) scheduleTick(pluginContextID uint32) {
+	p := r.plugin(pluginContextID)
+	clock := &r.vm.host.clock
+	p.tick = clock.schedule(clock.now+time.Duration(p.tickPeriod)*time.Millisecond, r.vm, pluginContextID, func() {
+		r.scheduleTick(pluginContextID)
+		r.TickPluginContext(pluginContextID)
+	})
+}
+
 // impl internal.ProxyWasmHost
 func (r *rootHostEmulator) ProxyRegisterSharedQueue(nameData *byte, nameSize int32, returnID *uint32) internal.Status {
-	name := unsafe.String(nameData, nameSize)
-	if id, ok := r.queueNameID[name]; ok {
-		*returnID = id
-		return internal.StatusOK
+	key := queueKey{vmID: r.vm.id, name: strings.Clone(unsafe.String(nameData, nameSize))}
+	shared := r.vm.host
+	id, ok := shared.queueIDs[key]
+	if !ok {
+		id = uint32(len(shared.queues))
+		shared.queues[id] = &sharedQueue{}
+		shared.queueIDs[key] = id
 	}
 
-	id := uint32(len(r.queues))
-	r.queues[id] = [][]byte{}
-	r.queueNameID[name] = id
+	queue := shared.queues[id]
+	queue.vm = r.vm
+	queue.pluginContextID = r.rootContextID(r.vm.state.ActiveContextID())
+	*returnID = id
+	return internal.StatusOK
+}
+
+// impl internal.ProxyWasmHost
+func (r *rootHostEmulator) ProxyResolveSharedQueue(vmIDData *byte, vmIDSize int32, nameData *byte, nameSize int32, returnID *uint32) internal.Status {
+	key := queueKey{vmID: unsafe.String(vmIDData, vmIDSize), name: unsafe.String(nameData, nameSize)}
+	id, ok := r.vm.host.queueIDs[key]
+	if !ok {
+		log.Printf("queue %s of vm %q is not found", key.name, key.vmID)
+		return internal.StatusNotFound
+	}
 	*returnID = id
 	return internal.StatusOK
 }
 
 // impl internal.ProxyWasmHost
 func (r *rootHostEmulator) ProxyDequeueSharedQueue(queueID uint32, returnValueData unsafe.Pointer, returnValueSize *int32) internal.Status {
-	queue, ok := r.queues[queueID]
+	queue, ok := r.vm.host.queues[queueID]
 	if !ok {
 		log.Printf("queue %d is not found", queueID)
 		return internal.StatusNotFound
-	} else if len(queue) == 0 {
+	} else if len(queue.data) == 0 {
 		log.Printf("queue %d is empty", queueID)
 		return internal.StatusEmpty
 	}
 
-	data := queue[0]
+	data := queue.data[0]
 	*(**byte)(returnValueData) = &data[0]
 	*returnValueSize = int32(len(data))
-	r.queues[queueID] = queue[1:]
+	queue.data = queue.data[1:]
 	return internal.StatusOK
 }
 
 // impl internal.ProxyWasmHost
 func (r *rootHostEmulator) ProxyEnqueueSharedQueue(queueID uint32, valueData *byte, valueSize int32) internal.Status {
-	queue, ok := r.queues[queueID]
+	queue, ok := r.vm.host.queues[queueID]
 	if !ok {
 		log.Printf("queue %d is not found", queueID)
 		return internal.StatusNotFound
 	}
 
-	r.queues[queueID] = append(queue, unsafe.Slice(valueData, valueSize))
-	internal.ProxyOnQueueReady(PluginContextID, queueID)
+	// Copy the data since plugins may reuse the buffer after the call.
+	queue.data = append(queue.data, bytes.Clone(unsafe.Slice(valueData, valueSize)))
+
+	// The queue is ready in the VM which registered it, which may not be the caller.
+	defer queue.vm.enter()()
+	defer queue.vm.enterCallback("proxy_on_queue_ready")()
+	internal.ProxyOnQueueReady(queue.pluginContextID, queueID)
 	return internal.StatusOK
 }
 
@@ -153,7 +285,7 @@ func (r *rootHostEmulator) ProxyGetSharedData(keyData *byte, keySize int32,
 	returnValueData unsafe.Pointer, returnValueSize *int32, returnCas *uint32) internal.Status {
 	key := unsafe.String(keyData, keySize)
 
-	value, ok := r.sharedDataKVS[key]
+	value, ok := r.vm.host.sharedDataKVS[key]
 	if !ok {
 		return internal.StatusNotFound
 	}
@@ -176,21 +308,22 @@ func (r *rootHostEmulator) ProxySetSharedData(keyData *byte, keySize int32,
 	value := make([]byte, len(v))
 	copy(value, v)
 
-	prev, ok := r.sharedDataKVS[key]
+	prev, ok := r.vm.host.sharedDataKVS[key]
 	if !ok {
-		r.sharedDataKVS[key] = &sharedData{
+		r.vm.host.sharedDataKVS[key] = &sharedData{
 			data: value,
 			cas:  cas + 1,
 		}
 		return internal.StatusOK
 	}
 
-	if prev.cas != cas {
+	// CAS 0 means the caller does not check the current value, as in Envoy.
+	if cas != 0 && prev.cas != cas {
 		return internal.StatusCasMismatch
 	}
 
-	r.sharedDataKVS[key].cas = cas + 1
-	r.sharedDataKVS[key].data = value
+	r.vm.host.sharedDataKVS[key].cas = prev.cas + 1
+	r.vm.host.sharedDataKVS[key].data = value
 	return internal.StatusOK
 }
 
@@ -253,16 +386,29 @@ func (r *rootHostEmulator) ProxyHttpCall(upstreamData *byte, upstreamSize int32,
 	log.Printf("[http callout to %s] body: %s", upstream, body)
 	log.Printf("[http callout to %s] trailers: %v", upstream, trailers)
 
-	calloutID := uint32(len(r.httpCalloutIDToContextID))
-	contextID := internal.VMStateGetActiveContextID()
-	r.httpCalloutIDToContextID[calloutID] = contextID
-	r.httpContextIDToCalloutInfos[contextID] = append(r.httpContextIDToCalloutInfos[contextID], HttpCalloutAttribute{
+	// The IDs of answered callouts are reused.
+	var calloutID uint32
+	for {
+		if _, ok := r.httpCalloutIDToContextID[calloutID]; !ok {
+			break
+		}
+		calloutID++
+	}
+	contextID := r.vm.state.ActiveContextID()
+	req := HttpCalloutAttribute{
 		CalloutID: calloutID,
 		Upstream:  upstream,
 		Headers:   headers,
 		Trailers:  trailers,
 		Body:      []byte(body),
-	})
+	}
+	r.httpCalloutIDToContextID[calloutID] = contextID
+	r.httpContextIDToCalloutInfos[contextID] = append(r.httpContextIDToCalloutInfos[contextID], req)
+	if c, ok := r.clusters[upstream]; ok {
+		r.dispatchToCluster(c, req, contextID, time.Duration(timeout)*time.Millisecond)
+	} else if timeout > 0 {
+		r.scheduleCalloutResponse(req, contextID, time.Duration(timeout)*time.Millisecond, timeoutResponse)
+	}
 
 	*calloutIDPtr = calloutID
 	return internal.StatusOK
@@ -352,11 +498,11 @@ func (r *rootHostEmulator) rootHostEmulatorProxyGetBufferBytes(bt internal.Buffe
 	var buf []byte
 	switch bt {
 	case internal.BufferTypePluginConfiguration:
-		buf = r.pluginConfiguration
+		buf = r.activePlugin().configuration
 	case internal.BufferTypeVMConfiguration:
 		buf = r.vmConfiguration
 	case internal.BufferTypeHttpCallResponseBody:
-		activeID := internal.VMStateGetActiveContextID()
+		activeID := r.vm.state.ActiveContextID()
 		res, ok := r.httpCalloutResponse[r.activeCalloutID]
 		if !ok {
 			log.Fatalf("callout response unregistered for %d", activeID)
@@ -419,17 +565,44 @@ func (r *rootHostEmulator) getLogs(level internal.LogLevel) []string {
 
 // impl HostEmulator
 func (r *rootHostEmulator) GetTickPeriod() uint32 {
-	return r.tickPeriod
+	return r.GetPluginContextTickPeriod(PluginContextID)
+}
+
+// impl HostEmulator
+func (r *rootHostEmulator) GetPluginContextTickPeriod(pluginContextID uint32) uint32 {
+	return r.plugin(pluginContextID).tickPeriod
 }
 
 // impl HostEmulator
 func (r *rootHostEmulator) Tick() {
-	internal.ProxyOnTick(PluginContextID)
+	r.TickPluginContext(PluginContextID)
+}
+
+// impl HostEmulator
+func (r *rootHostEmulator) TickPluginContext(pluginContextID uint32) {
+	defer r.vm.call()()
+	defer r.vm.enterCallback("proxy_on_tick")()
+	r.plugin(pluginContextID)
+	internal.ProxyOnTick(pluginContextID)
+}
+
+// impl HostEmulator
+func (r *rootHostEmulator) GetPluginContextID(rootID string) (uint32, error) {
+	for _, id := range r.pluginContextIDs {
+		if r.plugins[id].rootID == rootID {
+			return id, nil
+		}
+	}
+	return 0, fmt.Errorf("plugin with root id %q not found", rootID)
 }
 
 // impl HostEmulator
 func (r *rootHostEmulator) GetQueueSize(queueID uint32) int {
-	return len(r.queues[queueID])
+	queue, ok := r.vm.host.queues[queueID]
+	if !ok {
+		return 0
+	}
+	return len(queue.data)
 }
 
 // impl HostEmulator
@@ -440,34 +613,59 @@ func (r *rootHostEmulator) GetCalloutAttributesFromContext(contextID uint32) []H
 
 // impl HostEmulator
 func (r *rootHostEmulator) StartVM() types.OnVMStartStatus {
+	defer r.vm.call()()
+	defer r.vm.enterCallback("proxy_on_vm_start")()
 	return internal.ProxyOnVMStart(PluginContextID, int32(len(r.vmConfiguration)))
 }
 
 // impl HostEmulator
 func (r *rootHostEmulator) StartPlugin() types.OnPluginStartStatus {
-	return internal.ProxyOnConfigure(PluginContextID, int32(len(r.pluginConfiguration)))
+	return r.StartPluginContext(PluginContextID)
+}
+
+// impl HostEmulator
+func (r *rootHostEmulator) StartPluginContext(pluginContextID uint32) types.OnPluginStartStatus {
+	defer r.vm.call()()
+	defer r.vm.enterCallback("proxy_on_configure")()
+	p := r.plugin(pluginContextID)
+	return internal.ProxyOnConfigure(pluginContextID, int32(len(p.configuration)))
 }
 
 // impl HostEmulator
 func (r *rootHostEmulator) CallOnHttpCallResponse(calloutID uint32, headers, trailers [][2]string, body []byte) {
+	defer r.vm.call()()
+	if t, ok := r.httpCalloutTimers[calloutID]; ok {
+		r.vm.host.clock.stop(t)
+		delete(r.httpCalloutTimers, calloutID)
+	}
 	r.httpCalloutResponse[calloutID] = struct {
 		headers, trailers [][2]string
 		body              []byte
 	}{headers: cloneWithLowerCaseMapKeys(headers), trailers: cloneWithLowerCaseMapKeys(trailers), body: body}
 
-	// PluginContextID, calloutID uint32, numHeaders, bodySize, numTrailers in
+	// The response is delivered to the plugin context of the caller.
+	pluginContextID := r.rootContextID(r.httpCalloutIDToContextID[calloutID])
 	r.activeCalloutID = calloutID
 	defer func() {
 		r.activeCalloutID = 0
 		delete(r.httpCalloutResponse, calloutID)
 		delete(r.httpCalloutIDToContextID, calloutID)
 	}()
-	internal.ProxyOnHttpCallResponse(PluginContextID, calloutID, int32(len(headers)), int32(len(body)), int32(len(trailers)))
+	defer r.vm.enterCallback("proxy_on_http_call_response")()
+	internal.ProxyOnHttpCallResponse(pluginContextID, calloutID, int32(len(headers)), int32(len(body)), int32(len(trailers)))
 }
 
 // impl HostEmulator
 func (r *rootHostEmulator) FinishVM() bool {
-	return internal.ProxyOnDone(PluginContextID)
+	defer r.vm.call()()
+	defer r.vm.enterCallback("proxy_on_done")()
+	done := true
+	for _, id := range r.pluginContextIDs {
+		if !internal.ProxyOnDone(id) {
+			done = false
+		}
+	}
+	return done
 }
 
 func (r *rootHostEmulator) GetCounterMetric(name string) (uint64, error) {
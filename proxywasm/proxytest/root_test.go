@@ -0,0 +1,411 @@
+// Copyright 2020-2024 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxytest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm"
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSharedData(t *testing.T) {
+	_, reset := NewHostEmulator(NewEmulatorOption().WithVMContext(&testPlugin{}))
+	defer reset()
+
+	require.NoError(t, proxywasm.SetSharedData("key", []byte("v1"), 0))
+	_, cas, err := proxywasm.GetSharedData("key")
+	require.NoError(t, err)
+
+	require.NoError(t, proxywasm.SetSharedData("key", []byte("v2"), cas))
+	require.ErrorIs(t, proxywasm.SetSharedData("key", []byte("v3"), cas), types.ErrorStatusCasMismatch)
+
+	// CAS 0 always overwrites the current value.
+	require.NoError(t, proxywasm.SetSharedData("key", []byte("v3"), 0))
+	value, newCas, err := proxywasm.GetSharedData("key")
+	require.NoError(t, err)
+	require.Equal(t, []byte("v3"), value)
+	require.Equal(t, cas+2, newCas)
+}
+
+func TestResolveSharedQueue(t *testing.T) {
+	_, reset := NewHostEmulator(NewEmulatorOption().WithVMContext(&testPlugin{}).WithVMID("vm"))
+	defer reset()
+
+	_, err := proxywasm.ResolveSharedQueue("vm", "queue")
+	require.ErrorIs(t, err, types.ErrorStatusNotFound)
+
+	registered, err := proxywasm.RegisterSharedQueue("other")
+	require.NoError(t, err)
+	registered, err = proxywasm.RegisterSharedQueue("queue")
+	require.NoError(t, err)
+	resolved, err := proxywasm.ResolveSharedQueue("vm", "queue")
+	require.NoError(t, err)
+	require.Equal(t, registered, resolved)
+
+	// Queues are namespaced by vm_id.
+	_, err = proxywasm.ResolveSharedQueue("other-vm", "queue")
+	require.ErrorIs(t, err, types.ErrorStatusNotFound)
+}
+
+type multiPluginVMContext struct {
+	types.DefaultVMContext
+}
+
+type multiPluginContext struct {
+	types.DefaultPluginContext
+	configuration string
+}
+
+type multiPluginHttpContext struct {
+	types.DefaultHttpContext
+	configuration string
+}
+
+type multiPluginTcpContext struct {
+	types.DefaultTcpContext
+	configuration string
+}
+
+// NewPluginContext implements the same method on types.VMContext.
+func (*multiPluginVMContext) NewPluginContext(uint32) types.PluginContext {
+	return &multiPluginContext{}
+}
+
+// OnPluginStart implements the same method on types.PluginContext.
+func (p *multiPluginContext) OnPluginStart(int) types.OnPluginStartStatus {
+	data, err := proxywasm.GetPluginConfiguration()
+	if err != nil {
+		panic(err)
+	}
+	p.configuration = string(data)
+	rootID, err := proxywasm.GetProperty([]string{"plugin_root_id"})
+	if err != nil {
+		panic(err)
+	}
+	if err := proxywasm.SetTickPeriodMilliSeconds(uint32(len(rootID)) * 100); err != nil {
+		panic(err)
+	}
+	if _, err := proxywasm.RegisterSharedQueue(string(rootID)); err != nil {
+		panic(err)
+	}
+	return types.OnPluginStartStatusOK
+}
+
+// OnTick implements the same method on types.PluginContext.
+func (p *multiPluginContext) OnTick() {
+	proxywasm.LogInfof("tick in %s", p.configuration)
+}
+
+// OnQueueReady implements the same method on types.PluginContext.
+func (p *multiPluginContext) OnQueueReady(queueID uint32) {
+	proxywasm.LogInfof("queue %d ready in %s", queueID, p.configuration)
+}
+
+// NewHttpContext implements the same method on types.PluginContext.
+func (p *multiPluginContext) NewHttpContext(uint32) types.HttpContext {
+	if p.configuration == "config-tcp" {
+		return nil
+	}
+	return &multiPluginHttpContext{configuration: p.configuration}
+}
+
+// NewTcpContext implements the same method on types.PluginContext.
+func (p *multiPluginContext) NewTcpContext(uint32) types.TcpContext {
+	return &multiPluginTcpContext{configuration: p.configuration}
+}
+
+// OnHttpRequestHeaders implements the same method on types.HttpContext.
+func (h *multiPluginHttpContext) OnHttpRequestHeaders(int, bool) types.Action {
+	if _, err := proxywasm.DispatchHttpCall(h.configuration, nil, nil, nil, 1000, func(int, int, int) {
+		proxywasm.LogInfof("callout response in %s", h.configuration)
+	}); err != nil {
+		panic(err)
+	}
+	return types.ActionPause
+}
+
+// OnNewConnection implements the same method on types.TcpContext.
+func (t *multiPluginTcpContext) OnNewConnection() types.Action {
+	proxywasm.LogInfof("new connection in %s", t.configuration)
+	return types.ActionContinue
+}
+
+func TestMultiplePlugins(t *testing.T) {
+	opt := NewEmulatorOption().
+		WithVMContext(&multiPluginVMContext{}).
+		WithPlugin("a", []byte("config-a")).
+		WithPlugin("bb", []byte("config-b")).
+		WithPlugin("tcp", []byte("config-tcp"))
+	host, reset := NewHostEmulator(opt)
+	defer reset()
+
+	a, err := host.GetPluginContextID("a")
+	require.NoError(t, err)
+	require.Equal(t, PluginContextID, a)
+	b, err := host.GetPluginContextID("bb")
+	require.NoError(t, err)
+	require.NotEqual(t, a, b)
+	tcp, err := host.GetPluginContextID("tcp")
+	require.NoError(t, err)
+	_, err = host.GetPluginContextID("c")
+	require.Error(t, err)
+
+	require.Equal(t, types.OnPluginStartStatusOK, host.StartPlugin())
+	require.Equal(t, types.OnPluginStartStatusOK, host.StartPluginContext(b))
+	require.Equal(t, types.OnPluginStartStatusOK, host.StartPluginContext(tcp))
+	require.Equal(t, uint32(100), host.GetTickPeriod())
+	require.Equal(t, uint32(200), host.GetPluginContextTickPeriod(b))
+
+	host.Tick()
+	host.TickPluginContext(b)
+	require.Equal(t, []string{"tick in config-a", "tick in config-b"}, host.GetInfoLogs())
+
+	t.Run("queue ready in the registering plugin", func(t *testing.T) {
+		queueID, err := proxywasm.ResolveSharedQueue("", "bb")
+		require.NoError(t, err)
+		require.NoError(t, proxywasm.EnqueueSharedQueue(queueID, []byte("data")))
+		require.Contains(t, host.GetInfoLogs(), fmt.Sprintf("queue %d ready in config-b", queueID))
+	})
+
+	t.Run("stream contexts in each plugin", func(t *testing.T) {
+		_, action := host.InitializeConnectionWithParent(tcp)
+		require.Equal(t, types.ActionContinue, action)
+		require.Contains(t, host.GetInfoLogs(), "new connection in config-tcp")
+
+		for _, tc := range []struct {
+			pluginContextID uint32
+			upstream        string
+		}{
+			{pluginContextID: a, upstream: "config-a"},
+			{pluginContextID: b, upstream: "config-b"},
+		} {
+			id := host.InitializeHttpContextWithParent(tc.pluginContextID)
+			host.CallOnRequestHeaders(id, nil, false)
+			callouts := host.GetCalloutAttributesFromContext(id)
+			require.Len(t, callouts, 1)
+			require.Equal(t, tc.upstream, callouts[0].Upstream)
+
+			host.CallOnHttpCallResponse(callouts[0].CalloutID, nil, nil, nil)
+			require.Contains(t, host.GetInfoLogs(), "callout response in "+tc.upstream)
+		}
+	})
+
+	require.True(t, host.FinishVM())
+}
+
+type queueVMContext struct {
+	types.DefaultVMContext
+	consumer bool
+}
+
+type queuePluginContext struct {
+	types.DefaultPluginContext
+	consumer bool
+}
+
+type queueHttpContext struct {
+	types.DefaultHttpContext
+	queueID uint32
+}
+
+// NewPluginContext implements the same method on types.VMContext.
+func (v *queueVMContext) NewPluginContext(uint32) types.PluginContext {
+	return &queuePluginContext{consumer: v.consumer}
+}
+
+// OnPluginStart implements the same method on types.PluginContext.
+func (p *queuePluginContext) OnPluginStart(int) types.OnPluginStartStatus {
+	if p.consumer {
+		if _, err := proxywasm.RegisterSharedQueue("queue"); err != nil {
+			panic(err)
+		}
+	}
+	return types.OnPluginStartStatusOK
+}
+
+// OnQueueReady implements the same method on types.PluginContext.
+func (p *queuePluginContext) OnQueueReady(queueID uint32) {
+	data, err := proxywasm.DequeueSharedQueue(queueID)
+	if err != nil {
+		panic(err)
+	}
+	shared, _, err := proxywasm.GetSharedData("key")
+	if err != nil {
+		panic(err)
+	}
+	proxywasm.LogInfof("dequeued %s with %s", data, shared)
+}
+
+// NewHttpContext implements the same method on types.PluginContext.
+func (p *queuePluginContext) NewHttpContext(uint32) types.HttpContext {
+	queueID, err := proxywasm.ResolveSharedQueue("consumer", "queue")
+	if err != nil {
+		panic(err)
+	}
+	return &queueHttpContext{queueID: queueID}
+}
+
+// OnHttpRequestHeaders implements the same method on types.HttpContext.
+func (h *queueHttpContext) OnHttpRequestHeaders(int, bool) types.Action {
+	if err := proxywasm.SetSharedData("key", []byte("shared"), 0); err != nil {
+		panic(err)
+	}
+	if err := proxywasm.EnqueueSharedQueue(h.queueID, []byte("data")); err != nil {
+		panic(err)
+	}
+	proxywasm.LogInfo("enqueued")
+	return types.ActionContinue
+}
+
+func TestMultipleVMs(t *testing.T) {
+	consumer, reset := NewHostEmulator(NewEmulatorOption().
+		WithVMContext(&queueVMContext{consumer: true}).
+		WithVMID("consumer"))
+	defer reset()
+	require.Equal(t, types.OnPluginStartStatusOK, consumer.StartPlugin())
+
+	producer := consumer.NewVM(NewEmulatorOption().
+		WithVMContext(&queueVMContext{}).
+		WithVMID("producer"))
+	require.Equal(t, types.OnPluginStartStatusOK, producer.StartPlugin())
+
+	id := producer.InitializeHttpContext()
+	require.Equal(t, types.ActionContinue, producer.CallOnRequestHeaders(id, nil, false))
+
+	// OnQueueReady runs in the consumer VM, which sees the shared data of the producer.
+	require.Equal(t, []string{"enqueued"}, producer.GetInfoLogs())
+	require.Equal(t, []string{"dequeued data with shared"}, consumer.GetInfoLogs())
+
+	// The queue is shared by the VMs and has been drained by the consumer.
+	queueID, err := proxywasm.ResolveSharedQueue("consumer", "queue")
+	require.NoError(t, err)
+	require.Zero(t, consumer.GetQueueSize(queueID))
+	require.Zero(t, producer.GetQueueSize(queueID))
+}
+
+func TestMultipleVMs_properties(t *testing.T) {
+	first, reset := NewHostEmulator(NewEmulatorOption().WithVMContext(&testPlugin{}))
+	defer reset()
+	second := first.NewVM(NewEmulatorOption().WithVMContext(&testPlugin{}))
+
+	require.NoError(t, first.SetProperty([]string{"node", "id"}, []byte("first")))
+	require.NoError(t, second.SetProperty([]string{"node", "id"}, []byte("second")))
+	value, err := first.GetProperty([]string{"node", "id"})
+	require.NoError(t, err)
+	require.Equal(t, []byte("first"), value)
+	value, err = second.GetProperty([]string{"node", "id"})
+	require.NoError(t, err)
+	require.Equal(t, []byte("second"), value)
+}
+
+type parallelVMContext struct {
+	types.DefaultVMContext
+	types.DefaultPluginContext
+	name string
+}
+
+type parallelHttpContext struct {
+	types.DefaultHttpContext
+	name string
+}
+
+// NewPluginContext implements the same method on types.VMContext.
+func (v *parallelVMContext) NewPluginContext(uint32) types.PluginContext {
+	return v
+}
+
+// NewHttpContext implements the same method on types.PluginContext.
+func (v *parallelVMContext) NewHttpContext(uint32) types.HttpContext {
+	return &parallelHttpContext{name: v.name}
+}
+
+// OnHttpRequestHeaders implements the same method on types.HttpContext.
+func (h *parallelHttpContext) OnHttpRequestHeaders(int, bool) types.Action {
+	if err := proxywasm.SetSharedData("name", []byte(h.name), 0); err != nil {
+		panic(err)
+	}
+	if err := proxywasm.AddHttpRequestHeader("x-name", h.name); err != nil {
+		panic(err)
+	}
+	proxywasm.LogInfo(h.name)
+	return types.ActionContinue
+}
+
+func TestParallelEmulators(t *testing.T) {
+	for i := 0; i < 8; i++ {
+		name := fmt.Sprintf("emulator-%d", i)
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			host, reset := NewHostEmulator(NewEmulatorOption().WithVMContext(&parallelVMContext{name: name}).WithParallel())
+			defer reset()
+
+			for j := 0; j < 100; j++ {
+				id := host.InitializeHttpContext()
+				require.Equal(t, types.ActionContinue, host.CallOnRequestHeaders(id, nil, false))
+				require.Equal(t, [][2]string{{"x-name", name}}, host.GetCurrentRequestHeaders(id))
+				host.CompleteHttpContext(id)
+
+				// Hostcalls from the test go to its own emulator.
+				host.RunInVM(func() {
+					value, _, err := proxywasm.GetSharedData("name")
+					require.NoError(t, err)
+					require.Equal(t, name, string(value))
+				})
+			}
+			logs := host.GetInfoLogs()
+			require.Len(t, logs, 100)
+			for _, log := range logs {
+				require.Equal(t, name, log)
+			}
+		})
+	}
+}
+
+func TestParallelEmulators_directCalls(t *testing.T) {
+	for i := 0; i < 8; i++ {
+		name := fmt.Sprintf("emulator-%d", i)
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			// Without WithParallel, the emulator is bound until reset so the test can call the SDK directly.
+			host, reset := NewHostEmulator(NewEmulatorOption().WithVMContext(&parallelVMContext{name: name}))
+			defer reset()
+
+			for j := 0; j < 100; j++ {
+				id := host.InitializeHttpContext()
+				require.Equal(t, types.ActionContinue, host.CallOnRequestHeaders(id, nil, false))
+				host.CompleteHttpContext(id)
+
+				value, _, err := proxywasm.GetSharedData("name")
+				require.NoError(t, err)
+				require.Equal(t, name, string(value))
+			}
+		})
+	}
+}
+
+func TestParallelEmulators_unbound(t *testing.T) {
+	host, reset := NewHostEmulator(NewEmulatorOption().WithParallel())
+	defer reset()
+
+	// The VM of a parallel emulator is bound only in RunInVM.
+	require.Panics(t, func() { _, _, _ = proxywasm.GetSharedData("name") })
+	host.RunInVM(func() {
+		_, _, err := proxywasm.GetSharedData("name")
+		require.ErrorIs(t, err, types.ErrorStatusNotFound)
+	})
+}
@@ -0,0 +1,577 @@
+// Copyright 2020-2024 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxytest
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"text/tabwriter"
+	"unsafe"
+
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/internal"
+)
+
+// HostCall is a hostcall made by the plugin, as recorded by the host.
+type HostCall struct {
+	// Name is the name of the hostcall in the proxy-wasm ABI, such as "proxy_get_shared_data".
+	Name string
+	// ContextID is the ID of the context the hostcall was made in.
+	ContextID uint32
+	// Callback is the name of the lifecycle callback the hostcall was made in, such as
+	// "proxy_on_request_headers". It is empty for hostcalls made outside of callbacks, such as
+	// directly by a test.
+	Callback string
+	// Args are the arguments of the hostcall. Names, keys and values are strings, buffers are
+	// []byte, header maps are [][2]string, property paths are []string and numbers keep their
+	// type in the ABI. Enums, such as the map type, are strings like "http_request_headers".
+	Args []interface{}
+	// Err is the error for the status returned by the host, or nil if the call succeeded.
+	Err error
+}
+
+// String returns the hostcall formatted as a call with its arguments and status.
+func (c HostCall) String() string {
+	var b strings.Builder
+	b.WriteString(c.Name)
+	b.WriteByte('(')
+	for i, arg := range c.Args {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		switch arg := arg.(type) {
+		case enum:
+			b.WriteString(string(arg))
+		case string, []byte:
+			fmt.Fprintf(&b, "%q", arg)
+		default:
+			fmt.Fprintf(&b, "%v", arg)
+		}
+	}
+	b.WriteString(") = ")
+	if c.Err == nil {
+		b.WriteString("ok")
+	} else {
+		b.WriteString(strings.TrimPrefix(c.Err.Error(), "error status returned by host: "))
+	}
+	return b.String()
+}
+
+// HostCallFilter selects hostcalls in HostEmulator.Calls.
+type HostCallFilter func(c HostCall) bool
+
+// CallsNamed selects the hostcalls with any of the names.
+func CallsNamed(names ...string) HostCallFilter {
+	return func(c HostCall) bool {
+		for _, name := range names {
+			if c.Name == name {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// CallsInContext selects the hostcalls made in the context with ID contextID.
+func CallsInContext(contextID uint32) HostCallFilter {
+	return func(c HostCall) bool { return c.ContextID == contextID }
+}
+
+// CallsInCallback selects the hostcalls made in the lifecycle callback with the name, such as
+// "proxy_on_request_headers".
+func CallsInCallback(callback string) HostCallFilter {
+	return func(c HostCall) bool { return c.Callback == callback }
+}
+
+// enum is an enum argument of a hostcall, which is formatted without quotes.
+type enum string
+
+// spyHost records the hostcalls of a VM before passing them to its emulator, unless they fail
+// with an injected fault.
+type spyHost struct {
+	vm        *vm
+	recording bool
+	calls     []HostCall
+	faults    []*fault
+}
+
+var _ internal.ProxyWasmHost = (*spyHost)(nil)
+
+// hostcall makes the hostcall by calling call, unless it fails with an injected fault, and records
+// it. Calls are recorded in the order they were made, including the ones made by callbacks the
+// hostcall runs, such as proxy_on_queue_ready.
+//
+// The arguments are only copied by args when the call is recorded or may fail with a fault, so
+// that hostcalls are cheap in benchmarks.
+func (s *spyHost) hostcall(name string, args func() []interface{}, call func() internal.Status) internal.Status {
+	if !s.recording && len(s.faults) == 0 {
+		return call()
+	}
+	c := HostCall{
+		Name:      name,
+		ContextID: s.vm.state.ActiveContextID(),
+		Callback:  s.vm.callback,
+	}
+	if args != nil {
+		c.Args = args()
+	}
+	i := -1
+	if s.recording {
+		i = len(s.calls)
+		s.calls = append(s.calls, c)
+	}
+	status, failed := s.injectedFault(c)
+	if !failed {
+		status = call()
+	}
+	if i >= 0 {
+		s.calls[i].Err = internal.StatusToError(status)
+	}
+	return status
+}
+
+// impl HostEmulator
+func (h *hostEmulator) Calls(filters ...HostCallFilter) []HostCall {
+	var ret []HostCall
+	for _, c := range h.vm.spy.calls {
+		matched := true
+		for _, f := range filters {
+			if !f(c) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			ret = append(ret, c)
+		}
+	}
+	return ret
+}
+
+// impl HostEmulator
+func (h *hostEmulator) DumpCallsOnFailure(t testing.TB) {
+	t.Cleanup(func() {
+		if t.Failed() {
+			t.Log(formatCalls(h.vm.spy.calls))
+		}
+	})
+}
+
+// formatCalls formats the hostcalls as a table of the context, the callback and the call.
+func formatCalls(calls []HostCall) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d hostcalls made by the plugin:\n", len(calls))
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	for i, c := range calls {
+		callback := c.Callback
+		if callback == "" {
+			callback = "-"
+		}
+		fmt.Fprintf(w, "%d\tcontext %d\t%s\t%s\n", i+1, c.ContextID, callback, c)
+	}
+	_ = w.Flush()
+	return b.String()
+}
+
+func spyString(data *byte, size int32) string {
+	return string(unsafe.Slice(data, size))
+}
+
+// spyBytes copies the data since plugins may reuse the buffer after the call.
+func spyBytes(data *byte, size int32) []byte {
+	return bytes.Clone(unsafe.Slice(data, size))
+}
+
+func spyMap(data *byte, size int32) [][2]string {
+	if size == 0 {
+		return nil
+	}
+	return internal.DeserializeMap(spyBytes(data, size))
+}
+
+func spyPath(data *byte, size int32) []string {
+	return strings.Split(spyString(data, size), "\x00")
+}
+
+func mapTypeName(t internal.MapType) enum {
+	switch t {
+	case internal.MapTypeHttpRequestHeaders:
+		return "http_request_headers"
+	case internal.MapTypeHttpRequestTrailers:
+		return "http_request_trailers"
+	case internal.MapTypeHttpResponseHeaders:
+		return "http_response_headers"
+	case internal.MapTypeHttpResponseTrailers:
+		return "http_response_trailers"
+	case internal.MapTypeHttpCallResponseHeaders:
+		return "http_call_response_headers"
+	case internal.MapTypeHttpCallResponseTrailers:
+		return "http_call_response_trailers"
+	}
+	return enum(fmt.Sprintf("map_type(%d)", t))
+}
+
+func bufferTypeName(t internal.BufferType) enum {
+	switch t {
+	case internal.BufferTypeHttpRequestBody:
+		return "http_request_body"
+	case internal.BufferTypeHttpResponseBody:
+		return "http_response_body"
+	case internal.BufferTypeDownstreamData:
+		return "downstream_data"
+	case internal.BufferTypeUpstreamData:
+		return "upstream_data"
+	case internal.BufferTypeHttpCallResponseBody:
+		return "http_call_response_body"
+	case internal.BufferTypeGrpcReceiveBuffer:
+		return "grpc_receive_buffer"
+	case internal.BufferTypeVMConfiguration:
+		return "vm_configuration"
+	case internal.BufferTypePluginConfiguration:
+		return "plugin_configuration"
+	case internal.BufferTypeCallData:
+		return "call_data"
+	}
+	return enum(fmt.Sprintf("buffer_type(%d)", t))
+}
+
+func streamTypeName(t internal.StreamType) enum {
+	switch t {
+	case internal.StreamTypeRequest:
+		return "request"
+	case internal.StreamTypeResponse:
+		return "response"
+	case internal.StreamTypeDownstream:
+		return "downstream"
+	case internal.StreamTypeUpstream:
+		return "upstream"
+	}
+	return enum(fmt.Sprintf("stream_type(%d)", t))
+}
+
+func metricTypeName(t internal.MetricType) enum {
+	switch t {
+	case internal.MetricTypeCounter:
+		return "counter"
+	case internal.MetricTypeGauge:
+		return "gauge"
+	case internal.MetricTypeHistogram:
+		return "histogram"
+	}
+	return enum(fmt.Sprintf("metric_type(%d)", t))
+}
+
+func logLevelName(l internal.LogLevel) enum {
+	if l >= internal.LogLevelMax {
+		return enum(fmt.Sprintf("log_level(%d)", l))
+	}
+	return enum(l.String())
+}
+
+// impl internal.ProxyWasmHost
+func (s *spyHost) ProxyLog(logLevel internal.LogLevel, messageData *byte, messageSize int32) internal.Status {
+	args := func() []interface{} {
+		return []interface{}{logLevelName(logLevel), spyString(messageData, messageSize)}
+	}
+	return s.hostcall("proxy_log", args, func() internal.Status {
+		return s.vm.emulator.ProxyLog(logLevel, messageData, messageSize)
+	})
+}
+
+// impl internal.ProxyWasmHost
+func (s *spyHost) ProxySetProperty(pathData *byte, pathSize int32, valueData *byte, valueSize int32) internal.Status {
+	args := func() []interface{} {
+		return []interface{}{spyPath(pathData, pathSize), spyBytes(valueData, valueSize)}
+	}
+	return s.hostcall("proxy_set_property", args, func() internal.Status {
+		return s.vm.emulator.ProxySetProperty(pathData, pathSize, valueData, valueSize)
+	})
+}
+
+// impl internal.ProxyWasmHost
+func (s *spyHost) ProxyGetProperty(pathData *byte, pathSize int32, returnValueData unsafe.Pointer, returnValueSize *int32) internal.Status {
+	args := func() []interface{} {
+		return []interface{}{spyPath(pathData, pathSize)}
+	}
+	return s.hostcall("proxy_get_property", args, func() internal.Status {
+		return s.vm.emulator.ProxyGetProperty(pathData, pathSize, returnValueData, returnValueSize)
+	})
+}
+
+// impl internal.ProxyWasmHost
+func (s *spyHost) ProxySendLocalResponse(statusCode uint32, statusCodeDetailData *byte, statusCodeDetailsSize int32,
+	bodyData *byte, bodySize int32, headersData *byte, headersSize int32, grpcStatus int32) internal.Status {
+	args := func() []interface{} {
+		return []interface{}{statusCode, spyString(statusCodeDetailData, statusCodeDetailsSize),
+			spyBytes(bodyData, bodySize), spyMap(headersData, headersSize), grpcStatus}
+	}
+	return s.hostcall("proxy_send_local_response", args, func() internal.Status {
+		return s.vm.emulator.ProxySendLocalResponse(statusCode, statusCodeDetailData, statusCodeDetailsSize,
+			bodyData, bodySize, headersData, headersSize, grpcStatus)
+	})
+}
+
+// impl internal.ProxyWasmHost
+func (s *spyHost) ProxyGetSharedData(keyData *byte, keySize int32, returnValueData unsafe.Pointer,
+	returnValueSize *int32, returnCas *uint32) internal.Status {
+	args := func() []interface{} {
+		return []interface{}{spyString(keyData, keySize)}
+	}
+	return s.hostcall("proxy_get_shared_data", args, func() internal.Status {
+		return s.vm.emulator.ProxyGetSharedData(keyData, keySize, returnValueData, returnValueSize, returnCas)
+	})
+}
+
+// impl internal.ProxyWasmHost
+func (s *spyHost) ProxySetSharedData(keyData *byte, keySize int32, valueData *byte, valueSize int32, cas uint32) internal.Status {
+	args := func() []interface{} {
+		return []interface{}{spyString(keyData, keySize), spyBytes(valueData, valueSize), cas}
+	}
+	return s.hostcall("proxy_set_shared_data", args, func() internal.Status {
+		return s.vm.emulator.ProxySetSharedData(keyData, keySize, valueData, valueSize, cas)
+	})
+}
+
+// impl internal.ProxyWasmHost
+func (s *spyHost) ProxyRegisterSharedQueue(nameData *byte, nameSize int32, returnID *uint32) internal.Status {
+	args := func() []interface{} {
+		return []interface{}{spyString(nameData, nameSize)}
+	}
+	return s.hostcall("proxy_register_shared_queue", args, func() internal.Status {
+		return s.vm.emulator.ProxyRegisterSharedQueue(nameData, nameSize, returnID)
+	})
+}
+
+// impl internal.ProxyWasmHost
+func (s *spyHost) ProxyResolveSharedQueue(vmIDData *byte, vmIDSize int32, nameData *byte, nameSize int32, returnID *uint32) internal.Status {
+	args := func() []interface{} {
+		return []interface{}{spyString(vmIDData, vmIDSize), spyString(nameData, nameSize)}
+	}
+	return s.hostcall("proxy_resolve_shared_queue", args, func() internal.Status {
+		return s.vm.emulator.ProxyResolveSharedQueue(vmIDData, vmIDSize, nameData, nameSize, returnID)
+	})
+}
+
+// impl internal.ProxyWasmHost
+func (s *spyHost) ProxyDequeueSharedQueue(queueID uint32, returnValueData unsafe.Pointer, returnValueSize *int32) internal.Status {
+	args := func() []interface{} {
+		return []interface{}{queueID}
+	}
+	return s.hostcall("proxy_dequeue_shared_queue", args, func() internal.Status {
+		return s.vm.emulator.ProxyDequeueSharedQueue(queueID, returnValueData, returnValueSize)
+	})
+}
+
+// impl internal.ProxyWasmHost
+func (s *spyHost) ProxyEnqueueSharedQueue(queueID uint32, valueData *byte, valueSize int32) internal.Status {
+	args := func() []interface{} {
+		return []interface{}{queueID, spyBytes(valueData, valueSize)}
+	}
+	return s.hostcall("proxy_enqueue_shared_queue", args, func() internal.Status {
+		return s.vm.emulator.ProxyEnqueueSharedQueue(queueID, valueData, valueSize)
+	})
+}
+
+// impl internal.ProxyWasmHost
+func (s *spyHost) ProxyGetHeaderMapValue(mapType internal.MapType, keyData *byte, keySize int32,
+	returnValueData unsafe.Pointer, returnValueSize *int32) internal.Status {
+	args := func() []interface{} {
+		return []interface{}{mapTypeName(mapType), spyString(keyData, keySize)}
+	}
+	return s.hostcall("proxy_get_header_map_value", args, func() internal.Status {
+		return s.vm.emulator.ProxyGetHeaderMapValue(mapType, keyData, keySize, returnValueData, returnValueSize)
+	})
+}
+
+// impl internal.ProxyWasmHost
+func (s *spyHost) ProxyAddHeaderMapValue(mapType internal.MapType, keyData *byte, keySize int32, valueData *byte, valueSize int32) internal.Status {
+	args := func() []interface{} {
+		return []interface{}{mapTypeName(mapType), spyString(keyData, keySize), spyString(valueData, valueSize)}
+	}
+	return s.hostcall("proxy_add_header_map_value", args, func() internal.Status {
+		return s.vm.emulator.ProxyAddHeaderMapValue(mapType, keyData, keySize, valueData, valueSize)
+	})
+}
+
+// impl internal.ProxyWasmHost
+func (s *spyHost) ProxyReplaceHeaderMapValue(mapType internal.MapType, keyData *byte, keySize int32, valueData *byte, valueSize int32) internal.Status {
+	args := func() []interface{} {
+		return []interface{}{mapTypeName(mapType), spyString(keyData, keySize), spyString(valueData, valueSize)}
+	}
+	return s.hostcall("proxy_replace_header_map_value", args, func() internal.Status {
+		return s.vm.emulator.ProxyReplaceHeaderMapValue(mapType, keyData, keySize, valueData, valueSize)
+	})
+}
+
+// impl internal.ProxyWasmHost
+func (s *spyHost) ProxyContinueStream(streamType internal.StreamType) internal.Status {
+	args := func() []interface{} {
+		return []interface{}{streamTypeName(streamType)}
+	}
+	return s.hostcall("proxy_continue_stream", args, func() internal.Status {
+		return s.vm.emulator.ProxyContinueStream(streamType)
+	})
+}
+
+// impl internal.ProxyWasmHost
+func (s *spyHost) ProxyCloseStream(streamType internal.StreamType) internal.Status {
+	args := func() []interface{} {
+		return []interface{}{streamTypeName(streamType)}
+	}
+	return s.hostcall("proxy_close_stream", args, func() internal.Status {
+		return s.vm.emulator.ProxyCloseStream(streamType)
+	})
+}
+
+// impl internal.ProxyWasmHost
+func (s *spyHost) ProxyRemoveHeaderMapValue(mapType internal.MapType, keyData *byte, keySize int32) internal.Status {
+	args := func() []interface{} {
+		return []interface{}{mapTypeName(mapType), spyString(keyData, keySize)}
+	}
+	return s.hostcall("proxy_remove_header_map_value", args, func() internal.Status {
+		return s.vm.emulator.ProxyRemoveHeaderMapValue(mapType, keyData, keySize)
+	})
+}
+
+// impl internal.ProxyWasmHost
+func (s *spyHost) ProxyGetHeaderMapPairs(mapType internal.MapType, returnValueData unsafe.Pointer, returnValueSize *int32) internal.Status {
+	args := func() []interface{} {
+		return []interface{}{mapTypeName(mapType)}
+	}
+	return s.hostcall("proxy_get_header_map_pairs", args, func() internal.Status {
+		return s.vm.emulator.ProxyGetHeaderMapPairs(mapType, returnValueData, returnValueSize)
+	})
+}
+
+// impl internal.ProxyWasmHost
+func (s *spyHost) ProxySetHeaderMapPairs(mapType internal.MapType, mapData *byte, mapSize int32) internal.Status {
+	args := func() []interface{} {
+		return []interface{}{mapTypeName(mapType), spyMap(mapData, mapSize)}
+	}
+	return s.hostcall("proxy_set_header_map_pairs", args, func() internal.Status {
+		return s.vm.emulator.ProxySetHeaderMapPairs(mapType, mapData, mapSize)
+	})
+}
+
+// impl internal.ProxyWasmHost
+func (s *spyHost) ProxyGetBufferBytes(bufferType internal.BufferType, start int32, maxSize int32,
+	returnBufferData unsafe.Pointer, returnBufferSize *int32) internal.Status {
+	args := func() []interface{} {
+		return []interface{}{bufferTypeName(bufferType), start, maxSize}
+	}
+	return s.hostcall("proxy_get_buffer_bytes", args, func() internal.Status {
+		return s.vm.emulator.ProxyGetBufferBytes(bufferType, start, maxSize, returnBufferData, returnBufferSize)
+	})
+}
+
+// impl internal.ProxyWasmHost
+func (s *spyHost) ProxySetBufferBytes(bufferType internal.BufferType, start int32, maxSize int32, bufferData *byte, bufferSize int32) internal.Status {
+	args := func() []interface{} {
+		return []interface{}{bufferTypeName(bufferType), start, maxSize, spyBytes(bufferData, bufferSize)}
+	}
+	return s.hostcall("proxy_set_buffer_bytes", args, func() internal.Status {
+		return s.vm.emulator.ProxySetBufferBytes(bufferType, start, maxSize, bufferData, bufferSize)
+	})
+}
+
+// impl internal.ProxyWasmHost
+func (s *spyHost) ProxyHttpCall(upstreamData *byte, upstreamSize int32, headerData *byte, headerSize int32, bodyData *byte,
+	bodySize int32, trailersData *byte, trailersSize int32, timeout uint32, calloutIDPtr *uint32) internal.Status {
+	args := func() []interface{} {
+		return []interface{}{spyString(upstreamData, upstreamSize), spyMap(headerData, headerSize),
+			spyBytes(bodyData, bodySize), spyMap(trailersData, trailersSize), timeout}
+	}
+	return s.hostcall("proxy_http_call", args, func() internal.Status {
+		return s.vm.emulator.ProxyHttpCall(upstreamData, upstreamSize, headerData, headerSize, bodyData,
+			bodySize, trailersData, trailersSize, timeout, calloutIDPtr)
+	})
+}
+
+// impl internal.ProxyWasmHost
+func (s *spyHost) ProxyCallForeignFunction(funcNamePtr *byte, funcNameSize int32, paramPtr *byte, paramSize int32,
+	returnData unsafe.Pointer, returnSize *int32) internal.Status {
+	args := func() []interface{} {
+		return []interface{}{spyString(funcNamePtr, funcNameSize), spyBytes(paramPtr, paramSize)}
+	}
+	return s.hostcall("proxy_call_foreign_function", args, func() internal.Status {
+		return s.vm.emulator.ProxyCallForeignFunction(funcNamePtr, funcNameSize, paramPtr, paramSize, returnData, returnSize)
+	})
+}
+
+// impl internal.ProxyWasmHost
+func (s *spyHost) ProxySetTickPeriodMilliseconds(period uint32) internal.Status {
+	args := func() []interface{} {
+		return []interface{}{period}
+	}
+	return s.hostcall("proxy_set_tick_period_milliseconds", args, func() internal.Status {
+		return s.vm.emulator.ProxySetTickPeriodMilliseconds(period)
+	})
+}
+
+// impl internal.ProxyWasmHost
+func (s *spyHost) ProxySetEffectiveContext(contextID uint32) internal.Status {
+	args := func() []interface{} {
+		return []interface{}{contextID}
+	}
+	return s.hostcall("proxy_set_effective_context", args, func() internal.Status {
+		return s.vm.emulator.ProxySetEffectiveContext(contextID)
+	})
+}
+
+// impl internal.ProxyWasmHost
+func (s *spyHost) ProxyDone() internal.Status {
+	return s.hostcall("proxy_done", nil, s.vm.emulator.ProxyDone)
+}
+
+// impl internal.ProxyWasmHost
+func (s *spyHost) ProxyDefineMetric(metricType internal.MetricType, metricNameData *byte, metricNameSize int32, returnMetricIDPtr *uint32) internal.Status {
+	args := func() []interface{} {
+		return []interface{}{metricTypeName(metricType), spyString(metricNameData, metricNameSize)}
+	}
+	return s.hostcall("proxy_define_metric", args, func() internal.Status {
+		return s.vm.emulator.ProxyDefineMetric(metricType, metricNameData, metricNameSize, returnMetricIDPtr)
+	})
+}
+
+// impl internal.ProxyWasmHost
+func (s *spyHost) ProxyIncrementMetric(metricID uint32, offset int64) internal.Status {
+	args := func() []interface{} {
+		return []interface{}{metricID, offset}
+	}
+	return s.hostcall("proxy_increment_metric", args, func() internal.Status {
+		return s.vm.emulator.ProxyIncrementMetric(metricID, offset)
+	})
+}
+
+// impl internal.ProxyWasmHost
+func (s *spyHost) ProxyRecordMetric(metricID uint32, value uint64) internal.Status {
+	args := func() []interface{} {
+		return []interface{}{metricID, value}
+	}
+	return s.hostcall("proxy_record_metric", args, func() internal.Status {
+		return s.vm.emulator.ProxyRecordMetric(metricID, value)
+	})
+}
+
+// impl internal.ProxyWasmHost
+func (s *spyHost) ProxyGetMetric(metricID uint32, returnMetricValue *uint64) internal.Status {
+	args := func() []interface{} {
+		return []interface{}{metricID}
+	}
+	return s.hostcall("proxy_get_metric", args, func() internal.Status {
+		return s.vm.emulator.ProxyGetMetric(metricID, returnMetricValue)
+	})
+}
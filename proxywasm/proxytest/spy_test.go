@@ -0,0 +1,143 @@
+// Copyright 2020-2024 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxytest
+
+import (
+	"testing"
+
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm"
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/types"
+	"github.com/stretchr/testify/require"
+)
+
+type spyVMContext struct {
+	types.DefaultVMContext
+	types.DefaultPluginContext
+}
+
+type spyHttpContext struct {
+	types.DefaultHttpContext
+}
+
+// NewPluginContext implements the same method on types.VMContext.
+func (v *spyVMContext) NewPluginContext(uint32) types.PluginContext {
+	return v
+}
+
+// OnPluginStart implements the same method on types.PluginContext.
+func (v *spyVMContext) OnPluginStart(int) types.OnPluginStartStatus {
+	proxywasm.LogInfo("started")
+	return types.OnPluginStartStatusOK
+}
+
+// NewHttpContext implements the same method on types.PluginContext.
+func (v *spyVMContext) NewHttpContext(uint32) types.HttpContext {
+	return &spyHttpContext{}
+}
+
+// OnHttpRequestHeaders implements the same method on types.HttpContext. Users are remembered in
+// the shared data the first time they are seen.
+func (h *spyHttpContext) OnHttpRequestHeaders(int, bool) types.Action {
+	user, err := proxywasm.GetHttpRequestHeader("x-user")
+	if err != nil {
+		return types.ActionContinue
+	}
+	if _, _, err := proxywasm.GetSharedData("users/" + user); err != nil {
+		if err := proxywasm.SetSharedData("users/"+user, []byte("seen"), 0); err != nil {
+			panic(err)
+		}
+	}
+	return types.ActionContinue
+}
+
+// notTiming filters out the durations of the callbacks, which the SDK logs at debug level when
+// built with the proxywasm_timing tag.
+func notTiming(c HostCall) bool {
+	return c.Name != "proxy_log" || c.Args[0] != enum("debug")
+}
+
+func TestCalls(t *testing.T) {
+	host, reset := NewHostEmulator(NewEmulatorOption().WithVMContext(&spyVMContext{}))
+	defer reset()
+	host.DumpCallsOnFailure(t)
+
+	require.Equal(t, types.OnPluginStartStatusOK, host.StartPlugin())
+	require.Equal(t, []HostCall{{
+		Name:      "proxy_log",
+		ContextID: PluginContextID,
+		Callback:  "proxy_on_configure",
+		Args:      []interface{}{enum("info"), "started"},
+	}}, host.Calls(notTiming, CallsInCallback("proxy_on_configure")))
+
+	first := host.InitializeHttpContext()
+	host.CallOnRequestHeaders(first, [][2]string{{"x-user", "alice"}}, true)
+	second := host.InitializeHttpContext()
+	host.CallOnRequestHeaders(second, [][2]string{{"x-user", "alice"}}, true)
+	anonymous := host.InitializeHttpContext()
+	host.CallOnRequestHeaders(anonymous, nil, true)
+
+	calls := host.Calls(notTiming, CallsInContext(first))
+	require.Len(t, calls, 3)
+	require.Equal(t, HostCall{
+		Name:      "proxy_get_shared_data",
+		ContextID: first,
+		Callback:  "proxy_on_request_headers",
+		Args:      []interface{}{"users/alice"},
+		Err:       types.ErrorStatusNotFound,
+	}, calls[1])
+	require.Equal(t, `proxy_get_header_map_value(http_request_headers, "x-user") = ok`, calls[0].String())
+	require.Equal(t, `proxy_set_shared_data("users/alice", "seen", 0) = ok`, calls[2].String())
+
+	// Users already seen don't write shared data.
+	require.Len(t, host.Calls(notTiming, CallsInContext(second)), 2)
+	require.Empty(t, host.Calls(CallsInContext(second), CallsNamed("proxy_set_shared_data")))
+	require.Len(t, host.Calls(CallsNamed("proxy_get_shared_data", "proxy_set_shared_data")), 3)
+
+	require.Equal(t, types.ErrorStatusNotFound, host.Calls(notTiming, CallsInContext(anonymous))[0].Err)
+	require.Len(t, host.Calls(notTiming), 7)
+}
+
+func TestCalls_withoutRecording(t *testing.T) {
+	host, reset := NewHostEmulator(NewEmulatorOption().WithVMContext(&spyVMContext{}).WithoutCallRecording())
+	defer reset()
+
+	require.Equal(t, types.OnPluginStartStatusOK, host.StartPlugin())
+	id := host.InitializeHttpContext()
+	host.CallOnRequestHeaders(id, [][2]string{{"x-user", "alice"}}, true)
+	require.Empty(t, host.Calls())
+
+	// Faults still apply: the plugin panics when the write fails.
+	host.InjectFault("proxy_set_shared_data", types.ErrorStatusCasMismatch)
+	require.Panics(t, func() {
+		host.CallOnRequestHeaders(host.InitializeHttpContext(), [][2]string{{"x-user", "bob"}}, true)
+	})
+	require.Empty(t, host.Calls())
+}
+
+func TestFormatCalls(t *testing.T) {
+	require.Equal(t, `2 hostcalls made by the plugin:
+1  context 1  proxy_on_tick  proxy_http_call("cluster", [[:path /]], "", [], 1000) = bad argument
+2  context 0  -              proxy_done() = ok
+`, formatCalls([]HostCall{
+		{
+			Name:      "proxy_http_call",
+			ContextID: 1,
+			Callback:  "proxy_on_tick",
+			Args:      []interface{}{"cluster", [][2]string{{":path", "/"}}, []byte{}, [][2]string{}, uint32(1000)},
+			Err:       types.ErrorStatusBadArgument,
+		},
+		{Name: "proxy_done"},
+	}))
+}
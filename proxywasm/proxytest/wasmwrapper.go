@@ -16,8 +16,11 @@ package proxytest
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"os"
+	"strings"
+	"time"
 	"unsafe"
 
 	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/internal"
@@ -25,6 +28,7 @@ import (
 	"github.com/tetratelabs/wazero"
 	"github.com/tetratelabs/wazero/api"
 	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
 )
 
 type guestABI struct {
@@ -41,6 +45,13 @@ type guestABI struct {
 	proxyOnResponseBody     api.Function
 	proxyOnResponseTrailers api.Function
 	proxyOnLog              api.Function
+	proxyOnDelete           api.Function
+
+	proxyOnNewConnection             api.Function
+	proxyOnDownstreamData            api.Function
+	proxyOnDownstreamConnectionClose api.Function
+	proxyOnUpstreamData              api.Function
+	proxyOnUpstreamConnectionClose   api.Function
 }
 
 // WasmVMContext is a VMContext that delegates execution to a compiled wasm binary.
@@ -54,6 +65,13 @@ type vmContext struct {
 	runtime wazero.Runtime
 	abi     guestABI
 	ctx     context.Context
+
+	// clock is the virtual clock of the host running the VM, which backs the clocks of WASI.
+	clock *clock
+
+	// tcp is set by the host while it creates a TCP context. Unlike the SDK, the wrapper can't
+	// tell which kind of stream context the plugin in the guest creates.
+	tcp bool
 }
 
 // NewWasmVMContext returns a types.VMContext that delegates plugin invocations to the provided compiled wasm binary.
@@ -79,8 +97,6 @@ type vmContext struct {
 //		require.NoError(t, err)
 //		vm = v
 //	}
-//
-// Note: Currently only HTTP plugins are supported.
 func NewWasmVMContext(wasm []byte) (WasmVMContext, error) {
 	ctx := context.Background()
 	r := wazero.NewRuntime(ctx)
@@ -100,16 +116,23 @@ func NewWasmVMContext(wasm []byte) (WasmVMContext, error) {
 		return nil, err
 	}
 
+	if missing := missingHostFunctions(r, compiled); len(missing) > 0 {
+		return nil, fmt.Errorf("host functions imported by the wasm binary are not provided: %s", strings.Join(missing, ", "))
+	}
+
+	v := &vmContext{runtime: r, ctx: ctx}
 	wazeroconfig := wazero.NewModuleConfig().
 		WithStartFunctions("_initialize", "_start", "main").
 		WithStdout(os.Stderr).
-		WithStderr(os.Stderr)
+		WithStderr(os.Stderr).
+		WithWalltime(v.walltime, sys.ClockResolution(time.Microsecond)).
+		WithNanotime(v.nanotime, sys.ClockResolution(1))
 	mod, err := r.InstantiateModule(ctx, compiled, wazeroconfig)
 	if err != nil {
 		return nil, err
 	}
 
-	abi := guestABI{
+	v.abi = guestABI{
 		proxyOnVMStart:          mod.ExportedFunction("proxy_on_vm_start"),
 		proxyOnContextCreate:    mod.ExportedFunction("proxy_on_context_create"),
 		proxyOnConfigure:        mod.ExportedFunction("proxy_on_configure"),
@@ -123,13 +146,33 @@ func NewWasmVMContext(wasm []byte) (WasmVMContext, error) {
 		proxyOnResponseBody:     mod.ExportedFunction("proxy_on_response_body"),
 		proxyOnResponseTrailers: mod.ExportedFunction("proxy_on_response_trailers"),
 		proxyOnLog:              mod.ExportedFunction("proxy_on_log"),
+		proxyOnDelete:           mod.ExportedFunction("proxy_on_delete"),
+
+		proxyOnNewConnection:             mod.ExportedFunction("proxy_on_new_connection"),
+		proxyOnDownstreamData:            mod.ExportedFunction("proxy_on_downstream_data"),
+		proxyOnDownstreamConnectionClose: mod.ExportedFunction("proxy_on_downstream_connection_close"),
+		proxyOnUpstreamData:              mod.ExportedFunction("proxy_on_upstream_data"),
+		proxyOnUpstreamConnectionClose:   mod.ExportedFunction("proxy_on_upstream_connection_close"),
 	}
 
-	return &vmContext{
-		runtime: r,
-		abi:     abi,
-		ctx:     ctx,
-	}, nil
+	return v, nil
+}
+
+// walltime implements sys.Walltime with the virtual clock of the host.
+func (v *vmContext) walltime() (sec int64, nsec int32) {
+	now := time.Now()
+	if v.clock != nil {
+		now = v.clock.start.Add(v.clock.now)
+	}
+	return now.Unix(), int32(now.Nanosecond())
+}
+
+// nanotime implements sys.Nanotime with the virtual clock of the host.
+func (v *vmContext) nanotime() int64 {
+	if v.clock != nil {
+		return int64(v.clock.now)
+	}
+	return time.Now().UnixNano()
 }
 
 // OnVMStart implements the same method on types.VMContext.
@@ -146,11 +189,19 @@ func (v *vmContext) NewPluginContext(contextID uint32) types.PluginContext {
 	handleErr(err)
 	return &pluginContext{
 		id:  uint64(contextID),
+		vm:  v,
 		abi: v.abi,
 		ctx: withPluginContextID(v.ctx, contextID),
 	}
 }
 
+// deleteContext deletes the context in the guest. The SDK has no callback on deletion which
+// could forward it, so the host calls this after internal.ProxyOnDelete.
+func (v *vmContext) deleteContext(contextID uint32) {
+	_, err := v.abi.proxyOnDelete.Call(v.ctx, uint64(contextID))
+	handleErr(err)
+}
+
 // Close implements the same method on io.Closer.
 func (v *vmContext) Close() error {
 	return v.runtime.Close(v.ctx)
@@ -159,6 +210,7 @@ func (v *vmContext) Close() error {
 // pluginContext implements types.PluginContext.
 type pluginContext struct {
 	id  uint64
+	vm  *vmContext
 	abi guestABI
 	ctx context.Context
 }
@@ -190,12 +242,24 @@ func (p *pluginContext) OnTick() {
 }
 
 // NewTcpContext implements the same method on types.PluginContext.
-func (p *pluginContext) NewTcpContext(uint32) types.TcpContext {
-	return nil
+func (p *pluginContext) NewTcpContext(contextID uint32) types.TcpContext {
+	if !p.vm.tcp {
+		return nil
+	}
+	_, err := p.abi.proxyOnContextCreate.Call(p.ctx, uint64(contextID), p.id)
+	handleErr(err)
+	return &tcpContext{
+		id:  uint64(contextID),
+		abi: p.abi,
+		ctx: p.ctx,
+	}
 }
 
 // NewHttpContext implements the same method on types.PluginContext.
 func (p *pluginContext) NewHttpContext(contextID uint32) types.HttpContext {
+	if p.vm.tcp {
+		return nil
+	}
 	_, err := p.abi.proxyOnContextCreate.Call(p.ctx, uint64(contextID), p.id)
 	handleErr(err)
 	return &httpContext{
@@ -260,6 +324,68 @@ func (h *httpContext) OnHttpStreamDone() {
 	handleErr(err)
 }
 
+// tcpContext implements types.TcpContext.
+type tcpContext struct {
+	id  uint64
+	abi guestABI
+	ctx context.Context
+}
+
+// OnNewConnection implements the same method on types.TcpContext.
+func (t *tcpContext) OnNewConnection() types.Action {
+	res, err := t.abi.proxyOnNewConnection.Call(t.ctx, t.id)
+	handleErr(err)
+	return types.Action(res[0])
+}
+
+// OnDownstreamData implements the same method on types.TcpContext.
+func (t *tcpContext) OnDownstreamData(dataSize int, endOfStream bool) types.Action {
+	res, err := t.abi.proxyOnDownstreamData.Call(t.ctx, t.id, uint64(dataSize), wasmBool(endOfStream))
+	handleErr(err)
+	return types.Action(res[0])
+}
+
+// OnDownstreamClose implements the same method on types.TcpContext.
+func (t *tcpContext) OnDownstreamClose(peerType types.PeerType) {
+	_, err := t.abi.proxyOnDownstreamConnectionClose.Call(t.ctx, t.id, uint64(peerType))
+	handleErr(err)
+}
+
+// OnUpstreamData implements the same method on types.TcpContext.
+func (t *tcpContext) OnUpstreamData(dataSize int, endOfStream bool) types.Action {
+	res, err := t.abi.proxyOnUpstreamData.Call(t.ctx, t.id, uint64(dataSize), wasmBool(endOfStream))
+	handleErr(err)
+	return types.Action(res[0])
+}
+
+// OnUpstreamClose implements the same method on types.TcpContext.
+func (t *tcpContext) OnUpstreamClose(peerType types.PeerType) {
+	_, err := t.abi.proxyOnUpstreamConnectionClose.Call(t.ctx, t.id, uint64(peerType))
+	handleErr(err)
+}
+
+// OnStreamDone implements the same method on types.TcpContext.
+func (t *tcpContext) OnStreamDone() {
+	_, err := t.abi.proxyOnLog.Call(t.ctx, t.id)
+	handleErr(err)
+}
+
+// missingHostFunctions returns the functions imported by the compiled module which are not
+// exported by the modules instantiated in the runtime, e.g. "env.proxy_log".
+func missingHostFunctions(r wazero.Runtime, compiled wazero.CompiledModule) []string {
+	var missing []string
+	for _, f := range compiled.ImportedFunctions() {
+		moduleName, name, _ := f.Import()
+		if m := r.Module(moduleName); m != nil {
+			if _, ok := m.ExportedFunctionDefinitions()[name]; ok {
+				continue
+			}
+		}
+		missing = append(missing, moduleName+"."+name)
+	}
+	return missing
+}
+
 func handleErr(err error) {
 	if err != nil {
 		panic(err)
@@ -604,6 +730,9 @@ func exportHostABI(ctx context.Context, r wazero.Runtime) error {
 			var calloutID uint32
 			ret := uint32(internal.ProxyHttpCall(upstreamPtr, int32(upstreamSize), headerPtr, int32(headerSize), bodyPtr, int32(bodySize), trailersPtr, int32(trailersSize), timeout, &calloutID))
 			handleMemoryStatus(mod.Memory().WriteUint32Le(calloutIDPtr, calloutID))
+			if internal.Status(ret) != internal.StatusOK {
+				return ret
+			}
 
 			// Finishing proxy_http_call executes a callback, not a plugin lifecycle method, unlike every other host function which would then end up in wasm.
 			// We can work around this by registering a callback here to go back to the wasm.
@@ -0,0 +1,32 @@
+// Copyright 2020-2024 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxytest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewWasmVMContext_missingHostFunction(t *testing.T) {
+	wasm := []byte{
+		0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00, // magic and version
+		0x01, 0x04, 0x01, 0x60, 0x00, 0x00, // type section: func()
+		0x02, 0x15, 0x01, // import section: env.proxy_unknown of type func()
+		0x03, 'e', 'n', 'v', 0x0d, 'p', 'r', 'o', 'x', 'y', '_', 'u', 'n', 'k', 'n', 'o', 'w', 'n', 0x00, 0x00,
+	}
+	_, err := NewWasmVMContext(wasm)
+	require.EqualError(t, err, "host functions imported by the wasm binary are not provided: env.proxy_unknown")
+}
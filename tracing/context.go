@@ -0,0 +1,170 @@
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// This file hosts the span context and its encodings as described in:
+// https://www.w3.org/TR/trace-context/
+// https://github.com/openzipkin/b3-propagation
+
+// ErrInvalidHeader means a propagation header could not be parsed.
+var ErrInvalidHeader = errors.New("tracing: invalid propagation header")
+
+// TraceID is the 16-byte identifier of a trace.
+type TraceID [16]byte
+
+// String returns the lower-case hex encoding of the ID.
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+
+// IsValid reports whether the ID is not all zeros.
+func (id TraceID) IsValid() bool { return id != TraceID{} }
+
+// SpanID is the 8-byte identifier of a span.
+type SpanID [8]byte
+
+// String returns the lower-case hex encoding of the ID.
+func (id SpanID) String() string { return hex.EncodeToString(id[:]) }
+
+// IsValid reports whether the ID is not all zeros.
+func (id SpanID) IsValid() bool { return id != SpanID{} }
+
+// SpanContext is the part of a span propagated across process boundaries.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+	// TraceState is the opaque vendor-specific "tracestate" header, if any.
+	TraceState string
+}
+
+// IsValid reports whether both the trace and span IDs are valid.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// ParseTraceparent parses the value of a W3C "traceparent" header.
+func ParseTraceparent(v string) (SpanContext, error) {
+	// version "-" trace-id "-" parent-id "-" trace-flags
+	const size = 2 + 1 + 32 + 1 + 16 + 1 + 2
+	if len(v) < size || v[2] != '-' || v[35] != '-' || v[52] != '-' {
+		return SpanContext{}, ErrInvalidHeader
+	}
+	// Upper-case hex is not allowed.
+	if strings.ToLower(v[:size]) != v[:size] {
+		return SpanContext{}, ErrInvalidHeader
+	}
+	version, ok := decodeHex(v[:2], 1)
+	if !ok || version[0] == 0xff || (version[0] == 0 && len(v) != size) {
+		return SpanContext{}, ErrInvalidHeader
+	}
+	// Future versions may append fields, separated by a dash.
+	if len(v) > size && v[size] != '-' {
+		return SpanContext{}, ErrInvalidHeader
+	}
+	var sc SpanContext
+	if !decodeHexInto(sc.TraceID[:], v[3:35]) || !decodeHexInto(sc.SpanID[:], v[36:52]) || !sc.IsValid() {
+		return SpanContext{}, ErrInvalidHeader
+	}
+	flags, ok := decodeHex(v[53:55], 1)
+	if !ok {
+		return SpanContext{}, ErrInvalidHeader
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, nil
+}
+
+// Traceparent returns the W3C "traceparent" header value of the span context.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// ParseB3 parses the value of a single "b3" header. Values carrying only a sampling
+// decision are reported as invalid since they do not identify a parent span.
+func ParseB3(v string) (SpanContext, error) {
+	parts := strings.Split(v, "-")
+	if len(parts) < 2 || len(parts) > 4 {
+		return SpanContext{}, ErrInvalidHeader
+	}
+	sampled := ""
+	if len(parts) > 2 {
+		sampled = parts[2]
+	}
+	return parseB3(parts[0], parts[1], sampled, "")
+}
+
+// B3 returns the single "b3" header value of the span context.
+func (sc SpanContext) B3() string {
+	sampled := "0"
+	if sc.Sampled {
+		sampled = "1"
+	}
+	return sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + sampled
+}
+
+// parseB3 parses the fields shared by the single and multiple header B3 encodings.
+// Absent sampling decisions are deferred to this tracer, which samples.
+func parseB3(traceID, spanID, sampled, flags string) (SpanContext, error) {
+	var sc SpanContext
+	switch len(traceID) {
+	case 32:
+		if !decodeHexInto(sc.TraceID[:], traceID) {
+			return SpanContext{}, ErrInvalidHeader
+		}
+	case 16:
+		// 64-bit trace IDs are left-padded with zeros.
+		if !decodeHexInto(sc.TraceID[8:], traceID) {
+			return SpanContext{}, ErrInvalidHeader
+		}
+	default:
+		return SpanContext{}, ErrInvalidHeader
+	}
+	if len(spanID) != 16 || !decodeHexInto(sc.SpanID[:], spanID) || !sc.IsValid() {
+		return SpanContext{}, ErrInvalidHeader
+	}
+	switch strings.ToLower(sampled) {
+	case "", "1", "d", "true":
+		sc.Sampled = true
+	case "0", "false":
+		sc.Sampled = flags == "1"
+	default:
+		return SpanContext{}, ErrInvalidHeader
+	}
+	return sc, nil
+}
+
+func decodeHex(s string, n int) ([]byte, bool) {
+	b := make([]byte, n)
+	return b, decodeHexInto(b, s)
+}
+
+func decodeHexInto(dst []byte, s string) bool {
+	if len(s) != 2*len(dst) {
+		return false
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err == nil
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
@@ -0,0 +1,126 @@
+package tracing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	testSpanID  = "00f067aa0ba902b7"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	sc, err := ParseTraceparent("00-" + testTraceID + "-" + testSpanID + "-01")
+	require.NoError(t, err)
+	require.Equal(t, testTraceID, sc.TraceID.String())
+	require.Equal(t, testSpanID, sc.SpanID.String())
+	require.True(t, sc.Sampled)
+	require.Equal(t, "00-"+testTraceID+"-"+testSpanID+"-01", sc.Traceparent())
+
+	sc, err = ParseTraceparent("00-" + testTraceID + "-" + testSpanID + "-00")
+	require.NoError(t, err)
+	require.False(t, sc.Sampled)
+
+	// Future versions may carry more fields.
+	_, err = ParseTraceparent("01-" + testTraceID + "-" + testSpanID + "-01-extra")
+	require.NoError(t, err)
+
+	for _, v := range []string{
+		"",
+		"00-" + testTraceID + "-" + testSpanID,
+		"00-" + testTraceID + "-" + testSpanID + "-01-extra",
+		"ff-" + testTraceID + "-" + testSpanID + "-01",
+		"00-00000000000000000000000000000000-" + testSpanID + "-01",
+		"00-" + testTraceID + "-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-" + testSpanID + "-01",
+		"00-" + testTraceID + "-" + testSpanID + "-zz",
+		"00_" + testTraceID + "-" + testSpanID + "-01",
+	} {
+		_, err := ParseTraceparent(v)
+		require.ErrorIs(t, err, ErrInvalidHeader, v)
+	}
+}
+
+func TestParseB3(t *testing.T) {
+	for _, tc := range []struct {
+		value    string
+		traceID  string
+		sampled  bool
+		hasError bool
+	}{
+		{value: testTraceID + "-" + testSpanID + "-1", traceID: testTraceID, sampled: true},
+		{value: testTraceID + "-" + testSpanID + "-0", traceID: testTraceID},
+		{value: testTraceID + "-" + testSpanID + "-d-" + testSpanID, traceID: testTraceID, sampled: true},
+		{value: testTraceID + "-" + testSpanID, traceID: testTraceID, sampled: true},
+		{value: "a3ce929d0e0e4736-" + testSpanID + "-1", traceID: "0000000000000000a3ce929d0e0e4736", sampled: true},
+		{value: "1", hasError: true},
+		{value: testTraceID + "-" + testSpanID + "-x", hasError: true},
+		{value: "abc-" + testSpanID, hasError: true},
+	} {
+		sc, err := ParseB3(tc.value)
+		if tc.hasError {
+			require.ErrorIs(t, err, ErrInvalidHeader, tc.value)
+			continue
+		}
+		require.NoError(t, err, tc.value)
+		require.Equal(t, tc.traceID, sc.TraceID.String())
+		require.Equal(t, testSpanID, sc.SpanID.String())
+		require.Equal(t, tc.sampled, sc.Sampled, tc.value)
+	}
+}
+
+func TestExtract(t *testing.T) {
+	traceparent := [2]string{"traceparent", "00-" + testTraceID + "-" + testSpanID + "-01"}
+	b3 := [2]string{"b3", "a3ce929d0e0e4736-" + testSpanID + "-1"}
+	multi := [][2]string{{"x-b3-traceid", "0e0e4736a3ce929d"}, {"x-b3-spanid", testSpanID}, {"x-b3-sampled", "0"}}
+
+	sc, ok := Extract([][2]string{b3, traceparent, {"tracestate", "vendor=value"}})
+	require.True(t, ok)
+	require.Equal(t, testTraceID, sc.TraceID.String())
+	require.Equal(t, "vendor=value", sc.TraceState)
+
+	sc, ok = Extract(append([][2]string{b3}, multi...))
+	require.True(t, ok)
+	require.Equal(t, "0000000000000000a3ce929d0e0e4736", sc.TraceID.String())
+
+	sc, ok = Extract(multi)
+	require.True(t, ok)
+	require.Equal(t, "00000000000000000e0e4736a3ce929d", sc.TraceID.String())
+	require.False(t, sc.Sampled)
+
+	sc, ok = Extract(append(multi, [2]string{"x-b3-flags", "1"}))
+	require.True(t, ok)
+	require.True(t, sc.Sampled)
+
+	// Invalid W3C headers fall back to B3.
+	_, ok = Extract([][2]string{{"traceparent", "invalid"}, b3})
+	require.True(t, ok)
+
+	_, ok = Extract([][2]string{{"traceparent", "invalid"}})
+	require.False(t, ok)
+}
+
+func TestInject(t *testing.T) {
+	sc, err := ParseTraceparent("00-" + testTraceID + "-" + testSpanID + "-01")
+	require.NoError(t, err)
+	sc.TraceState = "vendor=value"
+
+	require.Equal(t, [][2]string{
+		{"traceparent", "00-" + testTraceID + "-" + testSpanID + "-01"},
+		{"tracestate", "vendor=value"},
+		{"b3", testTraceID + "-" + testSpanID + "-1"},
+		{"x-b3-traceid", testTraceID},
+		{"x-b3-spanid", testSpanID},
+		{"x-b3-sampled", "1"},
+	}, Inject(sc, FormatW3C, FormatB3Single, FormatB3Multi))
+
+	for _, f := range []Format{FormatW3C, FormatB3Single, FormatB3Multi} {
+		actual, ok := Extract(Inject(sc, f))
+		require.True(t, ok)
+		require.Equal(t, sc.TraceID, actual.TraceID)
+		require.Equal(t, sc.SpanID, actual.SpanID)
+		require.True(t, actual.Sampled)
+	}
+}
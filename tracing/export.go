@@ -0,0 +1,231 @@
+package tracing
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/internal/export"
+)
+
+// ExportFormat is the encoding of exported spans.
+type ExportFormat int
+
+const (
+	// ExportFormatOTLPJSON is the OTLP/HTTP JSON encoding, as described in:
+	// https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+	ExportFormatOTLPJSON ExportFormat = iota
+	// ExportFormatZipkinJSON is the Zipkin v2 JSON encoding, as described in:
+	// https://zipkin.io/zipkin-api/#/default/post_spans
+	ExportFormatZipkinJSON
+)
+
+const (
+	defaultMaxBatchSize  = 512
+	defaultExportTimeout = 5 * time.Second
+)
+
+// Exporter sends finished spans to a collector with proxywasm.DispatchHttpCall.
+type Exporter struct {
+	// Cluster is the name of the cluster of the collector.
+	Cluster string
+	// Authority is the ":authority" header of export requests.
+	Authority string
+	// Path is the ":path" header of export requests. Defaults to "/v1/traces" for
+	// OTLP/JSON and "/api/v2/spans" for Zipkin JSON.
+	Path string
+	// Format is the encoding of the spans.
+	Format ExportFormat
+	// MaxBatchSize is the maximum number of spans per export request. Defaults to 512.
+	MaxBatchSize int
+	// Timeout of export requests. Defaults to 5 seconds.
+	Timeout time.Duration
+}
+
+func (e *Exporter) request(serviceName string, spans []*Span) (*export.Request, error) {
+	var body []byte
+	var err error
+	path := e.Path
+	switch e.Format {
+	case ExportFormatZipkinJSON:
+		body, err = encodeZipkin(serviceName, spans)
+		if path == "" {
+			path = "/api/v2/spans"
+		}
+	default:
+		body, err = encodeOTLP(serviceName, spans)
+		if path == "" {
+			path = "/v1/traces"
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	headers := [][2]string{
+		{":method", "POST"},
+		{":path", path},
+		{":authority", e.Authority},
+		{"content-type", "application/json"},
+	}
+	timeout := e.Timeout
+	if timeout == 0 {
+		timeout = defaultExportTimeout
+	}
+	return &export.Request{Cluster: e.Cluster, Headers: headers, Body: body, Timeout: timeout}, nil
+}
+
+func (e *Exporter) maxBatchSize() int {
+	if e.MaxBatchSize == 0 {
+		return defaultMaxBatchSize
+	}
+	return e.MaxBatchSize
+}
+
+type otlpKeyValue struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue string `json:"stringValue"`
+	} `json:"value"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	TraceState        string         `json:"traceState,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            struct {
+		Code    int    `json:"code,omitempty"`
+		Message string `json:"message,omitempty"`
+	} `json:"status"`
+}
+
+// OTLP span kinds and status codes.
+const (
+	otlpSpanKindInternal = 1
+	otlpSpanKindServer   = 2
+	otlpSpanKindClient   = 3
+
+	otlpStatusCodeError = 2
+)
+
+func encodeOTLP(serviceName string, spans []*Span) ([]byte, error) {
+	out := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		o := otlpSpan{
+			TraceID:           s.Context.TraceID.String(),
+			SpanID:            s.Context.SpanID.String(),
+			TraceState:        s.Context.TraceState,
+			Name:              s.Name,
+			StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+			Attributes:        otlpAttributes(s.Attributes),
+		}
+		if s.ParentSpanID.IsValid() {
+			o.ParentSpanID = s.ParentSpanID.String()
+		}
+		switch s.Kind {
+		case SpanKindServer:
+			o.Kind = otlpSpanKindServer
+		case SpanKindClient:
+			o.Kind = otlpSpanKindClient
+		default:
+			o.Kind = otlpSpanKindInternal
+		}
+		if s.Error != "" {
+			o.Status.Code = otlpStatusCodeError
+			o.Status.Message = s.Error
+		}
+		out = append(out, o)
+	}
+
+	type scope struct {
+		Name string `json:"name"`
+	}
+	type scopeSpans struct {
+		Scope scope      `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	type resource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	type resourceSpans struct {
+		Resource   resource     `json:"resource"`
+		ScopeSpans []scopeSpans `json:"scopeSpans"`
+	}
+	return json.Marshal(struct {
+		ResourceSpans []resourceSpans `json:"resourceSpans"`
+	}{
+		ResourceSpans: []resourceSpans{{
+			Resource:   resource{Attributes: otlpAttributes(map[string]string{"service.name": serviceName})},
+			ScopeSpans: []scopeSpans{{Scope: scope{Name: "proxy-wasm-go-sdk"}, Spans: out}},
+		}},
+	})
+}
+
+func otlpAttributes(attrs map[string]string) []otlpKeyValue {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := make([]otlpKeyValue, 0, len(keys))
+	for _, k := range keys {
+		kv := otlpKeyValue{Key: k}
+		kv.Value.StringValue = attrs[k]
+		out = append(out, kv)
+	}
+	return out
+}
+
+type zipkinSpan struct {
+	TraceID       string            `json:"traceId"`
+	ID            string            `json:"id"`
+	ParentID      string            `json:"parentId,omitempty"`
+	Name          string            `json:"name"`
+	Kind          string            `json:"kind,omitempty"`
+	Timestamp     int64             `json:"timestamp"`
+	Duration      int64             `json:"duration"`
+	LocalEndpoint map[string]string `json:"localEndpoint"`
+	Tags          map[string]string `json:"tags,omitempty"`
+}
+
+func encodeZipkin(serviceName string, spans []*Span) ([]byte, error) {
+	out := make([]zipkinSpan, 0, len(spans))
+	for _, s := range spans {
+		z := zipkinSpan{
+			TraceID:       s.Context.TraceID.String(),
+			ID:            s.Context.SpanID.String(),
+			Name:          s.Name,
+			Timestamp:     s.Start.UnixMicro(),
+			Duration:      s.End.Sub(s.Start).Microseconds(),
+			LocalEndpoint: map[string]string{"serviceName": serviceName},
+		}
+		if s.ParentSpanID.IsValid() {
+			z.ParentID = s.ParentSpanID.String()
+		}
+		switch s.Kind {
+		case SpanKindServer:
+			z.Kind = "SERVER"
+		case SpanKindClient:
+			z.Kind = "CLIENT"
+		}
+		if len(s.Attributes) > 0 || s.Error != "" {
+			z.Tags = make(map[string]string, len(s.Attributes)+1)
+			for k, v := range s.Attributes {
+				z.Tags[k] = v
+			}
+			if s.Error != "" {
+				z.Tags["error"] = s.Error
+			}
+		}
+		out = append(out, z)
+	}
+	return json.Marshal(out)
+}
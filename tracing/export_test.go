@@ -0,0 +1,94 @@
+package tracing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testSpans(t *testing.T) []*Span {
+	parent, err := ParseTraceparent("00-" + testTraceID + "-" + testSpanID + "-01")
+	require.NoError(t, err)
+	start := time.Unix(1700000000, 0)
+	return []*Span{
+		{
+			Name:         "HTTP GET",
+			Kind:         SpanKindClient,
+			Context:      SpanContext{TraceID: parent.TraceID, SpanID: SpanID{1, 2, 3, 4, 5, 6, 7, 8}, Sampled: true},
+			ParentSpanID: parent.SpanID,
+			Start:        start,
+			End:          start.Add(1500 * time.Microsecond),
+			Attributes:   map[string]string{"upstream_cluster": "checker", "http.status_code": "503"},
+			Error:        "HTTP 503",
+		},
+		{
+			Name:    "root",
+			Context: SpanContext{TraceID: parent.TraceID, SpanID: SpanID{8, 7, 6, 5, 4, 3, 2, 1}, Sampled: true},
+			Start:   start,
+			End:     start.Add(time.Second),
+		},
+	}
+}
+
+func TestEncodeOTLP(t *testing.T) {
+	actual, err := encodeOTLP("my-plugin", testSpans(t))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"resourceSpans": [{
+		"resource": {"attributes": [{"key": "service.name", "value": {"stringValue": "my-plugin"}}]},
+		"scopeSpans": [{
+			"scope": {"name": "proxy-wasm-go-sdk"},
+			"spans": [
+				{
+					"traceId": "`+testTraceID+`",
+					"spanId": "0102030405060708",
+					"parentSpanId": "`+testSpanID+`",
+					"name": "HTTP GET",
+					"kind": 3,
+					"startTimeUnixNano": "1700000000000000000",
+					"endTimeUnixNano": "1700000000001500000",
+					"attributes": [
+						{"key": "http.status_code", "value": {"stringValue": "503"}},
+						{"key": "upstream_cluster", "value": {"stringValue": "checker"}}
+					],
+					"status": {"code": 2, "message": "HTTP 503"}
+				},
+				{
+					"traceId": "`+testTraceID+`",
+					"spanId": "0807060504030201",
+					"name": "root",
+					"kind": 1,
+					"startTimeUnixNano": "1700000000000000000",
+					"endTimeUnixNano": "1700000001000000000",
+					"status": {}
+				}
+			]
+		}]
+	}]}`, string(actual))
+}
+
+func TestEncodeZipkin(t *testing.T) {
+	actual, err := encodeZipkin("my-plugin", testSpans(t))
+	require.NoError(t, err)
+	require.JSONEq(t, `[
+		{
+			"traceId": "`+testTraceID+`",
+			"id": "0102030405060708",
+			"parentId": "`+testSpanID+`",
+			"name": "HTTP GET",
+			"kind": "CLIENT",
+			"timestamp": 1700000000000000,
+			"duration": 1500,
+			"localEndpoint": {"serviceName": "my-plugin"},
+			"tags": {"upstream_cluster": "checker", "http.status_code": "503", "error": "HTTP 503"}
+		},
+		{
+			"traceId": "`+testTraceID+`",
+			"id": "0807060504030201",
+			"name": "root",
+			"timestamp": 1700000000000000,
+			"duration": 1000000,
+			"localEndpoint": {"serviceName": "my-plugin"}
+		}
+	]`, string(actual))
+}
@@ -0,0 +1,108 @@
+package tracing
+
+import (
+	"strings"
+
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm"
+)
+
+// Format is a propagation format.
+type Format int
+
+const (
+	// FormatW3C is the "traceparent" and "tracestate" headers.
+	FormatW3C Format = iota
+	// FormatB3Single is the single "b3" header.
+	FormatB3Single
+	// FormatB3Multi is the "x-b3-traceid", "x-b3-spanid" and "x-b3-sampled" headers.
+	FormatB3Multi
+)
+
+// Extract returns the span context found in the headers. W3C headers take
+// precedence over the single B3 header, which takes precedence over multiple B3 headers.
+func Extract(headers [][2]string) (SpanContext, bool) {
+	if v := headerValue(headers, "traceparent"); v != "" {
+		if sc, err := ParseTraceparent(v); err == nil {
+			sc.TraceState = headerValue(headers, "tracestate")
+			return sc, true
+		}
+	}
+	if v := headerValue(headers, "b3"); v != "" {
+		if sc, err := ParseB3(v); err == nil {
+			return sc, true
+		}
+	}
+	if traceID := headerValue(headers, "x-b3-traceid"); traceID != "" {
+		sc, err := parseB3(traceID, headerValue(headers, "x-b3-spanid"),
+			headerValue(headers, "x-b3-sampled"), headerValue(headers, "x-b3-flags"))
+		if err == nil {
+			return sc, true
+		}
+	}
+	return SpanContext{}, false
+}
+
+// Inject returns the headers propagating the span context in the given formats.
+func Inject(sc SpanContext, formats ...Format) [][2]string {
+	var headers [][2]string
+	for _, f := range formats {
+		switch f {
+		case FormatW3C:
+			headers = append(headers, [2]string{"traceparent", sc.Traceparent()})
+			if sc.TraceState != "" {
+				headers = append(headers, [2]string{"tracestate", sc.TraceState})
+			}
+		case FormatB3Single:
+			headers = append(headers, [2]string{"b3", sc.B3()})
+		case FormatB3Multi:
+			sampled := "0"
+			if sc.Sampled {
+				sampled = "1"
+			}
+			headers = append(headers,
+				[2]string{"x-b3-traceid", sc.TraceID.String()},
+				[2]string{"x-b3-spanid", sc.SpanID.String()},
+				[2]string{"x-b3-sampled", sampled},
+			)
+		}
+	}
+	return headers
+}
+
+// ExtractFromRequest returns the span context of the current HTTP request.
+// Only available during types.HttpContext.OnHttpRequestHeaders.
+func ExtractFromRequest() (SpanContext, bool) {
+	headers, err := proxywasm.GetHttpRequestHeaders()
+	if err != nil {
+		return SpanContext{}, false
+	}
+	return Extract(headers)
+}
+
+// InjectIntoRequest sets the headers propagating the span context on the current
+// HTTP request, so that the upstream spans become children of it. Stale B3 parent
+// span IDs are removed. Only available during types.HttpContext.OnHttpRequestHeaders.
+func InjectIntoRequest(sc SpanContext, formats ...Format) error {
+	for _, h := range Inject(sc, formats...) {
+		if err := proxywasm.ReplaceHttpRequestHeader(h[0], h[1]); err != nil {
+			return err
+		}
+	}
+	for _, f := range formats {
+		if f == FormatB3Multi {
+			if err := proxywasm.RemoveHttpRequestHeader("x-b3-parentspanid"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func headerValue(headers [][2]string, name string) string {
+	for _, h := range headers {
+		if strings.EqualFold(h[0], name) {
+			return h[1]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,190 @@
+// Package tracing makes the work done by plugins visible in distributed traces.
+//
+// It extracts and injects W3C Trace Context and B3 propagation headers, creates spans
+// around plugin work such as proxywasm.DispatchHttpCall, and exports the finished spans
+// in batches as OTLP/JSON or Zipkin JSON with proxywasm.DispatchHttpCall from
+// types.PluginContext.OnTick.
+//
+// A Tracer is usually created per plugin context, with the tick period set with
+// proxywasm.SetTickPeriodMilliSeconds and Tracer.OnTick called from
+// types.PluginContext.OnTick.
+package tracing
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/internal/export"
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm"
+)
+
+const defaultMaxQueueSize = 2048
+
+// SpanKind is the role of a span in a trace.
+type SpanKind int
+
+const (
+	// SpanKindInternal is work done within the plugin.
+	SpanKindInternal SpanKind = iota
+	// SpanKindServer is the handling of a downstream request.
+	SpanKindServer
+	// SpanKindClient is a request sent by the plugin, such as a callout.
+	SpanKindClient
+)
+
+// Tracer creates spans and buffers the finished ones until they are exported.
+type Tracer struct {
+	// ServiceName identifies the plugin in exported spans.
+	ServiceName string
+	// Propagation lists the formats injected into callouts and requests.
+	// Defaults to FormatW3C.
+	Propagation []Format
+	// Exporter, if set, exports finished spans on Tracer.OnTick.
+	Exporter *Exporter
+	// MaxQueueSize is the maximum number of buffered spans. Spans finished when the
+	// queue is full are dropped. Defaults to 2048.
+	MaxQueueSize int
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+
+	queue export.Queue[*Span]
+}
+
+// Span is a timed operation of a trace.
+type Span struct {
+	Name         string
+	Kind         SpanKind
+	Context      SpanContext
+	ParentSpanID SpanID
+	Start        time.Time
+	End          time.Time
+	Attributes   map[string]string
+	// Error is the error message of failed operations. Empty means success.
+	Error string
+
+	tracer *Tracer
+	ended  bool
+}
+
+// StartSpan starts a span. If the parent is valid, the span is its child and inherits
+// its sampling decision and trace state. Otherwise, the span starts a new sampled trace.
+func (t *Tracer) StartSpan(name string, kind SpanKind, parent SpanContext) *Span {
+	s := &Span{Name: name, Kind: kind, Start: t.now(), tracer: t}
+	if parent.IsValid() {
+		s.Context = SpanContext{TraceID: parent.TraceID, Sampled: parent.Sampled, TraceState: parent.TraceState}
+		s.ParentSpanID = parent.SpanID
+	} else {
+		s.Context = SpanContext{TraceID: newTraceID(), Sampled: true}
+	}
+	s.Context.SpanID = newSpanID()
+	return s
+}
+
+// SetAttribute sets an attribute of the span.
+func (s *Span) SetAttribute(key, value string) {
+	if s.Attributes == nil {
+		s.Attributes = map[string]string{}
+	}
+	s.Attributes[key] = value
+}
+
+// Finish records the end time of the span and queues it for export if it is sampled.
+// Calling Finish more than once has no effect.
+func (s *Span) Finish() {
+	if s.ended {
+		return
+	}
+	s.ended = true
+	t := s.tracer
+	s.End = t.now()
+	if !s.Context.Sampled {
+		return
+	}
+	t.queue.Add(s, t.maxQueueSize())
+}
+
+// DispatchHttpCall calls proxywasm.DispatchHttpCall within a client span child of the
+// parent. The span context is injected into the callout headers, and the span is
+// finished when the response arrives, right before the callback is invoked. Responses
+// without headers, as delivered by hosts on timeouts and connection failures, and
+// 5xx responses mark the span as failed.
+func (t *Tracer) DispatchHttpCall(parent SpanContext, cluster string, headers [][2]string, body []byte,
+	trailers [][2]string, timeoutMillisecond uint32, callback func(numHeaders, bodySize, numTrailers int),
+) (calloutID uint32, span *Span, err error) {
+	span = t.StartSpan("HTTP "+headerValue(headers, ":method"), SpanKindClient, parent)
+	span.SetAttribute("upstream_cluster", cluster)
+	if path := headerValue(headers, ":path"); path != "" {
+		span.SetAttribute("http.url", path)
+	}
+
+	injected := Inject(span.Context, t.propagation()...)
+	out := make([][2]string, 0, len(headers)+len(injected))
+	for _, h := range headers {
+		if headerValue(injected, h[0]) == "" {
+			out = append(out, h)
+		}
+	}
+	out = append(out, injected...)
+
+	calloutID, err = proxywasm.DispatchHttpCall(cluster, out, body, trailers, timeoutMillisecond,
+		func(numHeaders, bodySize, numTrailers int) {
+			if numHeaders == 0 {
+				span.Error = "no response"
+			} else if hs, err := proxywasm.GetHttpCallResponseHeaders(); err == nil {
+				status := headerValue(hs, ":status")
+				span.SetAttribute("http.status_code", status)
+				if code, err := strconv.Atoi(status); err == nil && code >= 500 {
+					span.Error = "HTTP " + status
+				}
+			}
+			span.Finish()
+			if callback != nil {
+				callback(numHeaders, bodySize, numTrailers)
+			}
+		})
+	if err != nil {
+		span.Error = err.Error()
+		span.Finish()
+		return 0, span, err
+	}
+	return calloutID, span, nil
+}
+
+// InjectIntoRequest sets the propagation headers of the span on the current HTTP
+// request in the formats of Tracer.Propagation.
+// Only available during types.HttpContext.OnHttpRequestHeaders.
+func (t *Tracer) InjectIntoRequest(sc SpanContext) error {
+	return InjectIntoRequest(sc, t.propagation()...)
+}
+
+// OnTick exports the buffered spans. It must be called from types.PluginContext.OnTick.
+func (t *Tracer) OnTick() {
+	if t.Exporter == nil {
+		t.queue.LogDropped("tracing", "spans")
+		return
+	}
+	t.queue.Flush("tracing", "spans", t.Exporter.maxBatchSize(), func(spans []*Span) (*export.Request, error) {
+		return t.Exporter.request(t.ServiceName, spans)
+	})
+}
+
+func (t *Tracer) propagation() []Format {
+	if len(t.Propagation) == 0 {
+		return []Format{FormatW3C}
+	}
+	return t.Propagation
+}
+
+func (t *Tracer) now() time.Time {
+	if t.Now != nil {
+		return t.Now()
+	}
+	return time.Now()
+}
+
+func (t *Tracer) maxQueueSize() int {
+	if t.MaxQueueSize == 0 {
+		return defaultMaxQueueSize
+	}
+	return t.MaxQueueSize
+}
@@ -0,0 +1,161 @@
+package tracing
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm"
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/proxytest"
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/types"
+	"github.com/stretchr/testify/require"
+)
+
+type testVMContext struct {
+	types.DefaultVMContext
+	tracer *Tracer
+}
+
+func (v *testVMContext) NewPluginContext(uint32) types.PluginContext {
+	return &testPluginContext{tracer: v.tracer}
+}
+
+type testPluginContext struct {
+	types.DefaultPluginContext
+	tracer *Tracer
+}
+
+func (p *testPluginContext) OnTick() { p.tracer.OnTick() }
+
+func (p *testPluginContext) NewHttpContext(uint32) types.HttpContext {
+	return &testHttpContext{tracer: p.tracer}
+}
+
+// testHttpContext checks requests with a callout, within a span child of the request span.
+type testHttpContext struct {
+	types.DefaultHttpContext
+	tracer *Tracer
+}
+
+func (h *testHttpContext) OnHttpRequestHeaders(int, bool) types.Action {
+	parent, _ := ExtractFromRequest()
+	span := h.tracer.StartSpan("check", SpanKindInternal, parent)
+	_, _, err := h.tracer.DispatchHttpCall(span.Context, "checker", [][2]string{
+		{":method", "GET"}, {":path", "/check"}, {":authority", "checker"}, {"traceparent", "stale"},
+	}, nil, nil, 1000, func(numHeaders, bodySize, numTrailers int) {
+		span.Finish()
+		if err := h.tracer.InjectIntoRequest(span.Context); err != nil {
+			proxywasm.LogErrorf("failed to inject: %v", err)
+		}
+		_ = proxywasm.ResumeHttpRequest()
+	})
+	if err != nil {
+		return types.ActionContinue
+	}
+	return types.ActionPause
+}
+
+func TestTracer(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tracer := &Tracer{
+		ServiceName: "my-plugin",
+		Propagation: []Format{FormatW3C, FormatB3Multi},
+		Exporter:    &Exporter{Cluster: "collector", Authority: "otel", MaxBatchSize: 1},
+		Now:         func() time.Time { return now },
+	}
+	opt := proxytest.NewEmulatorOption().WithVMContext(&testVMContext{tracer: tracer})
+	host, reset := proxytest.NewHostEmulator(opt)
+	defer reset()
+
+	id := host.InitializeHttpContext()
+	action := host.CallOnRequestHeaders(id, [][2]string{
+		{":method", "GET"},
+		{":path", "/"},
+		{"traceparent", "00-" + testTraceID + "-" + testSpanID + "-01"},
+		{"x-b3-parentspanid", "1111111111111111"},
+	}, true)
+	require.Equal(t, types.ActionPause, action)
+
+	callouts := host.GetCalloutAttributesFromContext(id)
+	require.Len(t, callouts, 1)
+	calloutContext, ok := Extract(callouts[0].Headers)
+	require.True(t, ok)
+	require.Equal(t, testTraceID, calloutContext.TraceID.String())
+	require.NotContains(t, callouts[0].Headers, [2]string{"traceparent", "stale"})
+	require.Contains(t, callouts[0].Headers, [2]string{"x-b3-traceid", testTraceID})
+
+	now = now.Add(20 * time.Millisecond)
+	host.CallOnHttpCallResponse(callouts[0].CalloutID, [][2]string{{":status", "503"}}, nil, nil)
+	require.Equal(t, types.ActionContinue, host.GetCurrentHttpStreamAction(id))
+
+	// The upstream request now carries the plugin span as its parent.
+	headers := host.GetCurrentRequestHeaders(id)
+	requestContext, ok := Extract(headers)
+	require.True(t, ok)
+	require.Equal(t, testTraceID, requestContext.TraceID.String())
+	require.NotEqual(t, testSpanID, requestContext.SpanID.String())
+	require.NotContains(t, headers, [2]string{"x-b3-parentspanid", "1111111111111111"})
+
+	// Two spans are exported, one per tick since the batch size is one.
+	var exported []otlpSpan
+	for i := 1; i <= 2; i++ {
+		host.Tick()
+		callouts := host.GetCalloutAttributesFromContext(proxytest.PluginContextID)
+		require.Len(t, callouts, i)
+		require.Equal(t, "collector", callouts[i-1].Upstream)
+		require.Contains(t, callouts[i-1].Headers, [2]string{":path", "/v1/traces"})
+
+		// A single export is in flight at a time.
+		host.Tick()
+		require.Len(t, host.GetCalloutAttributesFromContext(proxytest.PluginContextID), i)
+
+		var body struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []otlpSpan `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		require.NoError(t, json.Unmarshal(callouts[i-1].Body, &body))
+		exported = append(exported, body.ResourceSpans[0].ScopeSpans[0].Spans...)
+		host.CallOnHttpCallResponse(callouts[i-1].CalloutID, [][2]string{{":status", "200"}}, nil, nil)
+	}
+
+	require.Len(t, exported, 2)
+	client, check := exported[0], exported[1]
+	require.Equal(t, "HTTP GET", client.Name)
+	require.Equal(t, otlpSpanKindClient, client.Kind)
+	require.Equal(t, calloutContext.SpanID.String(), client.SpanID)
+	require.Equal(t, check.SpanID, client.ParentSpanID)
+	require.Equal(t, otlpStatusCodeError, client.Status.Code)
+	require.Equal(t, "1700000000000000000", client.StartTimeUnixNano)
+	require.Equal(t, "1700000000020000000", client.EndTimeUnixNano)
+
+	require.Equal(t, "check", check.Name)
+	require.Equal(t, testSpanID, check.ParentSpanID)
+	require.Equal(t, requestContext.SpanID.String(), check.SpanID)
+	require.Equal(t, 0, check.Status.Code)
+}
+
+func TestTracer_queue(t *testing.T) {
+	tracer := &Tracer{MaxQueueSize: 2}
+	_, reset := proxytest.NewHostEmulator(proxytest.NewEmulatorOption().WithVMContext(&testVMContext{tracer: tracer}))
+	defer reset()
+
+	root := tracer.StartSpan("root", SpanKindServer, SpanContext{})
+	require.True(t, root.Context.IsValid())
+	require.True(t, root.Context.Sampled)
+	require.False(t, root.ParentSpanID.IsValid())
+
+	unsampled := tracer.StartSpan("unsampled", SpanKindInternal, SpanContext{TraceID: newTraceID(), SpanID: newSpanID()})
+	unsampled.Finish()
+	require.Zero(t, tracer.queue.Len())
+
+	for i := 0; i < 3; i++ {
+		tracer.StartSpan("child", SpanKindInternal, root.Context).Finish()
+	}
+	root.Finish()
+	root.Finish()
+	require.Equal(t, 2, tracer.queue.Len())
+	require.Equal(t, uint64(2), tracer.queue.Dropped())
+}